@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rbacctl 通过管理后台的接口声明式管理业务的权限模型，声明式文件放在 Git 仓库里，变更走代码评审
+//
+//	rbacctl export -biz 1 -file model.yaml   导出业务当前的权限模型，作为声明式文件的初始版本
+//	rbacctl plan -biz 1 -file model.yaml     计算让业务和文件一致需要的变更
+//	rbacctl apply -biz 1 -file model.yaml    执行变更，默认先 plan 并且要求确认，执行的就是展示出来的计划
+//
+// 在 CI 中执行时用 -yes 跳过确认，或者用 -fingerprint 指定评审过的计划摘要
+//
+//	rbacctl backfill [-biz 1]                 为已经接入的业务补齐后来新增的系统表，不指定业务时处理全部业务，需要系统管理员权限
+//
+// 服务地址和登录 token 也可以通过 RBACCTL_SERVER 和 RBACCTL_TOKEN 环境变量指定
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd := os.Args[1]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	server := fs.String("server", envOr("RBACCTL_SERVER", "http://localhost:8080"), "管理后台地址")
+	token := fs.String("token", os.Getenv("RBACCTL_TOKEN"), "登录 token")
+	bizID := fs.Int64("biz", 0, "业务ID")
+	file := fs.String("file", "", "声明式文件，YAML 或者 JSON 格式")
+	fingerprint := fs.String("fingerprint", "", "apply 时指定评审过的计划摘要，不指定时先 plan 再 apply")
+	yes := fs.Bool("yes", false, "apply 时不询问确认，直接执行 plan 展示的计划")
+	_ = fs.Parse(os.Args[2:])
+	if cmd != "backfill" && (*bizID <= 0 || *file == "") {
+		usage()
+		os.Exit(2)
+	}
+	c := &client{server: *server, token: *token, http: &http.Client{Timeout: 5 * time.Minute}}
+
+	var err error
+	switch cmd {
+	case "export":
+		err = c.export(*bizID, *file)
+	case "plan":
+		_, err = c.plan(*bizID, *file)
+	case "apply":
+		err = c.apply(*bizID, *file, *fingerprint, *yes)
+	case "backfill":
+		err = c.backfill(*bizID)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: rbacctl export|plan|apply -biz <业务ID> -file <文件> [-server 地址] [-token token] [-fingerprint 摘要] [-yes]")
+	fmt.Fprintln(os.Stderr, "      rbacctl backfill [-biz <业务ID>] [-server 地址] [-token token]")
+}
+
+func envOr(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+// plan 是 BizModelPlan 的响应格式
+type plan struct {
+	Problems    []string `json:"problems"`
+	Warnings    []string `json:"warnings"`
+	Fingerprint string   `json:"fingerprint"`
+	Changes     []struct {
+		Table string `json:"table"`
+		Op    string `json:"op"`
+		Ref   string `json:"ref"`
+	} `json:"changes"`
+	Counts  map[string]int `json:"counts"`
+	Applied int            `json:"applied"`
+}
+
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func (c *client) export(bizID int64, file string) error {
+	query := url.Values{"bizId": {strconv.FormatInt(bizID, 10)}, "format": {"yaml"}, "declarative": {"true"}}
+	req, err := http.NewRequest(http.MethodGet, c.server+"/biz/model/export?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("导出失败: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
+
+func (c *client) plan(bizID int64, file string) (plan, error) {
+	p, err := c.post("/biz/model/plan", bizID, file, "")
+	if err != nil {
+		return p, err
+	}
+	printPlan(p)
+	return p, nil
+}
+
+func (c *client) apply(bizID int64, file, fingerprint string, yes bool) error {
+	if fingerprint == "" {
+		p, err := c.plan(bizID, file)
+		if err != nil {
+			return err
+		}
+		if len(p.Changes) == 0 {
+			return nil
+		}
+		if !yes && !confirm("确认执行以上变更？输入 yes 继续: ") {
+			return errors.New("已取消，没有执行任何变更")
+		}
+		fingerprint = p.Fingerprint
+	}
+	p, err := c.post("/biz/model/apply", bizID, file, fingerprint)
+	fmt.Printf("已执行 %d 个变更\n", p.Applied)
+	return err
+}
+
+// confirm 从标准输入读取确认，只有输入 yes 才继续
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+func (c *client) post(path string, bizID int64, file, fingerprint string) (plan, error) {
+	doc, err := os.ReadFile(file)
+	if err != nil {
+		return plan{}, err
+	}
+	body, err := json.Marshal(map[string]any{
+		"bizId":       bizID,
+		"document":    string(doc),
+		"fingerprint": fingerprint,
+	})
+	if err != nil {
+		return plan{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return plan{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return plan{}, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Msg  string `json:"msg"`
+		Data plan   `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return plan{}, fmt.Errorf("%s: 解析响应失败: %w", resp.Status, err)
+	}
+	for _, problem := range res.Data.Problems {
+		fmt.Fprintln(os.Stderr, "  !", problem)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if res.Msg == "" {
+			res.Msg = resp.Status
+		}
+		return res.Data, errors.New(res.Msg)
+	}
+	return res.Data, nil
+}
+
+// backfill 补齐系统表，bizID 为 0 时处理全部业务
+func (c *client) backfill(bizID int64) error {
+	body, err := json.Marshal(map[string]any{"bizId": bizID})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.server+"/admin/biz/backfill", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Msg  string `json:"msg"`
+		Data struct {
+			Rows []struct {
+				BizID           int64    `json:"bizId"`
+				Resources       []string `json:"resources"`
+				Permissions     []string `json:"permissions"`
+				RolePermissions []string `json:"rolePermissions"`
+			} `json:"rows"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("%s: 解析响应失败: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if res.Msg == "" {
+			res.Msg = resp.Status
+		}
+		return errors.New(res.Msg)
+	}
+	for _, row := range res.Data.Rows {
+		fmt.Printf("业务 %d: 新建资源 %d，新建权限 %d，授予业务管理员 %d\n",
+			row.BizID, len(row.Resources), len(row.Permissions), len(row.RolePermissions))
+	}
+	return nil
+}
+
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+var opSymbols = map[string]string{"create": "+", "update": "~", "delete": "-", "skip": "="}
+
+func printPlan(p plan) {
+	if len(p.Changes) == 0 {
+		fmt.Println("没有变更，业务当前状态和文件一致")
+		return
+	}
+	for _, change := range p.Changes {
+		fmt.Printf("  %s %-16s %s\n", opSymbols[change.Op], change.Table, change.Ref)
+	}
+	for _, warning := range p.Warnings {
+		fmt.Println("  警告:", warning)
+	}
+	fmt.Printf("新建 %d，更新 %d，删除 %d\n", p.Counts["create"], p.Counts["update"], p.Counts["delete"])
+	fmt.Println("计划摘要:", p.Fingerprint)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "errors"
+
+// AccessPolicy 允许用户自助申请的角色
+// 没有配置 AccessPolicy 的角色只能由管理员直接授予
+type AccessPolicy struct {
+	BizID  int64
+	RoleID int64
+	// Owners 角色负责人，负责审批自助申请
+	Owners []int64
+	// MaxDuration 单次申请的最长时长，毫秒
+	MaxDuration int64
+	Ctime       int64
+	Utime       int64
+}
+
+func (p AccessPolicy) Validate() error {
+	if p.RoleID <= 0 {
+		return errors.New("角色ID不能为空")
+	}
+	if len(p.Owners) == 0 {
+		return errors.New("至少需要一个角色负责人")
+	}
+	if p.MaxDuration <= 0 {
+		return errors.New("最长申请时长必须大于0")
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrChangeRequestNotPending = errors.New("变更请求不是待审批状态")
+	ErrNotApprover             = errors.New("不是该变更请求的审批人")
+	ErrSelfApproval            = errors.New("不能审批自己提交的变更请求")
+	ErrDuplicateApproval       = errors.New("已经审批过该变更请求")
+	ErrNotRequester            = errors.New("只有提交人可以取消变更请求")
+)
+
+// ApprovalPolicy 审批策略，授予策略覆盖的角色或者权限时需要 M-of-N 审批
+type ApprovalPolicy struct {
+	ID            int64
+	BizID         int64
+	Name          string
+	RoleIDs       []int64
+	PermissionIDs []int64
+	// Approvers 审批人，即 N
+	Approvers []int64
+	// RequiredApprovals 需要的同意人数，即 M
+	RequiredApprovals int
+	// TTL 变更请求的有效期，毫秒，为0时使用默认值
+	TTL   int64
+	Ctime int64
+	Utime int64
+}
+
+func (p ApprovalPolicy) Validate() error {
+	if len(p.RoleIDs) == 0 && len(p.PermissionIDs) == 0 {
+		return errors.New("审批策略至少需要覆盖一个角色或者权限")
+	}
+	if p.RequiredApprovals <= 0 {
+		return errors.New("审批人数必须大于0")
+	}
+	if p.RequiredApprovals > len(p.Approvers) {
+		return errors.New("审批人数不能超过审批人总数")
+	}
+	return nil
+}
+
+// Covers 判断策略是否覆盖变更请求的授权对象
+func (p ApprovalPolicy) Covers(kind ChangeRequestKind, targetID int64) bool {
+	switch kind {
+	case ChangeRequestGrantUserRole, ChangeRequestGrantAccountRole, ChangeRequestAccessRole:
+		return slices.Contains(p.RoleIDs, targetID)
+	case ChangeRequestGrantUserPermission:
+		return slices.Contains(p.PermissionIDs, targetID)
+	default:
+		return false
+	}
+}
+
+type ChangeRequestKind string
+
+const (
+	ChangeRequestGrantUserRole       ChangeRequestKind = "grant_user_role"
+	ChangeRequestGrantUserPermission ChangeRequestKind = "grant_user_permission"
+	// ChangeRequestGrantAccountRole 授予管理后台账号角色，例如业务管理员
+	ChangeRequestGrantAccountRole ChangeRequestKind = "grant_account_role"
+	// ChangeRequestAccessRole 用户自助申请的限时角色
+	ChangeRequestAccessRole ChangeRequestKind = "access_role"
+)
+
+func (k ChangeRequestKind) String() string {
+	return string(k)
+}
+
+type ChangeRequestStatus string
+
+const (
+	ChangeRequestPending   ChangeRequestStatus = "pending"
+	ChangeRequestApproved  ChangeRequestStatus = "approved"
+	ChangeRequestExecuted  ChangeRequestStatus = "executed"
+	ChangeRequestFailed    ChangeRequestStatus = "failed"
+	ChangeRequestRejected  ChangeRequestStatus = "rejected"
+	ChangeRequestCancelled ChangeRequestStatus = "cancelled"
+	ChangeRequestExpired   ChangeRequestStatus = "expired"
+)
+
+func (s ChangeRequestStatus) String() string {
+	return string(s)
+}
+
+// ApprovalDecision 审批人的一次审批
+type ApprovalDecision struct {
+	UID     int64
+	Approve bool
+	Comment string
+	Ctime   int64
+}
+
+// ChangeRequest 等待审批的授权变更，审批通过后才会真正调用权限平台
+type ChangeRequest struct {
+	ID       int64
+	BizID    int64
+	Kind     ChangeRequestKind
+	PolicyID int64
+	// TargetID 被授予的角色或者权限ID
+	TargetID     int64
+	TargetUserID int64
+	// Payload 原始授权请求，审批通过后按照它执行授权
+	Payload      string
+	RequesterUID int64
+	Reason       string
+	// Approvers 和 RequiredApprovals 是提交时策略的快照，策略后续修改不影响已经提交的请求
+	Approvers         []int64
+	RequiredApprovals int
+	Decisions         []ApprovalDecision
+	// Duration 限时授权的时长，毫秒，为0表示授权不限时
+	Duration int64
+	// GrantStartTime 和 GrantEndTime 是审批通过时按照 Duration 计算出来的授权生效时间段
+	GrantStartTime int64
+	GrantEndTime   int64
+	Status         ChangeRequestStatus
+	// Result 执行授权的结果或者错误信息
+	Result   string
+	ExpireAt int64
+	Ctime    int64
+	Utime    int64
+	// Version 乐观锁版本号
+	Version int64
+}
+
+func (r ChangeRequest) Approvals() int {
+	cnt := 0
+	for i := range r.Decisions {
+		if r.Decisions[i].Approve {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (r ChangeRequest) IsApprover(uid int64) bool {
+	return slices.Contains(r.Approvers, uid)
+}
+
+// Decide 记录审批人的决定
+// 任何一个审批人拒绝，请求就被拒绝；同意人数达到要求后进入 approved 状态，等待执行
+func (r *ChangeRequest) Decide(decision ApprovalDecision) error {
+	if r.Status != ChangeRequestPending {
+		return ErrChangeRequestNotPending
+	}
+	if decision.UID == r.RequesterUID {
+		return ErrSelfApproval
+	}
+	if !r.IsApprover(decision.UID) {
+		return ErrNotApprover
+	}
+	if slices.ContainsFunc(r.Decisions, func(d ApprovalDecision) bool { return d.UID == decision.UID }) {
+		return ErrDuplicateApproval
+	}
+	r.Decisions = append(r.Decisions, decision)
+	switch {
+	case !decision.Approve:
+		r.Status = ChangeRequestRejected
+	case r.Approvals() >= r.RequiredApprovals:
+		r.Status = ChangeRequestApproved
+		// 限时授权从审批通过开始计时，而不是从提交开始
+		if r.Duration > 0 {
+			r.GrantStartTime = decision.Ctime
+			r.GrantEndTime = decision.Ctime + r.Duration
+		}
+	}
+	r.Utime = decision.Ctime
+	return nil
+}
+
+func (r *ChangeRequest) Cancel(uid, now int64) error {
+	if r.Status != ChangeRequestPending {
+		return ErrChangeRequestNotPending
+	}
+	if uid != r.RequesterUID {
+		return ErrNotRequester
+	}
+	r.Status = ChangeRequestCancelled
+	r.Utime = now
+	return nil
+}
+
+func (r ChangeRequest) Expired(now int64) bool {
+	return r.Status == ChangeRequestPending && r.ExpireAt > 0 && r.ExpireAt <= now
+}
+
+// Active 授权已经执行并且仍然在有效期内
+func (r ChangeRequest) Active(now int64) bool {
+	return r.Status == ChangeRequestExecuted && (r.GrantEndTime == 0 || r.GrantEndTime > now)
+}
+
+// ChangeRequestQuery 变更请求查询条件，零值表示不过滤
+type ChangeRequestQuery struct {
+	BizID  int64
+	Status ChangeRequestStatus
+	// UID 只返回该用户提交的或者需要该用户审批的请求
+	UID int64
+	// RequesterUID 只返回该用户提交的请求
+	RequesterUID int64
+	Kind         ChangeRequestKind
+	// ActiveAt 大于0时只返回在该时间仍然生效的限时授权
+	ActiveAt int64
+	Offset   int
+	Limit    int
+}
+
+func (q ChangeRequestQuery) Match(r ChangeRequest) bool {
+	if q.Status != "" && r.Status != q.Status {
+		return false
+	}
+	if q.UID != 0 && r.RequesterUID != q.UID && !r.IsApprover(q.UID) {
+		return false
+	}
+	if q.RequesterUID != 0 && r.RequesterUID != q.RequesterUID {
+		return false
+	}
+	if q.Kind != "" && r.Kind != q.Kind {
+		return false
+	}
+	if q.ActiveAt > 0 && !r.Active(q.ActiveAt) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+)
+
+func (o AuditOutcome) String() string {
+	return string(o)
+}
+
+// AuditRecord 一次管理后台写操作的审计记录
+type AuditRecord struct {
+	// ID 审计记录ID，同一个业务内按照时间递增
+	ID       string
+	BizID    int64
+	ActorUID int64 // 操作人
+	Route    string
+	// Table 被操作的实体所在的表，即 SystemTableResource
+	Table        string
+	TargetID     int64 // 被操作的实体ID，创建时为0
+	TargetUserID int64 // 被授权或撤销授权的用户，和用户无关的操作为0
+	Payload      string
+	Result       string
+	Error        string
+	Outcome      AuditOutcome
+	ClientIP     string
+	Ctime        int64 // 毫秒
+	// Seq 在业务审计哈希链中的序号，从1开始
+	Seq int64
+	// PrevHash 同一个业务内上一条审计记录的哈希，第一条记录为空
+	PrevHash string
+	Hash     string
+}
+
+// ChainHash 计算审计记录在哈希链中的哈希，覆盖除 ID 和 Hash 以外的全部字段
+// Seq 也在哈希里，修改序号掩盖被删除的记录时哈希会不一致
+// 使用 JSON 数组序列化字段，避免字段内容中包含分隔符造成歧义
+func (r AuditRecord) ChainHash() string {
+	data, _ := json.Marshal([]any{
+		r.PrevHash, r.Seq, r.BizID, r.ActorUID, r.Route, r.Table, r.TargetID, r.TargetUserID,
+		r.Payload, r.Result, r.Error, r.Outcome, r.ClientIP, r.Ctime,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditCheckpoint 审计哈希链的签名检查点
+// 篡改者即使重新计算了后续全部记录的哈希，没有签名密钥也无法伪造检查点
+type AuditCheckpoint struct {
+	BizID     int64
+	RecordID  string
+	Seq       int64
+	Hash      string
+	Ctime     int64
+	Signature string
+}
+
+func (c AuditCheckpoint) Sign(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%d|%s|%d|%s|%d", c.BizID, c.RecordID, c.Seq, c.Hash, c.Ctime)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c AuditCheckpoint) Verify(key []byte) bool {
+	return hmac.Equal([]byte(c.Sign(key)), []byte(c.Signature))
+}
+
+// AuditVerification 审计哈希链的校验结果
+type AuditVerification struct {
+	BizID int64
+	// Checked 校验过的记录数
+	Checked int
+	// Checkpoints 校验过的检查点数
+	Checkpoints int
+	Valid       bool
+	// BrokenID 第一个校验失败的记录或者检查点对应的记录
+	BrokenID     string
+	BrokenReason string
+}
+
+// AuditQuery 审计记录查询条件，零值表示不过滤
+type AuditQuery struct {
+	BizID        int64
+	ActorUID     int64
+	TargetUserID int64
+	Table        string
+	StartTime    int64
+	EndTime      int64
+	Outcome      AuditOutcome
+	// Cursor 上一页最后一条记录的ID，结果按照时间倒序
+	Cursor string
+	Limit  int
+}
+
+func (q AuditQuery) Match(r AuditRecord) bool {
+	return (q.ActorUID == 0 || q.ActorUID == r.ActorUID) &&
+		(q.TargetUserID == 0 || q.TargetUserID == r.TargetUserID) &&
+		(q.Table == "" || q.Table == r.Table) &&
+		(q.Outcome == "" || q.Outcome == r.Outcome) &&
+		(q.StartTime == 0 || r.Ctime >= q.StartTime) &&
+		(q.EndTime == 0 || r.Ctime <= q.EndTime)
+}
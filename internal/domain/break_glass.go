@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+)
+
+var ErrBreakGlassActive = errors.New("已经有生效中的紧急授权，不能延长或者重复申请")
+
+// BreakGlassPolicy 业务的紧急授权配置
+type BreakGlassPolicy struct {
+	BizID int64
+	// RoleID 预先配置好的紧急角色
+	RoleID int64
+	// Responders 可以使用紧急授权的值班人员
+	Responders []int64
+	// Watchers 紧急授权生效和结束时额外通知的人
+	Watchers []int64
+	// Window 紧急授权的时长，毫秒，不能延长
+	Window int64
+	Ctime  int64
+	Utime  int64
+}
+
+func (p BreakGlassPolicy) Validate(maxWindow int64) error {
+	if p.RoleID <= 0 {
+		return errors.New("紧急角色不能为空")
+	}
+	if len(p.Responders) == 0 {
+		return errors.New("至少需要一个值班人员")
+	}
+	if p.Window <= 0 || p.Window > maxWindow {
+		return errors.New("紧急授权时长超出允许范围")
+	}
+	return nil
+}
+
+func (p BreakGlassPolicy) IsResponder(uid int64) bool {
+	return slices.Contains(p.Responders, uid)
+}
+
+type BreakGlassStatus string
+
+const (
+	BreakGlassActive BreakGlassStatus = "active"
+	// BreakGlassEnded 持有人提前结束
+	BreakGlassEnded BreakGlassStatus = "ended"
+	// BreakGlassExpired 到期后被自动回收
+	BreakGlassExpired BreakGlassStatus = "expired"
+	// BreakGlassFailed 授予紧急角色失败
+	BreakGlassFailed BreakGlassStatus = "failed"
+)
+
+func (s BreakGlassStatus) String() string {
+	return string(s)
+}
+
+// BreakGlassSession 一次紧急授权
+type BreakGlassSession struct {
+	ID       int64
+	BizID    int64
+	UID      int64
+	RoleID   int64
+	RoleName string
+	// UserRoleID 授予紧急角色生成的用户角色，回收时使用
+	UserRoleID int64
+	Reason     string
+	StartTime  int64
+	EndTime    int64
+	RevokedAt  int64
+	Status     BreakGlassStatus
+	Error      string
+}
@@ -0,0 +1,65 @@
+package domain
+
+import "errors"
+
+// BulkGrantStatus 批量授权中每一行的处理结果
+type BulkGrantStatus string
+
+const (
+	// BulkGrantInvalid 校验没有通过，整个文件都不会执行
+	BulkGrantInvalid BulkGrantStatus = "invalid"
+	// BulkGrantPending 校验通过，只校验不执行时的结果
+	BulkGrantPending BulkGrantStatus = "pending"
+	// BulkGrantExists 用户已经拥有这个角色，重复上传同一个文件时跳过
+	BulkGrantExists  BulkGrantStatus = "exists"
+	BulkGrantGranted BulkGrantStatus = "granted"
+	BulkGrantFailed  BulkGrantStatus = "failed"
+)
+
+func (s BulkGrantStatus) String() string {
+	return string(s)
+}
+
+// BulkUserRoleRow 批量授予用户角色文件中的一行
+type BulkUserRoleRow struct {
+	// Line 在文件中的行号，从 1 开始，包括表头
+	Line   int
+	UserID int64
+	// RoleID 和 RoleName 至少填写一个，都填写时必须是同一个角色
+	RoleID    int64
+	RoleName  string
+	StartTime int64
+	EndTime   int64
+	Status    BulkGrantStatus
+	Error     string
+}
+
+// Key 同一个用户和同一个角色只能出现一次
+func (r BulkUserRoleRow) Key() [2]int64 {
+	return [2]int64{r.UserID, r.RoleID}
+}
+
+func (r BulkUserRoleRow) Validate(now int64) error {
+	if r.UserID <= 0 {
+		return errors.New("用户ID不合法")
+	}
+	if r.RoleID <= 0 && r.RoleName == "" {
+		return errors.New("角色ID和角色名至少填写一个")
+	}
+	if r.EndTime > 0 && r.EndTime <= r.StartTime {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	if r.EndTime > 0 && r.EndTime <= now {
+		return errors.New("结束时间已经过去")
+	}
+	return nil
+}
+
+// Invalidate 标记校验失败，一行只保留第一个问题
+func (r *BulkUserRoleRow) Invalidate(err error) {
+	if r.Status == BulkGrantInvalid {
+		return
+	}
+	r.Status = BulkGrantInvalid
+	r.Error = err.Error()
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+)
+
+// PermissionComparison 两边实际允许的权限的差异，deny 的权限不算拥有
+type PermissionComparison struct {
+	OnlyLeft  []EffectivePermission
+	OnlyRight []EffectivePermission
+	// Both 两边都拥有的权限，来源取左边的
+	Both []EffectivePermission
+}
+
+// ComparePermissions 对比两组按照引用标识排序的有效权限
+func ComparePermissions(left, right []EffectivePermission) PermissionComparison {
+	allowed := func(perms []EffectivePermission) map[string]bool {
+		res := make(map[string]bool, len(perms))
+		for _, p := range perms {
+			if p.Effect.IsAllow() {
+				res[p.Ref()] = true
+			}
+		}
+		return res
+	}
+	leftAllowed, rightAllowed := allowed(left), allowed(right)
+	var res PermissionComparison
+	for _, p := range left {
+		switch {
+		case !p.Effect.IsAllow():
+		case rightAllowed[p.Ref()]:
+			res.Both = append(res.Both, p)
+		default:
+			res.OnlyLeft = append(res.OnlyLeft, p)
+		}
+	}
+	for _, p := range right {
+		if p.Effect.IsAllow() && !leftAllowed[p.Ref()] {
+			res.OnlyRight = append(res.OnlyRight, p)
+		}
+	}
+	return res
+}
+
+// RoleEffectivePermissions 角色自己和通过包含关系拥有的权限，按照引用标识排序
+func (m RBACModel) RoleEffectivePermissions(role string) []EffectivePermission {
+	rolePermissions := make(map[string][]ModelRolePermission)
+	for _, r := range m.RolePermissions {
+		rolePermissions[r.Role] = append(rolePermissions[r.Role], r)
+	}
+	byRef := make(map[string]*EffectivePermission)
+	for _, path := range includedRolePaths(m.roleIncludes(), role) {
+		for _, rp := range rolePermissions[path[len(path)-1]] {
+			p, ok := byRef[rp.PermissionRef()]
+			if !ok {
+				p = &EffectivePermission{ResourceType: rp.ResourceType, ResourceKey: rp.ResourceKey, Action: rp.Action, Effect: EffectAllow}
+				byRef[rp.PermissionRef()] = p
+			}
+			p.Sources = append(p.Sources, PermissionSource{Roles: path, Effect: EffectAllow})
+		}
+	}
+	res := make([]EffectivePermission, 0, len(byRef))
+	for _, p := range byRef {
+		res = append(res, *p)
+	}
+	slices.SortFunc(res, func(a, b EffectivePermission) int {
+		return strings.Compare(a.Ref(), b.Ref())
+	})
+	return res
+}
+
+// MissingUserRoles 复制权限时 to 需要新增的用户角色，保留 from 的有效期
+// 只复制没有过期的用户角色，to 已经有同名的没有过期的角色时跳过，直接授予的权限不复制
+func (m RBACModel) MissingUserRoles(from, to, at int64) []ModelUserRole {
+	held := make(map[string]bool)
+	for _, ur := range m.UserRoles {
+		if ur.UserID == to && (ur.EndTime == 0 || ur.EndTime > at) {
+			held[ur.Role] = true
+		}
+	}
+	var res []ModelUserRole
+	for _, ur := range m.UserRoles {
+		if ur.UserID != from || (ur.EndTime != 0 && ur.EndTime <= at) || held[ur.Role] {
+			continue
+		}
+		held[ur.Role] = true
+		res = append(res, ModelUserRole{UserID: to, Role: ur.Role, StartTime: ur.StartTime, EndTime: ur.EndTime})
+	}
+	return res
+}
@@ -0,0 +1,240 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// PermissionSource 用户获得一个权限的途径
+type PermissionSource struct {
+	// Roles 从授予用户的角色开始，沿着角色包含关系到达持有权限的角色，直接授予用户的权限为空
+	Roles  []string
+	Effect Effect
+	// StartTime 和 EndTime 是用户角色或者用户权限的有效期
+	StartTime int64
+	EndTime   int64
+}
+
+// Path 来源的可读形式，例如 "管理员 > 编辑"，直接授予时为 "direct"
+func (s PermissionSource) Path() string {
+	if len(s.Roles) == 0 {
+		return "direct"
+	}
+	return strings.Join(s.Roles, " > ")
+}
+
+// EffectivePermission 用户实际拥有的一个权限，同一个权限的多个来源合并在一起
+type EffectivePermission struct {
+	ResourceType string
+	ResourceKey  string
+	Action       string
+	// Effect 任意一个来源是 deny 时为 deny
+	Effect  Effect
+	Sources []PermissionSource
+}
+
+func (p EffectivePermission) Ref() string {
+	return permissionRef(p.ResourceType, p.ResourceKey, p.Action)
+}
+
+// ActiveAt 授权在 at 时刻是否生效，开始时间和结束时间为 0 表示不限制
+func ActiveAt(startTime, endTime, at int64) bool {
+	return (startTime == 0 || startTime <= at) && (endTime == 0 || endTime > at)
+}
+
+// EffectivePermissions 计算用户在 at 时刻实际拥有的权限，按照引用标识排序
+// 角色通过包含关系传递获得被包含角色的权限，不在有效期内的用户角色和用户权限不计算，
+// 角色权限都是 allow，直接授予的 deny 优先于任何来源的 allow
+func (m RBACModel) EffectivePermissions(userID, at int64) []EffectivePermission {
+	byRef := make(map[string]*EffectivePermission)
+	add := func(typ, key, action string, source PermissionSource) {
+		ref := permissionRef(typ, key, action)
+		p, ok := byRef[ref]
+		if !ok {
+			p = &EffectivePermission{ResourceType: typ, ResourceKey: key, Action: action, Effect: EffectAllow}
+			byRef[ref] = p
+		}
+		p.Sources = append(p.Sources, source)
+		if source.Effect.IsDeny() {
+			p.Effect = EffectDeny
+		}
+	}
+
+	rolePermissions := make(map[string][]ModelRolePermission)
+	for _, r := range m.RolePermissions {
+		rolePermissions[r.Role] = append(rolePermissions[r.Role], r)
+	}
+	includes := m.roleIncludes()
+	for _, ur := range m.UserRoles {
+		if ur.UserID != userID || !ActiveAt(ur.StartTime, ur.EndTime, at) {
+			continue
+		}
+		for _, path := range includedRolePaths(includes, ur.Role) {
+			for _, rp := range rolePermissions[path[len(path)-1]] {
+				add(rp.ResourceType, rp.ResourceKey, rp.Action, PermissionSource{
+					Roles:     path,
+					Effect:    EffectAllow,
+					StartTime: ur.StartTime,
+					EndTime:   ur.EndTime,
+				})
+			}
+		}
+	}
+	for _, up := range m.UserPermissions {
+		if up.UserID != userID || !ActiveAt(up.StartTime, up.EndTime, at) {
+			continue
+		}
+		add(up.ResourceType, up.ResourceKey, up.Action, PermissionSource{
+			Effect:    up.Effect,
+			StartTime: up.StartTime,
+			EndTime:   up.EndTime,
+		})
+	}
+
+	res := make([]EffectivePermission, 0, len(byRef))
+	for _, p := range byRef {
+		res = append(res, *p)
+	}
+	slices.SortFunc(res, func(a, b EffectivePermission) int {
+		return strings.Compare(a.Ref(), b.Ref())
+	})
+	return res
+}
+
+// roleIncludes 每个角色直接包含的角色
+func (m RBACModel) roleIncludes() map[string][]string {
+	includes := make(map[string][]string)
+	for _, r := range m.RoleInclusions {
+		includes[r.Including] = append(includes[r.Including], r.Included)
+	}
+	return includes
+}
+
+// includedRolePaths 广度优先遍历角色包含关系，返回从 role 到它自己和每个被包含角色的最短路径
+// 包含关系有环时每个角色只访问一次
+func includedRolePaths(includes map[string][]string, role string) [][]string {
+	visited := map[string]bool{role: true}
+	paths := [][]string{{role}}
+	for i := 0; i < len(paths); i++ {
+		path := paths[i]
+		for _, next := range includes[path[len(path)-1]] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			paths = append(paths, append(slices.Clone(path), next))
+		}
+	}
+	return paths
+}
+
+// RoleHolder 持有权限的角色，Roles 是从这个角色沿着包含关系到达直接持有权限的角色的路径
+type RoleHolder struct {
+	Role       string
+	Permission string
+	Roles      []string
+}
+
+// PermissionHolder 拥有权限的一个用户
+type PermissionHolder struct {
+	UserID int64
+	EffectivePermission
+}
+
+// WhoCan 反查在 at 时刻拥有资源上某个动作的权限的角色和用户，resourceType 为空时匹配全部资源类型
+// 用户按照ID排序，被直接 deny 的用户也会返回，Effect 为 deny
+func (m RBACModel) WhoCan(resourceType, resourceKey, action string, at int64) ([]RoleHolder, []PermissionHolder) {
+	matches := func(typ, key, act string) bool {
+		return (resourceType == "" || typ == resourceType) && key == resourceKey && act == action
+	}
+	rolePermissions := make(map[string][]ModelRolePermission)
+	for _, r := range m.RolePermissions {
+		if matches(r.ResourceType, r.ResourceKey, r.Action) {
+			rolePermissions[r.Role] = append(rolePermissions[r.Role], r)
+		}
+	}
+
+	type reached struct {
+		path       []string
+		permission ModelRolePermission
+	}
+	includes := m.roleIncludes()
+	reach := make(map[string][]reached)
+	reachOf := func(role string) []reached {
+		if res, ok := reach[role]; ok {
+			return res
+		}
+		var res []reached
+		for _, path := range includedRolePaths(includes, role) {
+			for _, rp := range rolePermissions[path[len(path)-1]] {
+				res = append(res, reached{path: path, permission: rp})
+			}
+		}
+		reach[role] = res
+		return res
+	}
+
+	var roles []RoleHolder
+	for _, r := range m.Roles {
+		for _, item := range reachOf(r.Name) {
+			roles = append(roles, RoleHolder{Role: r.Name, Permission: item.permission.PermissionRef(), Roles: item.path})
+		}
+	}
+
+	type holderKey struct {
+		uid int64
+		ref string
+	}
+	byKey := make(map[holderKey]*PermissionHolder)
+	add := func(uid int64, typ, key, act string, source PermissionSource) {
+		k := holderKey{uid: uid, ref: permissionRef(typ, key, act)}
+		h, ok := byKey[k]
+		if !ok {
+			h = &PermissionHolder{UserID: uid, EffectivePermission: EffectivePermission{
+				ResourceType: typ, ResourceKey: key, Action: act, Effect: EffectAllow,
+			}}
+			byKey[k] = h
+		}
+		h.Sources = append(h.Sources, source)
+		if source.Effect.IsDeny() {
+			h.Effect = EffectDeny
+		}
+	}
+	for _, ur := range m.UserRoles {
+		if !ActiveAt(ur.StartTime, ur.EndTime, at) {
+			continue
+		}
+		for _, item := range reachOf(ur.Role) {
+			rp := item.permission
+			add(ur.UserID, rp.ResourceType, rp.ResourceKey, rp.Action, PermissionSource{
+				Roles:     item.path,
+				Effect:    EffectAllow,
+				StartTime: ur.StartTime,
+				EndTime:   ur.EndTime,
+			})
+		}
+	}
+	for _, up := range m.UserPermissions {
+		if !ActiveAt(up.StartTime, up.EndTime, at) || !matches(up.ResourceType, up.ResourceKey, up.Action) {
+			continue
+		}
+		add(up.UserID, up.ResourceType, up.ResourceKey, up.Action, PermissionSource{
+			Effect:    up.Effect,
+			StartTime: up.StartTime,
+			EndTime:   up.EndTime,
+		})
+	}
+
+	users := make([]PermissionHolder, 0, len(byKey))
+	for _, h := range byKey {
+		users = append(users, *h)
+	}
+	slices.SortFunc(users, func(a, b PermissionHolder) int {
+		if a.UserID != b.UserID {
+			return cmp.Compare(a.UserID, b.UserID)
+		}
+		return strings.Compare(a.Ref(), b.Ref())
+	})
+	return roles, users
+}
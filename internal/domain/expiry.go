@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// ExpiryAction 授权过了结束时间之后如何处理
+type ExpiryAction string
+
+const (
+	// ExpiryActionRevoke 直接回收
+	ExpiryActionRevoke ExpiryAction = "revoke"
+	// ExpiryActionArchive 先归档一份，再回收
+	ExpiryActionArchive ExpiryAction = "archive"
+)
+
+// ExpiryNotifyThreshold 返回授权命中的提醒天数，命中多个时返回最小的一个
+// 例如 days 为 [7, 1]，还剩 20 小时到期时返回 1，已经过期或者没有结束时间的授权不提醒
+func ExpiryNotifyThreshold(endTime, now int64, days []int) (int, bool) {
+	if endTime <= now {
+		return 0, false
+	}
+	left := time.Duration(endTime-now) * time.Millisecond
+	sorted := slices.Clone(days)
+	slices.Sort(sorted)
+	for _, d := range sorted {
+		if d > 0 && left <= time.Duration(d)*24*time.Hour {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// ArchivedGrant 到期后被归档的授权
+type ArchivedGrant struct {
+	BizID int64
+	// Table 授权所在的表，user_roles 或者 user_permissions
+	Table   SystemTableResource
+	GrantID int64
+	UserID  int64
+	// TargetID 角色ID或者权限ID
+	TargetID   int64
+	TargetName string
+	StartTime  int64
+	EndTime    int64
+	ArchivedAt int64
+}
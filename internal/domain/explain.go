@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+)
+
+// GrantStatus 一个匹配的授权在判定时的状态
+type GrantStatus string
+
+const (
+	GrantActive GrantStatus = "active"
+	// GrantNotStarted 和 GrantExpired 不在有效期内，判定时跳过
+	GrantNotStarted GrantStatus = "not_started"
+	GrantExpired    GrantStatus = "expired"
+	// GrantOverridden 生效的 allow 被生效的 deny 覆盖
+	GrantOverridden GrantStatus = "overridden"
+)
+
+func (s GrantStatus) String() string {
+	return string(s)
+}
+
+// GrantTrace 判定时匹配到的一个授权
+type GrantTrace struct {
+	PermissionSource
+	Status GrantStatus
+}
+
+// PermissionExplanation 用户能不能对资源执行动作，以及得出结论的全部授权
+type PermissionExplanation struct {
+	Allowed bool
+	Reason  string
+	// Grants 先是通过角色的授权，按照用户角色的顺序，然后是直接授予的授权
+	Grants []GrantTrace
+}
+
+// Explain 按照 EffectivePermissions 的规则解释用户在 at 时刻对资源执行动作的判定
+// 不在有效期内的授权也会返回，方便排查刚刚过期或者还没有生效的授权
+func (m RBACModel) Explain(userID int64, resourceType, resourceKey, action string, at int64) PermissionExplanation {
+	ref := permissionRef(resourceType, resourceKey, action)
+	status := func(startTime, endTime int64) GrantStatus {
+		switch {
+		case startTime > 0 && startTime > at:
+			return GrantNotStarted
+		case endTime > 0 && endTime <= at:
+			return GrantExpired
+		default:
+			return GrantActive
+		}
+	}
+
+	holders := make(map[string]bool)
+	for _, rp := range m.RolePermissions {
+		if rp.PermissionRef() == ref {
+			holders[rp.Role] = true
+		}
+	}
+	var res PermissionExplanation
+	includes := m.roleIncludes()
+	for _, ur := range m.UserRoles {
+		if ur.UserID != userID {
+			continue
+		}
+		for _, path := range includedRolePaths(includes, ur.Role) {
+			if holders[path[len(path)-1]] {
+				res.Grants = append(res.Grants, GrantTrace{
+					PermissionSource: PermissionSource{Roles: path, Effect: EffectAllow, StartTime: ur.StartTime, EndTime: ur.EndTime},
+					Status:           status(ur.StartTime, ur.EndTime),
+				})
+			}
+		}
+	}
+	for _, up := range m.UserPermissions {
+		if up.UserID == userID && up.PermissionRef() == ref {
+			res.Grants = append(res.Grants, GrantTrace{
+				PermissionSource: PermissionSource{Effect: up.Effect, StartTime: up.StartTime, EndTime: up.EndTime},
+				Status:           status(up.StartTime, up.EndTime),
+			})
+		}
+	}
+
+	active := func(effect func(Effect) bool) int {
+		return slices.IndexFunc(res.Grants, func(g GrantTrace) bool {
+			return g.Status == GrantActive && effect(g.Effect)
+		})
+	}
+	deny, allow := active(Effect.IsDeny), active(Effect.IsAllow)
+	switch {
+	case deny >= 0:
+		res.Reason = "直接授予的 deny 覆盖了其他授权"
+		for i := range res.Grants {
+			if res.Grants[i].Status == GrantActive && res.Grants[i].Effect.IsAllow() {
+				res.Grants[i].Status = GrantOverridden
+			}
+		}
+	case allow >= 0:
+		res.Allowed = true
+		res.Reason = fmt.Sprintf("通过 %s 允许", res.Grants[allow].Path())
+	case len(res.Grants) > 0:
+		res.Reason = "匹配的授权都不在有效期内"
+	default:
+		res.Reason = "没有匹配的角色权限或者直接授权"
+	}
+	return res
+}
@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// HygieneKind 权限模型中可以清理的问题
+type HygieneKind string
+
+const (
+	// HygieneUnusedResource 没有任何权限的资源
+	HygieneUnusedResource HygieneKind = "unused_resource"
+	// HygieneUnusedPermission 没有授予任何角色和用户的权限
+	HygieneUnusedPermission HygieneKind = "unused_permission"
+	// HygieneUnusedRole 没有用户，也不包含和被包含的角色
+	HygieneUnusedRole HygieneKind = "unused_role"
+	// HygieneRedundantRolePermission 角色直接拥有，同时又通过被包含的角色拥有的权限
+	HygieneRedundantRolePermission HygieneKind = "redundant_role_permission"
+	// HygieneRedundantUserPermission 直接授予用户的 allow 权限，用户的角色在同样的有效期内已经拥有
+	HygieneRedundantUserPermission HygieneKind = "redundant_user_permission"
+	// HygieneExpiredUserRole 和 HygieneExpiredUserPermission 已经过期的用户授权
+	HygieneExpiredUserRole       HygieneKind = "expired_user_role"
+	HygieneExpiredUserPermission HygieneKind = "expired_user_permission"
+)
+
+func (k HygieneKind) String() string {
+	return string(k)
+}
+
+// HygieneFinding 一个问题和建议的清理方式，Changes 是清理需要的删除，按照依赖顺序排列
+type HygieneFinding struct {
+	Kind       HygieneKind
+	Ref        string
+	Detail     string
+	Suggestion string
+	Changes    []ModelChange
+}
+
+// Key 在一次报告中唯一标识问题，选择要清理的问题时使用
+func (f HygieneFinding) Key() string {
+	return f.Kind.String() + "/" + f.Ref
+}
+
+// cleanupOrder 删除的顺序和依赖的方向相反，先删除授权，最后删除资源
+var cleanupOrder = []SystemTableResource{
+	UserPermissionTable, UserRoleTable, RolePermissionTable, RoleInclusionTable, RoleTable, PermissionTable, ResourceTable,
+}
+
+// Hygiene 检查模型中可以清理的对象，at 用来判断授权是否过期
+// 清理需要删除账号角色或者系统资源的问题不返回，这些对象由管理后台维护
+// 结果按照问题的种类和引用标识排序
+func (m RBACModel) Hygiene(at int64) []HygieneFinding {
+	var res []HygieneFinding
+	remove := func(table SystemTableResource, i int, ref string, id int64) ModelChange {
+		return ModelChange{Table: table, Op: ModelChangeDelete, Ref: ref, Index: i, ExistingID: id}
+	}
+
+	permissionsOfResource := make(map[string]int)
+	for _, p := range m.Permissions {
+		permissionsOfResource[p.ResourceRef()]++
+	}
+	for i, r := range m.Resources {
+		if permissionsOfResource[r.Ref()] == 0 {
+			res = append(res, HygieneFinding{
+				Kind:       HygieneUnusedResource,
+				Ref:        r.Ref(),
+				Detail:     "资源没有任何权限",
+				Suggestion: "删除资源",
+				Changes:    []ModelChange{remove(ResourceTable, i, r.Ref(), r.ID)},
+			})
+		}
+	}
+
+	granted := make(map[string]bool)
+	for _, r := range m.RolePermissions {
+		granted[r.PermissionRef()] = true
+	}
+	for _, r := range m.UserPermissions {
+		granted[r.PermissionRef()] = true
+	}
+	for i, p := range m.Permissions {
+		if !granted[p.Ref()] {
+			res = append(res, HygieneFinding{
+				Kind:       HygieneUnusedPermission,
+				Ref:        p.Ref(),
+				Detail:     "权限没有授予任何角色和用户",
+				Suggestion: "删除权限",
+				Changes:    []ModelChange{remove(PermissionTable, i, p.Ref(), p.ID)},
+			})
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, r := range m.UserRoles {
+		used[r.Role] = true
+	}
+	for _, r := range m.RoleInclusions {
+		used[r.Including], used[r.Included] = true, true
+	}
+	for i, r := range m.Roles {
+		if used[r.Name] {
+			continue
+		}
+		// 角色权限引用角色，删除角色之前先回收
+		var changes []ModelChange
+		for j, rp := range m.RolePermissions {
+			if rp.Role == r.Name {
+				changes = append(changes, remove(RolePermissionTable, j, rp.Ref(), rp.ID))
+			}
+		}
+		res = append(res, HygieneFinding{
+			Kind:       HygieneUnusedRole,
+			Ref:        r.Ref(),
+			Detail:     fmt.Sprintf("角色没有用户，也没有包含关系，有 %d 个权限", len(changes)),
+			Suggestion: "回收角色的权限并且删除角色",
+			Changes:    append(changes, remove(RoleTable, i, r.Ref(), r.ID)),
+		})
+	}
+
+	includes := m.roleIncludes()
+	rolePermissions := make(map[string]map[string]bool)
+	for _, r := range m.RolePermissions {
+		if rolePermissions[r.Role] == nil {
+			rolePermissions[r.Role] = make(map[string]bool)
+		}
+		rolePermissions[r.Role][r.PermissionRef()] = true
+	}
+	for i, r := range m.RolePermissions {
+		for _, path := range includedRolePaths(includes, r.Role)[1:] {
+			if rolePermissions[path[len(path)-1]][r.PermissionRef()] {
+				res = append(res, HygieneFinding{
+					Kind:       HygieneRedundantRolePermission,
+					Ref:        r.Ref(),
+					Detail:     fmt.Sprintf("通过 %s 已经拥有", PermissionSource{Roles: path}.Path()),
+					Suggestion: "回收角色直接拥有的权限",
+					Changes:    []ModelChange{remove(RolePermissionTable, i, r.Ref(), r.ID)},
+				})
+				break
+			}
+		}
+	}
+
+	expired := func(endTime int64) bool {
+		return endTime > 0 && endTime <= at
+	}
+	// covers 角色授权的有效期完全覆盖直接授权的有效期
+	covers := func(ur ModelUserRole, up ModelUserPermission) bool {
+		return (ur.StartTime == 0 || (up.StartTime != 0 && ur.StartTime <= up.StartTime)) &&
+			(ur.EndTime == 0 || (up.EndTime != 0 && ur.EndTime >= up.EndTime))
+	}
+	for i, up := range m.UserPermissions {
+		if expired(up.EndTime) {
+			res = append(res, HygieneFinding{
+				Kind:       HygieneExpiredUserPermission,
+				Ref:        up.Ref(),
+				Detail:     fmt.Sprintf("授权已经在 %d 过期", up.EndTime),
+				Suggestion: "回收用户权限",
+				Changes:    []ModelChange{remove(UserPermissionTable, i, up.Ref(), up.ID)},
+			})
+			continue
+		}
+		if !up.Effect.IsAllow() {
+			continue
+		}
+	roles:
+		for _, ur := range m.UserRoles {
+			if ur.UserID != up.UserID || expired(ur.EndTime) || !covers(ur, up) {
+				continue
+			}
+			for _, path := range includedRolePaths(includes, ur.Role) {
+				if rolePermissions[path[len(path)-1]][up.PermissionRef()] {
+					res = append(res, HygieneFinding{
+						Kind:       HygieneRedundantUserPermission,
+						Ref:        up.Ref(),
+						Detail:     fmt.Sprintf("通过角色 %s 已经拥有", PermissionSource{Roles: path}.Path()),
+						Suggestion: "回收直接授予的权限",
+						Changes:    []ModelChange{remove(UserPermissionTable, i, up.Ref(), up.ID)},
+					})
+					break roles
+				}
+			}
+		}
+	}
+	for i, ur := range m.UserRoles {
+		if expired(ur.EndTime) {
+			res = append(res, HygieneFinding{
+				Kind:       HygieneExpiredUserRole,
+				Ref:        ur.Ref(),
+				Detail:     fmt.Sprintf("授权已经在 %d 过期", ur.EndTime),
+				Suggestion: "回收用户角色",
+				Changes:    []ModelChange{remove(UserRoleTable, i, ur.Ref(), ur.ID)},
+			})
+		}
+	}
+
+	res = slices.DeleteFunc(res, func(f HygieneFinding) bool {
+		return slices.ContainsFunc(f.Changes, m.Protected)
+	})
+	slices.SortStableFunc(res, func(a, b HygieneFinding) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Ref, b.Ref))
+	})
+	return res
+}
+
+// CleanupPlan 合并选中的问题的清理方式，同一个对象只删除一次，按照依赖的反方向排列
+func CleanupPlan(findings []HygieneFinding) ModelImportPlan {
+	var plan ModelImportPlan
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		for _, c := range f.Changes {
+			key := fmt.Sprintf("%s/%d/%s", c.Table, c.ExistingID, c.Ref)
+			if !seen[key] {
+				seen[key] = true
+				plan.Changes = append(plan.Changes, c)
+			}
+		}
+	}
+	slices.SortStableFunc(plan.Changes, func(a, b ModelChange) int {
+		return cmp.Compare(slices.Index(cleanupOrder, a.Table), slices.Index(cleanupOrder, b.Table))
+	})
+	return plan
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHygiene(t *testing.T) {
+	const at = int64(1000)
+	read := ModelPermission{ResourceType: "order", ResourceKey: "/order", Action: "read"}
+	viewerRead := ModelRolePermission{Role: "viewer", ResourceType: "order", ResourceKey: "/order", Action: "read"}
+	userRead := ModelUserPermission{UserID: 1, ResourceType: "order", ResourceKey: "/order", Action: "read", Effect: EffectAllow}
+	testCases := []struct {
+		name  string
+		model RBACModel
+		// want 问题的 Key，按照种类和引用标识排序
+		want []string
+	}{
+		{
+			name: "没有权限的资源和没有授予的权限",
+			model: RBACModel{
+				Resources:       []ModelResource{{Type: "order", Key: "/order"}, {Type: "report", Key: "/report"}},
+				Permissions:     []ModelPermission{read, {ResourceType: "order", ResourceKey: "/order", Action: "delete"}},
+				Roles:           []ModelRole{{Name: "viewer"}},
+				RolePermissions: []ModelRolePermission{viewerRead},
+				UserRoles:       []ModelUserRole{{UserID: 1, Role: "viewer"}},
+			},
+			want: []string{"unused_permission/order:/order#delete", "unused_resource/report:/report"},
+		},
+		{
+			name: "没有使用的角色，账号角色由管理后台维护",
+			model: RBACModel{
+				Roles:           []ModelRole{{Name: "viewer"}, {Name: "account_1", Type: DefaultAccountRoleType}},
+				RolePermissions: []ModelRolePermission{viewerRead},
+			},
+			want: []string{"unused_role/viewer"},
+		},
+		{
+			name: "通过被包含的角色已经拥有的权限",
+			model: RBACModel{
+				RoleInclusions: []ModelRoleInclusion{{Including: "editor", Included: "staff"}, {Including: "staff", Included: "viewer"}},
+				RolePermissions: []ModelRolePermission{
+					viewerRead, {Role: "editor", ResourceType: "order", ResourceKey: "/order", Action: "read"},
+				},
+			},
+			want: []string{"redundant_role_permission/editor -> order:/order#read"},
+		},
+		{
+			name: "角色的有效期覆盖直接授予的权限",
+			model: RBACModel{
+				RolePermissions: []ModelRolePermission{viewerRead},
+				UserRoles:       []ModelUserRole{{UserID: 1, Role: "viewer", EndTime: 3000}},
+				UserPermissions: []ModelUserPermission{func() ModelUserPermission { p := userRead; p.EndTime = 2000; return p }()},
+			},
+			want: []string{"redundant_user_permission/1 -> order:/order#read"},
+		},
+		{
+			name: "角色的有效期没有覆盖直接授予的权限",
+			model: RBACModel{
+				RolePermissions: []ModelRolePermission{viewerRead},
+				UserRoles:       []ModelUserRole{{UserID: 1, Role: "viewer", EndTime: 3000}},
+				UserPermissions: []ModelUserPermission{userRead},
+			},
+		},
+		{
+			name: "deny 权限不算冗余",
+			model: RBACModel{
+				RolePermissions: []ModelRolePermission{viewerRead},
+				UserRoles:       []ModelUserRole{{UserID: 1, Role: "viewer"}},
+				UserPermissions: []ModelUserPermission{func() ModelUserPermission { p := userRead; p.Effect = EffectDeny; return p }()},
+			},
+		},
+		{
+			name: "过期的授权",
+			model: RBACModel{
+				UserRoles: []ModelUserRole{{UserID: 1, Role: "viewer", EndTime: at}, {UserID: 2, Role: "viewer", EndTime: at + 1}},
+				UserPermissions: []ModelUserPermission{
+					func() ModelUserPermission { p := userRead; p.EndTime = at - 1; return p }(),
+				},
+			},
+			want: []string{"expired_user_permission/1 -> order:/order#read", "expired_user_role/1 -> viewer"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, f := range tc.model.Hygiene(at) {
+				got = append(got, f.Key())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("Hygiene() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCleanupPlan(t *testing.T) {
+	model := RBACModel{
+		Resources:   []ModelResource{{ID: 1, Type: "order", Key: "/order"}, {ID: 2, Type: "report", Key: "/report"}},
+		Permissions: []ModelPermission{{ID: 3, ResourceType: "order", ResourceKey: "/order", Action: "read"}},
+		Roles:       []ModelRole{{ID: 4, Name: "viewer"}, {ID: 5, Name: "orphan"}},
+		RolePermissions: []ModelRolePermission{
+			{ID: 6, Role: "viewer", ResourceType: "order", ResourceKey: "/order", Action: "read"},
+			{ID: 7, Role: "orphan", ResourceType: "order", ResourceKey: "/order", Action: "read"},
+		},
+		UserRoles: []ModelUserRole{{ID: 8, UserID: 1, Role: "viewer", EndTime: 10}},
+	}
+	findings := model.Hygiene(100)
+	// 重复选择的问题只删除一次
+	plan := CleanupPlan(append(findings, findings...))
+	var got []int64
+	for _, c := range plan.Changes {
+		if c.Op != ModelChangeDelete {
+			t.Fatalf("CleanupPlan() 包含删除以外的变更 %+v", c)
+		}
+		got = append(got, c.ExistingID)
+	}
+	// 先回收授权，再删除角色，最后删除资源
+	want := []int64{8, 7, 5, 2}
+	if !slices.Equal(got, want) {
+		t.Fatalf("CleanupPlan() = %v, want %v", got, want)
+	}
+}
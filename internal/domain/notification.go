@@ -0,0 +1,24 @@
+package domain
+
+type NotificationPriority string
+
+const (
+	NotificationPriorityNormal NotificationPriority = "normal"
+	NotificationPriorityHigh   NotificationPriority = "high"
+)
+
+func (p NotificationPriority) String() string {
+	return string(p)
+}
+
+// Notification 发送给管理员或者用户的通知
+type Notification struct {
+	BizID    int64
+	Priority NotificationPriority
+	// Event 通知类型，例如 break_glass.activated
+	Event     string
+	Title     string
+	Content   string
+	Receivers []int64
+	Ctime     int64
+}
@@ -0,0 +1,607 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RBACModel 一个业务完整的权限模型，用于备份、迁移和评审
+// 各部分之间用资源标识、权限动作和角色名互相引用，不依赖ID，所以可以导入到另一个业务
+// ID 只在从权限平台读取时填写，导入时忽略
+// 从权限平台读取的模型包括管理后台的账号角色和系统资源，导出、导入和回滚只处理 Business 部分
+type RBACModel struct {
+	Resources       []ModelResource
+	Permissions     []ModelPermission
+	Roles           []ModelRole
+	RoleInclusions  []ModelRoleInclusion
+	RolePermissions []ModelRolePermission
+	UserRoles       []ModelUserRole
+	UserPermissions []ModelUserPermission
+}
+
+type ModelResource struct {
+	ID          int64
+	Type        string
+	Key         string
+	Name        string
+	Description string
+	Metadata    string
+}
+
+func (r ModelResource) Ref() string {
+	return resourceRef(r.Type, r.Key)
+}
+
+type ModelPermission struct {
+	ID           int64
+	Name         string
+	Description  string
+	ResourceType string
+	ResourceKey  string
+	Action       string
+	Metadata     string
+}
+
+// Ref 权限用资源和动作标识
+func (p ModelPermission) Ref() string {
+	return permissionRef(p.ResourceType, p.ResourceKey, p.Action)
+}
+
+func (p ModelPermission) ResourceRef() string {
+	return resourceRef(p.ResourceType, p.ResourceKey)
+}
+
+type ModelRole struct {
+	ID          int64
+	Name        string
+	Description string
+	Metadata    string
+	// Type 角色类型，为空时是业务角色
+	Type string
+}
+
+func (r ModelRole) Ref() string {
+	return r.Name
+}
+
+// IsAccount 管理后台的账号角色，例如业务管理员
+func (r ModelRole) IsAccount() bool {
+	return r.Type == DefaultAccountRoleType
+}
+
+// IsSystemResourceType 管理后台自己使用的资源类型，包括系统表和管理账号，由创建业务时初始化，不属于业务自己的权限模型
+func IsSystemResourceType(typ string) bool {
+	return typ == BusinessConfigTable.Type() || typ == ManagerAccountResource.Type()
+}
+
+type ModelRoleInclusion struct {
+	ID int64
+	// Including 包含其他角色的角色名
+	Including string
+	// Included 被包含的角色名
+	Included string
+}
+
+func (r ModelRoleInclusion) Ref() string {
+	return r.Including + " > " + r.Included
+}
+
+type ModelRolePermission struct {
+	ID           int64
+	Role         string
+	ResourceType string
+	ResourceKey  string
+	Action       string
+}
+
+func (r ModelRolePermission) Ref() string {
+	return r.Role + " -> " + r.PermissionRef()
+}
+
+func (r ModelRolePermission) PermissionRef() string {
+	return permissionRef(r.ResourceType, r.ResourceKey, r.Action)
+}
+
+type ModelUserRole struct {
+	ID        int64
+	UserID    int64
+	Role      string
+	StartTime int64
+	EndTime   int64
+}
+
+func (r ModelUserRole) Ref() string {
+	return fmt.Sprintf("%d -> %s", r.UserID, r.Role)
+}
+
+type ModelUserPermission struct {
+	ID           int64
+	UserID       int64
+	ResourceType string
+	ResourceKey  string
+	Action       string
+	Effect       Effect
+	StartTime    int64
+	EndTime      int64
+}
+
+func (r ModelUserPermission) Ref() string {
+	return fmt.Sprintf("%d -> %s", r.UserID, r.PermissionRef())
+}
+
+func (r ModelUserPermission) PermissionRef() string {
+	return permissionRef(r.ResourceType, r.ResourceKey, r.Action)
+}
+
+// Unexpired 去掉到 now 已经过期的用户授权
+func (m RBACModel) Unexpired(now int64) RBACModel {
+	m.UserRoles = slices.DeleteFunc(slices.Clone(m.UserRoles), func(r ModelUserRole) bool {
+		return r.EndTime > 0 && r.EndTime <= now
+	})
+	m.UserPermissions = slices.DeleteFunc(slices.Clone(m.UserPermissions), func(r ModelUserPermission) bool {
+		return r.EndTime > 0 && r.EndTime <= now
+	})
+	return m
+}
+
+// Business 去掉账号角色、系统资源以及引用它们的包含关系和授权，剩下业务自己维护的部分
+// 导出、导入、声明式管理和快照回滚只处理这一部分，分析类的功能使用完整的模型
+func (m RBACModel) Business() RBACModel {
+	accounts := m.accountRoles()
+	return RBACModel{
+		Resources: slices.DeleteFunc(slices.Clone(m.Resources), func(r ModelResource) bool {
+			return IsSystemResourceType(r.Type)
+		}),
+		Permissions: slices.DeleteFunc(slices.Clone(m.Permissions), func(p ModelPermission) bool {
+			return IsSystemResourceType(p.ResourceType)
+		}),
+		Roles: slices.DeleteFunc(slices.Clone(m.Roles), ModelRole.IsAccount),
+		RoleInclusions: slices.DeleteFunc(slices.Clone(m.RoleInclusions), func(r ModelRoleInclusion) bool {
+			return accounts[r.Including] || accounts[r.Included]
+		}),
+		RolePermissions: slices.DeleteFunc(slices.Clone(m.RolePermissions), func(r ModelRolePermission) bool {
+			return accounts[r.Role] || IsSystemResourceType(r.ResourceType)
+		}),
+		UserRoles: slices.DeleteFunc(slices.Clone(m.UserRoles), func(r ModelUserRole) bool {
+			return accounts[r.Role]
+		}),
+		UserPermissions: slices.DeleteFunc(slices.Clone(m.UserPermissions), func(r ModelUserPermission) bool {
+			return IsSystemResourceType(r.ResourceType)
+		}),
+	}
+}
+
+// Protected 删除操作 c 涉及账号角色或者系统资源，c.Index 是对象在 m 里的下标
+// 删除这些对象会让业务管理员失去管理自己业务的权限
+func (m RBACModel) Protected(c ModelChange) bool {
+	accounts := m.accountRoles()
+	in := func(n int) bool { return c.Index >= 0 && c.Index < n }
+	switch c.Table {
+	case ResourceTable:
+		return in(len(m.Resources)) && IsSystemResourceType(m.Resources[c.Index].Type)
+	case PermissionTable:
+		return in(len(m.Permissions)) && IsSystemResourceType(m.Permissions[c.Index].ResourceType)
+	case RoleTable:
+		return in(len(m.Roles)) && m.Roles[c.Index].IsAccount()
+	case RoleInclusionTable:
+		return in(len(m.RoleInclusions)) &&
+			(accounts[m.RoleInclusions[c.Index].Including] || accounts[m.RoleInclusions[c.Index].Included])
+	case RolePermissionTable:
+		return in(len(m.RolePermissions)) &&
+			(accounts[m.RolePermissions[c.Index].Role] || IsSystemResourceType(m.RolePermissions[c.Index].ResourceType))
+	case UserRoleTable:
+		return in(len(m.UserRoles)) && accounts[m.UserRoles[c.Index].Role]
+	case UserPermissionTable:
+		return in(len(m.UserPermissions)) && IsSystemResourceType(m.UserPermissions[c.Index].ResourceType)
+	}
+	return false
+}
+
+func (m RBACModel) accountRoles() map[string]bool {
+	res := make(map[string]bool)
+	for _, r := range m.Roles {
+		if r.IsAccount() {
+			res[r.Name] = true
+		}
+	}
+	return res
+}
+
+func resourceRef(typ, key string) string {
+	return typ + ":" + key
+}
+
+func permissionRef(typ, key, action string) string {
+	return resourceRef(typ, key) + "#" + action
+}
+
+// Validate 导入前的校验，返回全部问题，没有问题时返回空
+// 引用的资源、权限和角色可以在模型里定义，也可以是目标业务里已经存在的
+func (m RBACModel) Validate(existing RBACModel) []string {
+	var problems []string
+	add := func(table SystemTableResource, i int, format string, args ...any) {
+		problems = append(problems, fmt.Sprintf("%s[%d]: %s", table, i, fmt.Sprintf(format, args...)))
+	}
+	resources := make(map[string]bool, len(existing.Resources)+len(m.Resources))
+	for _, r := range existing.Resources {
+		resources[r.Ref()] = true
+	}
+	seen := make(map[string]bool)
+	for i, r := range m.Resources {
+		if r.Type == "" || r.Key == "" {
+			add(ResourceTable, i, "资源类型和资源标识不能为空")
+			continue
+		}
+		if IsSystemResourceType(r.Type) {
+			add(ResourceTable, i, "资源类型 %s 由管理后台维护，不能导入", r.Type)
+		}
+		if seen[r.Ref()] {
+			add(ResourceTable, i, "资源 %s 重复", r.Ref())
+		}
+		seen[r.Ref()] = true
+		resources[r.Ref()] = true
+	}
+
+	permissions := make(map[string]bool, len(existing.Permissions)+len(m.Permissions))
+	for _, p := range existing.Permissions {
+		permissions[p.Ref()] = true
+	}
+	clear(seen)
+	for i, p := range m.Permissions {
+		if p.Action == "" {
+			add(PermissionTable, i, "权限动作不能为空")
+			continue
+		}
+		if !resources[p.ResourceRef()] {
+			add(PermissionTable, i, "资源 %s 不存在", p.ResourceRef())
+		}
+		if IsSystemResourceType(p.ResourceType) {
+			add(PermissionTable, i, "资源类型 %s 由管理后台维护，不能导入", p.ResourceType)
+		}
+		if seen[p.Ref()] {
+			add(PermissionTable, i, "权限 %s 重复", p.Ref())
+		}
+		seen[p.Ref()] = true
+		permissions[p.Ref()] = true
+	}
+
+	roles := make(map[string]bool, len(existing.Roles)+len(m.Roles))
+	for _, r := range existing.Roles {
+		roles[r.Ref()] = true
+	}
+	clear(seen)
+	for i, r := range m.Roles {
+		if r.Name == "" {
+			add(RoleTable, i, "角色名不能为空")
+			continue
+		}
+		if seen[r.Ref()] {
+			add(RoleTable, i, "角色 %s 重复", r.Name)
+		}
+		seen[r.Ref()] = true
+		roles[r.Ref()] = true
+	}
+
+	clear(seen)
+	for i, r := range m.RoleInclusions {
+		if r.Including == r.Included {
+			add(RoleInclusionTable, i, "角色 %s 不能包含自己", r.Including)
+		}
+		for _, name := range []string{r.Including, r.Included} {
+			if !roles[name] {
+				add(RoleInclusionTable, i, "角色 %s 不存在", name)
+			}
+		}
+		if seen[r.Ref()] {
+			add(RoleInclusionTable, i, "角色包含关系 %s 重复", r.Ref())
+		}
+		seen[r.Ref()] = true
+	}
+
+	clear(seen)
+	for i, r := range m.RolePermissions {
+		if !roles[r.Role] {
+			add(RolePermissionTable, i, "角色 %s 不存在", r.Role)
+		}
+		if !permissions[r.PermissionRef()] {
+			add(RolePermissionTable, i, "权限 %s 不存在", r.PermissionRef())
+		}
+		if seen[r.Ref()] {
+			add(RolePermissionTable, i, "角色权限 %s 重复", r.Ref())
+		}
+		seen[r.Ref()] = true
+	}
+
+	clear(seen)
+	for i, r := range m.UserRoles {
+		if r.UserID <= 0 {
+			add(UserRoleTable, i, "用户ID不合法")
+		}
+		if !roles[r.Role] {
+			add(UserRoleTable, i, "角色 %s 不存在", r.Role)
+		}
+		if r.EndTime > 0 && r.EndTime <= r.StartTime {
+			add(UserRoleTable, i, "结束时间必须晚于开始时间")
+		}
+		if seen[r.Ref()] {
+			add(UserRoleTable, i, "用户角色 %s 重复", r.Ref())
+		}
+		seen[r.Ref()] = true
+	}
+
+	clear(seen)
+	for i, r := range m.UserPermissions {
+		if r.UserID <= 0 {
+			add(UserPermissionTable, i, "用户ID不合法")
+		}
+		if !permissions[r.PermissionRef()] {
+			add(UserPermissionTable, i, "权限 %s 不存在", r.PermissionRef())
+		}
+		if !r.Effect.IsAllow() && !r.Effect.IsDeny() {
+			add(UserPermissionTable, i, "不支持的效果 %q", r.Effect)
+		}
+		if r.EndTime > 0 && r.EndTime <= r.StartTime {
+			add(UserPermissionTable, i, "结束时间必须晚于开始时间")
+		}
+		if seen[r.Ref()] {
+			add(UserPermissionTable, i, "用户权限 %s 重复", r.Ref())
+		}
+		seen[r.Ref()] = true
+	}
+	return problems
+}
+
+// ModelConflictStrategy 导入的对象在目标业务中已经存在并且内容不同时的处理方式
+type ModelConflictStrategy string
+
+const (
+	ModelConflictSkip      ModelConflictStrategy = "skip"
+	ModelConflictOverwrite ModelConflictStrategy = "overwrite"
+	ModelConflictFail      ModelConflictStrategy = "fail"
+)
+
+func (s ModelConflictStrategy) String() string {
+	return string(s)
+}
+
+func (s ModelConflictStrategy) Valid() bool {
+	return s == ModelConflictSkip || s == ModelConflictOverwrite || s == ModelConflictFail
+}
+
+type ModelChangeOp string
+
+const (
+	ModelChangeCreate ModelChangeOp = "create"
+	ModelChangeUpdate ModelChangeOp = "update"
+	// ModelChangeSkip 存在冲突，按照 skip 策略保留目标业务中的内容
+	ModelChangeSkip ModelChangeOp = "skip"
+	// ModelChangeUnchanged 目标业务中已经有完全相同的内容
+	ModelChangeUnchanged ModelChangeOp = "unchanged"
+	// ModelChangeDelete 目标业务中有但是声明式文件中没有
+	ModelChangeDelete ModelChangeOp = "delete"
+)
+
+func (o ModelChangeOp) String() string {
+	return string(o)
+}
+
+// ModelChange 导入时对一个对象的处理
+type ModelChange struct {
+	Table SystemTableResource
+	Op    ModelChangeOp
+	Ref   string
+	// Index 对象在模型对应部分里的下标，删除时是在目标业务当前模型里的下标
+	Index int
+	// ExistingID 目标业务中已经存在的对象ID，新建时为 0
+	ExistingID int64
+	// Payload 新建和更新时写入的内容，不包括ID，计算计划摘要时使用
+	Payload string
+}
+
+// ModelImportPlan 导入计划，按照资源、权限、角色、角色包含、角色权限、用户角色、用户权限的依赖顺序排列
+type ModelImportPlan struct {
+	Changes []ModelChange
+	// Conflicts fail 策略下发现的冲突，不为空时不能导入
+	Conflicts []string
+	// Warnings 不阻止执行但是需要评审时注意的问题
+	Warnings []string
+}
+
+// Fingerprint 计划的摘要，plan 和 apply 之间目标业务或者写入的内容发生变化时摘要不同
+func (p ModelImportPlan) Fingerprint() string {
+	h := sha256.New()
+	for _, c := range p.Changes {
+		if c.Op == ModelChangeUnchanged {
+			continue
+		}
+		_, _ = fmt.Fprintf(h, "%s\t%s\t%s\t%d\t%q\n", c.Op, c.Table, c.Ref, c.ExistingID, c.Payload)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Count 统计每种处理方式的数量
+func (p ModelImportPlan) Count() map[ModelChangeOp]int {
+	res := make(map[ModelChangeOp]int, 4)
+	for _, c := range p.Changes {
+		res[c.Op]++
+	}
+	return res
+}
+
+// Plan 对比目标业务已有的内容生成导入计划，调用前需要先通过 Validate
+// 角色包含和角色权限没有其他属性，已经存在就是完全相同
+func (m RBACModel) Plan(existing RBACModel, strategy ModelConflictStrategy) ModelImportPlan {
+	var plan ModelImportPlan
+	add := func(table SystemTableResource, i int, ref string, payload any, existingID int64, found, same bool) {
+		change := ModelChange{Table: table, Op: ModelChangeCreate, Ref: ref, Index: i, ExistingID: existingID, Payload: fmt.Sprintf("%+v", payload)}
+		switch {
+		case !found:
+		case same:
+			change.Op = ModelChangeUnchanged
+		case strategy == ModelConflictOverwrite:
+			change.Op = ModelChangeUpdate
+		case strategy == ModelConflictFail:
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("%s %s 已经存在并且内容不同", table, ref))
+			change.Op = ModelChangeSkip
+		default:
+			change.Op = ModelChangeSkip
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	resources := indexModel(existing.Resources, ModelResource.Ref)
+	for i, r := range m.Resources {
+		old, ok := resources[r.Ref()]
+		r.ID = 0
+		add(ResourceTable, i, r.Ref(), r, old.ID, ok,
+			old.Name == r.Name && old.Description == r.Description && old.Metadata == r.Metadata)
+	}
+	permissions := indexModel(existing.Permissions, ModelPermission.Ref)
+	for i, p := range m.Permissions {
+		old, ok := permissions[p.Ref()]
+		p.ID = 0
+		add(PermissionTable, i, p.Ref(), p, old.ID, ok,
+			old.Name == p.Name && old.Description == p.Description && old.Metadata == p.Metadata)
+	}
+	roles := indexModel(existing.Roles, ModelRole.Ref)
+	for i, r := range m.Roles {
+		old, ok := roles[r.Ref()]
+		r.ID = 0
+		add(RoleTable, i, r.Ref(), r, old.ID, ok, old.Description == r.Description && old.Metadata == r.Metadata)
+	}
+	inclusions := indexModel(existing.RoleInclusions, ModelRoleInclusion.Ref)
+	for i, r := range m.RoleInclusions {
+		old, ok := inclusions[r.Ref()]
+		r.ID = 0
+		add(RoleInclusionTable, i, r.Ref(), r, old.ID, ok, true)
+	}
+	rolePermissions := indexModel(existing.RolePermissions, ModelRolePermission.Ref)
+	for i, r := range m.RolePermissions {
+		old, ok := rolePermissions[r.Ref()]
+		r.ID = 0
+		add(RolePermissionTable, i, r.Ref(), r, old.ID, ok, true)
+	}
+	userRoles := indexModel(existing.UserRoles, ModelUserRole.Ref)
+	for i, r := range m.UserRoles {
+		old, ok := userRoles[r.Ref()]
+		r.ID = 0
+		add(UserRoleTable, i, r.Ref(), r, old.ID, ok, old.StartTime == r.StartTime && old.EndTime == r.EndTime)
+	}
+	userPermissions := indexModel(existing.UserPermissions, ModelUserPermission.Ref)
+	for i, r := range m.UserPermissions {
+		old, ok := userPermissions[r.Ref()]
+		r.ID = 0
+		add(UserPermissionTable, i, r.Ref(), r, old.ID, ok,
+			old.Effect == r.Effect && old.StartTime == r.StartTime && old.EndTime == r.EndTime)
+	}
+	return plan
+}
+
+// Diff 把模型当作期望状态，和目标业务的当前状态对比，生成让两者一致的计划，调用前需要先通过 Validate
+// 只管理资源、权限、角色、角色包含和角色权限，用户授权是日常运营数据，不在声明式文件中管理
+// 先新建和更新，再按照依赖的反方向删除，删除仍然授予给用户的角色和权限会给出警告
+func (m RBACModel) Diff(live RBACModel) ModelImportPlan {
+	desired := m
+	desired.UserRoles, desired.UserPermissions = nil, nil
+	return desired.diff(live, false)
+}
+
+// Rollback 把模型当作快照，生成让目标业务恢复到快照状态的计划
+// 和 Diff 不同的是用户授权也恢复，快照之后新增的用户授权会被回收
+// 快照里已经过期的授权不应该恢复，调用前先通过 Unexpired 去掉，计划里的下标指向去掉之后的模型
+func (m RBACModel) Rollback(live RBACModel) ModelImportPlan {
+	return m.diff(live, true)
+}
+
+func (m RBACModel) diff(live RBACModel, withGrants bool) ModelImportPlan {
+	plan := m.Plan(live, ModelConflictOverwrite)
+
+	remove := func(table SystemTableResource, i int, ref string, id int64) {
+		plan.Changes = append(plan.Changes, ModelChange{Table: table, Op: ModelChangeDelete, Ref: ref, Index: i, ExistingID: id})
+	}
+	if withGrants {
+		userPermissions := indexModel(m.UserPermissions, ModelUserPermission.Ref)
+		for i, r := range live.UserPermissions {
+			if _, ok := userPermissions[r.Ref()]; !ok {
+				remove(UserPermissionTable, i, r.Ref(), r.ID)
+			}
+		}
+		userRoles := indexModel(m.UserRoles, ModelUserRole.Ref)
+		for i, r := range live.UserRoles {
+			if _, ok := userRoles[r.Ref()]; !ok {
+				remove(UserRoleTable, i, r.Ref(), r.ID)
+			}
+		}
+	}
+	rolePermissions := indexModel(m.RolePermissions, ModelRolePermission.Ref)
+	for i, r := range live.RolePermissions {
+		if _, ok := rolePermissions[r.Ref()]; !ok {
+			remove(RolePermissionTable, i, r.Ref(), r.ID)
+		}
+	}
+	inclusions := indexModel(m.RoleInclusions, ModelRoleInclusion.Ref)
+	for i, r := range live.RoleInclusions {
+		if _, ok := inclusions[r.Ref()]; !ok {
+			remove(RoleInclusionTable, i, r.Ref(), r.ID)
+		}
+	}
+	roles := indexModel(m.Roles, ModelRole.Ref)
+	grantedRoles := make(map[string]int)
+	for _, r := range live.UserRoles {
+		grantedRoles[r.Role]++
+	}
+	for i, r := range live.Roles {
+		if _, ok := roles[r.Ref()]; ok {
+			continue
+		}
+		remove(RoleTable, i, r.Ref(), r.ID)
+		if n := grantedRoles[r.Name]; n > 0 && !withGrants {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("删除的角色 %s 仍然授予了 %d 个用户", r.Name, n))
+		}
+	}
+	permissions := indexModel(m.Permissions, ModelPermission.Ref)
+	grantedPermissions := make(map[string]int)
+	for _, r := range live.UserPermissions {
+		grantedPermissions[r.PermissionRef()]++
+	}
+	for i, p := range live.Permissions {
+		if _, ok := permissions[p.Ref()]; ok {
+			continue
+		}
+		remove(PermissionTable, i, p.Ref(), p.ID)
+		if n := grantedPermissions[p.Ref()]; n > 0 && !withGrants {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("删除的权限 %s 仍然直接授予了 %d 个用户", p.Ref(), n))
+		}
+	}
+	resources := indexModel(m.Resources, ModelResource.Ref)
+	for i, r := range live.Resources {
+		if _, ok := resources[r.Ref()]; !ok {
+			remove(ResourceTable, i, r.Ref(), r.ID)
+		}
+	}
+	return plan
+}
+
+// indexModel 按照引用标识建立索引，标识重复时保留第一个
+func indexModel[T any](items []T, ref func(T) string) map[string]T {
+	res := make(map[string]T, len(items))
+	for _, item := range items {
+		key := ref(item)
+		if _, ok := res[key]; !ok {
+			res[key] = item
+		}
+	}
+	return res
+}
+
+// ModelImportError 导入前的校验没有通过，没有写入任何数据
+type ModelImportError struct {
+	Problems []string
+}
+
+func (e *ModelImportError) Error() string {
+	return "权限模型不能导入: " + strings.Join(e.Problems, "; ")
+}
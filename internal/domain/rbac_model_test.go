@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRollbackUnexpired(t *testing.T) {
+	const now = int64(1000)
+	read := ModelPermission{ResourceType: "order", ResourceKey: "/order", Action: "read"}
+	userRead := func(uid, endTime int64) ModelUserPermission {
+		return ModelUserPermission{UserID: uid, ResourceType: "order", ResourceKey: "/order", Action: "read", Effect: EffectAllow, EndTime: endTime}
+	}
+	snapshot := RBACModel{
+		Permissions: []ModelPermission{read},
+		Roles:       []ModelRole{{Name: "viewer"}},
+		UserRoles: []ModelUserRole{
+			{UserID: 1, Role: "viewer", EndTime: now - 1},
+			{UserID: 2, Role: "viewer", EndTime: now + 1},
+			{UserID: 3, Role: "viewer"},
+		},
+		UserPermissions: []ModelUserPermission{userRead(1, now), userRead(2, 0)},
+	}
+	live := RBACModel{
+		Permissions: []ModelPermission{{ID: 1, ResourceType: "order", ResourceKey: "/order", Action: "read"}},
+		Roles:       []ModelRole{{ID: 2, Name: "viewer"}},
+		UserRoles: []ModelUserRole{
+			// 快照之后还没有被清理的过期授权，回滚时一起回收
+			{ID: 3, UserID: 1, Role: "viewer", EndTime: now - 1},
+			{ID: 4, UserID: 4, Role: "viewer"},
+		},
+	}
+
+	target := snapshot.Unexpired(now)
+	if len(snapshot.UserRoles) != 3 || len(snapshot.UserPermissions) != 2 {
+		t.Fatal("Unexpired 不应该修改原来的模型")
+	}
+	var got []string
+	for _, c := range target.Rollback(live).Changes {
+		if c.Op == ModelChangeUnchanged || (c.Table != UserRoleTable && c.Table != UserPermissionTable) {
+			continue
+		}
+		ref := c.Ref
+		if c.Op != ModelChangeDelete {
+			// 计划里的下标指向去掉过期授权之后的模型
+			switch c.Table {
+			case UserRoleTable:
+				ref = target.UserRoles[c.Index].Ref()
+			case UserPermissionTable:
+				ref = target.UserPermissions[c.Index].Ref()
+			}
+		}
+		got = append(got, string(c.Op)+" "+ref)
+	}
+	want := []string{
+		"create 2 -> viewer",
+		"create 3 -> viewer",
+		"create 2 -> order:/order#read",
+		"delete 1 -> viewer",
+		"delete 4 -> viewer",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Rollback() = %q, want %q", got, want)
+	}
+}
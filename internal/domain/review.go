@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrReviewItemDecided = errors.New("该授权已经审查过")
+	ErrNotReviewer       = errors.New("不是该授权的审查人")
+)
+
+// ReviewExpirePolicy 截止时间之后仍未审查的授权如何处理
+type ReviewExpirePolicy string
+
+const (
+	// ReviewExpireRevoke 自动回收
+	ReviewExpireRevoke ReviewExpirePolicy = "revoke"
+	// ReviewExpireEscalate 转交给升级审查人，并且发送高优先级通知
+	ReviewExpireEscalate ReviewExpirePolicy = "escalate"
+)
+
+type ReviewCampaignStatus string
+
+const (
+	ReviewCampaignActive ReviewCampaignStatus = "active"
+	// ReviewCampaignEscalated 已过截止时间，剩余授权已经升级处理
+	ReviewCampaignEscalated ReviewCampaignStatus = "escalated"
+	ReviewCampaignCompleted ReviewCampaignStatus = "completed"
+)
+
+func (s ReviewCampaignStatus) String() string {
+	return string(s)
+}
+
+// ReviewCampaign 一次权限审查
+type ReviewCampaign struct {
+	ID    int64
+	BizID int64
+	Name  string
+	// RoleIDs 审查范围，为空并且 Privileged 为 false 时审查业务内全部角色
+	RoleIDs []int64
+	// Privileged 审查全部特权角色，即管理后台账号角色和被审批策略覆盖的角色
+	Privileged bool
+	// DefaultReviewer 角色没有负责人、用户也没有上级时的审查人
+	DefaultReviewer int64
+	Deadline        int64
+	ExpirePolicy    ReviewExpirePolicy
+	// EscalateTo 升级审查人，ExpirePolicy 为 escalate 时必填
+	EscalateTo int64
+	CreatorUID int64
+	Status     ReviewCampaignStatus
+	Ctime      int64
+	Utime      int64
+}
+
+func (c ReviewCampaign) Validate(now int64) error {
+	if c.Deadline <= now {
+		return errors.New("截止时间必须晚于当前时间")
+	}
+	if c.DefaultReviewer <= 0 {
+		return errors.New("默认审查人不能为空")
+	}
+	switch c.ExpirePolicy {
+	case ReviewExpireRevoke:
+	case ReviewExpireEscalate:
+		if c.EscalateTo <= 0 {
+			return errors.New("升级审查人不能为空")
+		}
+	default:
+		return errors.New("未知的超期处理策略")
+	}
+	return nil
+}
+
+// InScope 判断授权是否在审查范围内，privilegedRoles 为业务内的特权角色
+func (c ReviewCampaign) InScope(roleID int64, roleType string, privilegedRoles []int64) bool {
+	if len(c.RoleIDs) > 0 && !slices.Contains(c.RoleIDs, roleID) {
+		return false
+	}
+	if c.Privileged {
+		return roleType == DefaultAccountRoleType || slices.Contains(privilegedRoles, roleID)
+	}
+	return true
+}
+
+type ReviewDecision string
+
+const (
+	ReviewPending     ReviewDecision = "pending"
+	ReviewCertified   ReviewDecision = "certified"
+	ReviewRevoked     ReviewDecision = "revoked"
+	ReviewAutoRevoked ReviewDecision = "auto_revoked"
+)
+
+func (d ReviewDecision) String() string {
+	return string(d)
+}
+
+// ReviewItem 审查开始时的一条用户角色快照
+type ReviewItem struct {
+	ID         int64
+	CampaignID int64
+	UserRoleID int64
+	UserID     int64
+	RoleID     int64
+	RoleName   string
+	RoleType   string
+	StartTime  int64
+	EndTime    int64
+	Reviewer   int64
+	Escalated  bool
+	Decision   ReviewDecision
+	Comment    string
+	DecidedBy  int64
+	DecidedAt  int64
+}
+
+func (i ReviewItem) Pending() bool {
+	return i.Decision == "" || i.Decision == ReviewPending
+}
+
+// ReviewReport 审查完成报告
+type ReviewReport struct {
+	Campaign    ReviewCampaign
+	Total       int
+	Certified   int
+	Revoked     int
+	AutoRevoked int
+	Escalated   int
+	Pending     int
+	Items       []ReviewItem
+}
+
+func NewReviewReport(campaign ReviewCampaign, items []ReviewItem) ReviewReport {
+	res := ReviewReport{Campaign: campaign, Total: len(items), Items: items}
+	for i := range items {
+		if items[i].Escalated {
+			res.Escalated++
+		}
+		switch items[i].Decision {
+		case ReviewCertified:
+			res.Certified++
+		case ReviewRevoked:
+			res.Revoked++
+		case ReviewAutoRevoked:
+			res.AutoRevoked++
+		default:
+			res.Pending++
+		}
+	}
+	return res
+}
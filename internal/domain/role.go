@@ -3,6 +3,8 @@ package domain
 const (
 	DefaultAccountRoleType  = "admin_account"
 	DefaultBusinessRoleType = "business_role"
+	// BusinessAdminRoleName 接入业务时创建的业务管理员角色，拥有全部系统表的权限
+	BusinessAdminRoleName = "业务管理员"
 )
 
 // Role 角色
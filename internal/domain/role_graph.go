@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+)
+
+// RoleGraphOptions 生成角色包含关系图的选项
+type RoleGraphOptions struct {
+	// Root 只包含以这个角色为根的子图，为空时包含全部角色
+	Root string
+	// Depth 从 Root 开始最多展开的层数，0 表示不限制，没有指定 Root 时忽略
+	Depth int
+	// WithPermissions 节点上带角色直接持有的权限
+	WithPermissions bool
+	// WithUserCounts 节点上带在 At 时刻直接拥有角色的用户数
+	WithUserCounts bool
+	At             int64
+}
+
+// RoleGraph 角色是节点，包含关系是从包含方指向被包含方的边
+type RoleGraph struct {
+	Nodes []RoleGraphNode
+	Edges []ModelRoleInclusion
+}
+
+type RoleGraphNode struct {
+	Name        string
+	Description string
+	Permissions []string
+	UserCount   int
+}
+
+// RoleGraph 生成角色包含关系图，节点按照角色名排序，边按照包含方和被包含方排序
+func (m RBACModel) RoleGraph(opts RoleGraphOptions) RoleGraph {
+	included := make(map[string]bool, len(m.Roles))
+	if opts.Root == "" {
+		for _, r := range m.Roles {
+			included[r.Name] = true
+		}
+	} else {
+		includes := m.roleIncludes()
+		for _, path := range includedRolePaths(includes, opts.Root) {
+			if opts.Depth > 0 && len(path)-1 > opts.Depth {
+				break
+			}
+			included[path[len(path)-1]] = true
+		}
+	}
+
+	var graph RoleGraph
+	for _, r := range m.RoleInclusions {
+		if included[r.Including] && included[r.Included] {
+			graph.Edges = append(graph.Edges, ModelRoleInclusion{Including: r.Including, Included: r.Included})
+		}
+	}
+	permissions := make(map[string][]string)
+	if opts.WithPermissions {
+		for _, r := range m.RolePermissions {
+			permissions[r.Role] = append(permissions[r.Role], r.PermissionRef())
+		}
+	}
+	users := make(map[string]int)
+	if opts.WithUserCounts {
+		for _, r := range m.UserRoles {
+			if ActiveAt(r.StartTime, r.EndTime, opts.At) {
+				users[r.Role]++
+			}
+		}
+	}
+	for _, r := range m.Roles {
+		if !included[r.Name] {
+			continue
+		}
+		perms := permissions[r.Name]
+		slices.Sort(perms)
+		graph.Nodes = append(graph.Nodes, RoleGraphNode{
+			Name:        r.Name,
+			Description: r.Description,
+			Permissions: perms,
+			UserCount:   users[r.Name],
+		})
+	}
+	slices.SortFunc(graph.Nodes, func(a, b RoleGraphNode) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	slices.SortFunc(graph.Edges, func(a, b ModelRoleInclusion) int {
+		return strings.Compare(a.Ref(), b.Ref())
+	})
+	return graph
+}
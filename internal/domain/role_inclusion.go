@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidRoleInclusion = errors.New("角色包含关系不合法")
+
+// CheckRoleInclusion 校验在现有的包含关系上新增 including 包含 included 之后，包含关系仍然没有环，
+// 并且最长的包含链不超过 maxDepth 层，maxDepth 为 0 表示不限制。错误信息里带上有问题的路径
+func CheckRoleInclusion(existing []ModelRoleInclusion, including, included string, maxDepth int) error {
+	if including == included {
+		return fmt.Errorf("%w: 角色 %s 不能包含自己", ErrInvalidRoleInclusion, including)
+	}
+	model := RBACModel{RoleInclusions: existing}
+	includes := model.roleIncludes()
+	if slices.Contains(includes[including], included) {
+		return fmt.Errorf("%w: %s 已经包含 %s", ErrInvalidRoleInclusion, including, included)
+	}
+	if path := rolePath(includes, included, including); path != nil {
+		return fmt.Errorf("%w: 形成环 %s > %s", ErrInvalidRoleInclusion, including, strings.Join(path, " > "))
+	}
+	if maxDepth <= 0 {
+		return nil
+	}
+	includedBy := make(map[string][]string)
+	for _, r := range existing {
+		includedBy[r.Included] = append(includedBy[r.Included], r.Including)
+	}
+	// 新的边加在最长的向上链和最长的向下链之间
+	up := slices.Clone(longestRolePath(includedBy, including, map[string]bool{}, map[string][]string{}))
+	slices.Reverse(up)
+	down := longestRolePath(includes, included, map[string]bool{}, map[string][]string{})
+	chain := append(up, down...)
+	if len(chain)-1 > maxDepth {
+		return fmt.Errorf("%w: 包含层级 %d 超过上限 %d: %s", ErrInvalidRoleInclusion, len(chain)-1, maxDepth, strings.Join(chain, " > "))
+	}
+	return nil
+}
+
+// longestRolePath 从 role 出发沿着 edges 的最长路径，包括 role 自己
+// memo 记录每个角色的结果，角色之间共享下游时每个角色只计算一次
+// 已有的包含关系可能是在校验之前创建的，有环时跳过正在访问的角色，这时的结果只是近似值
+func longestRolePath(edges map[string][]string, role string, visiting map[string]bool, memo map[string][]string) []string {
+	if path, ok := memo[role]; ok {
+		return path
+	}
+	visiting[role] = true
+	defer delete(visiting, role)
+	var longest []string
+	for _, next := range edges[role] {
+		if visiting[next] {
+			continue
+		}
+		if path := longestRolePath(edges, next, visiting, memo); len(path) > len(longest) {
+			longest = path
+		}
+	}
+	res := append([]string{role}, longest...)
+	memo[role] = res
+	return res
+}
+
+// rolePath 从 from 沿着 edges 到 to 的一条最短路径，包括两端，不可达时返回 nil
+// 使用广度优先搜索，每个角色只访问一次
+func rolePath(edges map[string][]string, from, to string) []string {
+	parent := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if role == to {
+			var path []string
+			for ; role != from; role = parent[role] {
+				path = append(path, role)
+			}
+			path = append(path, from)
+			slices.Reverse(path)
+			return path
+		}
+		for _, next := range edges[role] {
+			if _, ok := parent[next]; !ok {
+				parent[next] = role
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckRoleInclusion(t *testing.T) {
+	chain := func(roles ...string) []ModelRoleInclusion {
+		res := make([]ModelRoleInclusion, 0, len(roles))
+		for i := 1; i < len(roles); i++ {
+			res = append(res, ModelRoleInclusion{Including: roles[i-1], Included: roles[i]})
+		}
+		return res
+	}
+	testCases := []struct {
+		name      string
+		existing  []ModelRoleInclusion
+		including string
+		included  string
+		maxDepth  int
+		// wantErr 为空表示校验通过，否则是错误信息里必须包含的内容
+		wantErr string
+	}{
+		{name: "包含自己", including: "a", included: "a", wantErr: "不能包含自己"},
+		{name: "重复创建", existing: chain("a", "b"), including: "a", included: "b", wantErr: "已经包含"},
+		{name: "直接成环", existing: chain("a", "b"), including: "b", included: "a", wantErr: "形成环 b > a > b"},
+		{name: "间接成环", existing: chain("a", "b", "c"), including: "c", included: "a", wantErr: "形成环 c > a > b > c"},
+		{name: "不限制层级", existing: chain("a", "b", "c", "d"), including: "d", included: "e"},
+		{name: "层级刚好等于上限", existing: chain("a", "b"), including: "b", included: "c", maxDepth: 2},
+		{name: "向下的链超过上限", existing: chain("b", "c"), including: "a", included: "b", maxDepth: 1, wantErr: "包含层级 2 超过上限 1: a > b > c"},
+		{
+			name:      "向上和向下的链连起来超过上限",
+			existing:  append(chain("a", "b"), chain("c", "d")...),
+			including: "b",
+			included:  "c",
+			maxDepth:  2,
+			wantErr:   "包含层级 3 超过上限 2: a > b > c > d",
+		},
+		{
+			// 已有的包含关系里有环时也要能结束
+			name:      "已有的包含关系有环",
+			existing:  append(chain("a", "b", "a"), chain("x", "y")...),
+			including: "y",
+			included:  "a",
+			maxDepth:  10,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckRoleInclusion(tc.existing, tc.including, tc.included, tc.maxDepth)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckRoleInclusion() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidRoleInclusion) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("CheckRoleInclusion() = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestCheckRoleInclusionDAG 每层两个角色都包含下一层的两个角色，不缓存时路径数是 2 的层数次方
+func TestCheckRoleInclusionDAG(t *testing.T) {
+	const layers = 60
+	var existing []ModelRoleInclusion
+	for i := 0; i < layers-1; i++ {
+		for _, from := range []string{"l", "r"} {
+			for _, to := range []string{"l", "r"} {
+				existing = append(existing, ModelRoleInclusion{
+					Including: fmt.Sprintf("%s%d", from, i),
+					Included:  fmt.Sprintf("%s%d", to, i+1),
+				})
+			}
+		}
+	}
+	err := CheckRoleInclusion(existing, "top", "l0", layers)
+	if err != nil {
+		t.Fatalf("CheckRoleInclusion() = %v, want nil", err)
+	}
+	err = CheckRoleInclusion(existing, "top", "l0", layers-1)
+	if !errors.Is(err, ErrInvalidRoleInclusion) {
+		t.Fatalf("CheckRoleInclusion() = %v, want ErrInvalidRoleInclusion", err)
+	}
+}
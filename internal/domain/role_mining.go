@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultRoleMiningThreshold  = 0.6
+	defaultRoleMiningMinMembers = 2
+)
+
+// RoleMiningOptions 从直接授予用户的权限中挖掘候选角色的参数，零值使用默认值
+type RoleMiningOptions struct {
+	// Threshold 用户的权限集合和候选角色的权限集合的 Jaccard 相似度下限，默认 0.6
+	Threshold float64
+	// MinMembers 候选角色最少的成员数量，默认 2
+	MinMembers int
+	// MinPermissions 候选角色最少的权限数量，默认 1
+	MinPermissions int
+}
+
+func (o RoleMiningOptions) withDefaults() RoleMiningOptions {
+	if o.Threshold <= 0 || o.Threshold > 1 {
+		o.Threshold = defaultRoleMiningThreshold
+	}
+	o.MinMembers = max(o.MinMembers, defaultRoleMiningMinMembers)
+	o.MinPermissions = max(o.MinPermissions, 1)
+	return o
+}
+
+// RoleSuggestion 一个候选角色，Permissions 是全部成员都直接拥有的权限，
+// 所以用角色代替这些直接授权之后，成员的权限不会变多也不会变少
+type RoleSuggestion struct {
+	Name        string
+	Permissions []string
+	Members     []int64
+}
+
+// Key 候选角色的摘要，权限或者成员变化之后摘要不同，接受时用来确认还是同一个候选角色
+func (s RoleSuggestion) Key() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%v\n", strings.Join(s.Permissions, "\n"), s.Members)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Saved 接受之后减少的直接授权数量，扣除新增的角色权限和用户角色
+func (s RoleSuggestion) Saved() int {
+	return len(s.Permissions)*len(s.Members) - len(s.Permissions) - len(s.Members)
+}
+
+// MineRoles 按照直接授予的权限集合对用户聚类，提出候选角色，按照减少的授权数量从多到少排序
+// 只考虑没有过期的 allow 授权，有效期不同的授权合并成角色之后有效期会丢失，所以也不考虑有期限的授权
+// 用户按照ID顺序依次加入第一个足够相似的聚类，聚类的权限集合是全部成员权限的交集
+func (m RBACModel) MineRoles(opts RoleMiningOptions, at int64) []RoleSuggestion {
+	opts = opts.withDefaults()
+	grants := make(map[int64]map[string]bool)
+	for _, up := range m.UserPermissions {
+		if !up.Effect.IsAllow() || up.StartTime != 0 || up.EndTime != 0 {
+			continue
+		}
+		if grants[up.UserID] == nil {
+			grants[up.UserID] = make(map[string]bool)
+		}
+		grants[up.UserID][up.PermissionRef()] = true
+	}
+	// 被直接 deny 的权限即使通过角色授予也不生效，但是合并成角色之后会让人误解，跳过这些用户的这个权限
+	for _, up := range m.UserPermissions {
+		if up.Effect.IsDeny() && ActiveAt(up.StartTime, up.EndTime, at) && grants[up.UserID] != nil {
+			delete(grants[up.UserID], up.PermissionRef())
+		}
+	}
+
+	type cluster struct {
+		core    map[string]bool
+		members []int64
+	}
+	var clusters []*cluster
+	for _, uid := range slices.Sorted(maps.Keys(grants)) {
+		perms := grants[uid]
+		if len(perms) < opts.MinPermissions {
+			continue
+		}
+		joined := false
+		for _, c := range clusters {
+			if jaccard(c.core, perms) < opts.Threshold {
+				continue
+			}
+			core := make(map[string]bool)
+			for ref := range c.core {
+				if perms[ref] {
+					core[ref] = true
+				}
+			}
+			if len(core) < opts.MinPermissions {
+				continue
+			}
+			c.core, c.members = core, append(c.members, uid)
+			joined = true
+			break
+		}
+		if !joined {
+			clusters = append(clusters, &cluster{core: perms, members: []int64{uid}})
+		}
+	}
+
+	var res []RoleSuggestion
+	for _, c := range clusters {
+		if len(c.members) < opts.MinMembers {
+			continue
+		}
+		res = append(res, RoleSuggestion{Permissions: slices.Sorted(maps.Keys(c.core)), Members: c.members})
+	}
+	slices.SortStableFunc(res, func(a, b RoleSuggestion) int {
+		return cmp.Or(cmp.Compare(b.Saved(), a.Saved()), cmp.Compare(a.Members[0], b.Members[0]))
+	})
+	roles := indexModel(m.Roles, ModelRole.Ref)
+	for i := range res {
+		res[i].Name = candidateRoleName(roles, i+1)
+	}
+	return res
+}
+
+// candidateRoleName 候选角色的默认名称，避开已经存在的角色
+func candidateRoleName(roles map[string]ModelRole, n int) string {
+	name := fmt.Sprintf("mined-role-%d", n)
+	for i := 2; ; i++ {
+		if _, ok := roles[name]; !ok {
+			return name
+		}
+		name = fmt.Sprintf("mined-role-%d-%d", n, i)
+	}
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	inter := 0
+	for ref := range a {
+		if b[ref] {
+			inter++
+		}
+	}
+	union := len(a) + len(b) - inter
+	if union == 0 {
+		return 0
+	}
+	return float64(inter) / float64(union)
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMineRoles(t *testing.T) {
+	const at = int64(1000)
+	grant := func(uid int64, actions ...string) []ModelUserPermission {
+		res := make([]ModelUserPermission, 0, len(actions))
+		for _, a := range actions {
+			res = append(res, ModelUserPermission{UserID: uid, ResourceType: "doc", ResourceKey: "/doc", Action: a, Effect: EffectAllow})
+		}
+		return res
+	}
+	grants := func(groups ...[]ModelUserPermission) []ModelUserPermission {
+		var res []ModelUserPermission
+		for _, g := range groups {
+			res = append(res, g...)
+		}
+		return res
+	}
+	testCases := []struct {
+		name  string
+		model RBACModel
+		opts  RoleMiningOptions
+		want  []RoleSuggestion
+	}{
+		{
+			name:  "权限完全相同",
+			model: RBACModel{UserPermissions: grants(grant(2, "read", "write"), grant(1, "read", "write"))},
+			want:  []RoleSuggestion{{Name: "mined-role-1", Permissions: []string{"doc:/doc#read", "doc:/doc#write"}, Members: []int64{1, 2}}},
+		},
+		{
+			name:  "足够相似时取交集",
+			model: RBACModel{UserPermissions: grants(grant(1, "a", "b", "c"), grant(2, "a", "b", "c", "d"))},
+			want:  []RoleSuggestion{{Name: "mined-role-1", Permissions: []string{"doc:/doc#a", "doc:/doc#b", "doc:/doc#c"}, Members: []int64{1, 2}}},
+		},
+		{
+			name:  "不够相似",
+			model: RBACModel{UserPermissions: grants(grant(1, "a", "b"), grant(2, "a", "c"))},
+		},
+		{
+			name:  "降低相似度下限",
+			model: RBACModel{UserPermissions: grants(grant(1, "a", "b"), grant(2, "a", "c"))},
+			opts:  RoleMiningOptions{Threshold: 0.3},
+			want:  []RoleSuggestion{{Name: "mined-role-1", Permissions: []string{"doc:/doc#a"}, Members: []int64{1, 2}}},
+		},
+		{
+			name: "有期限的授权和被 deny 的权限不参与",
+			model: RBACModel{UserPermissions: grants(
+				grant(1, "a", "b"), grant(2, "a"), grant(3, "a", "b"),
+				[]ModelUserPermission{
+					{UserID: 2, ResourceType: "doc", ResourceKey: "/doc", Action: "b", Effect: EffectAllow, EndTime: 2000},
+					{UserID: 3, ResourceType: "doc", ResourceKey: "/doc", Action: "b", Effect: EffectDeny},
+				},
+			)},
+			opts: RoleMiningOptions{Threshold: 1},
+			want: []RoleSuggestion{{Name: "mined-role-1", Permissions: []string{"doc:/doc#a"}, Members: []int64{2, 3}}},
+		},
+		{
+			name:  "成员数量不够",
+			model: RBACModel{UserPermissions: grants(grant(1, "a"), grant(2, "a"))},
+			opts:  RoleMiningOptions{MinMembers: 3},
+		},
+		{
+			name: "按照减少的授权数量排序，名称避开已有的角色",
+			model: RBACModel{
+				Roles:           []ModelRole{{Name: "mined-role-1"}},
+				UserPermissions: grants(grant(1, "a"), grant(2, "a"), grant(3, "x", "y", "z"), grant(4, "x", "y", "z")),
+			},
+			want: []RoleSuggestion{
+				{Name: "mined-role-1-2", Permissions: []string{"doc:/doc#x", "doc:/doc#y", "doc:/doc#z"}, Members: []int64{3, 4}},
+				{Name: "mined-role-2", Permissions: []string{"doc:/doc#a"}, Members: []int64{1, 2}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.model.MineRoles(tc.opts, at)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("MineRoles() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRoleSuggestionKey(t *testing.T) {
+	s := RoleSuggestion{Name: "mined-role-1", Permissions: []string{"doc:/doc#a"}, Members: []int64{1, 2}}
+	renamed := s
+	renamed.Name = "reader"
+	if s.Key() != renamed.Key() {
+		t.Fatal("修改名称之后 Key 不应该变化")
+	}
+	changed := s
+	changed.Members = []int64{1, 2, 3}
+	if s.Key() == changed.Key() {
+		t.Fatal("成员变化之后 Key 应该变化")
+	}
+}
@@ -0,0 +1,86 @@
+package domain
+
+import "errors"
+
+var (
+	ErrScheduledChangeNotPending = errors.New("定时变更已经开始执行或者已经结束")
+	// ErrScheduledChangeNeedsApproval 执行时授权需要审批，重试也不会成功
+	ErrScheduledChangeNeedsApproval = errors.New("该授权需要审批，不能定时执行")
+	// ErrScheduledChangeForbidden 执行时创建人已经没有对应的权限，重试也不会成功
+	ErrScheduledChangeForbidden = errors.New("定时变更的创建人已经没有权限")
+)
+
+// ScheduledChangeKind 定时变更的类型
+type ScheduledChangeKind string
+
+const (
+	ScheduledGrantUserRole        ScheduledChangeKind = "grant_user_role"
+	ScheduledRevokeUserRole       ScheduledChangeKind = "revoke_user_role"
+	ScheduledGrantUserPermission  ScheduledChangeKind = "grant_user_permission"
+	ScheduledRevokeUserPermission ScheduledChangeKind = "revoke_user_permission"
+	ScheduledGrantRolePermission  ScheduledChangeKind = "grant_role_permission"
+	ScheduledRevokeRolePermission ScheduledChangeKind = "revoke_role_permission"
+)
+
+func (k ScheduledChangeKind) String() string {
+	return string(k)
+}
+
+// Table 变更影响的表，创建定时变更时需要这张表的写权限
+func (k ScheduledChangeKind) Table() SystemTableResource {
+	switch k {
+	case ScheduledGrantUserRole, ScheduledRevokeUserRole:
+		return UserRoleTable
+	case ScheduledGrantUserPermission, ScheduledRevokeUserPermission:
+		return UserPermissionTable
+	case ScheduledGrantRolePermission, ScheduledRevokeRolePermission:
+		return RolePermissionTable
+	default:
+		return ""
+	}
+}
+
+type ScheduledChangeStatus string
+
+const (
+	ScheduledChangePending   ScheduledChangeStatus = "pending"
+	ScheduledChangeExecuted  ScheduledChangeStatus = "executed"
+	ScheduledChangeFailed    ScheduledChangeStatus = "failed"
+	ScheduledChangeCancelled ScheduledChangeStatus = "cancelled"
+)
+
+func (s ScheduledChangeStatus) String() string {
+	return string(s)
+}
+
+// ScheduledChange 在指定时间执行的授权变更
+type ScheduledChange struct {
+	ID    int64
+	BizID int64
+	Kind  ScheduledChangeKind
+	// TargetID 授予时是角色ID或者权限ID，回收时是被回收的授权ID
+	TargetID     int64
+	TargetUserID int64
+	// Payload 执行时使用的请求，JSON 格式
+	Payload    string
+	Reason     string
+	CreatorUID int64
+	ExecuteAt  int64
+	Status     ScheduledChangeStatus
+	Attempts   int
+	// Result 执行成功时是执行结果，失败时是最后一次的错误
+	Result     string
+	ExecutedAt int64
+	Ctime      int64
+	Utime      int64
+}
+
+func (c ScheduledChange) Validate(now int64) error {
+	if c.ExecuteAt <= now {
+		return errors.New("执行时间必须晚于当前时间")
+	}
+	if c.Kind.Table() == "" {
+		return errors.New("未知的定时变更类型")
+	}
+	return nil
+}
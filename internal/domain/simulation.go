@@ -0,0 +1,223 @@
+package domain
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidModelMutation = errors.New("模拟的变更不合法")
+
+// ModelMutationOp 模拟时支持的变更
+type ModelMutationOp string
+
+const (
+	MutationGrantRolePermission  ModelMutationOp = "grant_role_permission"
+	MutationRevokeRolePermission ModelMutationOp = "revoke_role_permission"
+	MutationAddRoleInclusion     ModelMutationOp = "add_role_inclusion"
+	MutationRemoveRoleInclusion  ModelMutationOp = "remove_role_inclusion"
+	MutationGrantUserRole        ModelMutationOp = "grant_user_role"
+	MutationRevokeUserRole       ModelMutationOp = "revoke_user_role"
+)
+
+func (o ModelMutationOp) String() string {
+	return string(o)
+}
+
+// ModelMutation 一个假设的变更，和模型一样用角色名、资源和动作引用，不同的 Op 使用不同的字段
+type ModelMutation struct {
+	Op ModelMutationOp
+	// Role 角色权限和用户角色的角色，包含关系中包含其他角色的角色
+	Role string
+	// IncludedRole 包含关系中被包含的角色
+	IncludedRole string
+	UserID       int64
+	ResourceType string
+	ResourceKey  string
+	Action       string
+	StartTime    int64
+	EndTime      int64
+}
+
+// ResourceImpact 用户在一个资源上得到和失去的动作
+type ResourceImpact struct {
+	ResourceType string
+	ResourceKey  string
+	Gained       []string
+	Lost         []string
+}
+
+// UserImpact 一个用户有效权限的变化，按照资源排序
+type UserImpact struct {
+	UserID    int64
+	Resources []ResourceImpact
+}
+
+// Apply 在模型的副本上依次执行变更，引用不存在的角色或者权限、撤销不存在的授权、包含关系形成环都返回错误
+func (m RBACModel) Apply(mutations []ModelMutation) (RBACModel, error) {
+	res := m
+	res.RoleInclusions = slices.Clone(m.RoleInclusions)
+	res.RolePermissions = slices.Clone(m.RolePermissions)
+	res.UserRoles = slices.Clone(m.UserRoles)
+	roles := indexModel(m.Roles, ModelRole.Ref)
+	permissions := indexModel(m.Permissions, ModelPermission.Ref)
+	for i, mu := range mutations {
+		if err := res.apply(mu, roles, permissions); err != nil {
+			return RBACModel{}, fmt.Errorf("%w: 第 %d 个变更 %s: %w", ErrInvalidModelMutation, i+1, mu.Op, err)
+		}
+	}
+	return res, nil
+}
+
+func (m *RBACModel) apply(mu ModelMutation, roles map[string]ModelRole, permissions map[string]ModelPermission) error {
+	if _, ok := roles[mu.Role]; !ok {
+		return fmt.Errorf("角色 %s 不存在", mu.Role)
+	}
+	switch mu.Op {
+	case MutationGrantRolePermission, MutationRevokeRolePermission:
+		rp := ModelRolePermission{Role: mu.Role, ResourceType: mu.ResourceType, ResourceKey: mu.ResourceKey, Action: mu.Action}
+		idx := slices.IndexFunc(m.RolePermissions, func(r ModelRolePermission) bool { return r.Ref() == rp.Ref() })
+		if mu.Op == MutationRevokeRolePermission {
+			if idx < 0 {
+				return fmt.Errorf("%s 不存在", rp.Ref())
+			}
+			m.RolePermissions = slices.Delete(m.RolePermissions, idx, idx+1)
+			return nil
+		}
+		if _, ok := permissions[rp.PermissionRef()]; !ok {
+			return fmt.Errorf("权限 %s 不存在", rp.PermissionRef())
+		}
+		if idx < 0 {
+			m.RolePermissions = append(m.RolePermissions, rp)
+		}
+	case MutationAddRoleInclusion, MutationRemoveRoleInclusion:
+		if _, ok := roles[mu.IncludedRole]; !ok {
+			return fmt.Errorf("角色 %s 不存在", mu.IncludedRole)
+		}
+		ri := ModelRoleInclusion{Including: mu.Role, Included: mu.IncludedRole}
+		if mu.Op == MutationAddRoleInclusion {
+			if err := CheckRoleInclusion(m.RoleInclusions, ri.Including, ri.Included, 0); err != nil {
+				return err
+			}
+			m.RoleInclusions = append(m.RoleInclusions, ri)
+			return nil
+		}
+		idx := slices.IndexFunc(m.RoleInclusions, func(r ModelRoleInclusion) bool { return r.Ref() == ri.Ref() })
+		if idx < 0 {
+			return fmt.Errorf("%s 不存在", ri.Ref())
+		}
+		m.RoleInclusions = slices.Delete(m.RoleInclusions, idx, idx+1)
+	case MutationGrantUserRole:
+		if mu.UserID <= 0 {
+			return errors.New("用户ID不合法")
+		}
+		m.UserRoles = append(m.UserRoles, ModelUserRole{UserID: mu.UserID, Role: mu.Role, StartTime: mu.StartTime, EndTime: mu.EndTime})
+	case MutationRevokeUserRole:
+		// 同一个用户可能有多个不同有效期的同名角色，全部撤销
+		n := len(m.UserRoles)
+		m.UserRoles = slices.DeleteFunc(m.UserRoles, func(r ModelUserRole) bool {
+			return r.UserID == mu.UserID && r.Role == mu.Role
+		})
+		if len(m.UserRoles) == n {
+			return fmt.Errorf("用户 %d 没有角色 %s", mu.UserID, mu.Role)
+		}
+	default:
+		return errors.New("不支持的变更")
+	}
+	return nil
+}
+
+// Simulate 计算执行变更前后每个受影响用户在 at 时刻的有效权限差异，不修改模型
+// 只有通过角色包含关系能到达被修改的角色的用户，以及被直接授予或者撤销角色的用户才会受影响
+func (m RBACModel) Simulate(mutations []ModelMutation, at int64) ([]UserImpact, error) {
+	after, err := m.Apply(mutations)
+	if err != nil {
+		return nil, err
+	}
+	touched := make(map[string]bool)
+	candidates := make(map[int64]bool)
+	for _, mu := range mutations {
+		touched[mu.Role] = true
+		if mu.Op == MutationGrantUserRole || mu.Op == MutationRevokeUserRole {
+			candidates[mu.UserID] = true
+		}
+	}
+	for _, model := range []RBACModel{m, after} {
+		includes := model.roleIncludes()
+		reaches := make(map[string]bool)
+		for _, ur := range model.UserRoles {
+			reach, ok := reaches[ur.Role]
+			if !ok {
+				reach = slices.ContainsFunc(includedRolePaths(includes, ur.Role), func(path []string) bool {
+					return touched[path[len(path)-1]]
+				})
+				reaches[ur.Role] = reach
+			}
+			if reach {
+				candidates[ur.UserID] = true
+			}
+		}
+	}
+
+	var res []UserImpact
+	for uid := range candidates {
+		if impact, ok := permissionImpact(uid, m.EffectivePermissions(uid, at), after.EffectivePermissions(uid, at)); ok {
+			res = append(res, impact)
+		}
+	}
+	slices.SortFunc(res, func(a, b UserImpact) int {
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+	return res, nil
+}
+
+// permissionImpact 对比前后实际允许的权限，deny 的权限不算拥有
+func permissionImpact(uid int64, before, after []EffectivePermission) (UserImpact, bool) {
+	allowed := func(perms []EffectivePermission) map[string]EffectivePermission {
+		res := make(map[string]EffectivePermission, len(perms))
+		for _, p := range perms {
+			if p.Effect.IsAllow() {
+				res[p.Ref()] = p
+			}
+		}
+		return res
+	}
+	beforeAllowed, afterAllowed := allowed(before), allowed(after)
+	byResource := make(map[string]*ResourceImpact)
+	resource := func(p EffectivePermission) *ResourceImpact {
+		ref := resourceRef(p.ResourceType, p.ResourceKey)
+		r, ok := byResource[ref]
+		if !ok {
+			r = &ResourceImpact{ResourceType: p.ResourceType, ResourceKey: p.ResourceKey}
+			byResource[ref] = r
+		}
+		return r
+	}
+	for ref, p := range afterAllowed {
+		if _, ok := beforeAllowed[ref]; !ok {
+			r := resource(p)
+			r.Gained = append(r.Gained, p.Action)
+		}
+	}
+	for ref, p := range beforeAllowed {
+		if _, ok := afterAllowed[ref]; !ok {
+			r := resource(p)
+			r.Lost = append(r.Lost, p.Action)
+		}
+	}
+	if len(byResource) == 0 {
+		return UserImpact{}, false
+	}
+	impact := UserImpact{UserID: uid, Resources: make([]ResourceImpact, 0, len(byResource))}
+	for _, r := range byResource {
+		slices.Sort(r.Gained)
+		slices.Sort(r.Lost)
+		impact.Resources = append(impact.Resources, *r)
+	}
+	slices.SortFunc(impact.Resources, func(a, b ResourceImpact) int {
+		return strings.Compare(resourceRef(a.ResourceType, a.ResourceKey), resourceRef(b.ResourceType, b.ResourceKey))
+	})
+	return impact, true
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	const at = int64(1000)
+	model := RBACModel{
+		Permissions: []ModelPermission{
+			{ResourceType: "order", ResourceKey: "/order", Action: "read"},
+			{ResourceType: "order", ResourceKey: "/order", Action: "write"},
+			{ResourceType: "report", ResourceKey: "/report", Action: "read"},
+		},
+		Roles: []ModelRole{{Name: "viewer"}, {Name: "editor"}, {Name: "analyst"}},
+		RoleInclusions: []ModelRoleInclusion{
+			{Including: "editor", Included: "viewer"},
+		},
+		RolePermissions: []ModelRolePermission{
+			{Role: "viewer", ResourceType: "order", ResourceKey: "/order", Action: "read"},
+			{Role: "editor", ResourceType: "order", ResourceKey: "/order", Action: "write"},
+			{Role: "analyst", ResourceType: "report", ResourceKey: "/report", Action: "read"},
+		},
+		UserRoles: []ModelUserRole{
+			{UserID: 1, Role: "viewer"},
+			{UserID: 2, Role: "editor"},
+			{UserID: 3, Role: "analyst"},
+		},
+	}
+	testCases := []struct {
+		name      string
+		mutations []ModelMutation
+		want      []UserImpact
+		wantErr   bool
+	}{
+		{
+			name: "回收被包含角色的权限影响所有间接拥有它的用户",
+			mutations: []ModelMutation{
+				{Op: MutationRevokeRolePermission, Role: "viewer", ResourceType: "order", ResourceKey: "/order", Action: "read"},
+			},
+			want: []UserImpact{
+				{UserID: 1, Resources: []ResourceImpact{{ResourceType: "order", ResourceKey: "/order", Lost: []string{"read"}}}},
+				{UserID: 2, Resources: []ResourceImpact{{ResourceType: "order", ResourceKey: "/order", Lost: []string{"read"}}}},
+			},
+		},
+		{
+			name: "新增包含关系",
+			mutations: []ModelMutation{
+				{Op: MutationAddRoleInclusion, Role: "analyst", IncludedRole: "viewer"},
+			},
+			want: []UserImpact{
+				{UserID: 3, Resources: []ResourceImpact{{ResourceType: "order", ResourceKey: "/order", Gained: []string{"read"}}}},
+			},
+		},
+		{
+			name: "授予已经间接拥有的权限没有影响",
+			mutations: []ModelMutation{
+				{Op: MutationGrantUserRole, Role: "viewer", UserID: 2},
+			},
+		},
+		{
+			name: "撤销用户角色",
+			mutations: []ModelMutation{
+				{Op: MutationRevokeUserRole, Role: "analyst", UserID: 3},
+			},
+			want: []UserImpact{
+				{UserID: 3, Resources: []ResourceImpact{{ResourceType: "report", ResourceKey: "/report", Lost: []string{"read"}}}},
+			},
+		},
+		{
+			name: "包含关系形成环",
+			mutations: []ModelMutation{
+				{Op: MutationAddRoleInclusion, Role: "viewer", IncludedRole: "editor"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "授予不存在的权限",
+			mutations: []ModelMutation{
+				{Op: MutationGrantRolePermission, Role: "viewer", ResourceType: "order", ResourceKey: "/order", Action: "delete"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "撤销用户没有的角色",
+			mutations: []ModelMutation{
+				{Op: MutationRevokeUserRole, Role: "editor", UserID: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := model.Simulate(tc.mutations, at)
+			if tc.wantErr {
+				if !errors.Is(err, ErrInvalidModelMutation) {
+					t.Fatalf("Simulate() error = %v, want ErrInvalidModelMutation", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Simulate() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+	// 模拟不能修改原来的模型
+	if len(model.RolePermissions) != 3 || len(model.RoleInclusions) != 1 || len(model.UserRoles) != 3 {
+		t.Fatalf("Simulate 修改了原来的模型: %+v", model)
+	}
+}
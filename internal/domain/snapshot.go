@@ -0,0 +1,68 @@
+package domain
+
+import "time"
+
+// SnapshotTrigger 快照的创建方式
+type SnapshotTrigger string
+
+const (
+	SnapshotManual    SnapshotTrigger = "manual"
+	SnapshotScheduled SnapshotTrigger = "scheduled"
+	// SnapshotPreRollback 回滚前自动保存的当前状态，回滚错了可以再回滚到这个快照
+	SnapshotPreRollback SnapshotTrigger = "pre_rollback"
+)
+
+func (t SnapshotTrigger) String() string {
+	return string(t)
+}
+
+// Snapshot 业务在某个时间点完整的权限模型，包括用户授权
+type Snapshot struct {
+	ID         int64
+	BizID      int64
+	Trigger    SnapshotTrigger
+	CreatorUID int64 // 定时创建时为0
+	Note       string
+	// Counts 各张表的对象数量，查询列表时不加载完整的模型
+	Counts map[SystemTableResource]int
+	// Size 压缩后的字节数
+	Size  int
+	Ctime int64 // 毫秒
+	Model RBACModel
+}
+
+// Counts 统计模型中各张表的对象数量
+func (m RBACModel) Counts() map[SystemTableResource]int {
+	return map[SystemTableResource]int{
+		ResourceTable:       len(m.Resources),
+		PermissionTable:     len(m.Permissions),
+		RoleTable:           len(m.Roles),
+		RoleInclusionTable:  len(m.RoleInclusions),
+		RolePermissionTable: len(m.RolePermissions),
+		UserRoleTable:       len(m.UserRoles),
+		UserPermissionTable: len(m.UserPermissions),
+	}
+}
+
+// SnapshotRetention 快照的保留策略，两个条件都是 0 时不清理
+type SnapshotRetention struct {
+	// MaxCount 每个业务最多保留的快照数量
+	MaxCount int
+	// MaxAge 快照最长的保留时间
+	MaxAge time.Duration
+}
+
+// Expired 返回超出保留策略的快照ID，snapshots 按照创建时间倒序排列
+// 最新的快照总是保留，长时间没有变化的业务也至少有一个可以回滚的快照
+func (r SnapshotRetention) Expired(snapshots []Snapshot, now int64) []int64 {
+	var res []int64
+	for i, s := range snapshots {
+		if i == 0 {
+			continue
+		}
+		if (r.MaxCount > 0 && i >= r.MaxCount) || (r.MaxAge > 0 && s.Ctime < now-r.MaxAge.Milliseconds()) {
+			res = append(res, s.ID)
+		}
+	}
+	return res
+}
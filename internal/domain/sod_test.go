@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSoDConstraintValidate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		constraint SoDConstraint
+		wantErr    bool
+	}{
+		{name: "角色互斥", constraint: SoDConstraint{Name: "付款", Kind: SoDRole, Members: []string{"payer", "approver"}}},
+		{name: "没有名称", constraint: SoDConstraint{Kind: SoDRole, Members: []string{"payer", "approver"}}, wantErr: true},
+		{name: "未知类型", constraint: SoDConstraint{Name: "付款", Kind: "user", Members: []string{"payer", "approver"}}, wantErr: true},
+		{name: "去重之后只有一个", constraint: SoDConstraint{Name: "付款", Kind: SoDRole, Members: []string{"payer", "payer"}}, wantErr: true},
+		{name: "有空的对象", constraint: SoDConstraint{Name: "付款", Kind: SoDRole, Members: []string{"payer", "", "approver"}}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.constraint.Validate(); (err != nil) != tc.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSoDViolations(t *testing.T) {
+	const at = int64(1000)
+	roles := SoDConstraint{ID: 1, Name: "付款", Kind: SoDRole, Members: []string{"payer", "approver"}}
+	permissions := SoDConstraint{ID: 2, Name: "记账", Kind: SoDPermission, Members: []string{"ledger:/books#write", "ledger:/books#audit"}}
+	writeBooks := ModelRolePermission{Role: "clerk", ResourceType: "ledger", ResourceKey: "/books", Action: "write"}
+	auditBooks := ModelUserPermission{UserID: 1, ResourceType: "ledger", ResourceKey: "/books", Action: "audit", Effect: EffectAllow}
+	testCases := []struct {
+		name  string
+		model RBACModel
+		// want 违反约束的 约束ID/用户ID
+		want [][2]int64
+	}{
+		{
+			name: "直接拥有两个互斥角色",
+			model: RBACModel{UserRoles: []ModelUserRole{
+				{UserID: 1, Role: "payer"}, {UserID: 1, Role: "approver"}, {UserID: 2, Role: "payer"},
+			}},
+			want: [][2]int64{{1, 1}},
+		},
+		{
+			name: "通过角色包含间接拥有",
+			model: RBACModel{
+				RoleInclusions: []ModelRoleInclusion{{Including: "manager", Included: "approver"}},
+				UserRoles:      []ModelUserRole{{UserID: 1, Role: "payer"}, {UserID: 1, Role: "manager"}},
+			},
+			want: [][2]int64{{1, 1}},
+		},
+		{
+			name: "过期的授权不算",
+			model: RBACModel{UserRoles: []ModelUserRole{
+				{UserID: 1, Role: "payer"}, {UserID: 1, Role: "approver", EndTime: at},
+			}},
+		},
+		{
+			name: "还没有生效的授权也算",
+			model: RBACModel{UserRoles: []ModelUserRole{
+				{UserID: 1, Role: "payer"}, {UserID: 1, Role: "approver", StartTime: at + 1},
+			}},
+			want: [][2]int64{{1, 1}},
+		},
+		{
+			name: "通过角色获得的权限和直接授予的权限互斥",
+			model: RBACModel{
+				RolePermissions: []ModelRolePermission{writeBooks},
+				UserRoles:       []ModelUserRole{{UserID: 1, Role: "clerk"}},
+				UserPermissions: []ModelUserPermission{auditBooks},
+			},
+			want: [][2]int64{{2, 1}},
+		},
+		{
+			name: "直接 deny 的权限不算",
+			model: RBACModel{
+				RolePermissions: []ModelRolePermission{writeBooks},
+				UserRoles:       []ModelUserRole{{UserID: 1, Role: "clerk"}},
+				UserPermissions: []ModelUserPermission{
+					auditBooks,
+					{UserID: 1, ResourceType: "ledger", ResourceKey: "/books", Action: "write", Effect: EffectDeny},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got [][2]int64
+			for _, v := range tc.model.SoDViolations([]SoDConstraint{roles, permissions}, at) {
+				got = append(got, [2]int64{v.ConstraintID, v.UserID})
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("SoDViolations() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewSoDViolations(t *testing.T) {
+	constraints := []SoDConstraint{{ID: 1, Name: "付款", Kind: SoDRole, Members: []string{"payer", "approver", "auditor"}}}
+	before := RBACModel{UserRoles: []ModelUserRole{
+		{UserID: 1, Role: "payer"}, {UserID: 1, Role: "approver"}, {UserID: 2, Role: "payer"},
+	}}
+	testCases := []struct {
+		name  string
+		grant ModelUserRole
+		want  []int64
+	}{
+		{name: "已经存在的违反不阻止无关的授权", grant: ModelUserRole{UserID: 1, Role: "viewer"}},
+		{name: "新出现的违反", grant: ModelUserRole{UserID: 2, Role: "approver"}, want: []int64{2}},
+		// 拥有的对象变多也是新的违反
+		{name: "已经违反的用户又多了一个互斥角色", grant: ModelUserRole{UserID: 1, Role: "auditor"}, want: []int64{1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			after := before
+			after.UserRoles = append(slices.Clone(before.UserRoles), tc.grant)
+			var got []int64
+			for _, v := range NewSoDViolations(constraints, before, after, 0) {
+				got = append(got, v.UserID)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("NewSoDViolations() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
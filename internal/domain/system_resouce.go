@@ -13,8 +13,55 @@ const (
 	RolePermissionTable SystemTableResource = "role_permissions"
 	UserRoleTable       SystemTableResource = "user_roles"
 	UserPermissionTable SystemTableResource = "user_permissions"
+	// AuditLogTable 审计日志，查询需要单独授权
+	AuditLogTable SystemTableResource = "audit_logs"
+	// ApprovalTable 审批策略和变更请求
+	ApprovalTable SystemTableResource = "approvals"
+	// SoDTable 职责分离约束
+	SoDTable SystemTableResource = "sod_constraints"
+	// ScheduleTable 定时变更，创建和修改还需要变更所影响的表的写权限
+	ScheduleTable SystemTableResource = "scheduled_changes"
+	// ReviewTable 权限审查，审查人确认和回收自己的审查项不需要这个权限
+	ReviewTable SystemTableResource = "access_reviews"
 )
 
+// BusinessTables 业务管理员可以管理的系统表
+var BusinessTables = []SystemTableResource{
+	ResourceTable,
+	PermissionTable,
+	RoleTable,
+	RoleInclusionTable,
+	RolePermissionTable,
+	UserRoleTable,
+	UserPermissionTable,
+}
+
+// InitialBusinessTables 接入业务时作为业务内部资源初始化的系统表，业务管理员拥有它们的读写权限
+// 新增的系统表也要加到这里，已经接入的业务通过 /admin/biz/backfill 补齐
+var InitialBusinessTables = []SystemTableResource{
+	ResourceTable,
+	PermissionTable,
+	RoleTable,
+	RoleInclusionTable,
+	RolePermissionTable,
+	UserRoleTable,
+	UserPermissionTable,
+	AuditLogTable,
+	ApprovalTable,
+	SoDTable,
+	ScheduleTable,
+	ReviewTable,
+}
+
+// SystemTableBackfill 为已经接入的业务补齐系统表的结果
+type SystemTableBackfill struct {
+	BizID int64
+	// Resources Permissions RolePermissions 新创建的资源、权限和授予业务管理员的权限
+	Resources       []string
+	Permissions     []string
+	RolePermissions []string
+}
+
 func (rk SystemTableResource) Type() string {
 	return "system_table"
 }
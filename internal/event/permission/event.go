@@ -0,0 +1,30 @@
+package permission
+
+import "encoding/json"
+
+type ChangeAction string
+
+const (
+	ChangeActionCreate ChangeAction = "create"
+	ChangeActionUpdate ChangeAction = "update"
+	ChangeActionDelete ChangeAction = "delete"
+	ChangeActionGrant  ChangeAction = "grant"
+	ChangeActionRevoke ChangeAction = "revoke"
+)
+
+func (a ChangeAction) String() string {
+	return string(a)
+}
+
+// ChangeEvent 权限变更事件，一个业务内的全部变更按照发生顺序写入同一个 Redis Stream
+type ChangeEvent struct {
+	// ID 由 Redis Stream 生成，同时作为 SSE 的 id 字段，用于断线续传
+	ID    string `json:"id"`
+	BizID int64  `json:"bizId"`
+	// Table 受影响的系统表，即 domain.SystemTableResource
+	Table  string       `json:"table"`
+	Action ChangeAction `json:"action"`
+	// Data 变更后的实体，删除和撤销时只包含 ID
+	Data  json.RawMessage `json:"data"`
+	Ctime int64           `json:"ctime"`
+}
@@ -0,0 +1,140 @@
+package permission
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fieldEvent = "event"
+	// emptyStreamID 空的 Stream 中从头开始读取
+	emptyStreamID = "0-0"
+)
+
+// Stream 基于 Redis Stream 的权限变更事件流
+// 每个业务一个 Stream，通过 MAXLEN 限制长度，作为断线续传的有界重放缓冲区
+type Stream struct {
+	client redis.Cmdable
+	// maxLen 每个业务最多保留的事件数量
+	maxLen int64
+	logger *elog.Component
+}
+
+func NewStream(client redis.Cmdable, maxLen int64) *Stream {
+	return &Stream{client: client, maxLen: maxLen, logger: elog.DefaultLogger}
+}
+
+func (s *Stream) Publish(ctx context.Context, evt ChangeEvent) error {
+	if evt.Ctime == 0 {
+		evt.Ctime = time.Now().UnixMilli()
+	}
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("序列化权限变更事件失败: %w", err)
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.key(evt.BizID),
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]any{fieldEvent: string(data)},
+	}).Err()
+}
+
+// LatestID 返回当前最新事件的 ID，用于新连接从“现在”开始订阅
+func (s *Stream) LatestID(ctx context.Context, bizID int64) (string, error) {
+	msgs, err := s.client.XRevRangeN(ctx, s.key(bizID), "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return emptyStreamID, nil
+	}
+	return msgs[0].ID, nil
+}
+
+// Truncated 判断 lastID 之后的事件是否已经被裁剪出重放缓冲区
+// 返回 true 时客户端无法完整续传，需要全量刷新
+func (s *Stream) Truncated(ctx context.Context, bizID int64, lastID string) (bool, error) {
+	msgs, err := s.client.XRangeN(ctx, s.key(bizID), "-", "+", 1).Result()
+	if err != nil {
+		return false, err
+	}
+	if len(msgs) == 0 {
+		return false, nil
+	}
+	return compareID(lastID, msgs[0].ID) < 0, nil
+}
+
+// Read 阻塞读取 lastID 之后的事件，超时返回空切片
+// 格式错误的事件记录日志后跳过，不能让一条坏数据中断整个业务的订阅，
+// 所以同时返回读到的最后一条消息的 ID，调用方从这里继续读取
+func (s *Stream) Read(ctx context.Context, bizID int64, lastID string, count int64, block time.Duration) ([]ChangeEvent, string, error) {
+	streams, err := s.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{s.key(bizID), lastID},
+		Count:   count,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, lastID, nil
+	}
+	if err != nil {
+		return nil, lastID, err
+	}
+	var events []ChangeEvent
+	for i := range streams {
+		for _, msg := range streams[i].Messages {
+			lastID = msg.ID
+			evt, err := s.toEvent(msg)
+			if err != nil {
+				s.logger.Error("跳过格式错误的权限变更事件", elog.FieldErr(err), elog.Int64("bizId", bizID), elog.String("id", msg.ID))
+				continue
+			}
+			events = append(events, evt)
+		}
+	}
+	return events, lastID, nil
+}
+
+func (s *Stream) toEvent(msg redis.XMessage) (ChangeEvent, error) {
+	var evt ChangeEvent
+	val, ok := msg.Values[fieldEvent].(string)
+	if !ok {
+		return evt, fmt.Errorf("权限变更事件格式错误: id=%s", msg.ID)
+	}
+	err := json.Unmarshal([]byte(val), &evt)
+	if err != nil {
+		return evt, fmt.Errorf("反序列化权限变更事件失败: %w", err)
+	}
+	evt.ID = msg.ID
+	return evt, nil
+}
+
+func (s *Stream) key(bizID int64) string {
+	return fmt.Sprintf("permission:changes:%d", bizID)
+}
+
+// compareID 比较两个 Stream ID 的先后，格式为 <毫秒时间戳>-<序号>
+func compareID(a, b string) int {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	if am != bm {
+		return cmp.Compare(am, bm)
+	}
+	return cmp.Compare(as, bs)
+}
+
+func splitID(id string) (ms, seq uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(msPart, 10, 64)
+	seq, _ = strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
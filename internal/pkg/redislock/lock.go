@@ -0,0 +1,77 @@
+package redislock
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrLockNotHeld = errors.New("没有持有锁")
+
+	//go:embed lua/unlock.lua
+	luaUnlock string
+	//go:embed lua/refresh.lua
+	luaRefresh string
+
+	unlockScript  = redis.NewScript(luaUnlock)
+	refreshScript = redis.NewScript(luaRefresh)
+)
+
+// Lock 基于 Redis SET NX 的租约锁，实现了 ecron.Lock
+// 每个实例使用唯一的持有人标识，只能释放和续约自己持有的锁
+type Lock struct {
+	client redis.Cmdable
+	key    string
+	value  string
+}
+
+func New(client redis.Cmdable, key string) *Lock {
+	host, _ := os.Hostname()
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return &Lock{client: client, key: key, value: fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(buf))}
+}
+
+func (l *Lock) Lock(ctx context.Context, ttl time.Duration) error {
+	ok, err := l.client.SetNX(ctx, l.key, l.value, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 持有人标识包含进程号和随机数，只有同一个实例上一次没有释放锁时（例如解锁失败）才能续约成功
+		if err = l.Refresh(ctx, ttl); err != nil {
+			return fmt.Errorf("锁 %s 被其他实例持有", l.key)
+		}
+	}
+	return nil
+}
+
+func (l *Lock) Unlock(ctx context.Context) error {
+	res, err := unlockScript.Run(ctx, l.client, []string{l.key}, l.value).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (l *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	res, err := refreshScript.Run(ctx, l.client, []string{l.key}, l.value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
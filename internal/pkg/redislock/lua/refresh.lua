@@ -0,0 +1,6 @@
+-- 只续约自己持有的锁
+-- KEYS[1] 锁，ARGV[1] 持有人标识，ARGV[2] 租期，毫秒
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
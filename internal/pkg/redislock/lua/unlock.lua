@@ -0,0 +1,6 @@
+-- 只释放自己持有的锁
+-- KEYS[1] 锁，ARGV[1] 持有人标识
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0
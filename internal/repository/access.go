@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrAccessPolicyNotFound = errors.New("该角色不允许自助申请")
+
+type AccessPolicyRepository interface {
+	Save(ctx context.Context, policy domain.AccessPolicy) error
+	Delete(ctx context.Context, bizID, roleID int64) error
+	Get(ctx context.Context, bizID, roleID int64) (domain.AccessPolicy, error)
+	List(ctx context.Context, bizID int64) ([]domain.AccessPolicy, error)
+}
+
+// RedisAccessPolicyRepository 每个业务的自助申请策略保存在一个 Hash 中，字段为角色ID
+type RedisAccessPolicyRepository struct {
+	client redis.Cmdable
+}
+
+func NewRedisAccessPolicyRepository(client redis.Cmdable) AccessPolicyRepository {
+	return &RedisAccessPolicyRepository{client: client}
+}
+
+func (r *RedisAccessPolicyRepository) Save(ctx context.Context, policy domain.AccessPolicy) error {
+	val, err := json.Marshal(AccessPolicyEntity{
+		RoleID:      policy.RoleID,
+		Owners:      policy.Owners,
+		MaxDuration: policy.MaxDuration,
+		Ctime:       policy.Ctime,
+		Utime:       policy.Utime,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化自助申请策略失败: %w", err)
+	}
+	return r.client.HSet(ctx, r.key(policy.BizID), strconv.FormatInt(policy.RoleID, 10), string(val)).Err()
+}
+
+func (r *RedisAccessPolicyRepository) Delete(ctx context.Context, bizID, roleID int64) error {
+	return r.client.HDel(ctx, r.key(bizID), strconv.FormatInt(roleID, 10)).Err()
+}
+
+func (r *RedisAccessPolicyRepository) Get(ctx context.Context, bizID, roleID int64) (domain.AccessPolicy, error) {
+	val, err := r.client.HGet(ctx, r.key(bizID), strconv.FormatInt(roleID, 10)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.AccessPolicy{}, ErrAccessPolicyNotFound
+	}
+	if err != nil {
+		return domain.AccessPolicy{}, err
+	}
+	return r.toDomain(bizID, val)
+}
+
+func (r *RedisAccessPolicyRepository) List(ctx context.Context, bizID int64) ([]domain.AccessPolicy, error) {
+	vals, err := r.client.HVals(ctx, r.key(bizID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AccessPolicy, 0, len(vals))
+	for i := range vals {
+		policy, err1 := r.toDomain(bizID, vals[i])
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, policy)
+	}
+	return res, nil
+}
+
+func (r *RedisAccessPolicyRepository) key(bizID int64) string {
+	return fmt.Sprintf("access:policies:%d", bizID)
+}
+
+func (r *RedisAccessPolicyRepository) toDomain(bizID int64, val string) (domain.AccessPolicy, error) {
+	var entity AccessPolicyEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.AccessPolicy{}, fmt.Errorf("反序列化自助申请策略失败: %w", err)
+	}
+	return domain.AccessPolicy{
+		BizID:       bizID,
+		RoleID:      entity.RoleID,
+		Owners:      entity.Owners,
+		MaxDuration: entity.MaxDuration,
+		Ctime:       entity.Ctime,
+		Utime:       entity.Utime,
+	}, nil
+}
+
+type AccessPolicyEntity struct {
+	RoleID      int64   `json:"roleId"`
+	Owners      []int64 `json:"owners"`
+	MaxDuration int64   `json:"maxDuration"`
+	Ctime       int64   `json:"ctime"`
+	Utime       int64   `json:"utime"`
+}
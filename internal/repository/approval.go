@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fieldChangeRequestVersion = "version"
+	fieldChangeRequestData    = "data"
+	changeRequestScanBatch    = 100
+)
+
+var (
+	ErrApprovalPolicyNotFound = errors.New("审批策略不存在")
+	ErrChangeRequestNotFound  = errors.New("变更请求不存在")
+	// ErrChangeRequestConflict 变更请求已经被其他请求修改，需要重新读取后再修改
+	ErrChangeRequestConflict = errors.New("变更请求并发修改冲突")
+)
+
+var (
+	//go:embed lua/approval_update.lua
+	luaApprovalUpdate string
+	//go:embed lua/approval_claim.lua
+	luaApprovalClaim string
+)
+
+type ApprovalRepository interface {
+	CreatePolicy(ctx context.Context, policy domain.ApprovalPolicy) (domain.ApprovalPolicy, error)
+	UpdatePolicy(ctx context.Context, policy domain.ApprovalPolicy) error
+	DeletePolicy(ctx context.Context, bizID, id int64) error
+	ListPolicies(ctx context.Context, bizID int64) ([]domain.ApprovalPolicy, error)
+
+	CreateChangeRequest(ctx context.Context, req domain.ChangeRequest) (domain.ChangeRequest, error)
+	GetChangeRequest(ctx context.Context, id int64) (domain.ChangeRequest, error)
+	// UpdateChangeRequest req.Version 必须等于存储中的版本号，否则返回 ErrChangeRequestConflict
+	UpdateChangeRequest(ctx context.Context, req domain.ChangeRequest) (domain.ChangeRequest, error)
+	// ListChangeRequests 按照创建时间倒序查询
+	ListChangeRequests(ctx context.Context, query domain.ChangeRequestQuery) ([]domain.ChangeRequest, error)
+	// ListExpiredChangeRequests 按照过期时间返回早于 now 并且仍然待审批的变更请求ID，offset 用来跳过处理失败的
+	ListExpiredChangeRequests(ctx context.Context, now int64, offset, limit int64) ([]int64, error)
+	// ListApprovedChangeRequests 返回审批通过、还没有执行并且没有被认领的变更请求ID
+	ListApprovedChangeRequests(ctx context.Context, now int64, limit int64) ([]int64, error)
+	// ClaimChangeRequest 认领一个审批通过的变更请求执行授权，认领成功后在 lease 时间内其他调用方都无法认领
+	ClaimChangeRequest(ctx context.Context, id, now int64, lease time.Duration) (bool, error)
+}
+
+// RedisApprovalRepository 审批策略和变更请求保存在 Redis 中
+// 每个变更请求是一个带版本号的 Hash，另外按照业务、过期时间和执行时间分别建立有序集合索引
+type RedisApprovalRepository struct {
+	client redis.Cmdable
+	update *redis.Script
+	claim  *redis.Script
+}
+
+func NewRedisApprovalRepository(client redis.Cmdable) ApprovalRepository {
+	return &RedisApprovalRepository{
+		client: client,
+		update: redis.NewScript(luaApprovalUpdate),
+		claim:  redis.NewScript(luaApprovalClaim),
+	}
+}
+
+func (r *RedisApprovalRepository) CreatePolicy(ctx context.Context, policy domain.ApprovalPolicy) (domain.ApprovalPolicy, error) {
+	id, err := r.client.Incr(ctx, "approval:policy:id").Result()
+	if err != nil {
+		return domain.ApprovalPolicy{}, err
+	}
+	policy.ID = id
+	return policy, r.savePolicy(ctx, policy)
+}
+
+func (r *RedisApprovalRepository) UpdatePolicy(ctx context.Context, policy domain.ApprovalPolicy) error {
+	ok, err := r.client.HExists(ctx, r.policyKey(policy.BizID), strconv.FormatInt(policy.ID, 10)).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrApprovalPolicyNotFound
+	}
+	return r.savePolicy(ctx, policy)
+}
+
+func (r *RedisApprovalRepository) savePolicy(ctx context.Context, policy domain.ApprovalPolicy) error {
+	val, err := json.Marshal(ApprovalPolicyEntity{
+		ID:                policy.ID,
+		BizID:             policy.BizID,
+		Name:              policy.Name,
+		RoleIDs:           policy.RoleIDs,
+		PermissionIDs:     policy.PermissionIDs,
+		Approvers:         policy.Approvers,
+		RequiredApprovals: policy.RequiredApprovals,
+		TTL:               policy.TTL,
+		Ctime:             policy.Ctime,
+		Utime:             policy.Utime,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化审批策略失败: %w", err)
+	}
+	return r.client.HSet(ctx, r.policyKey(policy.BizID), strconv.FormatInt(policy.ID, 10), string(val)).Err()
+}
+
+func (r *RedisApprovalRepository) DeletePolicy(ctx context.Context, bizID, id int64) error {
+	return r.client.HDel(ctx, r.policyKey(bizID), strconv.FormatInt(id, 10)).Err()
+}
+
+func (r *RedisApprovalRepository) ListPolicies(ctx context.Context, bizID int64) ([]domain.ApprovalPolicy, error) {
+	vals, err := r.client.HVals(ctx, r.policyKey(bizID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ApprovalPolicy, 0, len(vals))
+	for i := range vals {
+		var entity ApprovalPolicyEntity
+		if err = json.Unmarshal([]byte(vals[i]), &entity); err != nil {
+			return nil, fmt.Errorf("反序列化审批策略失败: %w", err)
+		}
+		res = append(res, domain.ApprovalPolicy{
+			ID:                entity.ID,
+			BizID:             entity.BizID,
+			Name:              entity.Name,
+			RoleIDs:           entity.RoleIDs,
+			PermissionIDs:     entity.PermissionIDs,
+			Approvers:         entity.Approvers,
+			RequiredApprovals: entity.RequiredApprovals,
+			TTL:               entity.TTL,
+			Ctime:             entity.Ctime,
+			Utime:             entity.Utime,
+		})
+	}
+	return res, nil
+}
+
+func (r *RedisApprovalRepository) CreateChangeRequest(ctx context.Context, req domain.ChangeRequest) (domain.ChangeRequest, error) {
+	id, err := r.client.Incr(ctx, "approval:request:id").Result()
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	req.ID = id
+	req.Version = 1
+	val, err := json.Marshal(r.toEntity(req))
+	if err != nil {
+		return domain.ChangeRequest{}, fmt.Errorf("序列化变更请求失败: %w", err)
+	}
+	member := strconv.FormatInt(id, 10)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.key(id), fieldChangeRequestVersion, req.Version, fieldChangeRequestData, string(val))
+		pipe.ZAdd(ctx, r.bizKey(req.BizID), redis.Z{Score: float64(req.Ctime), Member: member})
+		pipe.ZAdd(ctx, r.pendingKey(), redis.Z{Score: float64(req.ExpireAt), Member: member})
+		return nil
+	})
+	return req, err
+}
+
+func (r *RedisApprovalRepository) GetChangeRequest(ctx context.Context, id int64) (domain.ChangeRequest, error) {
+	vals, err := r.client.HMGet(ctx, r.key(id), fieldChangeRequestVersion, fieldChangeRequestData).Result()
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	return r.toDomain(id, vals)
+}
+
+func (r *RedisApprovalRepository) UpdateChangeRequest(ctx context.Context, req domain.ChangeRequest) (domain.ChangeRequest, error) {
+	val, err := json.Marshal(r.toEntity(req))
+	if err != nil {
+		return domain.ChangeRequest{}, fmt.Errorf("序列化变更请求失败: %w", err)
+	}
+	ok, err := r.update.Run(ctx, r.client, []string{r.key(req.ID), r.pendingKey(), r.approvedKey(), r.runningKey(req.ID)},
+		strconv.FormatInt(req.Version, 10), string(val), strconv.FormatInt(req.ID, 10), req.Status.String(), req.Utime).Int()
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	if ok == 0 {
+		return domain.ChangeRequest{}, ErrChangeRequestConflict
+	}
+	req.Version++
+	return req, nil
+}
+
+func (r *RedisApprovalRepository) ListChangeRequests(ctx context.Context, query domain.ChangeRequestQuery) ([]domain.ChangeRequest, error) {
+	res := make([]domain.ChangeRequest, 0, query.Limit)
+	skipped := 0
+	// 过滤条件可能很稀疏，所以一直扫描到凑满一页或者索引结束，不能中途截断
+	for start := 0; ; start += changeRequestScanBatch {
+		members, err := r.client.ZRevRange(ctx, r.bizKey(query.BizID), int64(start), int64(start+changeRequestScanBatch-1)).Result()
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]int64, len(members))
+		cmds := make([]*redis.SliceCmd, len(members))
+		_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i := range members {
+				ids[i], _ = strconv.ParseInt(members[i], 10, 64)
+				cmds[i] = pipe.HMGet(ctx, r.key(ids[i]), fieldChangeRequestVersion, fieldChangeRequestData)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range cmds {
+			req, err1 := r.toDomain(ids[i], cmds[i].Val())
+			if errors.Is(err1, ErrChangeRequestNotFound) {
+				continue
+			}
+			if err1 != nil {
+				return nil, err1
+			}
+			if !query.Match(req) {
+				continue
+			}
+			if skipped < query.Offset {
+				skipped++
+				continue
+			}
+			res = append(res, req)
+			if len(res) == query.Limit {
+				return res, nil
+			}
+		}
+		if len(members) < changeRequestScanBatch {
+			return res, nil
+		}
+	}
+}
+
+func (r *RedisApprovalRepository) ListExpiredChangeRequests(ctx context.Context, now int64, offset, limit int64) ([]int64, error) {
+	members, err := r.client.ZRangeByScore(ctx, r.pendingKey(), &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    strconv.FormatInt(now, 10),
+		Offset: offset,
+		Count:  limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(members))
+	for i := range members {
+		id, err1 := strconv.ParseInt(members[i], 10, 64)
+		if err1 != nil {
+			continue
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (r *RedisApprovalRepository) ListApprovedChangeRequests(ctx context.Context, now int64, limit int64) ([]int64, error) {
+	members, err := r.client.ZRangeByScore(ctx, r.approvedKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now, 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(members))
+	for i := range members {
+		id, err1 := strconv.ParseInt(members[i], 10, 64)
+		if err1 != nil {
+			continue
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (r *RedisApprovalRepository) ClaimChangeRequest(ctx context.Context, id, now int64, lease time.Duration) (bool, error) {
+	n, err := r.claim.Run(ctx, r.client, []string{r.approvedKey(), r.runningKey(id)}, id, now, lease.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (r *RedisApprovalRepository) policyKey(bizID int64) string {
+	return fmt.Sprintf("approval:policies:%d", bizID)
+}
+
+func (r *RedisApprovalRepository) key(id int64) string {
+	return fmt.Sprintf("approval:request:%d", id)
+}
+
+func (r *RedisApprovalRepository) bizKey(bizID int64) string {
+	return fmt.Sprintf("approval:requests:%d", bizID)
+}
+
+func (r *RedisApprovalRepository) pendingKey() string {
+	return "approval:pending"
+}
+
+// approvedKey 审批通过等待执行的变更请求，分数是可以认领执行的时间
+func (r *RedisApprovalRepository) approvedKey() string {
+	return "approval:approved"
+}
+
+func (r *RedisApprovalRepository) runningKey(id int64) string {
+	return fmt.Sprintf("approval:running:%d", id)
+}
+
+func (r *RedisApprovalRepository) toDomain(id int64, vals []any) (domain.ChangeRequest, error) {
+	const expectedLen = 2
+	if len(vals) != expectedLen || vals[1] == nil {
+		return domain.ChangeRequest{}, fmt.Errorf("%w: id=%d", ErrChangeRequestNotFound, id)
+	}
+	versionVal, _ := vals[0].(string)
+	data, _ := vals[1].(string)
+	var entity ChangeRequestEntity
+	if err := json.Unmarshal([]byte(data), &entity); err != nil {
+		return domain.ChangeRequest{}, fmt.Errorf("反序列化变更请求失败: %w", err)
+	}
+	version, _ := strconv.ParseInt(versionVal, 10, 64)
+	decisions := make([]domain.ApprovalDecision, 0, len(entity.Decisions))
+	for _, d := range entity.Decisions {
+		decisions = append(decisions, domain.ApprovalDecision(d))
+	}
+	return domain.ChangeRequest{
+		ID:                id,
+		BizID:             entity.BizID,
+		Kind:              domain.ChangeRequestKind(entity.Kind),
+		PolicyID:          entity.PolicyID,
+		TargetID:          entity.TargetID,
+		TargetUserID:      entity.TargetUserID,
+		Payload:           entity.Payload,
+		RequesterUID:      entity.RequesterUID,
+		Reason:            entity.Reason,
+		Approvers:         entity.Approvers,
+		RequiredApprovals: entity.RequiredApprovals,
+		Decisions:         decisions,
+		Duration:          entity.Duration,
+		GrantStartTime:    entity.GrantStartTime,
+		GrantEndTime:      entity.GrantEndTime,
+		Status:            domain.ChangeRequestStatus(entity.Status),
+		Result:            entity.Result,
+		ExpireAt:          entity.ExpireAt,
+		Ctime:             entity.Ctime,
+		Utime:             entity.Utime,
+		Version:           version,
+	}, nil
+}
+
+func (r *RedisApprovalRepository) toEntity(req domain.ChangeRequest) ChangeRequestEntity {
+	decisions := make([]ApprovalDecisionEntity, 0, len(req.Decisions))
+	for _, d := range req.Decisions {
+		decisions = append(decisions, ApprovalDecisionEntity(d))
+	}
+	return ChangeRequestEntity{
+		BizID:             req.BizID,
+		Kind:              req.Kind.String(),
+		PolicyID:          req.PolicyID,
+		TargetID:          req.TargetID,
+		TargetUserID:      req.TargetUserID,
+		Payload:           req.Payload,
+		RequesterUID:      req.RequesterUID,
+		Reason:            req.Reason,
+		Approvers:         req.Approvers,
+		RequiredApprovals: req.RequiredApprovals,
+		Decisions:         decisions,
+		Duration:          req.Duration,
+		GrantStartTime:    req.GrantStartTime,
+		GrantEndTime:      req.GrantEndTime,
+		Status:            req.Status.String(),
+		Result:            req.Result,
+		ExpireAt:          req.ExpireAt,
+		Ctime:             req.Ctime,
+		Utime:             req.Utime,
+	}
+}
+
+type ApprovalPolicyEntity struct {
+	ID                int64   `json:"id"`
+	BizID             int64   `json:"bizId"`
+	Name              string  `json:"name"`
+	RoleIDs           []int64 `json:"roleIds,omitzero"`
+	PermissionIDs     []int64 `json:"permissionIds,omitzero"`
+	Approvers         []int64 `json:"approvers"`
+	RequiredApprovals int     `json:"requiredApprovals"`
+	TTL               int64   `json:"ttl,omitzero"`
+	Ctime             int64   `json:"ctime"`
+	Utime             int64   `json:"utime"`
+}
+
+type ApprovalDecisionEntity struct {
+	UID     int64  `json:"uid"`
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment,omitzero"`
+	Ctime   int64  `json:"ctime"`
+}
+
+// ChangeRequestEntity 变更请求在 Redis 中的存储格式，版本号单独保存在 Hash 字段中
+type ChangeRequestEntity struct {
+	BizID             int64                    `json:"bizId"`
+	Kind              string                   `json:"kind"`
+	PolicyID          int64                    `json:"policyId"`
+	TargetID          int64                    `json:"targetId"`
+	TargetUserID      int64                    `json:"targetUserId"`
+	Payload           string                   `json:"payload"`
+	RequesterUID      int64                    `json:"requesterUid"`
+	Reason            string                   `json:"reason,omitzero"`
+	Approvers         []int64                  `json:"approvers"`
+	RequiredApprovals int                      `json:"requiredApprovals"`
+	Decisions         []ApprovalDecisionEntity `json:"decisions,omitzero"`
+	Duration          int64                    `json:"duration,omitzero"`
+	GrantStartTime    int64                    `json:"grantStartTime,omitzero"`
+	GrantEndTime      int64                    `json:"grantEndTime,omitzero"`
+	Status            string                   `json:"status"`
+	Result            string                   `json:"result,omitzero"`
+	ExpireAt          int64                    `json:"expireAt"`
+	Ctime             int64                    `json:"ctime"`
+	Utime             int64                    `json:"utime"`
+}
@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	fieldAuditRecord     = "record"
+	fieldAuditHash       = "hash"
+	fieldAuditSeq        = "seq"
+	fieldAuditCheckpoint = "checkpoint"
+	auditScanBatch       = 200
+)
+
+// ErrAuditChainConflict 链尾已经被其他请求更新，需要基于新的链尾重新计算哈希
+var ErrAuditChainConflict = errors.New("审计哈希链冲突")
+
+//go:embed lua/audit_append.lua
+var luaAuditAppend string
+
+type AuditRepository interface {
+	// Create 追加审计记录，record.PrevHash 和 record.Seq 必须等于当前链尾的哈希和下一个序号，否则返回 ErrAuditChainConflict
+	Create(ctx context.Context, record domain.AuditRecord) (domain.AuditRecord, error)
+	// ChainHead 返回业务审计哈希链链尾的哈希和序号，没有记录时为空和0
+	ChainHead(ctx context.Context, bizID int64) (string, int64, error)
+	// List 按照时间倒序查询，返回的 cursor 为本次扫描到的最后一条记录的ID
+	// 即使结果不足 Limit 条，只要 cursor 不为空就说明还有更早的记录
+	List(ctx context.Context, query domain.AuditQuery) ([]domain.AuditRecord, string, error)
+	// Range 按照时间正序返回 ID 在 (afterID, endID] 范围内的记录，afterID 为空时从 startTime 开始
+	Range(ctx context.Context, bizID int64, afterID string, startTime, endTime int64, count int64) ([]domain.AuditRecord, error)
+	// Previous 返回 ID 之前的一条记录
+	Previous(ctx context.Context, bizID int64, id string) (domain.AuditRecord, bool, error)
+	CreateCheckpoint(ctx context.Context, checkpoint domain.AuditCheckpoint) error
+	// ListCheckpoints 按照时间正序返回记录时间在 [startTime, endTime] 范围内的检查点
+	ListCheckpoints(ctx context.Context, bizID int64, startTime, endTime int64) ([]domain.AuditCheckpoint, error)
+}
+
+// RedisAuditRepository 每个业务的审计记录保存在一个 Redis Stream 中
+// Stream ID 本身就是毫秒时间戳，所以时间范围过滤可以直接转换为 ID 范围
+type RedisAuditRepository struct {
+	client redis.Cmdable
+	append *redis.Script
+	// maxScan 单次查询最多扫描的记录数，避免过滤条件过于稀疏时扫描整个 Stream
+	maxScan int
+}
+
+func NewRedisAuditRepository(client redis.Cmdable) AuditRepository {
+	const defaultMaxScan = 5000
+	return &RedisAuditRepository{
+		client:  client,
+		append:  redis.NewScript(luaAuditAppend),
+		maxScan: defaultMaxScan,
+	}
+}
+
+func (r *RedisAuditRepository) Create(ctx context.Context, record domain.AuditRecord) (domain.AuditRecord, error) {
+	val, err := json.Marshal(r.toEntity(record))
+	if err != nil {
+		return domain.AuditRecord{}, fmt.Errorf("序列化审计记录失败: %w", err)
+	}
+	res, err := r.append.Run(ctx, r.client,
+		[]string{r.headKey(record.BizID), r.key(record.BizID)},
+		record.PrevHash, record.Hash, string(val), record.Seq).Slice()
+	if err != nil {
+		return domain.AuditRecord{}, err
+	}
+	const expectedLen = 2
+	if len(res) != expectedLen {
+		return domain.AuditRecord{}, fmt.Errorf("追加审计记录返回值错误: %v", res)
+	}
+	id, _ := res[0].(string)
+	if id == "" {
+		return domain.AuditRecord{}, ErrAuditChainConflict
+	}
+	record.ID = id
+	record.Seq, _ = res[1].(int64)
+	return record, nil
+}
+
+func (r *RedisAuditRepository) ChainHead(ctx context.Context, bizID int64) (string, int64, error) {
+	vals, err := r.client.HMGet(ctx, r.headKey(bizID), fieldAuditHash, fieldAuditSeq).Result()
+	if err != nil {
+		return "", 0, err
+	}
+	hash, _ := vals[0].(string)
+	seqVal, _ := vals[1].(string)
+	seq, _ := strconv.ParseInt(seqVal, 10, 64)
+	return hash, seq, nil
+}
+
+func (r *RedisAuditRepository) List(ctx context.Context, query domain.AuditQuery) ([]domain.AuditRecord, string, error) {
+	start, end := "-", "+"
+	if query.StartTime > 0 {
+		start = fmt.Sprintf("%d-0", query.StartTime)
+	}
+	if query.EndTime > 0 {
+		end = fmt.Sprintf("%d-%d", query.EndTime, uint64(math.MaxUint64))
+	}
+	if query.Cursor != "" {
+		// ( 表示开区间，需要 Redis 6.2 及以上
+		end = "(" + query.Cursor
+	}
+	res := make([]domain.AuditRecord, 0, query.Limit)
+	scanned := 0
+	for scanned < r.maxScan {
+		msgs, err := r.client.XRevRangeN(ctx, r.key(query.BizID), end, start, auditScanBatch).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		for i := range msgs {
+			record, err1 := r.toDomain(msgs[i])
+			if err1 != nil {
+				return nil, "", err1
+			}
+			if query.Match(record) {
+				res = append(res, record)
+			}
+			if len(res) == query.Limit {
+				return res, msgs[i].ID, nil
+			}
+		}
+		if len(msgs) < auditScanBatch {
+			return res, "", nil
+		}
+		scanned += len(msgs)
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+	return res, end[1:], nil
+}
+
+func (r *RedisAuditRepository) Range(ctx context.Context, bizID int64, afterID string, startTime, endTime int64, count int64) ([]domain.AuditRecord, error) {
+	start, end := "-", "+"
+	if startTime > 0 {
+		start = fmt.Sprintf("%d-0", startTime)
+	}
+	if afterID != "" {
+		start = "(" + afterID
+	}
+	if endTime > 0 {
+		end = fmt.Sprintf("%d-%d", endTime, uint64(math.MaxUint64))
+	}
+	msgs, err := r.client.XRangeN(ctx, r.key(bizID), start, end, count).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AuditRecord, 0, len(msgs))
+	for i := range msgs {
+		record, err1 := r.toDomain(msgs[i])
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, record)
+	}
+	return res, nil
+}
+
+func (r *RedisAuditRepository) Previous(ctx context.Context, bizID int64, id string) (domain.AuditRecord, bool, error) {
+	msgs, err := r.client.XRevRangeN(ctx, r.key(bizID), "("+id, "-", 1).Result()
+	if err != nil || len(msgs) == 0 {
+		return domain.AuditRecord{}, false, err
+	}
+	record, err := r.toDomain(msgs[0])
+	return record, err == nil, err
+}
+
+func (r *RedisAuditRepository) CreateCheckpoint(ctx context.Context, checkpoint domain.AuditCheckpoint) error {
+	val, err := json.Marshal(AuditCheckpointEntity{
+		RecordID:  checkpoint.RecordID,
+		Seq:       checkpoint.Seq,
+		Hash:      checkpoint.Hash,
+		Ctime:     checkpoint.Ctime,
+		Signature: checkpoint.Signature,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化审计检查点失败: %w", err)
+	}
+	// 检查点的 Stream ID 复用审计记录的 ID，方便按照时间范围查找
+	return r.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.checkpointKey(checkpoint.BizID),
+		ID:     checkpoint.RecordID,
+		Values: map[string]any{fieldAuditCheckpoint: string(val)},
+	}).Err()
+}
+
+func (r *RedisAuditRepository) ListCheckpoints(ctx context.Context, bizID int64, startTime, endTime int64) ([]domain.AuditCheckpoint, error) {
+	start, end := "-", "+"
+	if startTime > 0 {
+		start = fmt.Sprintf("%d-0", startTime)
+	}
+	if endTime > 0 {
+		end = fmt.Sprintf("%d-%d", endTime, uint64(math.MaxUint64))
+	}
+	msgs, err := r.client.XRange(ctx, r.checkpointKey(bizID), start, end).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AuditCheckpoint, 0, len(msgs))
+	for i := range msgs {
+		val, ok := msgs[i].Values[fieldAuditCheckpoint].(string)
+		if !ok {
+			return nil, fmt.Errorf("审计检查点格式错误: id=%s", msgs[i].ID)
+		}
+		var entity AuditCheckpointEntity
+		if err = json.Unmarshal([]byte(val), &entity); err != nil {
+			return nil, fmt.Errorf("反序列化审计检查点失败: %w", err)
+		}
+		res = append(res, domain.AuditCheckpoint{
+			BizID:     bizID,
+			RecordID:  entity.RecordID,
+			Seq:       entity.Seq,
+			Hash:      entity.Hash,
+			Ctime:     entity.Ctime,
+			Signature: entity.Signature,
+		})
+	}
+	return res, nil
+}
+
+func (r *RedisAuditRepository) key(bizID int64) string {
+	return fmt.Sprintf("audit:records:%d", bizID)
+}
+
+func (r *RedisAuditRepository) headKey(bizID int64) string {
+	return fmt.Sprintf("audit:chain:%d", bizID)
+}
+
+func (r *RedisAuditRepository) checkpointKey(bizID int64) string {
+	return fmt.Sprintf("audit:checkpoints:%d", bizID)
+}
+
+func (r *RedisAuditRepository) toDomain(msg redis.XMessage) (domain.AuditRecord, error) {
+	val, ok := msg.Values[fieldAuditRecord].(string)
+	if !ok {
+		return domain.AuditRecord{}, fmt.Errorf("审计记录格式错误: id=%s", msg.ID)
+	}
+	var entity AuditRecordEntity
+	err := json.Unmarshal([]byte(val), &entity)
+	if err != nil {
+		return domain.AuditRecord{}, fmt.Errorf("反序列化审计记录失败: %w", err)
+	}
+	// 哈希链上线之前的记录没有这两个字段
+	hash, _ := msg.Values[fieldAuditHash].(string)
+	seqVal, _ := msg.Values[fieldAuditSeq].(string)
+	seq, _ := strconv.ParseInt(seqVal, 10, 64)
+	return domain.AuditRecord{
+		ID:           msg.ID,
+		BizID:        entity.BizID,
+		ActorUID:     entity.ActorUID,
+		Route:        entity.Route,
+		Table:        entity.Table,
+		TargetID:     entity.TargetID,
+		TargetUserID: entity.TargetUserID,
+		Payload:      entity.Payload,
+		Result:       entity.Result,
+		Error:        entity.Error,
+		Outcome:      domain.AuditOutcome(entity.Outcome),
+		ClientIP:     entity.ClientIP,
+		Ctime:        entity.Ctime,
+		Seq:          seq,
+		PrevHash:     entity.PrevHash,
+		Hash:         hash,
+	}, nil
+}
+
+func (r *RedisAuditRepository) toEntity(record domain.AuditRecord) AuditRecordEntity {
+	return AuditRecordEntity{
+		BizID:        record.BizID,
+		ActorUID:     record.ActorUID,
+		Route:        record.Route,
+		Table:        record.Table,
+		TargetID:     record.TargetID,
+		TargetUserID: record.TargetUserID,
+		Payload:      record.Payload,
+		Result:       record.Result,
+		Error:        record.Error,
+		Outcome:      record.Outcome.String(),
+		ClientIP:     record.ClientIP,
+		Ctime:        record.Ctime,
+		PrevHash:     record.PrevHash,
+	}
+}
+
+// AuditRecordEntity 审计记录在 Redis 中的存储格式
+type AuditRecordEntity struct {
+	BizID        int64  `json:"bizId"`
+	ActorUID     int64  `json:"actorUid"`
+	Route        string `json:"route"`
+	Table        string `json:"table"`
+	TargetID     int64  `json:"targetId,omitzero"`
+	TargetUserID int64  `json:"targetUserId,omitzero"`
+	Payload      string `json:"payload,omitzero"`
+	Result       string `json:"result,omitzero"`
+	Error        string `json:"error,omitzero"`
+	Outcome      string `json:"outcome"`
+	ClientIP     string `json:"clientIp,omitzero"`
+	Ctime        int64  `json:"ctime"`
+	PrevHash     string `json:"prevHash,omitzero"`
+}
+
+type AuditCheckpointEntity struct {
+	RecordID  string `json:"recordId"`
+	Seq       int64  `json:"seq"`
+	Hash      string `json:"hash"`
+	Ctime     int64  `json:"ctime"`
+	Signature string `json:"signature"`
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed lua/breakglass_create.lua
+var luaBreakGlassCreate string
+
+var (
+	ErrBreakGlassPolicyNotFound  = errors.New("业务没有配置紧急授权")
+	ErrBreakGlassSessionNotFound = errors.New("紧急授权不存在")
+)
+
+type BreakGlassRepository interface {
+	SavePolicy(ctx context.Context, policy domain.BreakGlassPolicy) error
+	GetPolicy(ctx context.Context, bizID int64) (domain.BreakGlassPolicy, error)
+
+	// CreateSession 创建紧急授权，同一个用户在同一个业务内同时只能有一个生效中的紧急授权
+	// 已经存在时返回 domain.ErrBreakGlassActive
+	CreateSession(ctx context.Context, session domain.BreakGlassSession) (domain.BreakGlassSession, error)
+	UpdateSession(ctx context.Context, session domain.BreakGlassSession) error
+	GetSession(ctx context.Context, id int64) (domain.BreakGlassSession, error)
+	// ListSessions 按照开始时间倒序查询
+	ListSessions(ctx context.Context, bizID int64, offset, limit int) ([]domain.BreakGlassSession, error)
+	// ListDueSessions 按照结束时间返回早于 now 并且还没有回收的紧急授权ID，offset 用来跳过回收失败的
+	ListDueSessions(ctx context.Context, now int64, offset, limit int64) ([]int64, error)
+}
+
+// RedisBreakGlassRepository 紧急授权保存在 Redis 中
+// 生效中的紧急授权按照结束时间放在一个有序集合里，供定时任务回收
+type RedisBreakGlassRepository struct {
+	client redis.Cmdable
+	create *redis.Script
+}
+
+func NewRedisBreakGlassRepository(client redis.Cmdable) BreakGlassRepository {
+	return &RedisBreakGlassRepository{client: client, create: redis.NewScript(luaBreakGlassCreate)}
+}
+
+func (r *RedisBreakGlassRepository) SavePolicy(ctx context.Context, policy domain.BreakGlassPolicy) error {
+	val, err := json.Marshal(BreakGlassPolicyEntity{
+		RoleID:     policy.RoleID,
+		Responders: policy.Responders,
+		Watchers:   policy.Watchers,
+		Window:     policy.Window,
+		Ctime:      policy.Ctime,
+		Utime:      policy.Utime,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化紧急授权配置失败: %w", err)
+	}
+	return r.client.HSet(ctx, "breakglass:policies", strconv.FormatInt(policy.BizID, 10), string(val)).Err()
+}
+
+func (r *RedisBreakGlassRepository) GetPolicy(ctx context.Context, bizID int64) (domain.BreakGlassPolicy, error) {
+	val, err := r.client.HGet(ctx, "breakglass:policies", strconv.FormatInt(bizID, 10)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.BreakGlassPolicy{}, ErrBreakGlassPolicyNotFound
+	}
+	if err != nil {
+		return domain.BreakGlassPolicy{}, err
+	}
+	var entity BreakGlassPolicyEntity
+	if err = json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.BreakGlassPolicy{}, fmt.Errorf("反序列化紧急授权配置失败: %w", err)
+	}
+	return domain.BreakGlassPolicy{
+		BizID:      bizID,
+		RoleID:     entity.RoleID,
+		Responders: entity.Responders,
+		Watchers:   entity.Watchers,
+		Window:     entity.Window,
+		Ctime:      entity.Ctime,
+		Utime:      entity.Utime,
+	}, nil
+}
+
+func (r *RedisBreakGlassRepository) CreateSession(ctx context.Context, session domain.BreakGlassSession) (domain.BreakGlassSession, error) {
+	id, err := r.client.Incr(ctx, "breakglass:session:id").Result()
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	session.ID = id
+	val, err := json.Marshal(r.toEntity(session))
+	if err != nil {
+		return domain.BreakGlassSession{}, fmt.Errorf("序列化紧急授权失败: %w", err)
+	}
+	// 占位键在窗口结束前一直存在，保证紧急授权不能被续期
+	ttl := session.EndTime - session.StartTime
+	ok, err := r.create.Run(ctx, r.client,
+		[]string{r.holderKey(session.BizID, session.UID), r.key(id), r.bizKey(session.BizID), r.dueKey()},
+		id, string(val), ttl, session.StartTime, session.EndTime).Bool()
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	if !ok {
+		return domain.BreakGlassSession{}, domain.ErrBreakGlassActive
+	}
+	return session, nil
+}
+
+func (r *RedisBreakGlassRepository) UpdateSession(ctx context.Context, session domain.BreakGlassSession) error {
+	val, err := json.Marshal(r.toEntity(session))
+	if err != nil {
+		return fmt.Errorf("序列化紧急授权失败: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(session.ID), string(val), 0)
+		if session.Status != domain.BreakGlassActive {
+			// 提前结束的紧急授权也不能在原窗口内重新申请，所以不删除占位键
+			pipe.ZRem(ctx, r.dueKey(), strconv.FormatInt(session.ID, 10))
+		}
+		if session.Status == domain.BreakGlassFailed {
+			// 授予失败时允许立刻重试
+			pipe.Del(ctx, r.holderKey(session.BizID, session.UID))
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisBreakGlassRepository) GetSession(ctx context.Context, id int64) (domain.BreakGlassSession, error) {
+	val, err := r.client.Get(ctx, r.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.BreakGlassSession{}, ErrBreakGlassSessionNotFound
+	}
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	return r.toDomain(id, val)
+}
+
+func (r *RedisBreakGlassRepository) ListSessions(ctx context.Context, bizID int64, offset, limit int) ([]domain.BreakGlassSession, error) {
+	members, err := r.client.ZRevRange(ctx, r.bizKey(bizID), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(members))
+	ids := make([]int64, len(members))
+	for i := range members {
+		ids[i], _ = strconv.ParseInt(members[i], 10, 64)
+		keys[i] = r.key(ids[i])
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.BreakGlassSession, 0, len(vals))
+	for i := range vals {
+		val, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		session, err1 := r.toDomain(ids[i], val)
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, session)
+	}
+	return res, nil
+}
+
+func (r *RedisBreakGlassRepository) ListDueSessions(ctx context.Context, now int64, offset, limit int64) ([]int64, error) {
+	members, err := r.client.ZRangeByScore(ctx, r.dueKey(), &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    strconv.FormatInt(now, 10),
+		Offset: offset,
+		Count:  limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(members))
+	for i := range members {
+		id, err1 := strconv.ParseInt(members[i], 10, 64)
+		if err1 != nil {
+			continue
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (r *RedisBreakGlassRepository) key(id int64) string {
+	return fmt.Sprintf("breakglass:session:%d", id)
+}
+
+func (r *RedisBreakGlassRepository) bizKey(bizID int64) string {
+	return fmt.Sprintf("breakglass:sessions:%d", bizID)
+}
+
+func (r *RedisBreakGlassRepository) holderKey(bizID, uid int64) string {
+	return fmt.Sprintf("breakglass:holder:%d:%d", bizID, uid)
+}
+
+func (r *RedisBreakGlassRepository) dueKey() string {
+	return "breakglass:due"
+}
+
+func (r *RedisBreakGlassRepository) toEntity(session domain.BreakGlassSession) BreakGlassSessionEntity {
+	return BreakGlassSessionEntity{
+		BizID:      session.BizID,
+		UID:        session.UID,
+		RoleID:     session.RoleID,
+		RoleName:   session.RoleName,
+		UserRoleID: session.UserRoleID,
+		Reason:     session.Reason,
+		StartTime:  session.StartTime,
+		EndTime:    session.EndTime,
+		RevokedAt:  session.RevokedAt,
+		Status:     session.Status.String(),
+		Error:      session.Error,
+	}
+}
+
+func (r *RedisBreakGlassRepository) toDomain(id int64, val string) (domain.BreakGlassSession, error) {
+	var entity BreakGlassSessionEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.BreakGlassSession{}, fmt.Errorf("反序列化紧急授权失败: %w", err)
+	}
+	return domain.BreakGlassSession{
+		ID:         id,
+		BizID:      entity.BizID,
+		UID:        entity.UID,
+		RoleID:     entity.RoleID,
+		RoleName:   entity.RoleName,
+		UserRoleID: entity.UserRoleID,
+		Reason:     entity.Reason,
+		StartTime:  entity.StartTime,
+		EndTime:    entity.EndTime,
+		RevokedAt:  entity.RevokedAt,
+		Status:     domain.BreakGlassStatus(entity.Status),
+		Error:      entity.Error,
+	}, nil
+}
+
+type BreakGlassPolicyEntity struct {
+	RoleID     int64   `json:"roleId"`
+	Responders []int64 `json:"responders"`
+	Watchers   []int64 `json:"watchers,omitzero"`
+	Window     int64   `json:"window"`
+	Ctime      int64   `json:"ctime"`
+	Utime      int64   `json:"utime"`
+}
+
+type BreakGlassSessionEntity struct {
+	BizID      int64  `json:"bizId"`
+	UID        int64  `json:"uid"`
+	RoleID     int64  `json:"roleId"`
+	RoleName   string `json:"roleName"`
+	UserRoleID int64  `json:"userRoleId,omitzero"`
+	Reason     string `json:"reason"`
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	RevokedAt  int64  `json:"revokedAt,omitzero"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitzero"`
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+type ExpiryRepository interface {
+	// MarkNotified 记录已经发送过的到期提醒，同一条授权的同一个提醒天数只会返回一次 true
+	MarkNotified(ctx context.Context, table domain.SystemTableResource, grantID int64, days int, ttl time.Duration) (bool, error)
+	// Archive 同一个授权重复归档时只保留第一次
+	Archive(ctx context.Context, grant domain.ArchivedGrant) error
+	// ListArchived 按照归档时间倒序查询
+	ListArchived(ctx context.Context, bizID int64, offset, limit int) ([]domain.ArchivedGrant, error)
+}
+
+// RedisExpiryRepository 到期提醒的去重标记使用带过期时间的键，授权到期后自动清理
+// 归档的授权按照归档时间放在每个业务的有序集合里，成员不包含归档时间，所以重复归档是幂等的
+type RedisExpiryRepository struct {
+	client redis.Cmdable
+}
+
+func NewRedisExpiryRepository(client redis.Cmdable) ExpiryRepository {
+	return &RedisExpiryRepository{client: client}
+}
+
+func (r *RedisExpiryRepository) MarkNotified(ctx context.Context, table domain.SystemTableResource, grantID int64, days int, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, fmt.Sprintf("expiry:notified:%s:%d:%d", table, grantID, days), 1, ttl).Result()
+}
+
+func (r *RedisExpiryRepository) Archive(ctx context.Context, grant domain.ArchivedGrant) error {
+	val, err := json.Marshal(ArchivedGrantEntity{
+		Table:      grant.Table.String(),
+		GrantID:    grant.GrantID,
+		UserID:     grant.UserID,
+		TargetID:   grant.TargetID,
+		TargetName: grant.TargetName,
+		StartTime:  grant.StartTime,
+		EndTime:    grant.EndTime,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化归档授权失败: %w", err)
+	}
+	return r.client.ZAddNX(ctx, r.archiveKey(grant.BizID), redis.Z{Score: float64(grant.ArchivedAt), Member: string(val)}).Err()
+}
+
+func (r *RedisExpiryRepository) ListArchived(ctx context.Context, bizID int64, offset, limit int) ([]domain.ArchivedGrant, error) {
+	vals, err := r.client.ZRevRangeWithScores(ctx, r.archiveKey(bizID), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ArchivedGrant, 0, len(vals))
+	for i := range vals {
+		var entity ArchivedGrantEntity
+		member, _ := vals[i].Member.(string)
+		if err = json.Unmarshal([]byte(member), &entity); err != nil {
+			return nil, fmt.Errorf("反序列化归档授权失败: %w", err)
+		}
+		// 早期的归档记录在成员里保存了归档时间
+		if entity.ArchivedAt == 0 {
+			entity.ArchivedAt = int64(vals[i].Score)
+		}
+		res = append(res, domain.ArchivedGrant{
+			BizID:      bizID,
+			Table:      domain.SystemTableResource(entity.Table),
+			GrantID:    entity.GrantID,
+			UserID:     entity.UserID,
+			TargetID:   entity.TargetID,
+			TargetName: entity.TargetName,
+			StartTime:  entity.StartTime,
+			EndTime:    entity.EndTime,
+			ArchivedAt: entity.ArchivedAt,
+		})
+	}
+	return res, nil
+}
+
+func (r *RedisExpiryRepository) archiveKey(bizID int64) string {
+	return fmt.Sprintf("expiry:archive:%d", bizID)
+}
+
+type ArchivedGrantEntity struct {
+	Table      string `json:"table"`
+	GrantID    int64  `json:"grantId"`
+	UserID     int64  `json:"userId"`
+	TargetID   int64  `json:"targetId"`
+	TargetName string `json:"targetName"`
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	ArchivedAt int64  `json:"archivedAt,omitempty"`
+}
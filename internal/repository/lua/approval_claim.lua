@@ -0,0 +1,13 @@
+-- 认领审批通过、等待执行的变更请求
+-- 认领后执行时间顺延一个租期，执行授权的实例崩溃时租期过后可以被重新认领
+-- KEYS[1] 待执行索引，KEYS[2] 执行中标记
+-- ARGV[1] 变更请求ID，ARGV[2] 当前时间，ARGV[3] 租期，毫秒
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) > tonumber(ARGV[2]) then
+    return 0
+end
+if not redis.call('SET', KEYS[2], 1, 'NX', 'PX', ARGV[3]) then
+    return 0
+end
+redis.call('ZADD', KEYS[1], tonumber(ARGV[2]) + tonumber(ARGV[3]), ARGV[1])
+return 1
@@ -0,0 +1,19 @@
+-- 基于版本号更新变更请求，防止并发审批互相覆盖
+-- KEYS[1] 变更请求，KEYS[2] 待审批索引，KEYS[3] 待执行索引，KEYS[4] 执行中标记
+-- ARGV[1] 期望的版本号，ARGV[2] 新的变更请求，ARGV[3] 变更请求ID，ARGV[4] 新的状态，ARGV[5] 更新时间
+local version = redis.call('HGET', KEYS[1], 'version')
+if version ~= ARGV[1] then
+    return 0
+end
+redis.call('HSET', KEYS[1], 'version', tonumber(version) + 1, 'data', ARGV[2])
+if ARGV[4] ~= 'pending' then
+    redis.call('ZREM', KEYS[2], ARGV[3])
+end
+if ARGV[4] == 'approved' then
+    -- 已经在索引里时保留执行任务顺延的租期
+    redis.call('ZADD', KEYS[3], 'NX', ARGV[5], ARGV[3])
+else
+    redis.call('ZREM', KEYS[3], ARGV[3])
+    redis.call('DEL', KEYS[4])
+end
+return 1
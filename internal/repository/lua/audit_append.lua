@@ -0,0 +1,16 @@
+-- 追加一条审计记录，保证同一个业务内的哈希链不会分叉
+-- KEYS[1] 链尾，KEYS[2] 审计记录 Stream
+-- ARGV[1] 期望的链尾哈希，ARGV[2] 新记录的哈希，ARGV[3] 新记录，ARGV[4] 新记录的序号
+local head = redis.call('HGET', KEYS[1], 'hash')
+if not head then
+    head = ''
+end
+local seq = tonumber(redis.call('HGET', KEYS[1], 'seq') or '0') + 1
+if head ~= ARGV[1] or seq ~= tonumber(ARGV[4]) then
+    -- 其他实例已经追加了记录，调用方需要重新计算哈希
+    return {'', 0}
+end
+redis.call('HSET', KEYS[1], 'seq', seq)
+local id = redis.call('XADD', KEYS[2], '*', 'record', ARGV[3], 'hash', ARGV[2], 'seq', seq)
+redis.call('HSET', KEYS[1], 'hash', ARGV[2])
+return {id, seq}
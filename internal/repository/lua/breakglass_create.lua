@@ -0,0 +1,10 @@
+-- 创建紧急授权，占位键和紧急授权记录一起写入，避免占位键写入后记录写入失败把值班人员锁在窗口外
+-- KEYS[1] 占位键，KEYS[2] 紧急授权，KEYS[3] 业务的紧急授权索引，KEYS[4] 到期索引
+-- ARGV[1] 紧急授权ID，ARGV[2] 紧急授权内容，ARGV[3] 占位键的有效期，毫秒，ARGV[4] 开始时间，ARGV[5] 结束时间
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[3]) then
+    return 0
+end
+redis.call('SET', KEYS[2], ARGV[2])
+redis.call('ZADD', KEYS[3], ARGV[4], ARGV[1])
+redis.call('ZADD', KEYS[4], ARGV[5], ARGV[1])
+return 1
@@ -0,0 +1,7 @@
+-- 取消或者修改执行时间前，把还没有开始执行的定时变更移出待执行索引
+-- KEYS[1] 待执行索引，KEYS[2] 执行中标记
+-- ARGV[1] 定时变更ID
+if redis.call('EXISTS', KEYS[2]) == 1 then
+    return 0
+end
+return redis.call('ZREM', KEYS[1], ARGV[1])
@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrReviewCampaignNotFound = errors.New("权限审查不存在")
+	ErrReviewItemNotFound     = errors.New("审查项不存在")
+)
+
+type ReviewRepository interface {
+	// CreateCampaign 保存权限审查和授权快照，审查项的ID在审查内从1开始编号
+	CreateCampaign(ctx context.Context, campaign domain.ReviewCampaign, items []domain.ReviewItem) (domain.ReviewCampaign, error)
+	// UpdateCampaign 更新审查状态，不再是 active 状态的审查会移出截止时间索引
+	UpdateCampaign(ctx context.Context, campaign domain.ReviewCampaign) error
+	GetCampaign(ctx context.Context, id int64) (domain.ReviewCampaign, error)
+	// ListCampaigns 按照创建时间倒序查询
+	ListCampaigns(ctx context.Context, bizID int64, offset, limit int) ([]domain.ReviewCampaign, error)
+	// ListDueCampaigns 按照截止时间返回早于 now 并且还是 active 状态的审查ID
+	ListDueCampaigns(ctx context.Context, now int64, offset, limit int64) ([]int64, error)
+
+	// ListItems 按照ID顺序返回审查内的全部审查项
+	ListItems(ctx context.Context, campaignID int64) ([]domain.ReviewItem, error)
+	GetItem(ctx context.Context, campaignID, itemID int64) (domain.ReviewItem, error)
+	// Decide 记录审查结论，每个审查项只能有一个结论，已经有结论时返回 domain.ErrReviewItemDecided
+	Decide(ctx context.Context, item domain.ReviewItem) error
+	// Reassign 修改审查项的审查人
+	Reassign(ctx context.Context, items []domain.ReviewItem) error
+}
+
+// RedisReviewRepository 权限审查保存在 Redis 中
+// 授权快照和审查结论分别放在两个 Hash 里，结论使用 HSETNX 写入，
+// 审查人和到期回收的定时任务同时处理同一个审查项时只有一方会成功
+type RedisReviewRepository struct {
+	client redis.Cmdable
+}
+
+func NewRedisReviewRepository(client redis.Cmdable) ReviewRepository {
+	return &RedisReviewRepository{client: client}
+}
+
+func (r *RedisReviewRepository) CreateCampaign(ctx context.Context, campaign domain.ReviewCampaign, items []domain.ReviewItem) (domain.ReviewCampaign, error) {
+	id, err := r.client.Incr(ctx, "review:campaign:id").Result()
+	if err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	campaign.ID = id
+	val, err := json.Marshal(r.toCampaignEntity(campaign))
+	if err != nil {
+		return domain.ReviewCampaign{}, fmt.Errorf("序列化权限审查失败: %w", err)
+	}
+	fields := make([]any, 0, 2*len(items))
+	for i := range items {
+		item, err1 := json.Marshal(r.toItemEntity(items[i]))
+		if err1 != nil {
+			return domain.ReviewCampaign{}, fmt.Errorf("序列化审查项失败: %w", err1)
+		}
+		fields = append(fields, strconv.Itoa(i+1), string(item))
+	}
+	member := strconv.FormatInt(id, 10)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(id), string(val), 0)
+		if len(fields) > 0 {
+			pipe.HSet(ctx, r.itemsKey(id), fields...)
+		}
+		pipe.ZAdd(ctx, r.bizKey(campaign.BizID), redis.Z{Score: float64(campaign.Ctime), Member: member})
+		pipe.ZAdd(ctx, r.dueKey(), redis.Z{Score: float64(campaign.Deadline), Member: member})
+		return nil
+	})
+	return campaign, err
+}
+
+func (r *RedisReviewRepository) UpdateCampaign(ctx context.Context, campaign domain.ReviewCampaign) error {
+	val, err := json.Marshal(r.toCampaignEntity(campaign))
+	if err != nil {
+		return fmt.Errorf("序列化权限审查失败: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(campaign.ID), string(val), 0)
+		if campaign.Status != domain.ReviewCampaignActive {
+			pipe.ZRem(ctx, r.dueKey(), strconv.FormatInt(campaign.ID, 10))
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisReviewRepository) GetCampaign(ctx context.Context, id int64) (domain.ReviewCampaign, error) {
+	val, err := r.client.Get(ctx, r.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.ReviewCampaign{}, ErrReviewCampaignNotFound
+	}
+	if err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	return r.toCampaign(id, val)
+}
+
+func (r *RedisReviewRepository) ListCampaigns(ctx context.Context, bizID int64, offset, limit int) ([]domain.ReviewCampaign, error) {
+	members, err := r.client.ZRevRange(ctx, r.bizKey(bizID), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(members))
+	ids := make([]int64, len(members))
+	for i := range members {
+		ids[i], _ = strconv.ParseInt(members[i], 10, 64)
+		keys[i] = r.key(ids[i])
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ReviewCampaign, 0, len(vals))
+	for i := range vals {
+		val, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		campaign, err1 := r.toCampaign(ids[i], val)
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, campaign)
+	}
+	return res, nil
+}
+
+func (r *RedisReviewRepository) ListDueCampaigns(ctx context.Context, now int64, offset, limit int64) ([]int64, error) {
+	members, err := r.client.ZRangeByScore(ctx, r.dueKey(), &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    strconv.FormatInt(now, 10),
+		Offset: offset,
+		Count:  limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(members))
+	for i := range members {
+		id, err1 := strconv.ParseInt(members[i], 10, 64)
+		if err1 != nil {
+			continue
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (r *RedisReviewRepository) ListItems(ctx context.Context, campaignID int64) ([]domain.ReviewItem, error) {
+	items, err := r.client.HGetAll(ctx, r.itemsKey(campaignID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := r.client.HGetAll(ctx, r.decisionsKey(campaignID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ReviewItem, 0, len(items))
+	for field, val := range items {
+		id, err1 := strconv.ParseInt(field, 10, 64)
+		if err1 != nil {
+			continue
+		}
+		item, err1 := r.toItem(campaignID, id, val, decisions[field])
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, item)
+	}
+	slices.SortFunc(res, func(a, b domain.ReviewItem) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return res, nil
+}
+
+func (r *RedisReviewRepository) GetItem(ctx context.Context, campaignID, itemID int64) (domain.ReviewItem, error) {
+	field := strconv.FormatInt(itemID, 10)
+	val, err := r.client.HGet(ctx, r.itemsKey(campaignID), field).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.ReviewItem{}, ErrReviewItemNotFound
+	}
+	if err != nil {
+		return domain.ReviewItem{}, err
+	}
+	decision, err := r.client.HGet(ctx, r.decisionsKey(campaignID), field).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return domain.ReviewItem{}, err
+	}
+	return r.toItem(campaignID, itemID, val, decision)
+}
+
+func (r *RedisReviewRepository) Decide(ctx context.Context, item domain.ReviewItem) error {
+	val, err := json.Marshal(ReviewDecisionEntity{
+		Decision:  item.Decision.String(),
+		Comment:   item.Comment,
+		DecidedBy: item.DecidedBy,
+		DecidedAt: item.DecidedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化审查结论失败: %w", err)
+	}
+	ok, err := r.client.HSetNX(ctx, r.decisionsKey(item.CampaignID), strconv.FormatInt(item.ID, 10), string(val)).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.ErrReviewItemDecided
+	}
+	return nil
+}
+
+func (r *RedisReviewRepository) Reassign(ctx context.Context, items []domain.ReviewItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range items {
+			val, err := json.Marshal(r.toItemEntity(items[i]))
+			if err != nil {
+				return fmt.Errorf("序列化审查项失败: %w", err)
+			}
+			pipe.HSet(ctx, r.itemsKey(items[i].CampaignID), strconv.FormatInt(items[i].ID, 10), string(val))
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisReviewRepository) key(id int64) string {
+	return fmt.Sprintf("review:campaign:%d", id)
+}
+
+func (r *RedisReviewRepository) itemsKey(id int64) string {
+	return fmt.Sprintf("review:items:%d", id)
+}
+
+func (r *RedisReviewRepository) decisionsKey(id int64) string {
+	return fmt.Sprintf("review:decisions:%d", id)
+}
+
+func (r *RedisReviewRepository) bizKey(bizID int64) string {
+	return fmt.Sprintf("review:campaigns:%d", bizID)
+}
+
+func (r *RedisReviewRepository) dueKey() string {
+	return "review:due"
+}
+
+func (r *RedisReviewRepository) toCampaignEntity(c domain.ReviewCampaign) ReviewCampaignEntity {
+	return ReviewCampaignEntity{
+		BizID:           c.BizID,
+		Name:            c.Name,
+		RoleIDs:         c.RoleIDs,
+		Privileged:      c.Privileged,
+		DefaultReviewer: c.DefaultReviewer,
+		Deadline:        c.Deadline,
+		ExpirePolicy:    string(c.ExpirePolicy),
+		EscalateTo:      c.EscalateTo,
+		CreatorUID:      c.CreatorUID,
+		Status:          c.Status.String(),
+		Ctime:           c.Ctime,
+		Utime:           c.Utime,
+	}
+}
+
+func (r *RedisReviewRepository) toCampaign(id int64, val string) (domain.ReviewCampaign, error) {
+	var entity ReviewCampaignEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.ReviewCampaign{}, fmt.Errorf("反序列化权限审查失败: %w", err)
+	}
+	return domain.ReviewCampaign{
+		ID:              id,
+		BizID:           entity.BizID,
+		Name:            entity.Name,
+		RoleIDs:         entity.RoleIDs,
+		Privileged:      entity.Privileged,
+		DefaultReviewer: entity.DefaultReviewer,
+		Deadline:        entity.Deadline,
+		ExpirePolicy:    domain.ReviewExpirePolicy(entity.ExpirePolicy),
+		EscalateTo:      entity.EscalateTo,
+		CreatorUID:      entity.CreatorUID,
+		Status:          domain.ReviewCampaignStatus(entity.Status),
+		Ctime:           entity.Ctime,
+		Utime:           entity.Utime,
+	}, nil
+}
+
+func (r *RedisReviewRepository) toItemEntity(item domain.ReviewItem) ReviewItemEntity {
+	return ReviewItemEntity{
+		UserRoleID: item.UserRoleID,
+		UserID:     item.UserID,
+		RoleID:     item.RoleID,
+		RoleName:   item.RoleName,
+		RoleType:   item.RoleType,
+		StartTime:  item.StartTime,
+		EndTime:    item.EndTime,
+		Reviewer:   item.Reviewer,
+		Escalated:  item.Escalated,
+	}
+}
+
+func (r *RedisReviewRepository) toItem(campaignID, id int64, val, decision string) (domain.ReviewItem, error) {
+	var entity ReviewItemEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.ReviewItem{}, fmt.Errorf("反序列化审查项失败: %w", err)
+	}
+	item := domain.ReviewItem{
+		ID:         id,
+		CampaignID: campaignID,
+		UserRoleID: entity.UserRoleID,
+		UserID:     entity.UserID,
+		RoleID:     entity.RoleID,
+		RoleName:   entity.RoleName,
+		RoleType:   entity.RoleType,
+		StartTime:  entity.StartTime,
+		EndTime:    entity.EndTime,
+		Reviewer:   entity.Reviewer,
+		Escalated:  entity.Escalated,
+		Decision:   domain.ReviewPending,
+	}
+	if decision == "" {
+		return item, nil
+	}
+	var d ReviewDecisionEntity
+	if err := json.Unmarshal([]byte(decision), &d); err != nil {
+		return domain.ReviewItem{}, fmt.Errorf("反序列化审查结论失败: %w", err)
+	}
+	item.Decision = domain.ReviewDecision(d.Decision)
+	item.Comment = d.Comment
+	item.DecidedBy = d.DecidedBy
+	item.DecidedAt = d.DecidedAt
+	return item, nil
+}
+
+type ReviewCampaignEntity struct {
+	BizID           int64   `json:"bizId"`
+	Name            string  `json:"name"`
+	RoleIDs         []int64 `json:"roleIds,omitzero"`
+	Privileged      bool    `json:"privileged,omitzero"`
+	DefaultReviewer int64   `json:"defaultReviewer"`
+	Deadline        int64   `json:"deadline"`
+	ExpirePolicy    string  `json:"expirePolicy"`
+	EscalateTo      int64   `json:"escalateTo,omitzero"`
+	CreatorUID      int64   `json:"creatorUid"`
+	Status          string  `json:"status"`
+	Ctime           int64   `json:"ctime"`
+	Utime           int64   `json:"utime"`
+}
+
+type ReviewItemEntity struct {
+	UserRoleID int64  `json:"userRoleId"`
+	UserID     int64  `json:"userId"`
+	RoleID     int64  `json:"roleId"`
+	RoleName   string `json:"roleName"`
+	RoleType   string `json:"roleType,omitzero"`
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	Reviewer   int64  `json:"reviewer"`
+	Escalated  bool   `json:"escalated,omitzero"`
+}
+
+type ReviewDecisionEntity struct {
+	Decision  string `json:"decision"`
+	Comment   string `json:"comment,omitzero"`
+	DecidedBy int64  `json:"decidedBy"`
+	DecidedAt int64  `json:"decidedAt"`
+}
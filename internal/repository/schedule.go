@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrScheduledChangeNotFound = errors.New("定时变更不存在")
+
+var (
+	//go:embed lua/schedule_claim.lua
+	luaScheduleClaim string
+	//go:embed lua/schedule_take.lua
+	luaScheduleTake string
+)
+
+type ScheduledChangeRepository interface {
+	// Create 保存定时变更并且按照执行时间加入待执行索引
+	Create(ctx context.Context, change domain.ScheduledChange) (domain.ScheduledChange, error)
+	// Save 保存定时变更并且清除执行中标记，
+	// pending 状态的变更按照执行时间放回待执行索引，其他状态的变更移出待执行索引
+	Save(ctx context.Context, change domain.ScheduledChange) error
+	Get(ctx context.Context, id int64) (domain.ScheduledChange, error)
+	// List 按照创建时间倒序查询
+	List(ctx context.Context, bizID int64, offset, limit int) ([]domain.ScheduledChange, error)
+	// ListDue 返回执行时间早于 now 的定时变更ID
+	ListDue(ctx context.Context, now int64, limit int64) ([]int64, error)
+	// Claim 执行任务认领一个到期的定时变更，认领成功后在 lease 时间内其他调用方都无法认领
+	Claim(ctx context.Context, id, now int64, lease time.Duration) (bool, error)
+	// Take 把还没有开始执行的定时变更移出待执行索引，取消和修改执行时间之前调用
+	// 已经在执行或者已经结束时返回 false
+	Take(ctx context.Context, id int64) (bool, error)
+}
+
+// RedisScheduledChangeRepository 定时变更保存在 Redis 中，待执行的变更按照执行时间放在一个有序集合里
+// 执行中的变更有一个带租期的标记，取消和修改执行时间都不能和执行同时进行
+type RedisScheduledChangeRepository struct {
+	client redis.Cmdable
+	claim  *redis.Script
+	take   *redis.Script
+}
+
+func NewRedisScheduledChangeRepository(client redis.Cmdable) ScheduledChangeRepository {
+	return &RedisScheduledChangeRepository{
+		client: client,
+		claim:  redis.NewScript(luaScheduleClaim),
+		take:   redis.NewScript(luaScheduleTake),
+	}
+}
+
+func (r *RedisScheduledChangeRepository) Create(ctx context.Context, change domain.ScheduledChange) (domain.ScheduledChange, error) {
+	id, err := r.client.Incr(ctx, "schedule:change:id").Result()
+	if err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	change.ID = id
+	val, err := json.Marshal(r.toEntity(change))
+	if err != nil {
+		return domain.ScheduledChange{}, fmt.Errorf("序列化定时变更失败: %w", err)
+	}
+	member := strconv.FormatInt(id, 10)
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(id), string(val), 0)
+		pipe.ZAdd(ctx, r.bizKey(change.BizID), redis.Z{Score: float64(change.Ctime), Member: member})
+		pipe.ZAdd(ctx, r.dueKey(), redis.Z{Score: float64(change.ExecuteAt), Member: member})
+		return nil
+	})
+	return change, err
+}
+
+func (r *RedisScheduledChangeRepository) Save(ctx context.Context, change domain.ScheduledChange) error {
+	val, err := json.Marshal(r.toEntity(change))
+	if err != nil {
+		return fmt.Errorf("序列化定时变更失败: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		member := strconv.FormatInt(change.ID, 10)
+		pipe.Set(ctx, r.key(change.ID), string(val), 0)
+		if change.Status == domain.ScheduledChangePending {
+			pipe.ZAdd(ctx, r.dueKey(), redis.Z{Score: float64(change.ExecuteAt), Member: member})
+		} else {
+			pipe.ZRem(ctx, r.dueKey(), member)
+		}
+		pipe.Del(ctx, r.runningKey(change.ID))
+		return nil
+	})
+	return err
+}
+
+func (r *RedisScheduledChangeRepository) Get(ctx context.Context, id int64) (domain.ScheduledChange, error) {
+	val, err := r.client.Get(ctx, r.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.ScheduledChange{}, ErrScheduledChangeNotFound
+	}
+	if err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	return r.toDomain(id, val)
+}
+
+func (r *RedisScheduledChangeRepository) List(ctx context.Context, bizID int64, offset, limit int) ([]domain.ScheduledChange, error) {
+	members, err := r.client.ZRevRange(ctx, r.bizKey(bizID), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(members))
+	ids := make([]int64, len(members))
+	for i := range members {
+		ids[i], _ = strconv.ParseInt(members[i], 10, 64)
+		keys[i] = r.key(ids[i])
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ScheduledChange, 0, len(vals))
+	for i := range vals {
+		val, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		change, err1 := r.toDomain(ids[i], val)
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, change)
+	}
+	return res, nil
+}
+
+func (r *RedisScheduledChangeRepository) ListDue(ctx context.Context, now int64, limit int64) ([]int64, error) {
+	members, err := r.client.ZRangeByScore(ctx, r.dueKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now, 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(members))
+	for i := range members {
+		id, err1 := strconv.ParseInt(members[i], 10, 64)
+		if err1 != nil {
+			continue
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (r *RedisScheduledChangeRepository) Claim(ctx context.Context, id, now int64, lease time.Duration) (bool, error) {
+	n, err := r.claim.Run(ctx, r.client, []string{r.dueKey(), r.runningKey(id)}, id, now, lease.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (r *RedisScheduledChangeRepository) Take(ctx context.Context, id int64) (bool, error) {
+	n, err := r.take.Run(ctx, r.client, []string{r.dueKey(), r.runningKey(id)}, id).Int()
+	return n == 1, err
+}
+
+func (r *RedisScheduledChangeRepository) key(id int64) string {
+	return fmt.Sprintf("schedule:change:%d", id)
+}
+
+func (r *RedisScheduledChangeRepository) bizKey(bizID int64) string {
+	return fmt.Sprintf("schedule:changes:%d", bizID)
+}
+
+func (r *RedisScheduledChangeRepository) runningKey(id int64) string {
+	return fmt.Sprintf("schedule:running:%d", id)
+}
+
+func (r *RedisScheduledChangeRepository) dueKey() string {
+	return "schedule:due"
+}
+
+func (r *RedisScheduledChangeRepository) toEntity(change domain.ScheduledChange) ScheduledChangeEntity {
+	return ScheduledChangeEntity{
+		BizID:        change.BizID,
+		Kind:         change.Kind.String(),
+		TargetID:     change.TargetID,
+		TargetUserID: change.TargetUserID,
+		Payload:      change.Payload,
+		Reason:       change.Reason,
+		CreatorUID:   change.CreatorUID,
+		ExecuteAt:    change.ExecuteAt,
+		Status:       change.Status.String(),
+		Attempts:     change.Attempts,
+		Result:       change.Result,
+		ExecutedAt:   change.ExecutedAt,
+		Ctime:        change.Ctime,
+		Utime:        change.Utime,
+	}
+}
+
+func (r *RedisScheduledChangeRepository) toDomain(id int64, val string) (domain.ScheduledChange, error) {
+	var entity ScheduledChangeEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.ScheduledChange{}, fmt.Errorf("反序列化定时变更失败: %w", err)
+	}
+	return domain.ScheduledChange{
+		ID:           id,
+		BizID:        entity.BizID,
+		Kind:         domain.ScheduledChangeKind(entity.Kind),
+		TargetID:     entity.TargetID,
+		TargetUserID: entity.TargetUserID,
+		Payload:      entity.Payload,
+		Reason:       entity.Reason,
+		CreatorUID:   entity.CreatorUID,
+		ExecuteAt:    entity.ExecuteAt,
+		Status:       domain.ScheduledChangeStatus(entity.Status),
+		Attempts:     entity.Attempts,
+		Result:       entity.Result,
+		ExecutedAt:   entity.ExecutedAt,
+		Ctime:        entity.Ctime,
+		Utime:        entity.Utime,
+	}, nil
+}
+
+type ScheduledChangeEntity struct {
+	BizID        int64  `json:"bizId"`
+	Kind         string `json:"kind"`
+	TargetID     int64  `json:"targetId,omitzero"`
+	TargetUserID int64  `json:"targetUserId,omitzero"`
+	Payload      string `json:"payload"`
+	Reason       string `json:"reason,omitzero"`
+	CreatorUID   int64  `json:"creatorUid"`
+	ExecuteAt    int64  `json:"executeAt"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts,omitzero"`
+	Result       string `json:"result,omitzero"`
+	ExecutedAt   int64  `json:"executedAt,omitzero"`
+	Ctime        int64  `json:"ctime"`
+	Utime        int64  `json:"utime"`
+}
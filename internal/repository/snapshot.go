@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrSnapshotNotFound = errors.New("快照不存在")
+
+type SnapshotRepository interface {
+	// Create 压缩保存快照的模型，返回填写了ID和大小的快照
+	Create(ctx context.Context, snapshot domain.Snapshot) (domain.Snapshot, error)
+	// Get 查询快照并且加载完整的模型
+	Get(ctx context.Context, id int64) (domain.Snapshot, error)
+	// List 按照创建时间倒序查询业务的全部快照，不加载模型
+	List(ctx context.Context, bizID int64) ([]domain.Snapshot, error)
+	Delete(ctx context.Context, bizID, id int64) error
+}
+
+// SnapshotBlobStore 保存压缩后的快照模型
+type SnapshotBlobStore interface {
+	Put(ctx context.Context, bizID, id int64, data []byte) error
+	Get(ctx context.Context, bizID, id int64) ([]byte, error)
+	Delete(ctx context.Context, bizID, id int64) error
+}
+
+// RedisSnapshotRepository 快照的元数据保存在 Redis 中，每个业务的快照按照创建时间放在一个有序集合里
+// 模型用 gzip 压缩后保存在 SnapshotBlobStore 中，可以是 Redis 也可以是本地磁盘
+type RedisSnapshotRepository struct {
+	client redis.Cmdable
+	blobs  SnapshotBlobStore
+}
+
+func NewRedisSnapshotRepository(client redis.Cmdable, blobs SnapshotBlobStore) SnapshotRepository {
+	return &RedisSnapshotRepository{client: client, blobs: blobs}
+}
+
+func (r *RedisSnapshotRepository) Create(ctx context.Context, snapshot domain.Snapshot) (domain.Snapshot, error) {
+	data, err := r.compress(snapshot.Model)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	id, err := r.client.Incr(ctx, "snapshot:id").Result()
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	snapshot.ID = id
+	snapshot.Size = len(data)
+	snapshot.Counts = snapshot.Model.Counts()
+	val, err := json.Marshal(r.toEntity(snapshot))
+	if err != nil {
+		return domain.Snapshot{}, fmt.Errorf("序列化快照失败: %w", err)
+	}
+	// 先保存模型再保存元数据，查询到的快照一定有模型
+	if err = r.blobs.Put(ctx, snapshot.BizID, id, data); err != nil {
+		return domain.Snapshot{}, fmt.Errorf("保存快照模型失败: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(id), string(val), 0)
+		pipe.ZAdd(ctx, r.bizKey(snapshot.BizID), redis.Z{Score: float64(snapshot.Ctime), Member: strconv.FormatInt(id, 10)})
+		return nil
+	})
+	return snapshot, err
+}
+
+func (r *RedisSnapshotRepository) Get(ctx context.Context, id int64) (domain.Snapshot, error) {
+	val, err := r.client.Get(ctx, r.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.Snapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	snapshot, err := r.toDomain(id, val)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	data, err := r.blobs.Get(ctx, snapshot.BizID, id)
+	if err != nil {
+		return domain.Snapshot{}, fmt.Errorf("读取快照模型失败: %w", err)
+	}
+	snapshot.Model, err = r.decompress(data)
+	return snapshot, err
+}
+
+func (r *RedisSnapshotRepository) List(ctx context.Context, bizID int64) ([]domain.Snapshot, error) {
+	members, err := r.client.ZRevRange(ctx, r.bizKey(bizID), 0, -1).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(members))
+	ids := make([]int64, len(members))
+	for i := range members {
+		ids[i], _ = strconv.ParseInt(members[i], 10, 64)
+		keys[i] = r.key(ids[i])
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Snapshot, 0, len(vals))
+	for i := range vals {
+		val, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		snapshot, err1 := r.toDomain(ids[i], val)
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, snapshot)
+	}
+	return res, nil
+}
+
+// Delete 先删除元数据再删除模型，中途失败时只会留下查询不到的模型
+func (r *RedisSnapshotRepository) Delete(ctx context.Context, bizID, id int64) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.key(id))
+		pipe.ZRem(ctx, r.bizKey(bizID), strconv.FormatInt(id, 10))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.blobs.Delete(ctx, bizID, id)
+}
+
+func (r *RedisSnapshotRepository) key(id int64) string {
+	return fmt.Sprintf("snapshot:%d", id)
+}
+
+func (r *RedisSnapshotRepository) bizKey(bizID int64) string {
+	return fmt.Sprintf("snapshots:%d", bizID)
+}
+
+func (r *RedisSnapshotRepository) compress(model domain.RBACModel) ([]byte, error) {
+	val, err := json.Marshal(r.toModelEntity(model))
+	if err != nil {
+		return nil, fmt.Errorf("序列化快照模型失败: %w", err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(val); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *RedisSnapshotRepository) decompress(data []byte) (domain.RBACModel, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return domain.RBACModel{}, fmt.Errorf("解压快照模型失败: %w", err)
+	}
+	defer reader.Close()
+	var entity SnapshotModelEntity
+	if err = json.NewDecoder(reader).Decode(&entity); err != nil {
+		return domain.RBACModel{}, fmt.Errorf("反序列化快照模型失败: %w", err)
+	}
+	return r.toModelDomain(entity), nil
+}
+
+func (r *RedisSnapshotRepository) toEntity(snapshot domain.Snapshot) SnapshotEntity {
+	counts := make(map[string]int, len(snapshot.Counts))
+	for table, n := range snapshot.Counts {
+		counts[table.String()] = n
+	}
+	return SnapshotEntity{
+		BizID:      snapshot.BizID,
+		Trigger:    snapshot.Trigger.String(),
+		CreatorUID: snapshot.CreatorUID,
+		Note:       snapshot.Note,
+		Counts:     counts,
+		Size:       snapshot.Size,
+		Ctime:      snapshot.Ctime,
+	}
+}
+
+func (r *RedisSnapshotRepository) toDomain(id int64, val string) (domain.Snapshot, error) {
+	var entity SnapshotEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.Snapshot{}, fmt.Errorf("反序列化快照失败: %w", err)
+	}
+	counts := make(map[domain.SystemTableResource]int, len(entity.Counts))
+	for table, n := range entity.Counts {
+		counts[domain.SystemTableResource(table)] = n
+	}
+	return domain.Snapshot{
+		ID:         id,
+		BizID:      entity.BizID,
+		Trigger:    domain.SnapshotTrigger(entity.Trigger),
+		CreatorUID: entity.CreatorUID,
+		Note:       entity.Note,
+		Counts:     counts,
+		Size:       entity.Size,
+		Ctime:      entity.Ctime,
+	}, nil
+}
+
+// toModelEntity 快照里的对象互相用引用标识关联，不保存ID，回滚时对象可能已经被删除重建
+func (r *RedisSnapshotRepository) toModelEntity(model domain.RBACModel) SnapshotModelEntity {
+	return SnapshotModelEntity{
+		Resources: slice.Map(model.Resources, func(_ int, src domain.ModelResource) SnapshotResourceEntity {
+			return SnapshotResourceEntity{Type: src.Type, Key: src.Key, Name: src.Name, Description: src.Description, Metadata: src.Metadata}
+		}),
+		Permissions: slice.Map(model.Permissions, func(_ int, src domain.ModelPermission) SnapshotPermissionEntity {
+			return SnapshotPermissionEntity{
+				Name:         src.Name,
+				Description:  src.Description,
+				ResourceType: src.ResourceType,
+				ResourceKey:  src.ResourceKey,
+				Action:       src.Action,
+				Metadata:     src.Metadata,
+			}
+		}),
+		Roles: slice.Map(model.Roles, func(_ int, src domain.ModelRole) SnapshotRoleEntity {
+			return SnapshotRoleEntity{Name: src.Name, Description: src.Description, Metadata: src.Metadata, Type: src.Type}
+		}),
+		RoleInclusions: slice.Map(model.RoleInclusions, func(_ int, src domain.ModelRoleInclusion) SnapshotRoleInclusionEntity {
+			return SnapshotRoleInclusionEntity{Including: src.Including, Included: src.Included}
+		}),
+		RolePermissions: slice.Map(model.RolePermissions, func(_ int, src domain.ModelRolePermission) SnapshotRolePermissionEntity {
+			return SnapshotRolePermissionEntity{Role: src.Role, ResourceType: src.ResourceType, ResourceKey: src.ResourceKey, Action: src.Action}
+		}),
+		UserRoles: slice.Map(model.UserRoles, func(_ int, src domain.ModelUserRole) SnapshotUserRoleEntity {
+			return SnapshotUserRoleEntity{UserID: src.UserID, Role: src.Role, StartTime: src.StartTime, EndTime: src.EndTime}
+		}),
+		UserPermissions: slice.Map(model.UserPermissions, func(_ int, src domain.ModelUserPermission) SnapshotUserPermissionEntity {
+			return SnapshotUserPermissionEntity{
+				UserID:       src.UserID,
+				ResourceType: src.ResourceType,
+				ResourceKey:  src.ResourceKey,
+				Action:       src.Action,
+				Effect:       src.Effect.String(),
+				StartTime:    src.StartTime,
+				EndTime:      src.EndTime,
+			}
+		}),
+	}
+}
+
+func (r *RedisSnapshotRepository) toModelDomain(entity SnapshotModelEntity) domain.RBACModel {
+	return domain.RBACModel{
+		Resources: slice.Map(entity.Resources, func(_ int, src SnapshotResourceEntity) domain.ModelResource {
+			return domain.ModelResource{Type: src.Type, Key: src.Key, Name: src.Name, Description: src.Description, Metadata: src.Metadata}
+		}),
+		Permissions: slice.Map(entity.Permissions, func(_ int, src SnapshotPermissionEntity) domain.ModelPermission {
+			return domain.ModelPermission{
+				Name:         src.Name,
+				Description:  src.Description,
+				ResourceType: src.ResourceType,
+				ResourceKey:  src.ResourceKey,
+				Action:       src.Action,
+				Metadata:     src.Metadata,
+			}
+		}),
+		Roles: slice.Map(entity.Roles, func(_ int, src SnapshotRoleEntity) domain.ModelRole {
+			return domain.ModelRole{Name: src.Name, Description: src.Description, Metadata: src.Metadata, Type: src.Type}
+		}),
+		RoleInclusions: slice.Map(entity.RoleInclusions, func(_ int, src SnapshotRoleInclusionEntity) domain.ModelRoleInclusion {
+			return domain.ModelRoleInclusion{Including: src.Including, Included: src.Included}
+		}),
+		RolePermissions: slice.Map(entity.RolePermissions, func(_ int, src SnapshotRolePermissionEntity) domain.ModelRolePermission {
+			return domain.ModelRolePermission{Role: src.Role, ResourceType: src.ResourceType, ResourceKey: src.ResourceKey, Action: src.Action}
+		}),
+		UserRoles: slice.Map(entity.UserRoles, func(_ int, src SnapshotUserRoleEntity) domain.ModelUserRole {
+			return domain.ModelUserRole{UserID: src.UserID, Role: src.Role, StartTime: src.StartTime, EndTime: src.EndTime}
+		}),
+		UserPermissions: slice.Map(entity.UserPermissions, func(_ int, src SnapshotUserPermissionEntity) domain.ModelUserPermission {
+			return domain.ModelUserPermission{
+				UserID:       src.UserID,
+				ResourceType: src.ResourceType,
+				ResourceKey:  src.ResourceKey,
+				Action:       src.Action,
+				Effect:       domain.Effect(src.Effect),
+				StartTime:    src.StartTime,
+				EndTime:      src.EndTime,
+			}
+		}),
+	}
+}
+
+// RedisSnapshotBlobStore 压缩后的模型直接保存在 Redis 中，适合多实例部署
+type RedisSnapshotBlobStore struct {
+	client redis.Cmdable
+}
+
+func NewRedisSnapshotBlobStore(client redis.Cmdable) SnapshotBlobStore {
+	return &RedisSnapshotBlobStore{client: client}
+}
+
+func (s *RedisSnapshotBlobStore) Put(ctx context.Context, _, id int64, data []byte) error {
+	return s.client.Set(ctx, s.key(id), data, 0).Err()
+}
+
+func (s *RedisSnapshotBlobStore) Get(ctx context.Context, _, id int64) ([]byte, error) {
+	data, err := s.client.Get(ctx, s.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSnapshotNotFound
+	}
+	return data, err
+}
+
+func (s *RedisSnapshotBlobStore) Delete(ctx context.Context, _, id int64) error {
+	return s.client.Del(ctx, s.key(id)).Err()
+}
+
+func (s *RedisSnapshotBlobStore) key(id int64) string {
+	return fmt.Sprintf("snapshot:data:%d", id)
+}
+
+// FileSnapshotBlobStore 压缩后的模型保存在本地磁盘的 {dir}/{bizId}/{id}.json.gz
+// 多实例部署时 dir 需要是共享存储，否则只有创建快照的实例可以读取
+type FileSnapshotBlobStore struct {
+	dir string
+}
+
+func NewFileSnapshotBlobStore(dir string) SnapshotBlobStore {
+	return &FileSnapshotBlobStore{dir: dir}
+}
+
+func (s *FileSnapshotBlobStore) Put(_ context.Context, bizID, id int64, data []byte) error {
+	path := s.path(bizID, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，进程中途退出不会留下不完整的快照
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileSnapshotBlobStore) Get(_ context.Context, bizID, id int64) ([]byte, error) {
+	file, err := os.Open(s.path(bizID, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func (s *FileSnapshotBlobStore) Delete(_ context.Context, bizID, id int64) error {
+	err := os.Remove(s.path(bizID, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileSnapshotBlobStore) path(bizID, id int64) string {
+	return filepath.Join(s.dir, strconv.FormatInt(bizID, 10), strconv.FormatInt(id, 10)+".json.gz")
+}
+
+type SnapshotEntity struct {
+	BizID      int64          `json:"bizId"`
+	Trigger    string         `json:"trigger"`
+	CreatorUID int64          `json:"creatorUid,omitzero"`
+	Note       string         `json:"note,omitzero"`
+	Counts     map[string]int `json:"counts"`
+	Size       int            `json:"size"`
+	Ctime      int64          `json:"ctime"`
+}
+
+type SnapshotModelEntity struct {
+	Resources       []SnapshotResourceEntity       `json:"resources,omitzero"`
+	Permissions     []SnapshotPermissionEntity     `json:"permissions,omitzero"`
+	Roles           []SnapshotRoleEntity           `json:"roles,omitzero"`
+	RoleInclusions  []SnapshotRoleInclusionEntity  `json:"roleInclusions,omitzero"`
+	RolePermissions []SnapshotRolePermissionEntity `json:"rolePermissions,omitzero"`
+	UserRoles       []SnapshotUserRoleEntity       `json:"userRoles,omitzero"`
+	UserPermissions []SnapshotUserPermissionEntity `json:"userPermissions,omitzero"`
+}
+
+type SnapshotResourceEntity struct {
+	Type        string `json:"type"`
+	Key         string `json:"key"`
+	Name        string `json:"name,omitzero"`
+	Description string `json:"description,omitzero"`
+	Metadata    string `json:"metadata,omitzero"`
+}
+
+type SnapshotPermissionEntity struct {
+	Name         string `json:"name,omitzero"`
+	Description  string `json:"description,omitzero"`
+	ResourceType string `json:"resourceType"`
+	ResourceKey  string `json:"resourceKey"`
+	Action       string `json:"action"`
+	Metadata     string `json:"metadata,omitzero"`
+}
+
+type SnapshotRoleEntity struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitzero"`
+	Metadata    string `json:"metadata,omitzero"`
+	// Type 为空时是业务角色，账号角色依靠它和业务角色区分
+	Type string `json:"type,omitzero"`
+}
+
+type SnapshotRoleInclusionEntity struct {
+	Including string `json:"including"`
+	Included  string `json:"included"`
+}
+
+type SnapshotRolePermissionEntity struct {
+	Role         string `json:"role"`
+	ResourceType string `json:"resourceType"`
+	ResourceKey  string `json:"resourceKey"`
+	Action       string `json:"action"`
+}
+
+type SnapshotUserRoleEntity struct {
+	UserID    int64  `json:"userId"`
+	Role      string `json:"role"`
+	StartTime int64  `json:"startTime,omitzero"`
+	EndTime   int64  `json:"endTime,omitzero"`
+}
+
+type SnapshotUserPermissionEntity struct {
+	UserID       int64  `json:"userId"`
+	ResourceType string `json:"resourceType"`
+	ResourceKey  string `json:"resourceKey"`
+	Action       string `json:"action"`
+	Effect       string `json:"effect"`
+	StartTime    int64  `json:"startTime,omitzero"`
+	EndTime      int64  `json:"endTime,omitzero"`
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrSoDConstraintNotFound = errors.New("职责分离约束不存在")
+
+type SoDConstraintRepository interface {
+	Create(ctx context.Context, constraint domain.SoDConstraint) (domain.SoDConstraint, error)
+	Get(ctx context.Context, id int64) (domain.SoDConstraint, error)
+	// List 按照创建时间顺序查询业务的全部约束，每次授权都要检查全部约束，所以不分页
+	List(ctx context.Context, bizID int64) ([]domain.SoDConstraint, error)
+	Delete(ctx context.Context, bizID, id int64) error
+}
+
+// RedisSoDConstraintRepository 约束保存在 Redis 中，每个业务的约束按照创建时间放在一个有序集合里
+type RedisSoDConstraintRepository struct {
+	client redis.Cmdable
+}
+
+func NewRedisSoDConstraintRepository(client redis.Cmdable) SoDConstraintRepository {
+	return &RedisSoDConstraintRepository{client: client}
+}
+
+func (r *RedisSoDConstraintRepository) Create(ctx context.Context, constraint domain.SoDConstraint) (domain.SoDConstraint, error) {
+	id, err := r.client.Incr(ctx, "sod:constraint:id").Result()
+	if err != nil {
+		return domain.SoDConstraint{}, err
+	}
+	constraint.ID = id
+	val, err := json.Marshal(r.toEntity(constraint))
+	if err != nil {
+		return domain.SoDConstraint{}, fmt.Errorf("序列化职责分离约束失败: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(id), string(val), 0)
+		pipe.ZAdd(ctx, r.bizKey(constraint.BizID), redis.Z{Score: float64(constraint.Ctime), Member: strconv.FormatInt(id, 10)})
+		return nil
+	})
+	return constraint, err
+}
+
+func (r *RedisSoDConstraintRepository) Get(ctx context.Context, id int64) (domain.SoDConstraint, error) {
+	val, err := r.client.Get(ctx, r.key(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return domain.SoDConstraint{}, ErrSoDConstraintNotFound
+	}
+	if err != nil {
+		return domain.SoDConstraint{}, err
+	}
+	return r.toDomain(id, val)
+}
+
+func (r *RedisSoDConstraintRepository) List(ctx context.Context, bizID int64) ([]domain.SoDConstraint, error) {
+	members, err := r.client.ZRange(ctx, r.bizKey(bizID), 0, -1).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(members))
+	ids := make([]int64, len(members))
+	for i := range members {
+		ids[i], _ = strconv.ParseInt(members[i], 10, 64)
+		keys[i] = r.key(ids[i])
+	}
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SoDConstraint, 0, len(vals))
+	for i := range vals {
+		val, ok := vals[i].(string)
+		if !ok {
+			continue
+		}
+		constraint, err1 := r.toDomain(ids[i], val)
+		if err1 != nil {
+			return nil, err1
+		}
+		res = append(res, constraint)
+	}
+	return res, nil
+}
+
+func (r *RedisSoDConstraintRepository) Delete(ctx context.Context, bizID, id int64) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.key(id))
+		pipe.ZRem(ctx, r.bizKey(bizID), strconv.FormatInt(id, 10))
+		return nil
+	})
+	return err
+}
+
+func (r *RedisSoDConstraintRepository) key(id int64) string {
+	return fmt.Sprintf("sod:constraint:%d", id)
+}
+
+func (r *RedisSoDConstraintRepository) bizKey(bizID int64) string {
+	return fmt.Sprintf("sod:constraints:%d", bizID)
+}
+
+func (r *RedisSoDConstraintRepository) toEntity(constraint domain.SoDConstraint) SoDConstraintEntity {
+	return SoDConstraintEntity{
+		BizID:       constraint.BizID,
+		Name:        constraint.Name,
+		Description: constraint.Description,
+		Kind:        constraint.Kind.String(),
+		Members:     constraint.Members,
+		CreatorUID:  constraint.CreatorUID,
+		Ctime:       constraint.Ctime,
+		Utime:       constraint.Utime,
+	}
+}
+
+func (r *RedisSoDConstraintRepository) toDomain(id int64, val string) (domain.SoDConstraint, error) {
+	var entity SoDConstraintEntity
+	if err := json.Unmarshal([]byte(val), &entity); err != nil {
+		return domain.SoDConstraint{}, fmt.Errorf("反序列化职责分离约束失败: %w", err)
+	}
+	return domain.SoDConstraint{
+		ID:          id,
+		BizID:       entity.BizID,
+		Name:        entity.Name,
+		Description: entity.Description,
+		Kind:        domain.SoDKind(entity.Kind),
+		Members:     entity.Members,
+		CreatorUID:  entity.CreatorUID,
+		Ctime:       entity.Ctime,
+		Utime:       entity.Utime,
+	}, nil
+}
+
+type SoDConstraintEntity struct {
+	BizID       int64    `json:"bizId"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitzero"`
+	Kind        string   `json:"kind"`
+	Members     []string `json:"members"`
+	CreatorUID  int64    `json:"creatorUid"`
+	Ctime       int64    `json:"ctime"`
+	Utime       int64    `json:"utime"`
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+)
+
+// accessStatusActive 查询仍然有效的自助申请授权，不是变更请求本身的状态
+const accessStatusActive = "active"
+
+// AccessService 用户自助申请限时角色
+// 申请复用变更请求的审批流程，默认由角色负责人中的任意一人审批
+type AccessService struct {
+	repo      repository.AccessPolicyRepository
+	approvals *ApprovalService
+}
+
+func NewAccessService(repo repository.AccessPolicyRepository, approvals *ApprovalService) *AccessService {
+	return &AccessService{repo: repo, approvals: approvals}
+}
+
+func (s *AccessService) SavePolicy(ctx context.Context, policy domain.AccessPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	old, err := s.repo.Get(ctx, policy.BizID, policy.RoleID)
+	switch {
+	case err == nil:
+		policy.Ctime = old.Ctime
+	case errors.Is(err, repository.ErrAccessPolicyNotFound):
+		policy.Ctime = now
+	default:
+		return err
+	}
+	policy.Utime = now
+	return s.repo.Save(ctx, policy)
+}
+
+func (s *AccessService) DeletePolicy(ctx context.Context, bizID, roleID int64) error {
+	return s.repo.Delete(ctx, bizID, roleID)
+}
+
+func (s *AccessService) ListPolicies(ctx context.Context, bizID int64) ([]domain.AccessPolicy, error) {
+	return s.repo.List(ctx, bizID)
+}
+
+// Request 提交自助申请，req.TargetID 为申请的角色，req.Duration 为申请时长
+// 角色同时被审批策略覆盖时，按照审批策略的 M-of-N 要求审批，否则由任意一个角色负责人审批
+func (s *AccessService) Request(ctx context.Context, req domain.ChangeRequest) (domain.ChangeRequest, error) {
+	policy, err := s.repo.Get(ctx, req.BizID, req.TargetID)
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	if req.Duration <= 0 || req.Duration > policy.MaxDuration {
+		return domain.ChangeRequest{}, fmt.Errorf("申请时长必须在 (0, %s] 范围内", time.Duration(policy.MaxDuration)*time.Millisecond)
+	}
+	if req.Reason == "" {
+		return domain.ChangeRequest{}, errors.New("申请理由不能为空")
+	}
+	req.Kind = domain.ChangeRequestAccessRole
+	approval, ok, err := s.approvals.MatchPolicy(ctx, req.BizID, req.Kind, req.TargetID)
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	if !ok {
+		approval = domain.ApprovalPolicy{Approvers: policy.Owners, RequiredApprovals: 1}
+	}
+	return s.approvals.Submit(ctx, approval, req)
+}
+
+// ListMine 查询用户自己的自助申请，status 为 active 时只返回仍然有效的授权
+func (s *AccessService) ListMine(ctx context.Context, bizID, uid int64, status string, offset, limit int) ([]domain.ChangeRequest, error) {
+	query := domain.ChangeRequestQuery{
+		BizID:        bizID,
+		RequesterUID: uid,
+		Kind:         domain.ChangeRequestAccessRole,
+		Status:       domain.ChangeRequestStatus(status),
+		Offset:       offset,
+		Limit:        limit,
+	}
+	// 在仓储里过滤，分页针对的是过滤之后的结果
+	if status == accessStatusActive {
+		query.Status = domain.ChangeRequestExecuted
+		query.ActiveAt = time.Now().UnixMilli()
+	}
+	return s.approvals.List(ctx, query)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// AdminClient 以系统管理员或者业务管理员的身份调用权限平台
+// 定时任务等没有登录用户的场景使用
+type AdminClient struct {
+	permissionv1.RBACServiceClient
+	adminToken string
+}
+
+func NewAdminClient(rbacSvc permissionv1.RBACServiceClient, adminToken string) *AdminClient {
+	return &AdminClient{RBACServiceClient: rbacSvc, adminToken: adminToken}
+}
+
+func (c *AdminClient) SystemAdminCtx(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "Authorization", c.adminToken)
+}
+
+func (c *AdminClient) BusinessAdminCtx(ctx context.Context, bizID int64) (context.Context, error) {
+	resp, err := c.GetBusinessConfig(c.SystemAdminCtx(ctx), &permissionv1.GetBusinessConfigRequest{
+		Id: bizID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "Authorization", resp.Config.Token), nil
+}
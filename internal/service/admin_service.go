@@ -79,16 +79,8 @@ func (svc *AdminService) createBusinessConfig(ctx context.Context, businessConfi
 }
 
 func (svc *AdminService) createInitialBusinessResources(ctx context.Context, bizID int64) ([]domain.Resource, error) {
-	// 将管理平台的7张表，作为业务内部资源初始化，但使用预定义的Type、Key和Name
-	systemResources := []domain.SystemTableResource{
-		domain.ResourceTable,
-		domain.PermissionTable,
-		domain.RoleTable,
-		domain.RoleInclusionTable,
-		domain.RolePermissionTable,
-		domain.UserRoleTable,
-		domain.UserPermissionTable,
-	}
+	// 将管理平台的7张表、审计日志、审批、职责分离约束和定时变更，作为业务内部资源初始化，但使用预定义的Type、Key和Name
+	systemResources := domain.InitialBusinessTables
 	resources := make([]domain.Resource, 0, len(systemResources)+1)
 	for i := range systemResources {
 		res, err := svc.rbacSvc.CreateResource(ctx, &permissionv1.CreateResourceRequest{
@@ -184,7 +176,7 @@ func (svc *AdminService) createBusinessAdminRole(ctx context.Context, bizID int6
 		Role: &permissionv1.Role{
 			BizId:       bizID,
 			Type:        domain.DefaultAccountRoleType,
-			Name:        "业务管理员",
+			Name:        domain.BusinessAdminRoleName,
 			Description: "具有业务内最高管理权限",
 		},
 	})
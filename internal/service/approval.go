@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	// maxChangeRequestUpdateRetries 并发审批时重新读取变更请求的最大次数
+	maxChangeRequestUpdateRetries = 5
+	expireChangeRequestBatch      = 100
+	executeChangeRequestBatch     = 100
+)
+
+type ApprovalConfig struct {
+	// DefaultTTL 审批策略没有指定有效期时，变更请求的有效期
+	DefaultTTL time.Duration
+	// Lease 认领审批通过的变更请求执行授权的租期，执行的实例崩溃时租期过后由定时任务重新执行
+	Lease time.Duration
+}
+
+// ChangeRequestExecutor 执行审批通过的变更请求，返回执行结果
+type ChangeRequestExecutor func(ctx context.Context, req domain.ChangeRequest) (string, error)
+
+// ApprovalService 管理审批策略和待审批的授权变更请求
+// 这里只负责状态流转，审批通过后由调用方传入的 ChangeRequestExecutor 执行真正的授权
+type ApprovalService struct {
+	repo   repository.ApprovalRepository
+	cfg    ApprovalConfig
+	logger *elog.Component
+}
+
+func NewApprovalService(repo repository.ApprovalRepository, cfg ApprovalConfig) *ApprovalService {
+	return &ApprovalService{repo: repo, cfg: cfg, logger: elog.DefaultLogger}
+}
+
+func (s *ApprovalService) SavePolicy(ctx context.Context, policy domain.ApprovalPolicy) (domain.ApprovalPolicy, error) {
+	if err := policy.Validate(); err != nil {
+		return domain.ApprovalPolicy{}, err
+	}
+	now := time.Now().UnixMilli()
+	policy.Utime = now
+	if policy.ID == 0 {
+		policy.Ctime = now
+		return s.repo.CreatePolicy(ctx, policy)
+	}
+	return policy, s.repo.UpdatePolicy(ctx, policy)
+}
+
+func (s *ApprovalService) DeletePolicy(ctx context.Context, bizID, id int64) error {
+	return s.repo.DeletePolicy(ctx, bizID, id)
+}
+
+func (s *ApprovalService) ListPolicies(ctx context.Context, bizID int64) ([]domain.ApprovalPolicy, error) {
+	return s.repo.ListPolicies(ctx, bizID)
+}
+
+// MatchPolicy 查找覆盖授权对象的审批策略，多个策略同时命中时选择要求最严格的一个
+func (s *ApprovalService) MatchPolicy(ctx context.Context, bizID int64, kind domain.ChangeRequestKind, targetID int64) (domain.ApprovalPolicy, bool, error) {
+	policies, err := s.repo.ListPolicies(ctx, bizID)
+	if err != nil {
+		return domain.ApprovalPolicy{}, false, err
+	}
+	var (
+		res   domain.ApprovalPolicy
+		found bool
+	)
+	for i := range policies {
+		if !policies[i].Covers(kind, targetID) {
+			continue
+		}
+		if !found || policies[i].RequiredApprovals > res.RequiredApprovals {
+			res, found = policies[i], true
+		}
+	}
+	return res, found, nil
+}
+
+// Submit 按照审批策略创建待审批的变更请求
+func (s *ApprovalService) Submit(ctx context.Context, policy domain.ApprovalPolicy, req domain.ChangeRequest) (domain.ChangeRequest, error) {
+	now := time.Now()
+	ttl := s.cfg.DefaultTTL
+	if policy.TTL > 0 {
+		ttl = time.Duration(policy.TTL) * time.Millisecond
+	}
+	req.PolicyID = policy.ID
+	req.Approvers = policy.Approvers
+	req.RequiredApprovals = policy.RequiredApprovals
+	req.Status = domain.ChangeRequestPending
+	req.Ctime = now.UnixMilli()
+	req.Utime = now.UnixMilli()
+	req.ExpireAt = now.Add(ttl).UnixMilli()
+	return s.repo.CreateChangeRequest(ctx, req)
+}
+
+// Get 查询变更请求，已经过期但是还没有被清理的请求返回 expired 状态
+func (s *ApprovalService) Get(ctx context.Context, id int64) (domain.ChangeRequest, error) {
+	req, err := s.repo.GetChangeRequest(ctx, id)
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	if req.Expired(time.Now().UnixMilli()) {
+		req.Status = domain.ChangeRequestExpired
+	}
+	return req, nil
+}
+
+func (s *ApprovalService) List(ctx context.Context, query domain.ChangeRequestQuery) ([]domain.ChangeRequest, error) {
+	query.Limit = listLimit(query.Limit)
+	reqs, err := s.repo.ListChangeRequests(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	for i := range reqs {
+		if reqs[i].Expired(now) {
+			reqs[i].Status = domain.ChangeRequestExpired
+		}
+	}
+	return reqs, nil
+}
+
+// Decide 审批人同意或者拒绝变更请求，返回更新后的请求
+// 返回的请求处于 approved 状态时，调用方需要通过 Execute 执行授权
+func (s *ApprovalService) Decide(ctx context.Context, id int64, decision domain.ApprovalDecision) (domain.ChangeRequest, error) {
+	decision.Ctime = time.Now().UnixMilli()
+	return s.update(ctx, id, func(req *domain.ChangeRequest) error {
+		if req.Expired(decision.Ctime) {
+			return fmt.Errorf("%w: 变更请求已经过期", domain.ErrChangeRequestNotPending)
+		}
+		return req.Decide(decision)
+	})
+}
+
+func (s *ApprovalService) Cancel(ctx context.Context, id, uid int64) (domain.ChangeRequest, error) {
+	return s.update(ctx, id, func(req *domain.ChangeRequest) error {
+		return req.Cancel(uid, time.Now().UnixMilli())
+	})
+}
+
+// Execute 认领审批通过的变更请求并且执行授权，记录执行结果
+// 请求正在被其他调用方执行时直接返回请求当前的状态
+func (s *ApprovalService) Execute(ctx context.Context, id int64, exec ChangeRequestExecutor) (domain.ChangeRequest, error) {
+	ok, err := s.repo.ClaimChangeRequest(ctx, id, time.Now().UnixMilli(), s.cfg.Lease)
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	// 认领之前请求可能已经被其他调用方执行完成，所以认领之后再读取
+	req, err := s.repo.GetChangeRequest(ctx, id)
+	if err != nil || !ok {
+		return req, err
+	}
+	if req.Status != domain.ChangeRequestApproved {
+		// 索引里残留的已经结束的请求，保存一次就会被移出索引
+		return s.repo.UpdateChangeRequest(ctx, req)
+	}
+	result, execErr := exec(ctx, req)
+	if execErr != nil {
+		s.logger.Error("执行审批通过的授权失败",
+			elog.FieldErr(execErr),
+			elog.Int64("bizId", req.BizID),
+			elog.Int64("changeRequestId", req.ID))
+	}
+	return s.complete(ctx, req.ID, result, execErr)
+}
+
+// ExecuteApproved 重新执行审批通过但是没有执行完成的变更请求，由定时任务调用
+// 审批之后执行授权之前实例崩溃时，请求会停留在 approved 状态，租期过后由这里执行
+func (s *ApprovalService) ExecuteApproved(ctx context.Context, exec ChangeRequestExecutor) error {
+	var errs []error
+	for {
+		ids, err := s.repo.ListApprovedChangeRequests(ctx, time.Now().UnixMilli(), executeChangeRequestBatch)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		for _, id := range ids {
+			if _, err1 := s.Execute(ctx, id, exec); err1 != nil {
+				// 认领之后失败的请求在租期之内不会再被列出来，所以这里不会死循环
+				s.logger.Error("重新执行变更请求失败", elog.FieldErr(err1), elog.Int64("id", id))
+				errs = append(errs, fmt.Errorf("变更请求 %d: %w", id, err1))
+			}
+		}
+		if len(ids) < executeChangeRequestBatch {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// complete 记录审批通过后执行授权的结果
+func (s *ApprovalService) complete(ctx context.Context, id int64, result string, execErr error) (domain.ChangeRequest, error) {
+	return s.update(ctx, id, func(req *domain.ChangeRequest) error {
+		if req.Status != domain.ChangeRequestApproved {
+			return fmt.Errorf("变更请求不是审批通过状态: %s", req.Status)
+		}
+		req.Status = domain.ChangeRequestExecuted
+		req.Result = result
+		if execErr != nil {
+			req.Status = domain.ChangeRequestFailed
+			req.Result = execErr.Error()
+		}
+		req.Utime = time.Now().UnixMilli()
+		return nil
+	})
+}
+
+// ExpireStale 把已经过期的待审批请求标记为 expired，由定时任务调用
+func (s *ApprovalService) ExpireStale(ctx context.Context) error {
+	now := time.Now().UnixMilli()
+	var (
+		errs    []error
+		skipped int64
+	)
+	for {
+		ids, err := s.repo.ListExpiredChangeRequests(ctx, now, skipped, expireChangeRequestBatch)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		for _, id := range ids {
+			_, err = s.update(ctx, id, func(req *domain.ChangeRequest) error {
+				// 已经处理过的请求也会被移出待审批索引，所以这里不返回错误
+				if req.Status == domain.ChangeRequestPending {
+					req.Status = domain.ChangeRequestExpired
+					req.Utime = now
+				}
+				return nil
+			})
+			if err != nil {
+				// 失败的请求还在待审批索引里，后面的批次跳过它，下一次任务重试
+				skipped++
+				s.logger.Error("标记变更请求过期失败", elog.FieldErr(err), elog.Int64("id", id))
+				errs = append(errs, fmt.Errorf("变更请求 %d: %w", id, err))
+			}
+		}
+		if len(ids) < expireChangeRequestBatch {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// update 读取-修改-写回，版本冲突时重试
+func (s *ApprovalService) update(ctx context.Context, id int64, fn func(req *domain.ChangeRequest) error) (domain.ChangeRequest, error) {
+	for range maxChangeRequestUpdateRetries {
+		req, err := s.repo.GetChangeRequest(ctx, id)
+		if err != nil {
+			return domain.ChangeRequest{}, err
+		}
+		if err = fn(&req); err != nil {
+			return domain.ChangeRequest{}, err
+		}
+		req, err = s.repo.UpdateChangeRequest(ctx, req)
+		if errors.Is(err, repository.ErrChangeRequestConflict) {
+			continue
+		}
+		return req, err
+	}
+	return domain.ChangeRequest{}, fmt.Errorf("%w: id=%d", repository.ErrChangeRequestConflict, id)
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	// maxAuditAppendRetries 并发追加审计记录时，重新计算哈希的最大次数
+	maxAuditAppendRetries = 5
+	auditVerifyBatch      = 500
+	auditScanBatch        = 500
+)
+
+type AuditConfig struct {
+	// CheckpointKey 签名检查点使用的密钥
+	CheckpointKey string
+	// CheckpointInterval 每追加多少条记录生成一个签名检查点
+	CheckpointInterval int64
+}
+
+// AuditService 记录并查询管理后台写操作的审计日志
+// 同一个业务的审计记录组成一条哈希链，并且定期生成签名检查点，用于证明记录没有被事后篡改
+type AuditService struct {
+	repo   repository.AuditRepository
+	cfg    AuditConfig
+	logger *elog.Component
+}
+
+func NewAuditService(repo repository.AuditRepository, cfg AuditConfig) *AuditService {
+	return &AuditService{repo: repo, cfg: cfg, logger: elog.DefaultLogger}
+}
+
+func (s *AuditService) Record(ctx context.Context, record domain.AuditRecord) error {
+	if record.Ctime == 0 {
+		record.Ctime = time.Now().UnixMilli()
+	}
+	for range maxAuditAppendRetries {
+		head, seq, err := s.repo.ChainHead(ctx, record.BizID)
+		if err != nil {
+			return err
+		}
+		record.PrevHash, record.Seq = head, seq+1
+		record.Hash = record.ChainHash()
+		created, err := s.repo.Create(ctx, record)
+		if errors.Is(err, repository.ErrAuditChainConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if s.cfg.CheckpointInterval > 0 && created.Seq%s.cfg.CheckpointInterval == 0 {
+			s.checkpoint(ctx, created)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: bizId=%d", repository.ErrAuditChainConflict, record.BizID)
+}
+
+// RecordJob 记录定时任务的操作，定时任务没有经过 Web 层的审计，由各个任务自己调用
+// 操作已经完成，审计失败不影响任务，所以只记录日志
+func (s *AuditService) RecordJob(ctx context.Context, record domain.AuditRecord) {
+	if err := s.Record(ctx, record); err != nil {
+		s.logger.Error("记录定时任务审计日志失败", elog.FieldErr(err),
+			elog.String("route", record.Route), elog.Int64("bizId", record.BizID), elog.Int64("targetId", record.TargetID))
+	}
+}
+
+// checkpoint 生成签名检查点，失败只影响校验粒度，所以只记录日志
+func (s *AuditService) checkpoint(ctx context.Context, record domain.AuditRecord) {
+	cp := domain.AuditCheckpoint{
+		BizID:    record.BizID,
+		RecordID: record.ID,
+		Seq:      record.Seq,
+		Hash:     record.Hash,
+		Ctime:    time.Now().UnixMilli(),
+	}
+	cp.Signature = cp.Sign([]byte(s.cfg.CheckpointKey))
+	if err := s.repo.CreateCheckpoint(ctx, cp); err != nil {
+		s.logger.Error("生成审计检查点失败",
+			elog.FieldErr(err),
+			elog.Int64("bizId", record.BizID),
+			elog.String("recordId", record.ID))
+	}
+}
+
+func (s *AuditService) List(ctx context.Context, query domain.AuditQuery) ([]domain.AuditRecord, string, error) {
+	query.Limit = listLimit(query.Limit)
+	return s.repo.List(ctx, query)
+}
+
+// Scan 按照时间正序分批遍历 [from, to] 范围内的审计记录
+func (s *AuditService) Scan(ctx context.Context, bizID, from, to int64, fn func(records []domain.AuditRecord) error) error {
+	lastID := ""
+	for {
+		records, err := s.repo.Range(ctx, bizID, lastID, from, to, auditScanBatch)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err = fn(records); err != nil {
+			return err
+		}
+		if len(records) < auditScanBatch {
+			return nil
+		}
+		lastID = records[len(records)-1].ID
+	}
+}
+
+// Verify 重新计算 [from, to] 时间范围内的哈希链，并且校验范围内的签名检查点
+// 遇到第一个断开的链接就停止，并且返回它的位置和原因
+func (s *AuditService) Verify(ctx context.Context, bizID, from, to int64) (domain.AuditVerification, error) {
+	res := domain.AuditVerification{BizID: bizID}
+	checkpoints, err := s.repo.ListCheckpoints(ctx, bizID, from, to)
+	if err != nil {
+		return res, err
+	}
+	pending := make(map[string]domain.AuditCheckpoint, len(checkpoints))
+	for i := range checkpoints {
+		pending[checkpoints[i].RecordID] = checkpoints[i]
+	}
+
+	var (
+		prevHash string
+		prevSeq  int64
+		lastID   string
+		started  bool
+	)
+	for {
+		records, err1 := s.repo.Range(ctx, bizID, lastID, from, to, auditVerifyBatch)
+		if err1 != nil {
+			return res, err1
+		}
+		if len(records) > 0 && !started {
+			// 范围内第一条记录需要和范围外的上一条记录衔接
+			prev, ok, err2 := s.repo.Previous(ctx, bizID, records[0].ID)
+			if err2 != nil {
+				return res, err2
+			}
+			if ok {
+				prevHash, prevSeq = prev.Hash, prev.Seq
+			}
+			started = true
+		}
+		for i := range records {
+			reason := s.verifyRecord(records[i], prevHash, prevSeq, pending)
+			if reason != "" {
+				res.BrokenID, res.BrokenReason = records[i].ID, reason
+				return res, nil
+			}
+			if _, ok := pending[records[i].ID]; ok {
+				delete(pending, records[i].ID)
+				res.Checkpoints++
+			}
+			res.Checked++
+			prevHash, prevSeq = records[i].Hash, records[i].Seq
+			lastID = records[i].ID
+		}
+		if len(records) < auditVerifyBatch {
+			break
+		}
+	}
+
+	// 检查点存在，但是对应的记录不见了
+	for i := range checkpoints {
+		if _, ok := pending[checkpoints[i].RecordID]; ok {
+			res.BrokenID, res.BrokenReason = checkpoints[i].RecordID, "检查点对应的审计记录缺失"
+			return res, nil
+		}
+	}
+
+	// 校验范围覆盖到链尾时，链尾哈希必须和最后一条记录一致，防止末尾的记录被删除
+	if res.Checked > 0 && (to == 0 || to >= time.Now().UnixMilli()) {
+		head, _, err1 := s.repo.ChainHead(ctx, bizID)
+		if err1 != nil {
+			return res, err1
+		}
+		if head != prevHash {
+			res.BrokenID, res.BrokenReason = lastID, "链尾哈希不一致，末尾的审计记录可能被删除"
+			return res, nil
+		}
+	}
+	res.Valid = true
+	return res, nil
+}
+
+func (s *AuditService) verifyRecord(record domain.AuditRecord, prevHash string, prevSeq int64, checkpoints map[string]domain.AuditCheckpoint) string {
+	if record.Hash == "" {
+		return "审计记录缺少哈希"
+	}
+	if record.PrevHash != prevHash {
+		return "和上一条审计记录的哈希不衔接，记录可能被删除或者插入"
+	}
+	if record.Seq != prevSeq+1 {
+		return "和上一条审计记录的序号不连续，记录可能被删除或者插入"
+	}
+	if record.ChainHash() != record.Hash {
+		return "审计记录内容和哈希不一致，记录可能被修改"
+	}
+	cp, ok := checkpoints[record.ID]
+	if !ok {
+		return ""
+	}
+	if !cp.Verify([]byte(s.cfg.CheckpointKey)) {
+		return "检查点签名无效"
+	}
+	if cp.Hash != record.Hash || cp.Seq != record.Seq {
+		return "审计记录和签名检查点不一致"
+	}
+	return ""
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/gotomicro/ego/core/elog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	revokeBreakGlassBatch = 100
+	// breakGlassRevokeRoute 定时任务回收紧急授权时审计记录里的路由
+	breakGlassRevokeRoute = "cron.breakGlassRevoke"
+)
+
+type BreakGlassConfig struct {
+	// MaxWindow 业务可以配置的最长紧急授权时长
+	MaxWindow time.Duration
+}
+
+// BreakGlassService 紧急授权
+// 值班人员不需要审批就可以立刻获得预先配置的紧急角色，但是时长固定且不能延长，
+// 生效和回收都会发送高优先级通知并且记录审计日志
+type BreakGlassService struct {
+	repo     repository.BreakGlassRepository
+	client   *AdminClient
+	changes  *ChangePublisher
+	audits   *AuditService
+	notifier Notifier
+	cfg      BreakGlassConfig
+	logger   *elog.Component
+}
+
+func NewBreakGlassService(
+	repo repository.BreakGlassRepository,
+	client *AdminClient,
+	changes *ChangePublisher,
+	audits *AuditService,
+	notifier Notifier,
+	cfg BreakGlassConfig,
+) *BreakGlassService {
+	return &BreakGlassService{
+		repo:     repo,
+		client:   client,
+		changes:  changes,
+		audits:   audits,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   elog.DefaultLogger,
+	}
+}
+
+func (s *BreakGlassService) SavePolicy(ctx context.Context, policy domain.BreakGlassPolicy) error {
+	if err := policy.Validate(s.cfg.MaxWindow.Milliseconds()); err != nil {
+		return err
+	}
+	// 紧急角色只能是本业务的角色
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, policy.BizID)
+	if err != nil {
+		return err
+	}
+	roleResp, err := s.client.GetRole(bizCtx, &permissionv1.GetRoleRequest{Id: policy.RoleID})
+	if err != nil {
+		return err
+	}
+	if roleResp.Role.BizId != policy.BizID {
+		return errors.New("紧急角色不属于该业务")
+	}
+	now := time.Now().UnixMilli()
+	old, err := s.repo.GetPolicy(ctx, policy.BizID)
+	switch {
+	case err == nil:
+		policy.Ctime = old.Ctime
+	case errors.Is(err, repository.ErrBreakGlassPolicyNotFound):
+		policy.Ctime = now
+	default:
+		return err
+	}
+	policy.Utime = now
+	return s.repo.SavePolicy(ctx, policy)
+}
+
+func (s *BreakGlassService) GetPolicy(ctx context.Context, bizID int64) (domain.BreakGlassPolicy, error) {
+	return s.repo.GetPolicy(ctx, bizID)
+}
+
+// Activate 立刻授予紧急角色，授权的 EndTime 就是窗口结束时间，即使回收任务异常，权限平台也会让授权失效
+func (s *BreakGlassService) Activate(ctx context.Context, bizID, uid int64, reason string) (domain.BreakGlassSession, error) {
+	if reason == "" {
+		return domain.BreakGlassSession{}, errors.New("紧急授权必须填写原因")
+	}
+	policy, err := s.repo.GetPolicy(ctx, bizID)
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	if !policy.IsResponder(uid) {
+		return domain.BreakGlassSession{}, errors.New("不是该业务的值班人员，不能使用紧急授权")
+	}
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, bizID)
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	roleResp, err := s.client.GetRole(bizCtx, &permissionv1.GetRoleRequest{Id: policy.RoleID})
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+
+	now := time.Now().UnixMilli()
+	session, err := s.repo.CreateSession(ctx, domain.BreakGlassSession{
+		BizID:     bizID,
+		UID:       uid,
+		RoleID:    policy.RoleID,
+		RoleName:  roleResp.Role.Name,
+		Reason:    reason,
+		StartTime: now,
+		EndTime:   now + policy.Window,
+		Status:    domain.BreakGlassActive,
+	})
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	resp, err := s.client.GrantUserRole(bizCtx, &permissionv1.GrantUserRoleRequest{
+		UserRole: &permissionv1.UserRole{
+			BizId:     bizID,
+			UserId:    uid,
+			RoleId:    policy.RoleID,
+			RoleName:  roleResp.Role.Name,
+			RoleType:  roleResp.Role.Type,
+			StartTime: session.StartTime,
+			EndTime:   session.EndTime,
+		},
+	})
+	if err != nil {
+		session.Status, session.Error = domain.BreakGlassFailed, err.Error()
+		s.save(ctx, session)
+		return session, err
+	}
+	session.UserRoleID = resp.UserRole.Id
+	if err = s.repo.UpdateSession(ctx, session); err != nil {
+		// 没有保存授权ID就无法回收，撤销刚刚的授权，让值班人员重新申请
+		if err1 := s.revokeUserRole(ctx, bizID, session.UserRoleID); err1 != nil {
+			s.logger.Error("撤销紧急授权失败，授权会在窗口结束时失效", elog.FieldErr(err1),
+				elog.Int64("id", session.ID), elog.Int64("userRoleId", session.UserRoleID))
+		}
+		session.Status, session.Error = domain.BreakGlassFailed, err.Error()
+		s.save(ctx, session)
+		return session, fmt.Errorf("保存紧急授权失败: %w", err)
+	}
+	s.notify(ctx, policy, "break_glass.activated", "紧急授权已生效",
+		fmt.Sprintf("用户 %d 获得紧急角色 %s，有效期至 %s，原因：%s",
+			uid, session.RoleName, time.UnixMilli(session.EndTime).Format(time.DateTime), reason))
+	return session, nil
+}
+
+// End 持有人提前结束紧急授权
+func (s *BreakGlassService) End(ctx context.Context, id, uid int64) (domain.BreakGlassSession, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	if session.UID != uid {
+		return domain.BreakGlassSession{}, errors.New("只有持有人可以提前结束紧急授权")
+	}
+	if session.Status != domain.BreakGlassActive {
+		return session, nil
+	}
+	return s.revoke(ctx, session, domain.BreakGlassEnded)
+}
+
+func (s *BreakGlassService) GetSession(ctx context.Context, id int64) (domain.BreakGlassSession, error) {
+	return s.repo.GetSession(ctx, id)
+}
+
+func (s *BreakGlassService) ListSessions(ctx context.Context, bizID int64, offset, limit int) ([]domain.BreakGlassSession, error) {
+	return s.repo.ListSessions(ctx, bizID, offset, listLimit(limit))
+}
+
+// EmergencyRoleIDs 返回业务当前配置的紧急角色，以及 uid 生效中的紧急授权持有的角色
+// 策略修改之前开始的紧急授权可能持有旧的紧急角色，所以两者都要返回
+func (s *BreakGlassService) EmergencyRoleIDs(ctx context.Context, bizID, uid int64) ([]int64, error) {
+	var res []int64
+	policy, err := s.repo.GetPolicy(ctx, bizID)
+	switch {
+	case err == nil:
+		res = append(res, policy.RoleID)
+	case !errors.Is(err, repository.ErrBreakGlassPolicyNotFound):
+		return nil, err
+	}
+	now := time.Now()
+	for offset := 0; ; offset += revokeBreakGlassBatch {
+		sessions, err1 := s.repo.ListSessions(ctx, bizID, offset, revokeBreakGlassBatch)
+		if err1 != nil {
+			return nil, err1
+		}
+		for _, session := range sessions {
+			if session.UID == uid && session.Status == domain.BreakGlassActive && session.EndTime > now.UnixMilli() {
+				res = append(res, session.RoleID)
+			}
+		}
+		// 按照开始时间倒序，开始时间早于最长时长的紧急授权都已经结束
+		if len(sessions) < revokeBreakGlassBatch ||
+			(s.cfg.MaxWindow > 0 && sessions[len(sessions)-1].StartTime < now.Add(-s.cfg.MaxWindow).UnixMilli()) {
+			return res, nil
+		}
+	}
+}
+
+// Report 事后报告，列出紧急授权期间持有人的全部写操作
+func (s *BreakGlassService) Report(ctx context.Context, id int64) (domain.BreakGlassSession, []domain.AuditRecord, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return domain.BreakGlassSession{}, nil, err
+	}
+	end := session.EndTime
+	if session.RevokedAt > 0 {
+		end = min(end, session.RevokedAt)
+	}
+	var records []domain.AuditRecord
+	err = s.audits.Scan(ctx, session.BizID, session.StartTime, end, func(batch []domain.AuditRecord) error {
+		for i := range batch {
+			if batch[i].ActorUID == session.UID {
+				records = append(records, batch[i])
+			}
+		}
+		return nil
+	})
+	return session, records, err
+}
+
+// RevokeExpired 回收已经到期的紧急授权，由定时任务调用
+// 单个紧急授权回收失败时记录日志并且跳过，留给下一次任务重试，返回全部失败
+func (s *BreakGlassService) RevokeExpired(ctx context.Context) error {
+	now := time.Now().UnixMilli()
+	var (
+		errs    []error
+		skipped int64
+	)
+	for {
+		ids, err := s.repo.ListDueSessions(ctx, now, skipped, revokeBreakGlassBatch)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		for _, id := range ids {
+			session, err1 := s.repo.GetSession(ctx, id)
+			if err1 == nil {
+				_, err1 = s.revoke(ctx, session, domain.BreakGlassExpired)
+			}
+			if err1 != nil {
+				// 失败的紧急授权还在到期集合里，后面的批次跳过它
+				skipped++
+				s.logger.Error("回收到期的紧急授权失败", elog.FieldErr(err1), elog.Int64("id", id))
+				errs = append(errs, fmt.Errorf("紧急授权 %d: %w", id, err1))
+			}
+		}
+		if len(ids) < revokeBreakGlassBatch {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+func (s *BreakGlassService) revoke(ctx context.Context, session domain.BreakGlassSession, status domain.BreakGlassStatus) (domain.BreakGlassSession, error) {
+	if session.UserRoleID > 0 {
+		if err := s.revokeUserRole(ctx, session.BizID, session.UserRoleID); err != nil {
+			return session, err
+		}
+		s.changes.PublishRevoke(ctx, session.BizID, domain.UserRoleTable, session.UserRoleID)
+	}
+	session.Status = status
+	session.RevokedAt = time.Now().UnixMilli()
+	if err := s.repo.UpdateSession(ctx, session); err != nil {
+		return session, err
+	}
+	if status == domain.BreakGlassExpired {
+		s.audits.RecordJob(ctx, domain.AuditRecord{
+			BizID:        session.BizID,
+			Route:        breakGlassRevokeRoute,
+			Table:        domain.UserRoleTable.String(),
+			TargetID:     session.UserRoleID,
+			TargetUserID: session.UID,
+			Payload:      fmt.Sprintf(`{"breakGlassId":%d}`, session.ID),
+			Outcome:      domain.AuditOutcomeSuccess,
+		})
+	}
+	policy, err := s.repo.GetPolicy(ctx, session.BizID)
+	if err != nil {
+		policy = domain.BreakGlassPolicy{BizID: session.BizID}
+	}
+	s.notify(ctx, policy, "break_glass.revoked", "紧急授权已结束",
+		fmt.Sprintf("用户 %d 的紧急角色 %s 已回收（%s），请及时查看事后报告，紧急授权ID：%d",
+			session.UID, session.RoleName, status, session.ID))
+	return session, nil
+}
+
+// revokeUserRole 回收紧急角色，权限平台上已经不存在的授权视为已经回收
+func (s *BreakGlassService) revokeUserRole(ctx context.Context, bizID, userRoleID int64) error {
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.RevokeUserRole(bizCtx, &permissionv1.RevokeUserRoleRequest{Id: userRoleID})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("回收紧急角色失败: %w", err)
+	}
+	return nil
+}
+
+// save 保存紧急授权的状态，失败只记录日志，到期回收依赖的索引在创建时已经写入
+func (s *BreakGlassService) save(ctx context.Context, session domain.BreakGlassSession) {
+	if err := s.repo.UpdateSession(ctx, session); err != nil {
+		s.logger.Error("保存紧急授权失败", elog.FieldErr(err), elog.Int64("id", session.ID))
+	}
+}
+
+// notify 通知值班人员和关注人，通知失败不影响紧急授权本身
+func (s *BreakGlassService) notify(ctx context.Context, policy domain.BreakGlassPolicy, event, title, content string) {
+	receivers := make([]int64, 0, len(policy.Responders)+len(policy.Watchers))
+	receivers = append(receivers, policy.Responders...)
+	receivers = append(receivers, policy.Watchers...)
+	err := s.notifier.Notify(ctx, domain.Notification{
+		BizID:     policy.BizID,
+		Priority:  domain.NotificationPriorityHigh,
+		Event:     event,
+		Title:     title,
+		Content:   content,
+		Receivers: receivers,
+	})
+	if err != nil {
+		s.logger.Error("发送紧急授权通知失败", elog.FieldErr(err), elog.Int64("bizId", policy.BizID), elog.String("event", event))
+	}
+}
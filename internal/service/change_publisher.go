@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// ChangePublisher 推送权限变更事件
+// Web 层的写操作和定时任务的回收都通过它推送，订阅方才能看到全部的变更
+type ChangePublisher struct {
+	stream *permission.Stream
+	logger *elog.Component
+}
+
+func NewChangePublisher(stream *permission.Stream) *ChangePublisher {
+	return &ChangePublisher{stream: stream, logger: elog.DefaultLogger}
+}
+
+// Publish 写操作已经成功，所以推送失败只记录日志，不影响调用方
+func (p *ChangePublisher) Publish(ctx context.Context, bizID int64, table domain.SystemTableResource, action permission.ChangeAction, data any) {
+	val, err := json.Marshal(data)
+	if err == nil {
+		err = p.stream.Publish(ctx, permission.ChangeEvent{
+			BizID:  bizID,
+			Table:  table.String(),
+			Action: action,
+			Data:   val,
+		})
+	}
+	if err != nil {
+		p.logger.Error("推送权限变更事件失败",
+			elog.FieldErr(err),
+			elog.Int64("bizId", bizID),
+			elog.String("table", table.String()),
+			elog.String("action", action.String()))
+	}
+}
+
+// PublishRevoke 回收事件只包含授权的 ID
+func (p *ChangePublisher) PublishRevoke(ctx context.Context, bizID int64, table domain.SystemTableResource, id int64) {
+	p.Publish(ctx, bizID, table, permission.ChangeActionRevoke, struct {
+		ID int64 `json:"id"`
+	}{ID: id})
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/gotomicro/ego/core/elog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// expiryScanPageSize 每次从权限平台拉取的业务和授权数量
+	expiryScanPageSize = 500
+	// expirySweepRoute 定时任务回收到期授权时审计记录里的路由
+	expirySweepRoute = "cron.expirySweep"
+	// maxExpiringWithinDays 查询即将到期授权时最多往后看的天数
+	maxExpiringWithinDays = 90
+)
+
+type ExpiryConfig struct {
+	// NotifyDays 到期前多少天提醒授权持有人，例如 [7, 1]
+	NotifyDays []int
+	// Action 过了结束时间的授权回收还是归档后回收
+	Action domain.ExpiryAction
+}
+
+// ExpiryService 定时扫描各个业务的限时授权
+// 即将到期的授权提醒持有人，已经过期的授权按照配置回收或者归档，
+// 权限平台本身只在鉴权时判断有效期，过期的授权不清理会一直留在列表里
+type ExpiryService struct {
+	repo     repository.ExpiryRepository
+	client   *AdminClient
+	changes  *ChangePublisher
+	audits   *AuditService
+	notifier Notifier
+	cfg      ExpiryConfig
+	logger   *elog.Component
+}
+
+func NewExpiryService(
+	repo repository.ExpiryRepository,
+	client *AdminClient,
+	changes *ChangePublisher,
+	audits *AuditService,
+	notifier Notifier,
+	cfg ExpiryConfig,
+) *ExpiryService {
+	return &ExpiryService{
+		repo:     repo,
+		client:   client,
+		changes:  changes,
+		audits:   audits,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   elog.DefaultLogger,
+	}
+}
+
+// ListExpiring 查询接下来 withinDays 天内到期的用户角色和用户权限
+func (s *ExpiryService) ListExpiring(ctx context.Context, bizID int64, withinDays int) ([]*permissionv1.UserRole, []*permissionv1.UserPermission, error) {
+	if withinDays <= 0 || withinDays > maxExpiringWithinDays {
+		return nil, nil, fmt.Errorf("withinDays 必须在 1 到 %d 之间", maxExpiringWithinDays)
+	}
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, bizID)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	end := now.Add(time.Duration(withinDays) * 24 * time.Hour).UnixMilli()
+	expiring := func(endTime int64) bool {
+		return endTime > now.UnixMilli() && endTime <= end
+	}
+	var (
+		roles []*permissionv1.UserRole
+		perms []*permissionv1.UserPermission
+	)
+	err = s.scanUserRoles(bizCtx, bizID, func(src *permissionv1.UserRole) {
+		if expiring(src.EndTime) {
+			roles = append(roles, src)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.scanUserPermissions(bizCtx, bizID, func(src *permissionv1.UserPermission) {
+		if expiring(src.EndTime) {
+			perms = append(perms, src)
+		}
+	})
+	return roles, perms, err
+}
+
+func (s *ExpiryService) ListArchived(ctx context.Context, bizID int64, offset, limit int) ([]domain.ArchivedGrant, error) {
+	return s.repo.ListArchived(ctx, bizID, offset, listLimit(limit))
+}
+
+// Sweep 扫描全部业务，由定时任务调用
+// 单个业务失败不影响其他业务，全部扫描完之后再返回错误
+func (s *ExpiryService) Sweep(ctx context.Context) error {
+	var errs []error
+	for offset := int32(0); ; offset += expiryScanPageSize {
+		resp, err := s.client.ListBusinessConfigs(s.client.SystemAdminCtx(ctx), &permissionv1.ListBusinessConfigsRequest{
+			Offset: offset,
+			Limit:  expiryScanPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, biz := range resp.Configs {
+			if err = s.sweep(ctx, biz.Id); err != nil {
+				s.logger.Error("扫描到期授权失败", elog.FieldErr(err), elog.Int64("bizId", biz.Id))
+				errs = append(errs, fmt.Errorf("bizId=%d: %w", biz.Id, err))
+			}
+		}
+		if len(resp.Configs) < expiryScanPageSize {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+func (s *ExpiryService) sweep(ctx context.Context, bizID int64) error {
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	// 先扫描完再回收，边翻页边删除会跳过数据
+	var (
+		expired []domain.ArchivedGrant
+		notices = make(map[int64][]string)
+	)
+	collect := func(table domain.SystemTableResource, id, uid, targetID int64, name string, start, end int64) {
+		if end > 0 && end <= now {
+			expired = append(expired, domain.ArchivedGrant{
+				BizID:      bizID,
+				Table:      table,
+				GrantID:    id,
+				UserID:     uid,
+				TargetID:   targetID,
+				TargetName: name,
+				StartTime:  start,
+				EndTime:    end,
+			})
+			return
+		}
+		if s.shouldNotify(ctx, table, id, end, now) {
+			notices[uid] = append(notices[uid], fmt.Sprintf("%s 将于 %s 到期", name, time.UnixMilli(end).Format(time.DateTime)))
+		}
+	}
+	err = s.scanUserRoles(bizCtx, bizID, func(src *permissionv1.UserRole) {
+		collect(domain.UserRoleTable, src.Id, src.UserId, src.RoleId, "角色 "+src.RoleName, src.StartTime, src.EndTime)
+	})
+	if err != nil {
+		return err
+	}
+	err = s.scanUserPermissions(bizCtx, bizID, func(src *permissionv1.UserPermission) {
+		collect(domain.UserPermissionTable, src.Id, src.UserId, src.PermissionId, "权限 "+src.PermissionName, src.StartTime, src.EndTime)
+	})
+	if err != nil {
+		return err
+	}
+
+	for uid, lines := range notices {
+		s.notify(ctx, bizID, uid, "grant.expiring", "授权即将到期", strings.Join(lines, "\n"))
+	}
+	// 单个授权失败时继续处理其他授权，下次扫描时重试
+	var errs []error
+	for i := range expired {
+		if err = s.expire(ctx, bizCtx, expired[i]); err != nil {
+			s.logger.Error("处理到期授权失败", elog.FieldErr(err),
+				elog.String("table", expired[i].Table.String()), elog.Int64("id", expired[i].GrantID))
+			errs = append(errs, fmt.Errorf("%s %d: %w", expired[i].Table, expired[i].GrantID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shouldNotify 命中提醒天数并且这个提醒天数还没有发送过
+func (s *ExpiryService) shouldNotify(ctx context.Context, table domain.SystemTableResource, id, end, now int64) bool {
+	days, ok := domain.ExpiryNotifyThreshold(end, now, s.cfg.NotifyDays)
+	if !ok {
+		return false
+	}
+	// 标记保留到授权到期之后，避免同一个提醒重复发送
+	ttl := time.Duration(end-now)*time.Millisecond + 24*time.Hour
+	ok, err := s.repo.MarkNotified(ctx, table, id, days, ttl)
+	if err != nil {
+		s.logger.Error("记录到期提醒失败", elog.FieldErr(err), elog.String("table", table.String()), elog.Int64("id", id))
+		return false
+	}
+	return ok
+}
+
+// expire 先归档再回收，归档是幂等的，回收失败时下次扫描重新归档也不会重复
+func (s *ExpiryService) expire(ctx, bizCtx context.Context, grant domain.ArchivedGrant) error {
+	if s.cfg.Action == domain.ExpiryActionArchive {
+		grant.ArchivedAt = time.Now().UnixMilli()
+		if err := s.repo.Archive(ctx, grant); err != nil {
+			return err
+		}
+	}
+	var err error
+	switch grant.Table {
+	case domain.UserRoleTable:
+		_, err = s.client.RevokeUserRole(bizCtx, &permissionv1.RevokeUserRoleRequest{Id: grant.GrantID})
+	case domain.UserPermissionTable:
+		_, err = s.client.RevokeUserPermission(bizCtx, &permissionv1.RevokeUserPermissionRequest{Id: grant.GrantID})
+	}
+	// 权限平台上已经不存在的授权视为已经回收
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("回收到期授权失败: %w", err)
+	}
+	s.changes.PublishRevoke(ctx, grant.BizID, grant.Table, grant.GrantID)
+	s.audits.RecordJob(ctx, domain.AuditRecord{
+		BizID:        grant.BizID,
+		Route:        expirySweepRoute,
+		Table:        grant.Table.String(),
+		TargetID:     grant.GrantID,
+		TargetUserID: grant.UserID,
+		Payload:      fmt.Sprintf(`{"endTime":%d,"action":%q}`, grant.EndTime, s.cfg.Action),
+		Outcome:      domain.AuditOutcomeSuccess,
+	})
+	return nil
+}
+
+func (s *ExpiryService) scanUserRoles(bizCtx context.Context, bizID int64, fn func(src *permissionv1.UserRole)) error {
+	for offset := int32(0); ; offset += expiryScanPageSize {
+		resp, err := s.client.ListUserRoles(bizCtx, &permissionv1.ListUserRolesRequest{
+			BizId:  bizID,
+			Offset: offset,
+			Limit:  expiryScanPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, src := range resp.UserRoles {
+			fn(src)
+		}
+		if len(resp.UserRoles) < expiryScanPageSize {
+			return nil
+		}
+	}
+}
+
+func (s *ExpiryService) scanUserPermissions(bizCtx context.Context, bizID int64, fn func(src *permissionv1.UserPermission)) error {
+	for offset := int32(0); ; offset += expiryScanPageSize {
+		resp, err := s.client.ListUserPermissions(bizCtx, &permissionv1.ListUserPermissionsRequest{
+			BizId:  bizID,
+			Offset: offset,
+			Limit:  expiryScanPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, src := range resp.UserPermissions {
+			fn(src)
+		}
+		if len(resp.UserPermissions) < expiryScanPageSize {
+			return nil
+		}
+	}
+}
+
+// notify 提醒失败不影响扫描本身
+func (s *ExpiryService) notify(ctx context.Context, bizID, uid int64, event, title, content string) {
+	err := s.notifier.Notify(ctx, domain.Notification{
+		BizID:     bizID,
+		Priority:  domain.NotificationPriorityNormal,
+		Event:     event,
+		Title:     title,
+		Content:   content,
+		Receivers: []int64{uid},
+	})
+	if err != nil {
+		s.logger.Error("发送到期提醒失败", elog.FieldErr(err), elog.Int64("bizId", bizID), elog.Int64("uid", uid))
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+)
+
+// expiryLog 按照调用顺序记录扫描过程中的外部调用
+type expiryLog []string
+
+func (l *expiryLog) add(format string, args ...any) {
+	*l = append(*l, fmt.Sprintf(format, args...))
+}
+
+type expiryRBAC struct {
+	permissionv1.RBACServiceClient
+	log   *expiryLog
+	roles []*permissionv1.UserRole
+	perms []*permissionv1.UserPermission
+}
+
+func (c *expiryRBAC) ListBusinessConfigs(context.Context, *permissionv1.ListBusinessConfigsRequest, ...grpc.CallOption) (*permissionv1.ListBusinessConfigsResponse, error) {
+	return &permissionv1.ListBusinessConfigsResponse{Configs: []*permissionv1.BusinessConfig{{Id: 1}}}, nil
+}
+
+func (c *expiryRBAC) GetBusinessConfig(_ context.Context, in *permissionv1.GetBusinessConfigRequest, _ ...grpc.CallOption) (*permissionv1.GetBusinessConfigResponse, error) {
+	return &permissionv1.GetBusinessConfigResponse{Config: &permissionv1.BusinessConfig{Id: in.Id, Token: "token"}}, nil
+}
+
+func (c *expiryRBAC) ListUserRoles(context.Context, *permissionv1.ListUserRolesRequest, ...grpc.CallOption) (*permissionv1.ListUserRolesResponse, error) {
+	return &permissionv1.ListUserRolesResponse{UserRoles: c.roles}, nil
+}
+
+func (c *expiryRBAC) ListUserPermissions(context.Context, *permissionv1.ListUserPermissionsRequest, ...grpc.CallOption) (*permissionv1.ListUserPermissionsResponse, error) {
+	return &permissionv1.ListUserPermissionsResponse{UserPermissions: c.perms}, nil
+}
+
+func (c *expiryRBAC) RevokeUserRole(_ context.Context, in *permissionv1.RevokeUserRoleRequest, _ ...grpc.CallOption) (*permissionv1.RevokeUserRoleResponse, error) {
+	c.log.add("revoke %s %d", domain.UserRoleTable, in.Id)
+	c.roles = slices.DeleteFunc(c.roles, func(r *permissionv1.UserRole) bool { return r.Id == in.Id })
+	return &permissionv1.RevokeUserRoleResponse{Success: true}, nil
+}
+
+func (c *expiryRBAC) RevokeUserPermission(_ context.Context, in *permissionv1.RevokeUserPermissionRequest, _ ...grpc.CallOption) (*permissionv1.RevokeUserPermissionResponse, error) {
+	c.log.add("revoke %s %d", domain.UserPermissionTable, in.Id)
+	c.perms = slices.DeleteFunc(c.perms, func(p *permissionv1.UserPermission) bool { return p.Id == in.Id })
+	return &permissionv1.RevokeUserPermissionResponse{Success: true}, nil
+}
+
+type expiryRepo struct {
+	repository.ExpiryRepository
+	log      *expiryLog
+	notified map[string]bool
+}
+
+func (r *expiryRepo) MarkNotified(_ context.Context, table domain.SystemTableResource, grantID int64, days int, _ time.Duration) (bool, error) {
+	key := fmt.Sprintf("%s %d %d", table, grantID, days)
+	r.log.add("mark %s", key)
+	if r.notified[key] {
+		return false, nil
+	}
+	r.notified[key] = true
+	return true, nil
+}
+
+func (r *expiryRepo) Archive(_ context.Context, grant domain.ArchivedGrant) error {
+	r.log.add("archive %s %d", grant.Table, grant.GrantID)
+	return nil
+}
+
+type expiryNotifier struct {
+	log *expiryLog
+}
+
+func (n *expiryNotifier) Notify(_ context.Context, notification domain.Notification) error {
+	n.log.add("notify %v %s", notification.Receivers, notification.Event)
+	return nil
+}
+
+// expiryRedis 只用来接收权限变更事件
+type expiryRedis struct {
+	redis.Cmdable
+	log *expiryLog
+}
+
+func (c *expiryRedis) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	for _, v := range a.Values.(map[string]any) {
+		var evt permission.ChangeEvent
+		_ = json.Unmarshal([]byte(v.(string)), &evt)
+		c.log.add("publish %s %s %s", evt.Table, evt.Action, evt.Data)
+	}
+	return redis.NewStringResult("1-0", nil)
+}
+
+type expiryAudits struct {
+	repository.AuditRepository
+}
+
+func (r *expiryAudits) ChainHead(context.Context, int64) (string, int64, error) {
+	return "", 0, nil
+}
+
+func (r *expiryAudits) Create(_ context.Context, record domain.AuditRecord) (domain.AuditRecord, error) {
+	return record, nil
+}
+
+func TestExpirySweep(t *testing.T) {
+	now := time.Now()
+	var log expiryLog
+	rbac := &expiryRBAC{
+		log: &log,
+		roles: []*permissionv1.UserRole{
+			{Id: 1, UserId: 10, RoleName: "viewer", EndTime: now.Add(12 * time.Hour).UnixMilli()},
+			{Id: 2, UserId: 10, RoleName: "editor", EndTime: now.Add(-time.Hour).UnixMilli()},
+			{Id: 3, UserId: 20, RoleName: "viewer"},
+		},
+		perms: []*permissionv1.UserPermission{
+			{Id: 4, UserId: 20, PermissionName: "read", EndTime: now.Add(-time.Hour).UnixMilli()},
+		},
+	}
+	svc := NewExpiryService(
+		&expiryRepo{log: &log, notified: make(map[string]bool)},
+		NewAdminClient(rbac, "admin"),
+		NewChangePublisher(permission.NewStream(&expiryRedis{log: &log}, 100)),
+		NewAuditService(&expiryAudits{}, AuditConfig{}),
+		&expiryNotifier{log: &log},
+		ExpiryConfig{NotifyDays: []int{7, 1}, Action: domain.ExpiryActionArchive},
+	)
+
+	testCases := []struct {
+		name string
+		want []string
+	}{
+		{
+			// 先扫描完再提醒，提醒之后才处理过期的授权，每个授权先归档再回收，回收之后推送事件
+			name: "第一次扫描",
+			want: []string{
+				"mark user_roles 1 1",
+				"notify [10] grant.expiring",
+				"archive user_roles 2",
+				"revoke user_roles 2",
+				`publish user_roles revoke {"id":2}`,
+				"archive user_permissions 4",
+				"revoke user_permissions 4",
+				`publish user_permissions revoke {"id":4}`,
+			},
+		},
+		{
+			// 同一个提醒天数已经提醒过，过期的授权已经回收
+			name: "重复扫描",
+			want: []string{"mark user_roles 1 1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			log = log[:0]
+			if err := svc.Sweep(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(log, tc.want) {
+				t.Fatalf("Sweep() 调用顺序 = %q, want %q", []string(log), tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package service
+
+const (
+	// defaultListLimit 调用方没有指定每页数量时的默认值
+	defaultListLimit = 50
+	// MaxListLimit 列表查询每页的上限，全部服务共用。Web 层每页的上限要小于它，多读一行判断是否还有下一页
+	MaxListLimit = 500
+)
+
+// listLimit 没有指定每页数量时使用默认值，超过上限时使用上限
+func listLimit(limit int) int {
+	if limit <= 0 {
+		return defaultListLimit
+	}
+	return min(limit, MaxListLimit)
+}
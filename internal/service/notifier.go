@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// Notifier 发送通知，具体渠道（IM、短信、值班系统）由 Webhook 的接收方决定
+type Notifier interface {
+	Notify(ctx context.Context, n domain.Notification) error
+}
+
+// LogNotifier 只记录日志，没有配置 Webhook 时使用
+type LogNotifier struct {
+	logger *elog.Component
+}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{logger: elog.DefaultLogger}
+}
+
+func (n *LogNotifier) Notify(_ context.Context, notification domain.Notification) error {
+	n.logger.Warn("通知",
+		elog.Int64("bizId", notification.BizID),
+		elog.String("priority", notification.Priority.String()),
+		elog.String("event", notification.Event),
+		elog.String("title", notification.Title),
+		elog.String("content", notification.Content),
+		elog.Any("receivers", notification.Receivers))
+	return nil
+}
+
+// WebhookNotifier 把通知以 JSON 格式 POST 到 Webhook
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification domain.Notification) error {
+	if notification.Ctime == 0 {
+		notification.Ctime = time.Now().UnixMilli()
+	}
+	body, err := json.Marshal(webhookNotification{
+		BizID:     notification.BizID,
+		Priority:  notification.Priority.String(),
+		Event:     notification.Event,
+		Title:     notification.Title,
+		Content:   notification.Content,
+		Receivers: notification.Receivers,
+		Ctime:     notification.Ctime,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("发送通知失败: %s", resp.Status)
+	}
+	return nil
+}
+
+type webhookNotification struct {
+	BizID     int64   `json:"bizId"`
+	Priority  string  `json:"priority"`
+	Event     string  `json:"event"`
+	Title     string  `json:"title"`
+	Content   string  `json:"content"`
+	Receivers []int64 `json:"receivers,omitzero"`
+	Ctime     int64   `json:"ctime"`
+}
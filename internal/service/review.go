@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/gotomicro/ego/core/elog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// reviewSnapshotPageSize 快照时每次从权限平台拉取的授权数量
+	reviewSnapshotPageSize = 500
+	reviewDeadlineBatch    = 20
+	// reviewDeadlineRoute 定时任务回收未审查授权时审计记录里的路由
+	reviewDeadlineRoute = "cron.reviewDeadline"
+)
+
+// ReviewService 周期性权限审查
+// 创建审查时对范围内的用户角色做快照，每条授权分配给一个审查人确认或者回收，
+// 截止时间之后仍未审查的授权按照审查的策略自动回收或者升级
+type ReviewService struct {
+	repo      repository.ReviewRepository
+	access    repository.AccessPolicyRepository
+	approvals *ApprovalService
+	client    *AdminClient
+	changes   *ChangePublisher
+	audits    *AuditService
+	notifier  Notifier
+	logger    *elog.Component
+}
+
+func NewReviewService(
+	repo repository.ReviewRepository,
+	access repository.AccessPolicyRepository,
+	approvals *ApprovalService,
+	client *AdminClient,
+	changes *ChangePublisher,
+	audits *AuditService,
+	notifier Notifier,
+) *ReviewService {
+	return &ReviewService{
+		repo:      repo,
+		access:    access,
+		approvals: approvals,
+		client:    client,
+		changes:   changes,
+		audits:    audits,
+		notifier:  notifier,
+		logger:    elog.DefaultLogger,
+	}
+}
+
+// Create 创建审查并且对当前授权做快照
+// 审查人依次取角色负责人、managers 中用户的上级、审查的默认审查人和升级审查人，用户不能审查自己的授权，
+// 找不到其他审查人时创建失败
+func (s *ReviewService) Create(ctx context.Context, campaign domain.ReviewCampaign, managers map[int64]int64) (domain.ReviewCampaign, error) {
+	now := time.Now().UnixMilli()
+	if err := campaign.Validate(now); err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	var privileged []int64
+	if campaign.Privileged {
+		policies, err := s.approvals.ListPolicies(ctx, campaign.BizID)
+		if err != nil {
+			return domain.ReviewCampaign{}, err
+		}
+		for i := range policies {
+			privileged = append(privileged, policies[i].RoleIDs...)
+		}
+	}
+	policies, err := s.access.List(ctx, campaign.BizID)
+	if err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	owners := make(map[int64][]int64, len(policies))
+	for i := range policies {
+		owners[policies[i].RoleID] = policies[i].Owners
+	}
+
+	items, err := s.snapshot(ctx, campaign, privileged, now)
+	if err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	if len(items) == 0 {
+		return domain.ReviewCampaign{}, errors.New("审查范围内没有生效中的授权")
+	}
+	for i := range items {
+		candidates := append(slices.Clone(owners[items[i].RoleID]), managers[items[i].UserID], campaign.DefaultReviewer, campaign.EscalateTo)
+		items[i].Reviewer = reviewer(items[i].UserID, candidates...)
+		if items[i].Reviewer == 0 {
+			return domain.ReviewCampaign{}, fmt.Errorf("用户 %d 的授权 %d 没有其他审查人，请更换默认审查人", items[i].UserID, items[i].UserRoleID)
+		}
+	}
+
+	campaign.Status = domain.ReviewCampaignActive
+	campaign.Ctime = now
+	campaign.Utime = now
+	campaign, err = s.repo.CreateCampaign(ctx, campaign, items)
+	if err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	reviewers := slice.ToMap(items, func(item domain.ReviewItem) int64 { return item.Reviewer })
+	receivers := make([]int64, 0, len(reviewers))
+	for uid := range reviewers {
+		receivers = append(receivers, uid)
+	}
+	s.notify(ctx, campaign.BizID, domain.NotificationPriorityNormal, receivers, "review.created", "请完成权限审查",
+		fmt.Sprintf("权限审查 %s（ID：%d）已开始，请在 %s 之前确认或者回收分配给你的授权",
+			campaign.Name, campaign.ID, time.UnixMilli(campaign.Deadline).Format(time.DateTime)))
+	return campaign, nil
+}
+
+func (s *ReviewService) GetCampaign(ctx context.Context, id int64) (domain.ReviewCampaign, error) {
+	return s.repo.GetCampaign(ctx, id)
+}
+
+func (s *ReviewService) ListCampaigns(ctx context.Context, bizID int64, offset, limit int) ([]domain.ReviewCampaign, error) {
+	return s.repo.ListCampaigns(ctx, bizID, offset, listLimit(limit))
+}
+
+// ListItems 查询审查项，reviewer 大于0时只返回分配给该审查人的审查项
+func (s *ReviewService) ListItems(ctx context.Context, campaignID, reviewer int64) ([]domain.ReviewItem, error) {
+	items, err := s.repo.ListItems(ctx, campaignID)
+	if err != nil || reviewer <= 0 {
+		return items, err
+	}
+	return slice.FilterMap(items, func(_ int, src domain.ReviewItem) (domain.ReviewItem, bool) {
+		return src, src.Reviewer == reviewer
+	}), nil
+}
+
+// Decide 审查人确认或者回收一条授权，返回处理后的审查项
+func (s *ReviewService) Decide(ctx context.Context, campaignID, itemID, uid int64, certify bool, comment string) (domain.ReviewItem, error) {
+	campaign, err := s.repo.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return domain.ReviewItem{}, err
+	}
+	if campaign.Status == domain.ReviewCampaignCompleted {
+		return domain.ReviewItem{}, errors.New("权限审查已经结束")
+	}
+	item, err := s.repo.GetItem(ctx, campaignID, itemID)
+	if err != nil {
+		return domain.ReviewItem{}, err
+	}
+	if !item.Pending() {
+		return domain.ReviewItem{}, domain.ErrReviewItemDecided
+	}
+	if item.Reviewer != uid {
+		return domain.ReviewItem{}, domain.ErrNotReviewer
+	}
+	if item.UserID == uid {
+		return domain.ReviewItem{}, errors.New("不能审查自己的授权")
+	}
+	item.Decision = domain.ReviewCertified
+	if !certify {
+		if err = s.revoke(ctx, campaign.BizID, item); err != nil {
+			return domain.ReviewItem{}, err
+		}
+		item.Decision = domain.ReviewRevoked
+	}
+	item.Comment = comment
+	item.DecidedBy = uid
+	item.DecidedAt = time.Now().UnixMilli()
+	if err = s.repo.Decide(ctx, item); err != nil {
+		return domain.ReviewItem{}, err
+	}
+	if err = s.tryComplete(ctx, campaign); err != nil {
+		s.logger.Error("结束权限审查失败", elog.FieldErr(err), elog.Int64("id", campaignID))
+	}
+	return item, nil
+}
+
+// Report 审查报告，审查结束后也可以随时查看
+func (s *ReviewService) Report(ctx context.Context, id int64) (domain.ReviewReport, error) {
+	campaign, err := s.repo.GetCampaign(ctx, id)
+	if err != nil {
+		return domain.ReviewReport{}, err
+	}
+	items, err := s.repo.ListItems(ctx, id)
+	if err != nil {
+		return domain.ReviewReport{}, err
+	}
+	return domain.NewReviewReport(campaign, items), nil
+}
+
+// ProcessDeadlines 处理已经过了截止时间的审查，由定时任务调用
+// 部分回收失败的审查会留在截止时间索引里，所以先读取全部到期的审查再逐个处理，
+// 单个审查失败时记录日志并且继续，返回全部失败
+func (s *ReviewService) ProcessDeadlines(ctx context.Context) error {
+	now := time.Now().UnixMilli()
+	var ids []int64
+	for offset := int64(0); ; offset += reviewDeadlineBatch {
+		batch, err := s.repo.ListDueCampaigns(ctx, now, offset, reviewDeadlineBatch)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, batch...)
+		if len(batch) < reviewDeadlineBatch {
+			break
+		}
+	}
+	var errs []error
+	for _, id := range ids {
+		campaign, err := s.repo.GetCampaign(ctx, id)
+		if err == nil {
+			err = s.expire(ctx, campaign)
+		}
+		if err != nil {
+			s.logger.Error("处理超期的权限审查失败", elog.FieldErr(err), elog.Int64("campaignId", id))
+			errs = append(errs, fmt.Errorf("权限审查 %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ReviewService) expire(ctx context.Context, campaign domain.ReviewCampaign) error {
+	items, err := s.repo.ListItems(ctx, campaign.ID)
+	if err != nil {
+		return err
+	}
+	pending := slice.FilterMap(items, func(_ int, src domain.ReviewItem) (domain.ReviewItem, bool) {
+		return src, src.Pending()
+	})
+	if campaign.ExpirePolicy == domain.ReviewExpireEscalate {
+		for i := range pending {
+			// 升级审查人就是被审查的用户时转交给审查的创建人，仍然是自己时保留原来的审查人
+			if to := reviewer(pending[i].UserID, campaign.EscalateTo, campaign.CreatorUID); to > 0 {
+				pending[i].Reviewer = to
+			}
+			pending[i].Escalated = true
+		}
+		if err = s.repo.Reassign(ctx, pending); err != nil {
+			return err
+		}
+		campaign.Status = domain.ReviewCampaignEscalated
+		if len(pending) == 0 {
+			campaign.Status = domain.ReviewCampaignCompleted
+		}
+		campaign.Utime = time.Now().UnixMilli()
+		if err = s.repo.UpdateCampaign(ctx, campaign); err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			s.notify(ctx, campaign.BizID, domain.NotificationPriorityHigh, []int64{campaign.EscalateTo, campaign.CreatorUID},
+				"review.escalated", "权限审查超期未完成",
+				fmt.Sprintf("权限审查 %s（ID：%d）有 %d 条授权超期未审查，已经转交给用户 %d 处理",
+					campaign.Name, campaign.ID, len(pending), campaign.EscalateTo))
+			return nil
+		}
+		s.notifyReport(ctx, campaign, items)
+		return nil
+	}
+
+	// 失败的审查项没有结论，审查留在截止时间索引里，下次定时任务重试
+	var errs []error
+	for i := range pending {
+		item := pending[i]
+		if err = s.revoke(ctx, campaign.BizID, item); err != nil {
+			s.logger.Error("回收超期未审查的授权失败", elog.FieldErr(err),
+				elog.Int64("campaignId", campaign.ID), elog.Int64("userRoleId", item.UserRoleID))
+			errs = append(errs, fmt.Errorf("审查项 %d: %w", item.ID, err))
+			continue
+		}
+		item.Decision = domain.ReviewAutoRevoked
+		item.DecidedAt = time.Now().UnixMilli()
+		err = s.repo.Decide(ctx, item)
+		if errors.Is(err, domain.ErrReviewItemDecided) {
+			continue
+		}
+		if err != nil {
+			// 授权已经回收，下次重试时回收不存在的授权视为成功
+			s.logger.Error("记录超期未审查的结论失败", elog.FieldErr(err),
+				elog.Int64("campaignId", campaign.ID), elog.Int64("itemId", item.ID))
+			errs = append(errs, fmt.Errorf("审查项 %d: %w", item.ID, err))
+			continue
+		}
+		s.audits.RecordJob(ctx, domain.AuditRecord{
+			BizID:        campaign.BizID,
+			Route:        reviewDeadlineRoute,
+			Table:        domain.UserRoleTable.String(),
+			TargetID:     item.UserRoleID,
+			TargetUserID: item.UserID,
+			Payload:      fmt.Sprintf(`{"campaignId":%d,"itemId":%d}`, campaign.ID, item.ID),
+			Outcome:      domain.AuditOutcomeSuccess,
+		})
+	}
+	return errors.Join(append(errs, s.tryComplete(ctx, campaign))...)
+}
+
+// tryComplete 全部审查项都有结论时结束审查并且发送报告
+func (s *ReviewService) tryComplete(ctx context.Context, campaign domain.ReviewCampaign) error {
+	items, err := s.repo.ListItems(ctx, campaign.ID)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		if items[i].Pending() {
+			return nil
+		}
+	}
+	campaign.Status = domain.ReviewCampaignCompleted
+	campaign.Utime = time.Now().UnixMilli()
+	if err = s.repo.UpdateCampaign(ctx, campaign); err != nil {
+		return err
+	}
+	s.notifyReport(ctx, campaign, items)
+	return nil
+}
+
+func (s *ReviewService) snapshot(ctx context.Context, campaign domain.ReviewCampaign, privileged []int64, now int64) ([]domain.ReviewItem, error) {
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, campaign.BizID)
+	if err != nil {
+		return nil, err
+	}
+	var res []domain.ReviewItem
+	for offset := int32(0); ; offset += reviewSnapshotPageSize {
+		resp, err1 := s.client.ListUserRoles(bizCtx, &permissionv1.ListUserRolesRequest{
+			BizId:  campaign.BizID,
+			Offset: offset,
+			Limit:  reviewSnapshotPageSize,
+		})
+		if err1 != nil {
+			return nil, err1
+		}
+		for _, src := range resp.UserRoles {
+			if src.EndTime > 0 && src.EndTime <= now {
+				continue
+			}
+			if !campaign.InScope(src.RoleId, src.RoleType, privileged) {
+				continue
+			}
+			res = append(res, domain.ReviewItem{
+				UserRoleID: src.Id,
+				UserID:     src.UserId,
+				RoleID:     src.RoleId,
+				RoleName:   src.RoleName,
+				RoleType:   src.RoleType,
+				StartTime:  src.StartTime,
+				EndTime:    src.EndTime,
+			})
+		}
+		if len(resp.UserRoles) < reviewSnapshotPageSize {
+			return res, nil
+		}
+	}
+}
+
+// reviewer 返回第一个不是 uid 本人的候选审查人，没有时返回 0
+func reviewer(uid int64, candidates ...int64) int64 {
+	for _, c := range candidates {
+		if c > 0 && c != uid {
+			return c
+		}
+	}
+	return 0
+}
+
+func (s *ReviewService) revoke(ctx context.Context, bizID int64, item domain.ReviewItem) error {
+	bizCtx, err := s.client.BusinessAdminCtx(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.RevokeUserRole(bizCtx, &permissionv1.RevokeUserRoleRequest{Id: item.UserRoleID})
+	// 权限平台上已经不存在的授权视为已经回收
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("回收授权失败: %w", err)
+	}
+	s.changes.PublishRevoke(ctx, bizID, domain.UserRoleTable, item.UserRoleID)
+	return nil
+}
+
+func (s *ReviewService) notifyReport(ctx context.Context, campaign domain.ReviewCampaign, items []domain.ReviewItem) {
+	report := domain.NewReviewReport(campaign, items)
+	s.notify(ctx, campaign.BizID, domain.NotificationPriorityNormal, []int64{campaign.CreatorUID}, "review.completed", "权限审查已完成",
+		fmt.Sprintf("权限审查 %s（ID：%d）已完成，共 %d 条授权：确认 %d 条，回收 %d 条，超期自动回收 %d 条，升级处理 %d 条",
+			campaign.Name, campaign.ID, report.Total, report.Certified, report.Revoked, report.AutoRevoked, report.Escalated))
+}
+
+// notify 通知失败不影响审查本身
+func (s *ReviewService) notify(ctx context.Context, bizID int64, priority domain.NotificationPriority, receivers []int64, event, title, content string) {
+	err := s.notifier.Notify(ctx, domain.Notification{
+		BizID:     bizID,
+		Priority:  priority,
+		Event:     event,
+		Title:     title,
+		Content:   content,
+		Receivers: receivers,
+	})
+	if err != nil {
+		s.logger.Error("发送权限审查通知失败", elog.FieldErr(err), elog.Int64("bizId", bizID), elog.String("event", event))
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	executeScheduledChangeBatch = 100
+	// scheduledChangeRoute 定时任务执行定时变更时审计记录里的路由
+	scheduledChangeRoute = "cron.scheduledChange"
+)
+
+type ScheduleConfig struct {
+	// MaxAttempts 执行失败时最多尝试的次数，包括第一次
+	MaxAttempts int
+	// RetryInterval 第 N 次失败后等待 N 倍的间隔再重试
+	RetryInterval time.Duration
+	// Lease 执行任务认领定时变更的租期，执行任务崩溃时租期过后由其他实例重新执行
+	Lease time.Duration
+}
+
+// ScheduledChangeExecutor 执行一个定时变更，返回执行结果
+type ScheduledChangeExecutor func(ctx context.Context, change domain.ScheduledChange) (string, error)
+
+// ScheduleService 管理在指定时间执行的授权变更
+// 和审批一样，这里只负责状态流转，真正的授权由调用方传入的 ScheduledChangeExecutor 执行
+type ScheduleService struct {
+	repo     repository.ScheduledChangeRepository
+	audits   *AuditService
+	notifier Notifier
+	cfg      ScheduleConfig
+	logger   *elog.Component
+}
+
+func NewScheduleService(
+	repo repository.ScheduledChangeRepository,
+	audits *AuditService,
+	notifier Notifier,
+	cfg ScheduleConfig,
+) *ScheduleService {
+	return &ScheduleService{
+		repo:     repo,
+		audits:   audits,
+		notifier: notifier,
+		cfg:      cfg,
+		logger:   elog.DefaultLogger,
+	}
+}
+
+func (s *ScheduleService) Schedule(ctx context.Context, change domain.ScheduledChange) (domain.ScheduledChange, error) {
+	now := time.Now().UnixMilli()
+	if err := change.Validate(now); err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	change.Status = domain.ScheduledChangePending
+	change.Ctime = now
+	change.Utime = now
+	return s.repo.Create(ctx, change)
+}
+
+func (s *ScheduleService) Get(ctx context.Context, id int64) (domain.ScheduledChange, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *ScheduleService) List(ctx context.Context, bizID int64, offset, limit int) ([]domain.ScheduledChange, error) {
+	return s.repo.List(ctx, bizID, offset, listLimit(limit))
+}
+
+// Cancel 取消还没有开始执行的定时变更
+func (s *ScheduleService) Cancel(ctx context.Context, id int64) (domain.ScheduledChange, error) {
+	return s.take(ctx, id, func(change *domain.ScheduledChange) {
+		change.Status = domain.ScheduledChangeCancelled
+	})
+}
+
+// Reschedule 修改还没有开始执行的定时变更的执行时间，重试中的变更会重新计算尝试次数
+func (s *ScheduleService) Reschedule(ctx context.Context, id, executeAt int64) (domain.ScheduledChange, error) {
+	if executeAt <= time.Now().UnixMilli() {
+		return domain.ScheduledChange{}, fmt.Errorf("执行时间必须晚于当前时间")
+	}
+	return s.take(ctx, id, func(change *domain.ScheduledChange) {
+		change.ExecuteAt = executeAt
+		change.Attempts = 0
+	})
+}
+
+// ExecuteDue 执行已经到期的定时变更，由定时任务调用
+func (s *ScheduleService) ExecuteDue(ctx context.Context, exec ScheduledChangeExecutor) error {
+	for {
+		now := time.Now().UnixMilli()
+		ids, err := s.repo.ListDue(ctx, now, executeScheduledChangeBatch)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			ok, err1 := s.repo.Claim(ctx, id, now, s.cfg.Lease)
+			if err1 != nil {
+				return err1
+			}
+			if !ok {
+				continue
+			}
+			if err1 = s.execute(ctx, id, exec); err1 != nil {
+				s.logger.Error("执行定时变更失败", elog.FieldErr(err1), elog.Int64("id", id))
+			}
+		}
+		if len(ids) < executeScheduledChangeBatch {
+			return nil
+		}
+	}
+}
+
+func (s *ScheduleService) execute(ctx context.Context, id int64, exec ScheduledChangeExecutor) error {
+	change, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if change.Status != domain.ScheduledChangePending {
+		// 索引里残留的已经结束的变更，保存一次就会被移出索引
+		return s.repo.Save(ctx, change)
+	}
+	result, execErr := exec(ctx, change)
+	now := time.Now()
+	change.Attempts++
+	change.Utime = now.UnixMilli()
+	switch {
+	case execErr == nil:
+		change.Status = domain.ScheduledChangeExecuted
+		change.Result = result
+		change.ExecutedAt = now.UnixMilli()
+	case change.Attempts < s.cfg.MaxAttempts && !errors.Is(execErr, domain.ErrScheduledChangeNeedsApproval) &&
+		!errors.Is(execErr, domain.ErrScheduledChangeForbidden):
+		change.Result = execErr.Error()
+		change.ExecuteAt = now.Add(time.Duration(change.Attempts) * s.cfg.RetryInterval).UnixMilli()
+	default:
+		change.Status = domain.ScheduledChangeFailed
+		change.Result = execErr.Error()
+	}
+	if err = s.repo.Save(ctx, change); err != nil {
+		return err
+	}
+	s.audit(ctx, change, execErr)
+	if change.Status == domain.ScheduledChangeFailed {
+		s.notifyFailed(ctx, change)
+	}
+	return execErr
+}
+
+// take 认领还没有开始执行的定时变更并且修改
+func (s *ScheduleService) take(ctx context.Context, id int64, fn func(change *domain.ScheduledChange)) (domain.ScheduledChange, error) {
+	change, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	if change.Status != domain.ScheduledChangePending {
+		return domain.ScheduledChange{}, domain.ErrScheduledChangeNotPending
+	}
+	ok, err := s.repo.Take(ctx, id)
+	if err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	if !ok {
+		return domain.ScheduledChange{}, domain.ErrScheduledChangeNotPending
+	}
+	fn(&change)
+	change.Utime = time.Now().UnixMilli()
+	return change, s.repo.Save(ctx, change)
+}
+
+// audit 每次执行都记录，失败时记录错误
+func (s *ScheduleService) audit(ctx context.Context, change domain.ScheduledChange, execErr error) {
+	record := domain.AuditRecord{
+		BizID:        change.BizID,
+		Route:        scheduledChangeRoute,
+		Table:        change.Kind.Table().String(),
+		TargetID:     change.TargetID,
+		TargetUserID: change.TargetUserID,
+		Payload:      change.Payload,
+		Outcome:      domain.AuditOutcomeSuccess,
+		Result:       change.Result,
+	}
+	if execErr != nil {
+		record.Outcome = domain.AuditOutcomeFailure
+		record.Error = execErr.Error()
+		record.Result = ""
+	}
+	s.audits.RecordJob(ctx, record)
+}
+
+func (s *ScheduleService) notifyFailed(ctx context.Context, change domain.ScheduledChange) {
+	err := s.notifier.Notify(ctx, domain.Notification{
+		BizID:    change.BizID,
+		Priority: domain.NotificationPriorityHigh,
+		Event:    "schedule.failed",
+		Title:    "定时变更执行失败",
+		Content: fmt.Sprintf("定时变更 %d（%s）重试 %d 次后仍然失败：%s",
+			change.ID, change.Kind, change.Attempts, change.Result),
+		Receivers: []int64{change.CreatorUID},
+	})
+	if err != nil {
+		s.logger.Error("发送定时变更失败通知失败", elog.FieldErr(err), elog.Int64("id", change.ID))
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	// snapshotRoute 定时任务创建快照时审计记录里的路由
+	snapshotRoute = "cron.snapshot"
+	// snapshotScanPageSize 每次从权限平台拉取的业务数量
+	snapshotScanPageSize = 500
+)
+
+type SnapshotConfig struct {
+	// Storage 压缩后的模型保存在 redis 还是本地磁盘 file
+	Storage string
+	// Dir Storage 为 file 时保存快照的目录
+	Dir string
+	// MaxCount 和 MaxAge 是每个业务的保留策略，最新的快照总是保留
+	MaxCount int
+	MaxAge   time.Duration
+}
+
+// ModelLoader 读取业务当前完整的权限模型
+type ModelLoader func(ctx context.Context, bizID int64) (domain.RBACModel, error)
+
+// SnapshotService 管理业务权限模型的快照，每次创建快照后按照保留策略清理旧快照
+// 读取权限模型和回滚都需要 Web 层的方法，由调用方传入
+type SnapshotService struct {
+	repo   repository.SnapshotRepository
+	client *AdminClient
+	audits *AuditService
+	cfg    SnapshotConfig
+	logger *elog.Component
+}
+
+func NewSnapshotService(
+	repo repository.SnapshotRepository,
+	client *AdminClient,
+	audits *AuditService,
+	cfg SnapshotConfig,
+) *SnapshotService {
+	return &SnapshotService{
+		repo:   repo,
+		client: client,
+		audits: audits,
+		cfg:    cfg,
+		logger: elog.DefaultLogger,
+	}
+}
+
+func (s *SnapshotService) Create(ctx context.Context, snapshot domain.Snapshot) (domain.Snapshot, error) {
+	snapshot.Ctime = time.Now().UnixMilli()
+	snapshot, err := s.repo.Create(ctx, snapshot)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	// 清理失败不影响这次创建，下次创建时还会再清理
+	if err = s.prune(ctx, snapshot.BizID); err != nil {
+		s.logger.Error("清理过期快照失败", elog.FieldErr(err), elog.Int64("bizId", snapshot.BizID))
+	}
+	return snapshot, nil
+}
+
+// Get 查询业务的快照，包括完整的模型
+func (s *SnapshotService) Get(ctx context.Context, bizID, id int64) (domain.Snapshot, error) {
+	snapshot, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Snapshot{}, err
+	}
+	if snapshot.BizID != bizID {
+		return domain.Snapshot{}, repository.ErrSnapshotNotFound
+	}
+	return snapshot, nil
+}
+
+// List 按照创建时间倒序查询业务的快照，保留策略限制了数量，所以不分页
+func (s *SnapshotService) List(ctx context.Context, bizID int64) ([]domain.Snapshot, error) {
+	return s.repo.List(ctx, bizID)
+}
+
+// SnapshotAll 为全部业务创建快照，由定时任务调用
+// 单个业务失败不影响其他业务，全部创建完之后再返回错误
+func (s *SnapshotService) SnapshotAll(ctx context.Context, load ModelLoader) error {
+	var errs []error
+	for offset := int32(0); ; offset += snapshotScanPageSize {
+		resp, err := s.client.ListBusinessConfigs(s.client.SystemAdminCtx(ctx), &permissionv1.ListBusinessConfigsRequest{
+			Offset: offset,
+			Limit:  snapshotScanPageSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, biz := range resp.Configs {
+			if err = s.snapshot(ctx, biz.Id, load); err != nil {
+				s.logger.Error("定时创建快照失败", elog.FieldErr(err), elog.Int64("bizId", biz.Id))
+				errs = append(errs, fmt.Errorf("bizId=%d: %w", biz.Id, err))
+			}
+		}
+		if len(resp.Configs) < snapshotScanPageSize {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+func (s *SnapshotService) snapshot(ctx context.Context, bizID int64, load ModelLoader) error {
+	model, err := load(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	snapshot, err := s.Create(ctx, domain.Snapshot{BizID: bizID, Trigger: domain.SnapshotScheduled, Model: model})
+	if err != nil {
+		return err
+	}
+	s.audits.RecordJob(ctx, domain.AuditRecord{
+		BizID:    bizID,
+		Route:    snapshotRoute,
+		Table:    domain.BusinessConfigTable.String(),
+		TargetID: snapshot.ID,
+		Payload:  fmt.Sprintf(`{"size":%d}`, snapshot.Size),
+		Outcome:  domain.AuditOutcomeSuccess,
+	})
+	return nil
+}
+
+func (s *SnapshotService) prune(ctx context.Context, bizID int64) error {
+	snapshots, err := s.repo.List(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	retention := domain.SnapshotRetention{MaxCount: s.cfg.MaxCount, MaxAge: s.cfg.MaxAge}
+	var errs []error
+	for _, id := range retention.Expired(snapshots, time.Now().UnixMilli()) {
+		if err = s.repo.Delete(ctx, bizID, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
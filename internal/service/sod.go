@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+)
+
+// SoDService 管理业务的职责分离约束
+// 检查需要业务完整的权限模型，由调用方读取后传入
+type SoDService struct {
+	repo repository.SoDConstraintRepository
+}
+
+func NewSoDService(repo repository.SoDConstraintRepository) *SoDService {
+	return &SoDService{repo: repo}
+}
+
+func (s *SoDService) Create(ctx context.Context, constraint domain.SoDConstraint) (domain.SoDConstraint, error) {
+	if err := constraint.Validate(); err != nil {
+		return domain.SoDConstraint{}, err
+	}
+	now := time.Now().UnixMilli()
+	constraint.Ctime = now
+	constraint.Utime = now
+	return s.repo.Create(ctx, constraint)
+}
+
+func (s *SoDService) List(ctx context.Context, bizID int64) ([]domain.SoDConstraint, error) {
+	return s.repo.List(ctx, bizID)
+}
+
+func (s *SoDService) Delete(ctx context.Context, bizID, id int64) error {
+	constraint, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if constraint.BizID != bizID {
+		return repository.ErrSoDConstraintNotFound
+	}
+	return s.repo.Delete(ctx, bizID, id)
+}
+
+// Check 在业务当前的权限模型上执行 change，产生新的违反时返回 domain.SoDError
+// 没有约束的业务不读取权限模型。批量授权时 change 应该包含整批变更，逐个检查看不到同一批里的其他授权
+func (s *SoDService) Check(ctx context.Context, bizID int64, load ModelLoader, change func(model *domain.RBACModel) error) error {
+	constraints, err := s.repo.List(ctx, bizID)
+	if err != nil || len(constraints) == 0 {
+		return err
+	}
+	before, err := load(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	// change 修改的是授权部分，复制之后再修改，避免影响 before
+	after := before
+	after.RoleInclusions = slices.Clone(before.RoleInclusions)
+	after.RolePermissions = slices.Clone(before.RolePermissions)
+	after.UserRoles = slices.Clone(before.UserRoles)
+	after.UserPermissions = slices.Clone(before.UserPermissions)
+	if err = change(&after); err != nil {
+		return err
+	}
+	violations := domain.NewSoDViolations(constraints, before, after, time.Now().UnixMilli())
+	if len(violations) > 0 {
+		return &domain.SoDError{Violations: violations}
+	}
+	return nil
+}
+
+// Report 列出业务当前全部违反约束的用户，约束可能是在授权之后才添加的
+func (s *SoDService) Report(ctx context.Context, bizID int64, model domain.RBACModel) ([]domain.SoDViolation, error) {
+	constraints, err := s.repo.List(ctx, bizID)
+	if err != nil {
+		return nil, err
+	}
+	return model.SoDViolations(constraints, time.Now().UnixMilli()), nil
+}
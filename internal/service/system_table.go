@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// systemTableScanPageSize 每次从权限平台拉取的行数
+const systemTableScanPageSize = 500
+
+var errBusinessAdminRoleNotFound = errors.New("业务没有业务管理员角色")
+
+// SystemTableService 为已经接入的业务补齐后来新增的系统表
+// 接入业务时只初始化当时已有的系统表，审计日志、审批、职责分离约束和定时变更是后来加的，
+// 老业务没有这些资源，业务管理员也就没有对应的权限。补齐是幂等的，已经存在的资源、权限和授权会跳过
+type SystemTableService struct {
+	client *AdminClient
+	logger *elog.Component
+}
+
+func NewSystemTableService(client *AdminClient) *SystemTableService {
+	return &SystemTableService{client: client, logger: elog.DefaultLogger}
+}
+
+// BackfillAll 为全部业务补齐系统表
+// 单个业务失败不影响其他业务，全部处理完之后再返回错误
+func (s *SystemTableService) BackfillAll(ctx context.Context) ([]domain.SystemTableBackfill, error) {
+	var (
+		res  []domain.SystemTableBackfill
+		errs []error
+	)
+	for offset := int32(0); ; offset += systemTableScanPageSize {
+		resp, err := s.client.ListBusinessConfigs(s.client.SystemAdminCtx(ctx), &permissionv1.ListBusinessConfigsRequest{
+			Offset: offset,
+			Limit:  systemTableScanPageSize,
+		})
+		if err != nil {
+			return res, err
+		}
+		for _, biz := range resp.Configs {
+			backfill, err1 := s.Backfill(ctx, biz.Id)
+			if err1 != nil {
+				s.logger.Error("补齐系统表失败", elog.FieldErr(err1), elog.Int64("bizId", biz.Id))
+				errs = append(errs, fmt.Errorf("bizId=%d: %w", biz.Id, err1))
+				continue
+			}
+			res = append(res, backfill)
+		}
+		if len(resp.Configs) < systemTableScanPageSize {
+			return res, errors.Join(errs...)
+		}
+	}
+}
+
+// Backfill 为一个业务补齐 domain.InitialBusinessTables 中缺少的资源、读写权限，并授予业务管理员
+func (s *SystemTableService) Backfill(ctx context.Context, bizID int64) (domain.SystemTableBackfill, error) {
+	res := domain.SystemTableBackfill{BizID: bizID}
+	ctx, err := s.client.BusinessAdminCtx(ctx, bizID)
+	if err != nil {
+		return res, err
+	}
+	admin, err := s.businessAdminRole(ctx, bizID)
+	if err != nil {
+		return res, err
+	}
+	resources, permissions, granted, err := s.existing(ctx, bizID, admin)
+	if err != nil {
+		return res, err
+	}
+	for _, table := range domain.InitialBusinessTables {
+		key := table.KeyForBusinessAdmin(bizID)
+		resource, ok := resources[key]
+		if !ok {
+			resp, err1 := s.client.CreateResource(ctx, &permissionv1.CreateResourceRequest{
+				Resource: &permissionv1.Resource{BizId: bizID, Type: table.Type(), Key: key, Name: table.String()},
+			})
+			if err1 != nil {
+				return res, fmt.Errorf("创建资源 %s 失败: %w", key, err1)
+			}
+			resource = resp.Resource
+			res.Resources = append(res.Resources, key)
+		}
+		for _, action := range []domain.PermissionActionType{domain.PermissionActionRead, domain.PermissionActionWrite} {
+			ref := key + ":" + action.String()
+			permission, ok := permissions[ref]
+			if !ok {
+				name := fmt.Sprintf("%s-%s", table, action)
+				resp, err1 := s.client.CreatePermission(ctx, &permissionv1.CreatePermissionRequest{
+					Permission: &permissionv1.Permission{
+						BizId:        bizID,
+						Name:         name,
+						Description:  name,
+						ResourceId:   resource.Id,
+						ResourceType: resource.Type,
+						ResourceKey:  resource.Key,
+						Actions:      []string{action.String()},
+					},
+				})
+				if err1 != nil {
+					return res, fmt.Errorf("创建权限 %s 失败: %w", ref, err1)
+				}
+				permission = resp.Permission
+				res.Permissions = append(res.Permissions, ref)
+			}
+			if granted[ref] {
+				continue
+			}
+			_, err1 := s.client.GrantRolePermission(ctx, &permissionv1.GrantRolePermissionRequest{
+				RolePermission: &permissionv1.RolePermission{
+					BizId:            bizID,
+					RoleId:           admin.Id,
+					PermissionId:     permission.Id,
+					RoleName:         admin.Name,
+					RoleType:         admin.Type,
+					ResourceType:     resource.Type,
+					ResourceKey:      resource.Key,
+					PermissionAction: action.String(),
+				},
+			})
+			if err1 != nil {
+				return res, fmt.Errorf("授予业务管理员权限 %s 失败: %w", ref, err1)
+			}
+			res.RolePermissions = append(res.RolePermissions, ref)
+		}
+	}
+	return res, nil
+}
+
+func (s *SystemTableService) businessAdminRole(ctx context.Context, bizID int64) (*permissionv1.Role, error) {
+	for offset := int32(0); ; offset += systemTableScanPageSize {
+		resp, err := s.client.ListRoles(ctx, &permissionv1.ListRolesRequest{
+			BizId:  bizID,
+			Type:   domain.DefaultAccountRoleType,
+			Offset: offset,
+			Limit:  systemTableScanPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range resp.Roles {
+			if role.Name == domain.BusinessAdminRoleName {
+				return role, nil
+			}
+		}
+		if len(resp.Roles) < systemTableScanPageSize {
+			return nil, errBusinessAdminRoleNotFound
+		}
+	}
+}
+
+// existing 读取业务已有的系统表资源、权限和业务管理员已经拥有的权限
+// 权限和授权按照 资源Key:动作 索引
+func (s *SystemTableService) existing(ctx context.Context, bizID int64, admin *permissionv1.Role) (
+	map[string]*permissionv1.Resource, map[string]*permissionv1.Permission, map[string]bool, error) {
+	resources := map[string]*permissionv1.Resource{}
+	permissions := map[string]*permissionv1.Permission{}
+	granted := map[string]bool{}
+	for offset := int32(0); ; offset += systemTableScanPageSize {
+		resp, err := s.client.ListResources(ctx, &permissionv1.ListResourcesRequest{BizId: bizID, Offset: offset, Limit: systemTableScanPageSize})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, r := range resp.Resources {
+			if r.Type == domain.ResourceTable.Type() {
+				resources[r.Key] = r
+			}
+		}
+		if len(resp.Resources) < systemTableScanPageSize {
+			break
+		}
+	}
+	for offset := int32(0); ; offset += systemTableScanPageSize {
+		resp, err := s.client.ListPermissions(ctx, &permissionv1.ListPermissionsRequest{BizId: bizID, Offset: offset, Limit: systemTableScanPageSize})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, p := range resp.Permissions {
+			// 只复用只有一个动作的权限，和接入业务时创建的一致
+			if p.ResourceType == domain.ResourceTable.Type() && len(p.Actions) == 1 {
+				permissions[p.ResourceKey+":"+p.Actions[0]] = p
+			}
+		}
+		if len(resp.Permissions) < systemTableScanPageSize {
+			break
+		}
+	}
+	for offset := int32(0); ; offset += systemTableScanPageSize {
+		resp, err := s.client.ListRolePermissions(ctx, &permissionv1.ListRolePermissionsRequest{BizId: bizID, Offset: offset, Limit: systemTableScanPageSize})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, rp := range resp.RolePermissions {
+			if rp.RoleId == admin.Id && rp.ResourceType == domain.ResourceTable.Type() {
+				granted[rp.ResourceKey+":"+rp.PermissionAction] = true
+			}
+		}
+		if len(resp.RolePermissions) < systemTableScanPageSize {
+			return resources, permissions, granted, nil
+		}
+	}
+}
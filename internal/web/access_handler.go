@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"errors"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// AccessHandler 用户自助申请限时角色
+// 审批复用 /approval/request/approve 和 /approval/request/reject
+type AccessHandler struct {
+	*BaseHandler
+	svc *service.AccessService
+}
+
+func NewAccessHandler(handler *BaseHandler, svc *service.AccessService) *AccessHandler {
+	return &AccessHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *AccessHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/access/policy/save", ginx.BS(audited(h.audits, domain.ApprovalTable, h.SavePolicy)))
+	server.POST("/access/policy/delete", ginx.BS(audited(h.audits, domain.ApprovalTable, h.DeletePolicy)))
+	server.GET("/access/policy/list", ginx.BS[AccessPolicyListReq](h.ListPolicies))
+
+	server.POST("/access/request", ginx.BS(audited(h.audits, domain.ApprovalTable, h.Request)))
+	server.GET("/access/request/list", ginx.BS[AccessRequestListReq](h.ListMine))
+}
+
+func (h *AccessHandler) SavePolicy(ctx *ginx.Context, req AccessPolicyReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	// 确认角色存在并且属于该业务
+	if _, err = h.role(businessAdminCtx, req.BizID, req.Policy.RoleID); err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.svc.SavePolicy(ctx, domain.AccessPolicy{
+		BizID:       req.BizID,
+		RoleID:      req.Policy.RoleID,
+		Owners:      req.Policy.Owners,
+		MaxDuration: req.Policy.MaxDuration,
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: true,
+	}, nil
+}
+
+func (h *AccessHandler) DeletePolicy(ctx *ginx.Context, req AccessPolicyReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if err = h.svc.DeletePolicy(ctx, req.BizID, req.Policy.RoleID); err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: true,
+	}, nil
+}
+
+// ListPolicies 列出可以自助申请的角色，所有登录用户都可以查询
+func (h *AccessHandler) ListPolicies(ctx *ginx.Context, req AccessPolicyListReq, _ session.Session) (ginx.Result, error) {
+	policies, err := h.svc.ListPolicies(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listSlice(h.BaseHandler, req.ListReq, policies, func(src domain.AccessPolicy) int64 { return src.RoleID },
+		func(src domain.AccessPolicy) AccessPolicy {
+			return AccessPolicy{
+				BizID:       src.BizID,
+				RoleID:      src.RoleID,
+				Owners:      src.Owners,
+				MaxDuration: src.MaxDuration,
+				Ctime:       src.Ctime,
+				Utime:       src.Utime,
+			}
+		})
+}
+
+// Request 为自己申请限时角色，审批通过后从审批时间开始生效 Duration 毫秒
+func (h *AccessHandler) Request(ctx *ginx.Context, req AccessRequestReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	role, err := h.role(businessAdminCtx, req.BizID, req.RoleID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	cr, err := h.svc.Request(ctx, domain.ChangeRequest{
+		BizID:        req.BizID,
+		TargetID:     req.RoleID,
+		TargetUserID: uid,
+		Payload: toJSONString(UserRoleReq{
+			BizID: req.BizID,
+			UserRole: UserRole{
+				BizID:  req.BizID,
+				UserID: uid,
+				Role:   Role{ID: role.Id, Name: role.Name},
+			},
+			Reason: req.Reason,
+		}),
+		RequesterUID: uid,
+		Reason:       req.Reason,
+		Duration:     req.Duration,
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toChangeRequestVO(cr),
+	}, nil
+}
+
+// ListMine 查询自己的自助申请，status 可以是变更请求的状态，也可以是 active
+func (h *AccessHandler) ListMine(ctx *ginx.Context, req AccessRequestListReq, sess session.Session) (ginx.Result, error) {
+	return listRows(h.BaseHandler, req.ListReq, func(offset, limit int32) ([]domain.ChangeRequest, error) {
+		return h.svc.ListMine(ctx, req.BizID, sess.Claims().Uid, req.Status, int(offset), int(limit))
+	}, changeRequestID, toChangeRequestVO)
+}
+
+func (h *AccessHandler) role(ctx context.Context, bizID, roleID int64) (*permissionv1.Role, error) {
+	resp, err := h.rbacSvc.GetRole(ctx, &permissionv1.GetRoleRequest{Id: roleID})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Role.BizId != bizID {
+		return nil, errors.New("角色不属于该业务")
+	}
+	return resp.Role, nil
+}
@@ -1,11 +1,9 @@
 package web
 
 import (
-	"context"
-
 	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
 	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
-	"github.com/ecodeclub/ekit/slice"
 	"github.com/ecodeclub/ginx"
 	"github.com/ecodeclub/ginx/session"
 	"github.com/gin-gonic/gin"
@@ -21,28 +19,17 @@ func NewAccountHandler(handler *BaseHandler) *AccountHandler {
 
 func (h *AccountHandler) PrivateRoutes(server *gin.Engine) {
 	// 创建角色
-	server.POST("/account/role/create", ginx.BS[CreateAccountRoleReq](h.CreateRole))
+	server.POST("/account/role/create", ginx.BS(audited(h.audits, domain.RoleTable, h.CreateRole)))
 	// 展示角色
 	server.GET("/account/role/list", ginx.BS[ListReq](h.ListRoles))
 	// 赋予角色权限
-	server.POST("/account/role/grant_permission", ginx.BS[GrantAccountRolePermissionReq](h.GrantRolePermission))
+	server.POST("/account/role/grant_permission", ginx.BS(audited(h.audits, domain.RolePermissionTable, h.GrantRolePermission)))
 	// 撤销角色权限
-	server.POST("/account/role/revoke_permission", ginx.BS[RevokeRolePermissionReq](h.RevokeRolePermission))
+	server.POST("/account/role/revoke_permission", ginx.BS(audited(h.audits, domain.RolePermissionTable, h.RevokeRolePermission)))
 	// 赋予用户角色
-	server.POST("/account/user/grant_role", ginx.BS[GrantUserRoleReq](h.GrantUserRole))
+	server.POST("/account/user/grant_role", ginx.BS(audited(h.audits, domain.UserRoleTable, h.GrantUserRole)))
 	// 撤销用户角色
-	server.POST("/account/user/revoke_role", ginx.BS[RevokeUserRoleReq](h.RevokeUserRole))
-}
-
-func (h *AccountHandler) checkAccountPermission(ctx context.Context, bizID, userID int64) error {
-	return h.checkPermission(ctx, &permissionv1.CheckPermissionRequest{
-		Uid: userID,
-		Permission: &permissionv1.Permission{
-			ResourceType: domain.ManagerAccountResource.Type(),
-			ResourceKey:  domain.ManagerAccountResource.KeyForBusinessAdmin(bizID),
-			Actions:      []string{domain.PermissionActionRead.String(), domain.PermissionActionWrite.String()},
-		},
-	})
+	server.POST("/account/user/revoke_role", ginx.BS(audited(h.audits, domain.UserRoleTable, h.RevokeUserRole)))
 }
 
 func (h *AccountHandler) CreateRole(ctx *ginx.Context, req CreateAccountRoleReq, sess session.Session) (ginx.Result, error) {
@@ -65,6 +52,7 @@ func (h *AccountHandler) CreateRole(ctx *ginx.Context, req CreateAccountRoleReq,
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RoleTable, permission.ChangeActionCreate, h.toRoleVO(resp.Role))
 	return ginx.Result{
 		Data: resp.Role.Id,
 	}, nil
@@ -79,21 +67,18 @@ func (h *AccountHandler) ListRoles(ctx *ginx.Context, req ListReq, sess session.
 	if err != nil {
 		return ginx.Result{}, err
 	}
-	resp, err := h.rbacSvc.ListRoles(businessAdminCtx, &permissionv1.ListRolesRequest{
-		Type:   domain.DefaultAccountRoleType,
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[Role]{
-			Rows: slice.Map(resp.Roles, func(_ int, src *permissionv1.Role) Role {
-				return h.toRoleVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h.BaseHandler, req, func(offset, limit int32) ([]*permissionv1.Role, error) {
+		resp, err := h.rbacSvc.ListRoles(businessAdminCtx, &permissionv1.ListRolesRequest{
+			BizId:  req.BizID,
+			Type:   domain.DefaultAccountRoleType,
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Roles, nil
+	}, func(r *permissionv1.Role) int64 { return r.Id }, h.toRoleVO)
 }
 
 func (h *AccountHandler) GrantRolePermission(ctx *ginx.Context, req GrantAccountRolePermissionReq, sess session.Session) (ginx.Result, error) {
@@ -118,6 +103,7 @@ func (h *AccountHandler) GrantRolePermission(ctx *ginx.Context, req GrantAccount
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RolePermissionTable, permission.ChangeActionGrant, h.toRolePermissionVO(resp.RolePermission))
 	return ginx.Result{
 		Data: h.toRolePermissionVO(resp.RolePermission),
 	}, nil
@@ -138,6 +124,7 @@ func (h *AccountHandler) RevokeRolePermission(ctx *ginx.Context, req RevokeRoleP
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RolePermissionTable, permission.ChangeActionRevoke, RolePermission{ID: req.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -152,20 +139,20 @@ func (h *AccountHandler) GrantUserRole(ctx *ginx.Context, req GrantUserRoleReq,
 	if err != nil {
 		return ginx.Result{}, err
 	}
-	resp, err := h.rbacSvc.GrantUserRole(businessAdminCtx, &permissionv1.GrantUserRoleRequest{
-		UserRole: &permissionv1.UserRole{
-			UserId:   req.UserID,
-			RoleId:   req.Role.ID,
-			RoleName: req.Role.Name,
-			RoleType: domain.DefaultAccountRoleType,
-		},
+	// 业务管理员这类账号角色通常需要审批
+	res, ok, err := h.requireApproval(businessAdminCtx, domain.ChangeRequest{
+		BizID:        req.BizID,
+		Kind:         domain.ChangeRequestGrantAccountRole,
+		TargetID:     req.Role.ID,
+		TargetUserID: req.UserID,
+		Payload:      toJSONString(req),
+		RequesterUID: sess.Claims().Uid,
+		Reason:       req.Reason,
 	})
-	if err != nil {
-		return ginx.Result{}, err
+	if ok || err != nil {
+		return res, err
 	}
-	return ginx.Result{
-		Data: h.toUserRoleVO(resp.UserRole),
-	}, nil
+	return h.grantAccountRole(businessAdminCtx, req)
 }
 
 func (h *AccountHandler) RevokeUserRole(ctx *ginx.Context, req RevokeUserRoleReq, sess session.Session) (ginx.Result, error) {
@@ -182,6 +169,7 @@ func (h *AccountHandler) RevokeUserRole(ctx *ginx.Context, req RevokeUserRoleReq
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RolePermissionTable, permission.ChangeActionRevoke, RolePermission{ID: req.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
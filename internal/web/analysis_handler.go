@@ -0,0 +1,285 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAnalysisPageSize = 50
+	maxAnalysisPageSize     = 500
+)
+
+var whoCanCSVHeader = []string{"userId", "resourceType", "resourceKey", "action", "effect", "sources"}
+
+// AnalysisHandler 基于业务完整的权限模型回答"谁能做什么"的问题，只读
+type AnalysisHandler struct {
+	*BaseHandler
+}
+
+func NewAnalysisHandler(handler *BaseHandler) *AnalysisHandler {
+	return &AnalysisHandler{BaseHandler: handler}
+}
+
+func (h *AnalysisHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/user/effective-permissions", ginx.BS[EffectivePermissionsReq](h.EffectivePermissions))
+	server.GET("/resource/who-can", ginx.BS[WhoCanReq](h.WhoCan))
+	server.GET("/role/graph", ginx.BS[RoleGraphReq](h.RoleGraph))
+	server.POST("/simulate", ginx.BS[SimulateReq](h.Simulate))
+	server.POST("/explain", ginx.BS[ExplainReq](h.Explain))
+}
+
+// EffectivePermissions 计算用户实际拥有的权限，展开角色包含关系，合并角色权限和直接授予的权限，
+// 每个权限都带上全部来源，需要全部业务表的读权限
+func (h *AnalysisHandler) EffectivePermissions(ctx *ginx.Context, req EffectivePermissionsReq, sess session.Session) (ginx.Result, error) {
+	if req.UserID <= 0 {
+		return ginx.Result{}, errors.New("用户ID不合法")
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if req.At == 0 {
+		req.At = time.Now().UnixMilli()
+	}
+	return ginx.Result{Data: EffectivePermissions{
+		UserID:      req.UserID,
+		At:          req.At,
+		Permissions: slice.Map(model.EffectivePermissions(req.UserID, req.At), toEffectivePermissionVO),
+	}}, nil
+}
+
+// WhoCan 反查谁能对资源执行某个动作，包括通过角色包含关系间接持有权限的角色和用户，
+// 被直接 deny 的用户也会返回，方便排查。指定 format 时导出全部用户
+func (h *AnalysisHandler) WhoCan(ctx *ginx.Context, req WhoCanReq, sess session.Session) (ginx.Result, error) {
+	if req.ResourceKey == "" || req.Action == "" {
+		return ginx.Result{}, errors.New("资源标识和动作不能为空")
+	}
+	if req.Format != "" && req.Format != exportFormatCSV && req.Format != exportFormatJSONL {
+		return ginx.Result{}, fmt.Errorf("不支持的导出格式: %s", req.Format)
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if req.At == 0 {
+		req.At = time.Now().UnixMilli()
+	}
+	roles, users := model.WhoCan(req.ResourceType, req.ResourceKey, req.Action, req.At)
+
+	if req.Format != "" {
+		exportReq := ExportReq{BizID: req.BizID, Format: req.Format, Gzip: req.Gzip}
+		enc := newExportEncoder(ctx.Context, exportReq, "who-can", whoCanCSVHeader)
+		for i := range users {
+			if err = enc.encode(toPermissionHolderVO(users[i])); err != nil {
+				break
+			}
+		}
+		return h.finishExport(enc, exportReq, err)
+	}
+	start, end := pageRange(req.Offset, req.Limit, len(users))
+	return ginx.Result{Data: WhoCan{
+		At: req.At,
+		Roles: slice.Map(roles, func(_ int, src domain.RoleHolder) RoleHolder {
+			return RoleHolder{Role: src.Role, Permission: src.Permission, Path: strings.Join(src.Roles, " > ")}
+		}),
+		Users: slice.Map(users[start:end], func(_ int, src domain.PermissionHolder) PermissionHolder {
+			return toPermissionHolderVO(src)
+		}),
+		Total: len(users),
+	}}, nil
+}
+
+// RoleGraph 以 DOT、Mermaid 或者 JSON 格式输出角色包含关系图，可以只输出某个角色的子图
+func (h *AnalysisHandler) RoleGraph(ctx *ginx.Context, req RoleGraphReq, sess session.Session) (ginx.Result, error) {
+	if req.Format == "" {
+		req.Format = roleGraphFormatJSON
+	}
+	if req.Format != roleGraphFormatJSON && req.Format != roleGraphFormatDOT && req.Format != roleGraphFormatMermaid {
+		return ginx.Result{}, fmt.Errorf("不支持的格式: %s", req.Format)
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if req.Root != "" && !slices.ContainsFunc(model.Roles, func(r domain.ModelRole) bool { return r.Name == req.Root }) {
+		return ginx.Result{}, fmt.Errorf("角色 %s 不存在", req.Root)
+	}
+	opts := domain.RoleGraphOptions{
+		Root:            req.Root,
+		Depth:           req.Depth,
+		WithPermissions: req.Permissions,
+		WithUserCounts:  req.UserCounts,
+		At:              time.Now().UnixMilli(),
+	}
+	graph := model.RoleGraph(opts)
+	switch req.Format {
+	case roleGraphFormatDOT:
+		ctx.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(renderRoleGraphDOT(graph, opts)))
+	case roleGraphFormatMermaid:
+		ctx.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(renderRoleGraphMermaid(graph, opts)))
+	default:
+		return ginx.Result{Data: toRoleGraphVO(graph)}, nil
+	}
+	return ginx.Result{}, ginx.ErrNoResponse
+}
+
+// Simulate 在业务当前的权限模型上模拟一组变更，返回每个受影响用户按资源分组的得到和失去的权限，
+// 不执行任何变更，所以不需要写权限也不记录审计日志
+func (h *AnalysisHandler) Simulate(ctx *ginx.Context, req SimulateReq, sess session.Session) (ginx.Result, error) {
+	if len(req.Mutations) == 0 {
+		return ginx.Result{}, errors.New("变更不能为空")
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if req.At == 0 {
+		req.At = time.Now().UnixMilli()
+	}
+	impacts, err := model.Simulate(slice.Map(req.Mutations, func(_ int, src ModelMutation) domain.ModelMutation {
+		return domain.ModelMutation{
+			Op:           domain.ModelMutationOp(src.Op),
+			Role:         src.Role,
+			IncludedRole: src.IncludedRole,
+			UserID:       src.UserID,
+			ResourceType: src.ResourceType,
+			ResourceKey:  src.ResourceKey,
+			Action:       src.Action,
+			StartTime:    src.StartTime,
+			EndTime:      src.EndTime,
+		}
+	}), req.At)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	res := Simulation{At: req.At, Total: len(impacts)}
+	for _, impact := range impacts {
+		for _, r := range impact.Resources {
+			res.Gained += len(r.Gained)
+			res.Lost += len(r.Lost)
+		}
+	}
+	start, end := pageRange(req.Offset, req.Limit, len(impacts))
+	res.Users = slice.Map(impacts[start:end], func(_ int, src domain.UserImpact) UserImpact {
+		return UserImpact{UserID: src.UserID, Resources: slice.Map(src.Resources, func(_ int, r domain.ResourceImpact) ResourceImpact {
+			return ResourceImpact{ResourceType: r.ResourceType, ResourceKey: r.ResourceKey, Gained: r.Gained, Lost: r.Lost}
+		})}
+	})
+	return ginx.Result{Data: res}, nil
+}
+
+// Explain 解释权限平台对用户的权限判定，判定结果来自权限平台，
+// 匹配的角色、包含路径、因为有效期跳过的授权和覆盖 allow 的 deny 根据权限模型计算
+func (h *AnalysisHandler) Explain(ctx *ginx.Context, req ExplainReq, sess session.Session) (ginx.Result, error) {
+	if req.UID <= 0 {
+		return ginx.Result{}, errors.New("用户ID不合法")
+	}
+	if req.ResourceType == "" || req.ResourceKey == "" || req.Action == "" {
+		return ginx.Result{}, errors.New("资源类型、资源标识和动作不能为空")
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	resp, err := h.permissionSvc.CheckPermission(businessAdminCtx, &permissionv1.CheckPermissionRequest{
+		Uid: req.UID,
+		Permission: &permissionv1.Permission{
+			BizId:        req.BizID,
+			ResourceType: req.ResourceType,
+			ResourceKey:  req.ResourceKey,
+			Actions:      []string{req.Action},
+		},
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	at := time.Now().UnixMilli()
+	explanation := model.Explain(req.UID, req.ResourceType, req.ResourceKey, req.Action, at)
+	return ginx.Result{Data: Explanation{
+		Allowed:      resp.Allowed,
+		ModelAllowed: explanation.Allowed,
+		Reason:       explanation.Reason,
+		At:           at,
+		Grants: slice.Map(explanation.Grants, func(_ int, src domain.GrantTrace) GrantTrace {
+			return GrantTrace{PermissionSource: toPermissionSourceVO(src.PermissionSource), Status: src.Status.String()}
+		}),
+	}}, nil
+}
+
+// pageRange 在内存中分页，返回当前页在全部结果中的下标范围
+func pageRange(offset, limit, total int) (int, int) {
+	if limit <= 0 {
+		limit = defaultAnalysisPageSize
+	}
+	limit = min(limit, maxAnalysisPageSize)
+	start := min(max(offset, 0), total)
+	return start, min(start+limit, total)
+}
+
+func toPermissionHolderVO(src domain.PermissionHolder) PermissionHolder {
+	return PermissionHolder{UserID: src.UserID, EffectivePermission: toEffectivePermissionVO(0, src.EffectivePermission)}
+}
+
+func toEffectivePermissionVO(_ int, src domain.EffectivePermission) EffectivePermission {
+	return EffectivePermission{
+		ResourceType: src.ResourceType,
+		ResourceKey:  src.ResourceKey,
+		Action:       src.Action,
+		Effect:       src.Effect.String(),
+		Sources: slice.Map(src.Sources, func(_ int, s domain.PermissionSource) PermissionSource {
+			return toPermissionSourceVO(s)
+		}),
+	}
+}
+
+func toPermissionSourceVO(src domain.PermissionSource) PermissionSource {
+	return PermissionSource{
+		Path:      src.Path(),
+		Roles:     src.Roles,
+		Effect:    src.Effect.String(),
+		StartTime: src.StartTime,
+		EndTime:   src.EndTime,
+	}
+}
+
+func (p PermissionHolder) csvRecord() []string {
+	sources := make([]string, 0, len(p.Sources))
+	for _, s := range p.Sources {
+		sources = append(sources, s.Path+"("+s.Effect+")")
+	}
+	return []string{
+		formatInt(p.UserID), p.ResourceType, p.ResourceKey, p.Action, p.Effect, strings.Join(sources, "; "),
+	}
+}
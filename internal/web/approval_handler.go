@@ -0,0 +1,332 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// ApprovalHandler 审批策略管理和授权变更请求的审批
+type ApprovalHandler struct {
+	*BaseHandler
+}
+
+func NewApprovalHandler(handler *BaseHandler) *ApprovalHandler {
+	return &ApprovalHandler{BaseHandler: handler}
+}
+
+func (h *ApprovalHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/approval/policy/save", ginx.BS(audited(h.audits, domain.ApprovalTable, h.SavePolicy)))
+	server.POST("/approval/policy/delete", ginx.BS(audited(h.audits, domain.ApprovalTable, h.DeletePolicy)))
+	server.GET("/approval/policy/list", ginx.BS[ApprovalPolicyListReq](h.ListPolicies))
+
+	server.POST("/approval/request/submit", ginx.BS(audited(h.audits, domain.ApprovalTable, h.Submit)))
+	server.POST("/approval/request/approve", ginx.BS(audited(h.audits, domain.ApprovalTable, h.Approve)))
+	server.POST("/approval/request/reject", ginx.BS(audited(h.audits, domain.ApprovalTable, h.Reject)))
+	server.POST("/approval/request/cancel", ginx.BS(audited(h.audits, domain.ApprovalTable, h.Cancel)))
+	server.GET("/approval/request/list", ginx.BS[ChangeRequestListReq](h.List))
+	server.GET("/approval/request/detail", ginx.BS[ChangeRequestReq](h.Detail))
+}
+
+func (h *ApprovalHandler) SavePolicy(ctx *ginx.Context, req ApprovalPolicyReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	policy, err := h.approvals.SavePolicy(ctx, domain.ApprovalPolicy{
+		ID:                req.Policy.ID,
+		BizID:             req.BizID,
+		Name:              req.Policy.Name,
+		RoleIDs:           req.Policy.RoleIDs,
+		PermissionIDs:     req.Policy.PermissionIDs,
+		Approvers:         req.Policy.Approvers,
+		RequiredApprovals: req.Policy.RequiredApprovals,
+		TTL:               req.Policy.TTL,
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: policy.ID,
+	}, nil
+}
+
+func (h *ApprovalHandler) DeletePolicy(ctx *ginx.Context, req ApprovalPolicyReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if err = h.approvals.DeletePolicy(ctx, req.BizID, req.Policy.ID); err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: true,
+	}, nil
+}
+
+func (h *ApprovalHandler) ListPolicies(ctx *ginx.Context, req ApprovalPolicyListReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	policies, err := h.approvals.ListPolicies(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listSlice(h.BaseHandler, req.ListReq, policies, func(src domain.ApprovalPolicy) int64 { return src.ID },
+		func(src domain.ApprovalPolicy) ApprovalPolicy {
+			return ApprovalPolicy{
+				ID:                src.ID,
+				BizID:             src.BizID,
+				Name:              src.Name,
+				RoleIDs:           src.RoleIDs,
+				PermissionIDs:     src.PermissionIDs,
+				Approvers:         src.Approvers,
+				RequiredApprovals: src.RequiredApprovals,
+				TTL:               src.TTL,
+				Ctime:             src.Ctime,
+				Utime:             src.Utime,
+			}
+		})
+}
+
+// Submit 主动提交变更请求，要求提交人有对应的授权权限，并且授权对象命中了审批策略
+func (h *ApprovalHandler) Submit(ctx *ginx.Context, req SubmitChangeRequestReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	cr := domain.ChangeRequest{
+		BizID:        req.BizID,
+		Kind:         domain.ChangeRequestKind(req.Kind),
+		RequesterUID: uid,
+		Reason:       req.Reason,
+	}
+	switch cr.Kind {
+	case domain.ChangeRequestGrantUserRole:
+		err = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.UserRoleTable, domain.PermissionActionWrite)
+		cr.TargetID, cr.TargetUserID = req.UserRole.Role.ID, req.UserRole.UserID
+		cr.Payload = toJSONString(UserRoleReq{BizID: req.BizID, UserRole: req.UserRole, Reason: req.Reason})
+	case domain.ChangeRequestGrantUserPermission:
+		err = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.UserPermissionTable, domain.PermissionActionWrite)
+		cr.TargetID, cr.TargetUserID = req.UserPermission.Permission.ID, req.UserPermission.UserID
+		cr.Payload = toJSONString(UserPermissionReq{BizID: req.BizID, UserPermission: req.UserPermission, Reason: req.Reason})
+	case domain.ChangeRequestGrantAccountRole:
+		err = h.checkAccountPermission(businessAdminCtx, req.BizID, uid)
+		cr.TargetID, cr.TargetUserID = req.UserRole.Role.ID, req.UserRole.UserID
+		cr.Payload = toJSONString(GrantUserRoleReq{BizID: req.BizID, UserID: req.UserRole.UserID, Role: req.UserRole.Role, Reason: req.Reason})
+	default:
+		return ginx.Result{}, fmt.Errorf("不支持的变更请求类型: %s", req.Kind)
+	}
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	res, ok, err := h.requireApproval(businessAdminCtx, cr)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if !ok {
+		return ginx.Result{}, errors.New("没有匹配的审批策略，请直接授权")
+	}
+	return res, nil
+}
+
+// Approve 同意变更请求，同意人数达到要求后立刻执行授权
+func (h *ApprovalHandler) Approve(ctx *ginx.Context, req ChangeRequestReq, sess session.Session) (ginx.Result, error) {
+	return h.decide(ctx, req, sess, true)
+}
+
+func (h *ApprovalHandler) Reject(ctx *ginx.Context, req ChangeRequestReq, sess session.Session) (ginx.Result, error) {
+	return h.decide(ctx, req, sess, false)
+}
+
+func (h *ApprovalHandler) decide(ctx *ginx.Context, req ChangeRequestReq, sess session.Session, approve bool) (ginx.Result, error) {
+	if _, err := h.changeRequest(ctx, req); err != nil {
+		return ginx.Result{}, err
+	}
+	cr, err := h.approvals.Decide(ctx, req.ID, domain.ApprovalDecision{
+		UID:     sess.Claims().Uid,
+		Approve: approve,
+		Comment: req.Comment,
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if cr.Status == domain.ChangeRequestApproved {
+		cr, err = h.approvals.Execute(ctx, cr.ID, h.execute)
+		if err != nil {
+			return ginx.Result{}, err
+		}
+	}
+	return ginx.Result{
+		Data: toChangeRequestVO(cr),
+	}, nil
+}
+
+// ExecuteApproved 重新执行审批通过但是没有执行完成的变更请求，由定时任务调用
+func (h *ApprovalHandler) ExecuteApproved(ctx context.Context) error {
+	return h.approvals.ExecuteApproved(ctx, h.execute)
+}
+
+// execute 以业务管理员身份执行审批通过的授权
+// 授权失败时变更请求进入 failed 状态，需要重新提交
+func (h *ApprovalHandler) execute(ctx context.Context, cr domain.ChangeRequest) (string, error) {
+	res, err := h.grant(ctx, cr)
+	if err != nil {
+		return "", err
+	}
+	return toJSONString(res.Data), nil
+}
+
+func (h *ApprovalHandler) grant(ctx context.Context, cr domain.ChangeRequest) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, cr.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	switch cr.Kind {
+	case domain.ChangeRequestGrantUserRole:
+		var req UserRoleReq
+		if err = json.Unmarshal([]byte(cr.Payload), &req); err != nil {
+			return ginx.Result{}, fmt.Errorf("解析变更请求失败: %w", err)
+		}
+		return h.grantUserRole(businessAdminCtx, req)
+	case domain.ChangeRequestAccessRole:
+		var req UserRoleReq
+		if err = json.Unmarshal([]byte(cr.Payload), &req); err != nil {
+			return ginx.Result{}, fmt.Errorf("解析变更请求失败: %w", err)
+		}
+		req.UserRole.StartTime, req.UserRole.EndTime = cr.GrantStartTime, cr.GrantEndTime
+		return h.grantUserRole(businessAdminCtx, req)
+	case domain.ChangeRequestGrantUserPermission:
+		var req UserPermissionReq
+		if err = json.Unmarshal([]byte(cr.Payload), &req); err != nil {
+			return ginx.Result{}, fmt.Errorf("解析变更请求失败: %w", err)
+		}
+		return h.grantUserPermission(businessAdminCtx, req)
+	case domain.ChangeRequestGrantAccountRole:
+		var req GrantUserRoleReq
+		if err = json.Unmarshal([]byte(cr.Payload), &req); err != nil {
+			return ginx.Result{}, fmt.Errorf("解析变更请求失败: %w", err)
+		}
+		return h.grantAccountRole(businessAdminCtx, req)
+	default:
+		return ginx.Result{}, fmt.Errorf("不支持的变更请求类型: %s", cr.Kind)
+	}
+}
+
+func (h *ApprovalHandler) Cancel(ctx *ginx.Context, req ChangeRequestReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.changeRequest(ctx, req); err != nil {
+		return ginx.Result{}, err
+	}
+	cr, err := h.approvals.Cancel(ctx, req.ID, sess.Claims().Uid)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toChangeRequestVO(cr),
+	}, nil
+}
+
+func (h *ApprovalHandler) List(ctx *ginx.Context, req ChangeRequestListReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	query := domain.ChangeRequestQuery{
+		BizID:  req.BizID,
+		Status: domain.ChangeRequestStatus(req.Status),
+	}
+	// 没有审批读权限的用户只能看到和自己有关的请求
+	if req.Mine || h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.ApprovalTable, domain.PermissionActionRead) != nil {
+		query.UID = uid
+	}
+	return listRows(h.BaseHandler, req.ListReq, func(offset, limit int32) ([]domain.ChangeRequest, error) {
+		query.Offset, query.Limit = int(offset), int(limit)
+		return h.approvals.List(ctx, query)
+	}, changeRequestID, toChangeRequestVO)
+}
+
+func (h *ApprovalHandler) Detail(ctx *ginx.Context, req ChangeRequestReq, sess session.Session) (ginx.Result, error) {
+	cr, err := h.changeRequest(ctx, req)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	if cr.RequesterUID != uid && !cr.IsApprover(uid) {
+		businessAdminCtx, err1 := h.businessAdminCtx(ctx, req.BizID)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+		err1 = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.ApprovalTable, domain.PermissionActionRead)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+	}
+	return ginx.Result{
+		Data: toChangeRequestVO(cr),
+	}, nil
+}
+
+// changeRequest 查询变更请求，并且确认它属于请求中的业务
+func (h *ApprovalHandler) changeRequest(ctx context.Context, req ChangeRequestReq) (domain.ChangeRequest, error) {
+	cr, err := h.approvals.Get(ctx, req.ID)
+	if err != nil {
+		return domain.ChangeRequest{}, err
+	}
+	if cr.BizID != req.BizID {
+		return domain.ChangeRequest{}, errors.New("变更请求不属于该业务")
+	}
+	return cr, nil
+}
+
+func changeRequestID(src domain.ChangeRequest) int64 {
+	return src.ID
+}
+
+func toChangeRequestVO(src domain.ChangeRequest) ChangeRequest {
+	return ChangeRequest{
+		ID:                src.ID,
+		BizID:             src.BizID,
+		Kind:              src.Kind.String(),
+		PolicyID:          src.PolicyID,
+		TargetID:          src.TargetID,
+		TargetUserID:      src.TargetUserID,
+		Payload:           src.Payload,
+		RequesterUID:      src.RequesterUID,
+		Reason:            src.Reason,
+		Approvers:         src.Approvers,
+		RequiredApprovals: src.RequiredApprovals,
+		Decisions: slice.Map(src.Decisions, func(_ int, d domain.ApprovalDecision) ApprovalDecision {
+			return ApprovalDecision(d)
+		}),
+		Duration:       src.Duration,
+		GrantStartTime: src.GrantStartTime,
+		GrantEndTime:   src.GrantEndTime,
+		Status:         src.Status.String(),
+		Result:         src.Result,
+		ExpireAt:       src.ExpireAt,
+		Ctime:          src.Ctime,
+		Utime:          src.Utime,
+	}
+}
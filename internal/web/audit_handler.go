@@ -0,0 +1,164 @@
+package web
+
+import (
+	"encoding/json"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// auditTarget 写请求操作的对象
+type auditTarget struct {
+	BizID        int64
+	TargetID     int64
+	TargetUserID int64
+}
+
+// auditableReq 需要记录审计日志的写请求
+type auditableReq interface {
+	auditTarget() auditTarget
+}
+
+type bizHandlerFunc[Req any] func(ctx *ginx.Context, req Req, sess session.Session) (ginx.Result, error)
+
+// audited 在写操作完成后记录审计日志，无论成功还是失败
+// 审计日志写入失败只记录错误日志，不影响写操作的结果
+func audited[Req auditableReq](svc *service.AuditService, table domain.SystemTableResource, fn bizHandlerFunc[Req]) bizHandlerFunc[Req] {
+	return func(ctx *ginx.Context, req Req, sess session.Session) (ginx.Result, error) {
+		res, err := fn(ctx, req, sess)
+		target := req.auditTarget()
+		record := domain.AuditRecord{
+			BizID:        target.BizID,
+			ActorUID:     sess.Claims().Uid,
+			Route:        ctx.FullPath(),
+			Table:        table.String(),
+			TargetID:     target.TargetID,
+			TargetUserID: target.TargetUserID,
+			Payload:      toJSONString(req),
+			Outcome:      domain.AuditOutcomeSuccess,
+			ClientIP:     ctx.ClientIP(),
+		}
+		if err != nil {
+			record.Outcome = domain.AuditOutcomeFailure
+			record.Error = err.Error()
+		} else {
+			record.Result = toJSONString(res.Data)
+			// 创建操作返回的是新实体的ID
+			if id, ok := res.Data.(int64); ok && record.TargetID == 0 {
+				record.TargetID = id
+			}
+		}
+		if err1 := svc.Record(ctx, record); err1 != nil {
+			elog.DefaultLogger.Error("记录审计日志失败",
+				elog.FieldErr(err1),
+				elog.Int64("bizId", record.BizID),
+				elog.String("route", record.Route))
+		}
+		return res, err
+	}
+}
+
+func toJSONString(val any) string {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// AuditHandler 审计日志查询
+type AuditHandler struct {
+	*BaseHandler
+}
+
+func NewAuditHandler(handler *BaseHandler) *AuditHandler {
+	return &AuditHandler{BaseHandler: handler}
+}
+
+func (h *AuditHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/audit/list", ginx.BS[AuditListReq](h.List))
+	server.GET("/admin/audit/verify", ginx.BS[AuditVerifyReq](h.Verify))
+}
+
+func (h *AuditHandler) List(ctx *ginx.Context, req AuditListReq, sess session.Session) (ginx.Result, error) {
+	if err := h.checkListLimit(req.Limit); err != nil {
+		return ginx.Result{}, err
+	}
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.AuditLogTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	records, cursor, err := h.audits.List(ctx, domain.AuditQuery{
+		BizID:        req.BizID,
+		ActorUID:     req.ActorUID,
+		TargetUserID: req.TargetUserID,
+		Table:        req.Table,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		Outcome:      domain.AuditOutcome(req.Outcome),
+		Cursor:       req.Cursor,
+		Limit:        req.Limit,
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: AuditListResp{
+			Rows:   slice.Map(records, func(_ int, src domain.AuditRecord) AuditRecord { return toAuditRecordVO(src) }),
+			Cursor: cursor,
+		},
+	}, nil
+}
+
+// Verify 重新计算业务的审计哈希链，只有系统管理员可以调用
+func (h *AuditHandler) Verify(ctx *ginx.Context, req AuditVerifyReq, sess session.Session) (ginx.Result, error) {
+	systemAdminCtx := h.systemAdminCtx(ctx)
+	err := h.checkSystemPermission(systemAdminCtx, req.BizID, sess.Claims().Uid, domain.AuditLogTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	res, err := h.audits.Verify(ctx, req.BizID, req.From, req.To)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: AuditVerification{
+			BizID:        res.BizID,
+			Checked:      res.Checked,
+			Checkpoints:  res.Checkpoints,
+			Valid:        res.Valid,
+			BrokenID:     res.BrokenID,
+			BrokenReason: res.BrokenReason,
+		},
+	}, nil
+}
+
+func toAuditRecordVO(src domain.AuditRecord) AuditRecord {
+	return AuditRecord{
+		ID:           src.ID,
+		BizID:        src.BizID,
+		ActorUID:     src.ActorUID,
+		Route:        src.Route,
+		Table:        src.Table,
+		TargetID:     src.TargetID,
+		TargetUserID: src.TargetUserID,
+		Payload:      src.Payload,
+		Result:       src.Result,
+		Error:        src.Error,
+		Outcome:      src.Outcome.String(),
+		ClientIP:     src.ClientIP,
+		Ctime:        src.Ctime,
+		Seq:          src.Seq,
+		PrevHash:     src.PrevHash,
+		Hash:         src.Hash,
+	}
+}
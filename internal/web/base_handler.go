@@ -3,22 +3,62 @@ package web
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
 	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
-	"github.com/ecodeclub/ekit/slice"
 	"github.com/ecodeclub/ginx"
+	"github.com/gotomicro/ego/core/elog"
 	"google.golang.org/grpc/metadata"
 )
 
+// errNoPermission 权限平台明确拒绝，和调用失败区分开
+var errNoPermission = errors.New("没有权限")
+
 type BaseHandler struct {
 	rbacSvc       permissionv1.RBACServiceClient
 	permissionSvc permissionv1.PermissionServiceClient
 	adminToken    string
-}
-
-func NewBaseHandler(rbacSvc permissionv1.RBACServiceClient, permissionSvc permissionv1.PermissionServiceClient, adminToken string) *BaseHandler {
-	return &BaseHandler{rbacSvc: rbacSvc, permissionSvc: permissionSvc, adminToken: adminToken}
+	changes       *permission.Stream
+	publisher     *service.ChangePublisher
+	audits        *service.AuditService
+	approvals     *service.ApprovalService
+	sod           *service.SoDService
+	// maxRoleInclusionDepth 角色包含关系最长的层级，0 表示不限制
+	maxRoleInclusionDepth int
+	// maxListLimit 列表接口每页的上限，0 表示使用默认值
+	maxListLimit int
+	logger       *elog.Component
+}
+
+func NewBaseHandler(
+	rbacSvc permissionv1.RBACServiceClient,
+	permissionSvc permissionv1.PermissionServiceClient,
+	adminToken string,
+	changes *permission.Stream,
+	publisher *service.ChangePublisher,
+	audits *service.AuditService,
+	approvals *service.ApprovalService,
+	sod *service.SoDService,
+	maxRoleInclusionDepth int,
+	maxListLimit int,
+) *BaseHandler {
+	return &BaseHandler{
+		rbacSvc:       rbacSvc,
+		permissionSvc: permissionSvc,
+		adminToken:    adminToken,
+		changes:       changes,
+		publisher:     publisher,
+		audits:        audits,
+		approvals:     approvals,
+		sod:           sod,
+		logger:        elog.DefaultLogger,
+
+		maxRoleInclusionDepth: maxRoleInclusionDepth,
+		maxListLimit:          maxListLimit,
+	}
 }
 
 func (h *BaseHandler) systemAdminCtx(ctx context.Context) context.Context {
@@ -41,11 +81,70 @@ func (h *BaseHandler) checkPermission(ctx context.Context, checkPermissionReques
 		return err
 	}
 	if !resp.Allowed {
-		return errors.New("没有权限")
+		return errNoPermission
 	}
 	return nil
 }
 
+// checkBusinessPermission 校验用户对业务内系统表的操作权限
+func (h *BaseHandler) checkBusinessPermission(ctx context.Context, bizID, uid int64, resource domain.SystemTableResource, action domain.PermissionActionType) error {
+	return h.checkPermission(ctx, &permissionv1.CheckPermissionRequest{
+		Uid: uid,
+		Permission: &permissionv1.Permission{
+			BizId:        bizID,
+			ResourceType: resource.Type(),
+			ResourceKey:  resource.KeyForBusinessAdmin(bizID),
+			Actions:      []string{action.String()},
+		},
+	})
+}
+
+// checkSystemPermission 校验用户对系统表的系统管理员权限
+func (h *BaseHandler) checkSystemPermission(ctx context.Context, bizID, uid int64, resource domain.SystemTableResource, action domain.PermissionActionType) error {
+	return h.checkPermission(ctx, &permissionv1.CheckPermissionRequest{
+		Uid: uid,
+		Permission: &permissionv1.Permission{
+			BizId:        bizID,
+			ResourceType: resource.Type(),
+			ResourceKey:  resource.KeyForSystemAdmin(),
+			Actions:      []string{action.String()},
+		},
+	})
+}
+
+func (h *BaseHandler) checkAccountPermission(ctx context.Context, bizID, userID int64) error {
+	return h.checkPermission(ctx, &permissionv1.CheckPermissionRequest{
+		Uid: userID,
+		Permission: &permissionv1.Permission{
+			ResourceType: domain.ManagerAccountResource.Type(),
+			ResourceKey:  domain.ManagerAccountResource.KeyForBusinessAdmin(bizID),
+			Actions:      []string{domain.PermissionActionRead.String(), domain.PermissionActionWrite.String()},
+		},
+	})
+}
+
+// requireApproval 授权对象命中审批策略时，创建待审批的变更请求代替直接授权
+// 返回 true 表示已经创建了变更请求，调用方不能再执行授权
+func (h *BaseHandler) requireApproval(ctx context.Context, req domain.ChangeRequest) (ginx.Result, bool, error) {
+	policy, ok, err := h.approvals.MatchPolicy(ctx, req.BizID, req.Kind, req.TargetID)
+	if err != nil || !ok {
+		return ginx.Result{}, false, err
+	}
+	cr, err := h.approvals.Submit(ctx, policy, req)
+	if err != nil {
+		return ginx.Result{}, true, err
+	}
+	return ginx.Result{
+		Msg:  "授权需要审批，已创建变更请求",
+		Data: toChangeRequestVO(cr),
+	}, true, nil
+}
+
+// publishChange 推送权限变更事件，推送失败只记录日志
+func (h *BaseHandler) publishChange(ctx context.Context, bizID int64, table domain.SystemTableResource, action permission.ChangeAction, data any) {
+	h.publisher.Publish(ctx, bizID, table, action, data)
+}
+
 // BusinessConfig
 
 func (h *BaseHandler) createBusinessConfig(ctx context.Context, req BusinessConfigReq) (ginx.Result, error) {
@@ -93,20 +192,13 @@ func (h *BaseHandler) toBusinessConfigVO(src *permissionv1.BusinessConfig) Busin
 }
 
 func (h *BaseHandler) listBusinessConfigs(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListBusinessConfigs(ctx, &permissionv1.ListBusinessConfigsRequest{
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[BusinessConfig]{
-			Rows: slice.Map(resp.Configs, func(_ int, src *permissionv1.BusinessConfig) BusinessConfig {
-				return h.toBusinessConfigVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.BusinessConfig, error) {
+		resp, err := h.rbacSvc.ListBusinessConfigs(ctx, &permissionv1.ListBusinessConfigsRequest{Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Configs, nil
+	}, func(r *permissionv1.BusinessConfig) int64 { return r.Id }, h.toBusinessConfigVO)
 }
 
 func (h *BaseHandler) updateBusinessConfig(ctx context.Context, req BusinessConfigReq) (ginx.Result, error) {
@@ -142,6 +234,7 @@ func (h *BaseHandler) createResource(ctx context.Context, req ResourceReq) (ginx
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.ResourceTable, permission.ChangeActionCreate, h.toResourceVO(resp.Resource))
 	return ginx.Result{
 		Data: resp.Resource.Id,
 	}, nil
@@ -184,20 +277,13 @@ func (h *BaseHandler) toResourceVO(src *permissionv1.Resource) Resource {
 }
 
 func (h *BaseHandler) listResources(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListResources(ctx, &permissionv1.ListResourcesRequest{
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[Resource]{
-			Rows: slice.Map(resp.Resources, func(_ int, src *permissionv1.Resource) Resource {
-				return h.toResourceVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.Resource, error) {
+		resp, err := h.rbacSvc.ListResources(ctx, &permissionv1.ListResourcesRequest{BizId: req.BizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Resources, nil
+	}, func(r *permissionv1.Resource) int64 { return r.Id }, h.toResourceVO)
 }
 
 func (h *BaseHandler) updateResource(ctx context.Context, req ResourceReq) (ginx.Result, error) {
@@ -207,6 +293,7 @@ func (h *BaseHandler) updateResource(ctx context.Context, req ResourceReq) (ginx
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.ResourceTable, permission.ChangeActionUpdate, req.Resource)
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -219,6 +306,7 @@ func (h *BaseHandler) deleteResource(ctx context.Context, req ResourceReq) (ginx
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.ResourceTable, permission.ChangeActionDelete, Resource{ID: req.Resource.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -233,6 +321,7 @@ func (h *BaseHandler) createPermission(ctx context.Context, req PermissionReq) (
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.PermissionTable, permission.ChangeActionCreate, h.toPermissionVO(resp.Permission))
 	return ginx.Result{
 		Data: resp.Permission.Id,
 	}, nil
@@ -280,20 +369,13 @@ func (h *BaseHandler) toPermissionVO(src *permissionv1.Permission) Permission {
 }
 
 func (h *BaseHandler) listPermissions(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListPermissions(ctx, &permissionv1.ListPermissionsRequest{
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[Permission]{
-			Rows: slice.Map(resp.Permissions, func(_ int, src *permissionv1.Permission) Permission {
-				return h.toPermissionVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.Permission, error) {
+		resp, err := h.rbacSvc.ListPermissions(ctx, &permissionv1.ListPermissionsRequest{BizId: req.BizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Permissions, nil
+	}, func(r *permissionv1.Permission) int64 { return r.Id }, h.toPermissionVO)
 }
 
 func (h *BaseHandler) updatePermission(ctx context.Context, req PermissionReq) (ginx.Result, error) {
@@ -303,18 +385,20 @@ func (h *BaseHandler) updatePermission(ctx context.Context, req PermissionReq) (
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.PermissionTable, permission.ChangeActionUpdate, req.Permission)
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
 }
 
 func (h *BaseHandler) deletePermission(ctx context.Context, req PermissionReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.DeleteResource(ctx, &permissionv1.DeleteResourceRequest{
+	resp, err := h.rbacSvc.DeletePermission(ctx, &permissionv1.DeletePermissionRequest{
 		Id: req.Permission.ID,
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.PermissionTable, permission.ChangeActionDelete, Permission{ID: req.Permission.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -329,6 +413,7 @@ func (h *BaseHandler) createRole(ctx context.Context, req RoleReq) (ginx.Result,
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RoleTable, permission.ChangeActionCreate, h.toRoleVO(resp.Role))
 	return ginx.Result{
 		Data: resp.Role.Id,
 	}, nil
@@ -368,21 +453,13 @@ func (h *BaseHandler) toRoleVO(src *permissionv1.Role) Role {
 }
 
 func (h *BaseHandler) listRoles(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListRoles(ctx, &permissionv1.ListRolesRequest{
-		Type:   domain.DefaultBusinessRoleType,
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[Role]{
-			Rows: slice.Map(resp.Roles, func(_ int, src *permissionv1.Role) Role {
-				return h.toRoleVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.Role, error) {
+		resp, err := h.rbacSvc.ListRoles(ctx, &permissionv1.ListRolesRequest{BizId: req.BizID, Type: domain.DefaultBusinessRoleType, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Roles, nil
+	}, func(r *permissionv1.Role) int64 { return r.Id }, h.toRoleVO)
 }
 
 func (h *BaseHandler) updateRole(ctx context.Context, req RoleReq) (ginx.Result, error) {
@@ -392,18 +469,20 @@ func (h *BaseHandler) updateRole(ctx context.Context, req RoleReq) (ginx.Result,
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RoleTable, permission.ChangeActionUpdate, req.Role)
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
 }
 
 func (h *BaseHandler) deleteRole(ctx context.Context, req RoleReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.DeleteResource(ctx, &permissionv1.DeleteResourceRequest{
+	resp, err := h.rbacSvc.DeleteRole(ctx, &permissionv1.DeleteRoleRequest{
 		Id: req.Role.ID,
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RoleTable, permission.ChangeActionDelete, Role{ID: req.Role.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -412,26 +491,72 @@ func (h *BaseHandler) deleteRole(ctx context.Context, req RoleReq) (ginx.Result,
 // RoleInclusion
 
 func (h *BaseHandler) createRoleInclusion(ctx context.Context, req RoleInclusionReq) (ginx.Result, error) {
+	req, roleType, err := h.checkRoleInclusion(ctx, req)
+	if err != nil {
+		return ginx.Result{}, err
+	}
 	resp, err := h.rbacSvc.CreateRoleInclusion(ctx, &permissionv1.CreateRoleInclusionRequest{
-		RoleInclusion: h.toRoleInclusionPB(req),
+		RoleInclusion: h.toRoleInclusionPB(req, roleType),
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RoleInclusionTable, permission.ChangeActionCreate, h.toRoleInclusionVO(resp.RoleInclusion))
 	return ginx.Result{
 		Data: resp.RoleInclusion.Id,
 	}, nil
 }
 
-func (h *BaseHandler) toRoleInclusionPB(req RoleInclusionReq) *permissionv1.RoleInclusion {
+// checkRoleInclusion 权限平台不校验包含关系，创建前校验两个角色都属于这个业务并且类型相同，
+// 新的包含关系不会形成环，角色名以权限平台中的为准。返回两个角色的类型
+func (h *BaseHandler) checkRoleInclusion(ctx context.Context, req RoleInclusionReq) (RoleInclusionReq, string, error) {
+	if req.RoleInclusion.BizID != 0 && req.RoleInclusion.BizID != req.BizID {
+		return req, "", fmt.Errorf("%w: 不能跨业务创建角色包含关系", domain.ErrInvalidRoleInclusion)
+	}
+	req.RoleInclusion.BizID = req.BizID
+	roles := []*Role{&req.RoleInclusion.IncludingRole, &req.RoleInclusion.IncludedRole}
+	types := make([]string, 0, len(roles))
+	for _, role := range roles {
+		resp, err := h.rbacSvc.GetRole(ctx, &permissionv1.GetRoleRequest{Id: role.ID})
+		if err != nil {
+			return req, "", fmt.Errorf("查询角色 %d 失败: %w", role.ID, err)
+		}
+		if resp.Role.BizId != req.BizID {
+			return req, "", fmt.Errorf("%w: 角色 %d 不属于业务 %d", domain.ErrInvalidRoleInclusion, role.ID, req.BizID)
+		}
+		role.Name = resp.Role.Name
+		types = append(types, resp.Role.Type)
+	}
+	// 账号角色之间可以互相包含，但是业务角色不能包含账号角色，反过来也不行
+	if types[0] != types[1] {
+		return req, "", fmt.Errorf("%w: 角色 %s 是 %s，角色 %s 是 %s，类型不同的角色不能包含",
+			domain.ErrInvalidRoleInclusion, roles[0].Name, types[0], roles[1].Name, types[1])
+	}
+	existing, err := h.loadRoleInclusions(ctx, req.BizID)
+	if err != nil {
+		return req, "", err
+	}
+	err = domain.CheckRoleInclusion(existing, roles[0].Name, roles[1].Name, h.maxRoleInclusionDepth)
+	if err != nil {
+		return req, "", err
+	}
+	// 包含关系让拥有 including 的用户间接拥有 included，可能违反职责分离约束
+	err = h.sod.Check(ctx, req.BizID, h.loadModel, func(model *domain.RBACModel) error {
+		model.RoleInclusions = append(model.RoleInclusions, domain.ModelRoleInclusion{Including: roles[0].Name, Included: roles[1].Name})
+		return nil
+	})
+	return req, types[0], err
+}
+
+func (h *BaseHandler) toRoleInclusionPB(req RoleInclusionReq, roleType string) *permissionv1.RoleInclusion {
 	return &permissionv1.RoleInclusion{
 		Id:                req.RoleInclusion.ID,
 		BizId:             req.RoleInclusion.BizID,
 		IncludingRoleId:   req.RoleInclusion.IncludingRole.ID,
-		IncludingRoleType: domain.DefaultBusinessRoleType,
+		IncludingRoleType: roleType,
 		IncludingRoleName: req.RoleInclusion.IncludingRole.Name,
 		IncludedRoleId:    req.RoleInclusion.IncludedRole.ID,
-		IncludedRoleType:  domain.DefaultBusinessRoleType,
+		IncludedRoleType:  roleType,
 		IncludedRoleName:  req.RoleInclusion.IncludedRole.Name,
 	}
 }
@@ -464,20 +589,13 @@ func (h *BaseHandler) toRoleInclusionVO(src *permissionv1.RoleInclusion) RoleInc
 }
 
 func (h *BaseHandler) listRoleInclusions(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListRoleInclusions(ctx, &permissionv1.ListRoleInclusionsRequest{
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[RoleInclusion]{
-			Rows: slice.Map(resp.RoleInclusions, func(_ int, src *permissionv1.RoleInclusion) RoleInclusion {
-				return h.toRoleInclusionVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.RoleInclusion, error) {
+		resp, err := h.rbacSvc.ListRoleInclusions(ctx, &permissionv1.ListRoleInclusionsRequest{BizId: req.BizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.RoleInclusions, nil
+	}, func(r *permissionv1.RoleInclusion) int64 { return r.Id }, h.toRoleInclusionVO)
 }
 
 func (h *BaseHandler) deleteRoleInclusion(ctx context.Context, req RoleInclusionReq) (ginx.Result, error) {
@@ -487,6 +605,7 @@ func (h *BaseHandler) deleteRoleInclusion(ctx context.Context, req RoleInclusion
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RoleInclusionTable, permission.ChangeActionDelete, RoleInclusion{ID: req.RoleInclusion.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -495,12 +614,41 @@ func (h *BaseHandler) deleteRoleInclusion(ctx context.Context, req RoleInclusion
 // RolePermission
 
 func (h *BaseHandler) grantRolePermission(ctx context.Context, req RolePermissionReq) (ginx.Result, error) {
+	err := h.sod.Check(ctx, req.BizID, h.loadModel, func(model *domain.RBACModel) error {
+		return addRolePermission(model, req)
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return h.doGrantRolePermission(ctx, req)
+}
+
+// addRolePermission 在权限模型上加入角色权限，用来检查职责分离约束
+func addRolePermission(model *domain.RBACModel, req RolePermissionReq) error {
+	role, err := modelRoleName(*model, req.RolePermission.Role)
+	if err != nil {
+		return err
+	}
+	for _, p := range modelPermissions(*model, req.RolePermission.Permission) {
+		model.RolePermissions = append(model.RolePermissions, domain.ModelRolePermission{
+			Role:         role,
+			ResourceType: p.ResourceType,
+			ResourceKey:  p.ResourceKey,
+			Action:       p.Action,
+		})
+	}
+	return nil
+}
+
+// doGrantRolePermission 授予角色权限，调用方负责检查职责分离约束
+func (h *BaseHandler) doGrantRolePermission(ctx context.Context, req RolePermissionReq) (ginx.Result, error) {
 	resp, err := h.rbacSvc.GrantRolePermission(ctx, &permissionv1.GrantRolePermissionRequest{
 		RolePermission: h.toRolePermissionPB(req),
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RolePermissionTable, permission.ChangeActionGrant, h.toRolePermissionVO(resp.RolePermission))
 	return ginx.Result{
 		Data: h.toRolePermissionVO(resp.RolePermission),
 	}, nil
@@ -538,19 +686,13 @@ func (h *BaseHandler) toRolePermissionVO(src *permissionv1.RolePermission) RoleP
 }
 
 func (h *BaseHandler) listRolePermissions(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListRolePermissions(ctx, &permissionv1.ListRolePermissionsRequest{
-		BizId: req.BizID,
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[RolePermission]{
-			Rows: slice.Map(resp.RolePermissions, func(_ int, src *permissionv1.RolePermission) RolePermission {
-				return h.toRolePermissionVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.RolePermission, error) {
+		resp, err := h.rbacSvc.ListRolePermissions(ctx, &permissionv1.ListRolePermissionsRequest{BizId: req.BizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.RolePermissions, nil
+	}, func(r *permissionv1.RolePermission) int64 { return r.Id }, h.toRolePermissionVO)
 }
 
 func (h *BaseHandler) revokeRolePermission(ctx context.Context, req RolePermissionReq) (ginx.Result, error) {
@@ -560,6 +702,7 @@ func (h *BaseHandler) revokeRolePermission(ctx context.Context, req RolePermissi
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.RolePermissionTable, permission.ChangeActionRevoke, RolePermission{ID: req.RolePermission.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
@@ -568,12 +711,39 @@ func (h *BaseHandler) revokeRolePermission(ctx context.Context, req RolePermissi
 // UserRole
 
 func (h *BaseHandler) grantUserRole(ctx context.Context, req UserRoleReq) (ginx.Result, error) {
+	err := h.sod.Check(ctx, req.BizID, h.loadModel, func(model *domain.RBACModel) error {
+		return addUserRole(model, req)
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return h.doGrantUserRole(ctx, req)
+}
+
+// addUserRole 在权限模型上加入用户角色，用来检查职责分离约束
+func addUserRole(model *domain.RBACModel, req UserRoleReq) error {
+	role, err := modelRoleName(*model, req.UserRole.Role)
+	if err != nil {
+		return err
+	}
+	model.UserRoles = append(model.UserRoles, domain.ModelUserRole{
+		UserID:    req.UserRole.UserID,
+		Role:      role,
+		StartTime: req.UserRole.StartTime,
+		EndTime:   req.UserRole.EndTime,
+	})
+	return nil
+}
+
+// doGrantUserRole 授予用户角色，调用方负责检查职责分离约束
+func (h *BaseHandler) doGrantUserRole(ctx context.Context, req UserRoleReq) (ginx.Result, error) {
 	resp, err := h.rbacSvc.GrantUserRole(ctx, &permissionv1.GrantUserRoleRequest{
 		UserRole: h.toUserRolePB(req),
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.UserRoleTable, permission.ChangeActionGrant, h.toUserRoleVO(resp.UserRole))
 	return ginx.Result{
 		Data: h.toUserRoleVO(resp.UserRole),
 	}, nil
@@ -607,42 +777,83 @@ func (h *BaseHandler) toUserRoleVO(src *permissionv1.UserRole) UserRole {
 }
 
 func (h *BaseHandler) listUserRoles(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListUserRoles(ctx, &permissionv1.ListUserRolesRequest{
-		BizId: req.BizID,
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.UserRole, error) {
+		resp, err := h.rbacSvc.ListUserRoles(ctx, &permissionv1.ListUserRolesRequest{BizId: req.BizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.UserRoles, nil
+	}, func(r *permissionv1.UserRole) int64 { return r.Id }, h.toUserRoleVO)
+}
+
+func (h *BaseHandler) revokeUserRole(ctx context.Context, req UserRoleReq) (ginx.Result, error) {
+	resp, err := h.rbacSvc.RevokeUserRole(ctx, &permissionv1.RevokeUserRoleRequest{
+		Id: req.UserRole.ID,
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.UserRoleTable, permission.ChangeActionRevoke, UserRole{ID: req.UserRole.ID})
 	return ginx.Result{
-		Data: ListResp[UserRole]{
-			Rows: slice.Map(resp.UserRoles, func(_ int, src *permissionv1.UserRole) UserRole {
-				return h.toUserRoleVO(src)
-			}),
-		},
+		Data: resp.Success,
 	}, nil
 }
 
-func (h *BaseHandler) revokeUserRole(ctx context.Context, req UserRoleReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.RevokeUserRole(ctx, &permissionv1.RevokeUserRoleRequest{
-		Id: req.UserRole.ID,
+func (h *BaseHandler) grantAccountRole(ctx context.Context, req GrantUserRoleReq) (ginx.Result, error) {
+	resp, err := h.rbacSvc.GrantUserRole(ctx, &permissionv1.GrantUserRoleRequest{
+		UserRole: &permissionv1.UserRole{
+			UserId:   req.UserID,
+			RoleId:   req.Role.ID,
+			RoleName: req.Role.Name,
+			RoleType: domain.DefaultAccountRoleType,
+		},
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.UserRoleTable, permission.ChangeActionGrant, h.toUserRoleVO(resp.UserRole))
 	return ginx.Result{
-		Data: resp.Success,
+		Data: h.toUserRoleVO(resp.UserRole),
 	}, nil
 }
 
 // UserPermission
 
 func (h *BaseHandler) grantUserPermission(ctx context.Context, req UserPermissionReq) (ginx.Result, error) {
+	err := h.sod.Check(ctx, req.BizID, h.loadModel, func(model *domain.RBACModel) error {
+		addUserPermission(model, req)
+		return nil
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return h.doGrantUserPermission(ctx, req)
+}
+
+// addUserPermission 在权限模型上加入用户权限，用来检查职责分离约束
+func addUserPermission(model *domain.RBACModel, req UserPermissionReq) {
+	for _, p := range modelPermissions(*model, req.UserPermission.Permission) {
+		model.UserPermissions = append(model.UserPermissions, domain.ModelUserPermission{
+			UserID:       req.UserPermission.UserID,
+			ResourceType: p.ResourceType,
+			ResourceKey:  p.ResourceKey,
+			Action:       p.Action,
+			Effect:       domain.Effect(req.UserPermission.Effect),
+			StartTime:    req.UserPermission.StartTime,
+			EndTime:      req.UserPermission.EndTime,
+		})
+	}
+}
+
+// doGrantUserPermission 授予用户权限，调用方负责检查职责分离约束
+func (h *BaseHandler) doGrantUserPermission(ctx context.Context, req UserPermissionReq) (ginx.Result, error) {
 	resp, err := h.rbacSvc.GrantUserPermission(ctx, &permissionv1.GrantUserPermissionRequest{
 		UserPermission: h.toUserPermissionPB(req),
 	})
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.UserPermissionTable, permission.ChangeActionGrant, h.toUserPermissionVO(resp.UserPermission))
 	return ginx.Result{
 		Data: h.toUserPermissionVO(resp.UserPermission),
 	}, nil
@@ -676,24 +887,18 @@ func (h *BaseHandler) toUserPermissionVO(src *permissionv1.UserPermission) UserP
 		},
 		StartTime: src.StartTime,
 		EndTime:   src.EndTime,
+		Effect:    src.Effect,
 	}
 }
 
 func (h *BaseHandler) listUserPermissions(ctx context.Context, req ListReq) (ginx.Result, error) {
-	resp, err := h.rbacSvc.ListUserPermissions(ctx, &permissionv1.ListUserPermissionsRequest{
-		Offset: int32(req.Offset),
-		Limit:  int32(req.Limit),
-	})
-	if err != nil {
-		return ginx.Result{}, err
-	}
-	return ginx.Result{
-		Data: ListResp[UserPermission]{
-			Rows: slice.Map(resp.UserPermissions, func(_ int, src *permissionv1.UserPermission) UserPermission {
-				return h.toUserPermissionVO(src)
-			}),
-		},
-	}, nil
+	return listRows(h, req, func(offset, limit int32) ([]*permissionv1.UserPermission, error) {
+		resp, err := h.rbacSvc.ListUserPermissions(ctx, &permissionv1.ListUserPermissionsRequest{BizId: req.BizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return nil, err
+		}
+		return resp.UserPermissions, nil
+	}, func(r *permissionv1.UserPermission) int64 { return r.Id }, h.toUserPermissionVO)
 }
 
 func (h *BaseHandler) revokeUserPermission(ctx context.Context, req UserPermissionReq) (ginx.Result, error) {
@@ -703,7 +908,29 @@ func (h *BaseHandler) revokeUserPermission(ctx context.Context, req UserPermissi
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	h.publishChange(ctx, req.BizID, domain.UserPermissionTable, permission.ChangeActionRevoke, UserPermission{ID: req.UserPermission.ID})
 	return ginx.Result{
 		Data: resp.Success,
 	}, nil
 }
+
+// modelRoleName 在权限模型中按照ID或者角色名找到角色
+func modelRoleName(model domain.RBACModel, role Role) (string, error) {
+	for _, r := range model.Roles {
+		if (role.ID != 0 && r.ID == role.ID) || (role.ID == 0 && r.Name == role.Name) {
+			return r.Name, nil
+		}
+	}
+	return "", fmt.Errorf("角色 %d %s 不存在", role.ID, role.Name)
+}
+
+// modelPermissions 在权限模型中按照ID找到权限，指定动作时只返回这个动作
+func modelPermissions(model domain.RBACModel, permission Permission) []domain.ModelPermission {
+	var res []domain.ModelPermission
+	for _, p := range model.Permissions {
+		if p.ID == permission.ID && (permission.Action == "" || p.Action == permission.Action) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
@@ -0,0 +1,186 @@
+package web
+
+import (
+	"errors"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// BreakGlassHandler 紧急授权
+type BreakGlassHandler struct {
+	*BaseHandler
+	svc *service.BreakGlassService
+}
+
+func NewBreakGlassHandler(handler *BaseHandler, svc *service.BreakGlassService) *BreakGlassHandler {
+	return &BreakGlassHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *BreakGlassHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/break-glass/policy/save", ginx.BS(audited(h.audits, domain.ApprovalTable, h.SavePolicy)))
+	server.GET("/break-glass/policy", ginx.BS[BreakGlassPolicyGetReq](h.GetPolicy))
+	server.POST("/break-glass/activate", ginx.BS(audited(h.audits, domain.UserRoleTable, h.Activate)))
+	server.POST("/break-glass/end", ginx.BS(audited(h.audits, domain.UserRoleTable, h.End)))
+	server.GET("/break-glass/list", ginx.BS[BreakGlassListReq](h.List))
+	server.GET("/break-glass/report", ginx.BS[BreakGlassReq](h.Report))
+}
+
+func (h *BreakGlassHandler) SavePolicy(ctx *ginx.Context, req BreakGlassPolicyReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.svc.SavePolicy(ctx, domain.BreakGlassPolicy{
+		BizID:      req.BizID,
+		RoleID:     req.Policy.RoleID,
+		Responders: req.Policy.Responders,
+		Watchers:   req.Policy.Watchers,
+		Window:     req.Policy.Window,
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: true,
+	}, nil
+}
+
+func (h *BreakGlassHandler) GetPolicy(ctx *ginx.Context, req BreakGlassPolicyGetReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ApprovalTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	policy, err := h.svc.GetPolicy(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: BreakGlassPolicy{
+			BizID:      policy.BizID,
+			RoleID:     policy.RoleID,
+			Responders: policy.Responders,
+			Watchers:   policy.Watchers,
+			Window:     policy.Window,
+			Ctime:      policy.Ctime,
+			Utime:      policy.Utime,
+		},
+	}, nil
+}
+
+// Activate 值班人员立刻获得紧急角色，不需要审批
+func (h *BreakGlassHandler) Activate(ctx *ginx.Context, req BreakGlassActivateReq, sess session.Session) (ginx.Result, error) {
+	s, err := h.svc.Activate(ctx, req.BizID, sess.Claims().Uid, req.Reason)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	h.publishChange(ctx, req.BizID, domain.UserRoleTable, permission.ChangeActionGrant, UserRole{
+		ID:        s.UserRoleID,
+		BizID:     s.BizID,
+		UserID:    s.UID,
+		Role:      Role{ID: s.RoleID, Name: s.RoleName},
+		StartTime: s.StartTime,
+		EndTime:   s.EndTime,
+	})
+	return ginx.Result{
+		Data: toBreakGlassSessionVO(s),
+	}, nil
+}
+
+func (h *BreakGlassHandler) End(ctx *ginx.Context, req BreakGlassReq, sess session.Session) (ginx.Result, error) {
+	s, err := h.session(ctx, req)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	s, err = h.svc.End(ctx, s.ID, sess.Claims().Uid)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toBreakGlassSessionVO(s),
+	}, nil
+}
+
+func (h *BreakGlassHandler) List(ctx *ginx.Context, req BreakGlassListReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.AuditLogTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listRows(h.BaseHandler, req.ListReq, func(offset, limit int32) ([]domain.BreakGlassSession, error) {
+		return h.svc.ListSessions(ctx, req.BizID, int(offset), int(limit))
+	}, func(src domain.BreakGlassSession) int64 { return src.ID }, toBreakGlassSessionVO)
+}
+
+// Report 事后报告，持有人本人或者有审计日志读权限的用户可以查看
+func (h *BreakGlassHandler) Report(ctx *ginx.Context, req BreakGlassReq, sess session.Session) (ginx.Result, error) {
+	s, err := h.session(ctx, req)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	if s.UID != uid {
+		businessAdminCtx, err1 := h.businessAdminCtx(ctx, req.BizID)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+		err1 = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.AuditLogTable, domain.PermissionActionRead)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+	}
+	s, records, err := h.svc.Report(ctx, s.ID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: BreakGlassReport{
+			Session: toBreakGlassSessionVO(s),
+			Actions: slice.Map(records, func(_ int, src domain.AuditRecord) AuditRecord { return toAuditRecordVO(src) }),
+		},
+	}, nil
+}
+
+func (h *BreakGlassHandler) session(ctx *ginx.Context, req BreakGlassReq) (domain.BreakGlassSession, error) {
+	s, err := h.svc.GetSession(ctx, req.ID)
+	if err != nil {
+		return domain.BreakGlassSession{}, err
+	}
+	if s.BizID != req.BizID {
+		return domain.BreakGlassSession{}, errors.New("紧急授权不属于该业务")
+	}
+	return s, nil
+}
+
+func toBreakGlassSessionVO(src domain.BreakGlassSession) BreakGlassSession {
+	return BreakGlassSession{
+		ID:         src.ID,
+		BizID:      src.BizID,
+		UID:        src.UID,
+		RoleID:     src.RoleID,
+		RoleName:   src.RoleName,
+		UserRoleID: src.UserRoleID,
+		Reason:     src.Reason,
+		StartTime:  src.StartTime,
+		EndTime:    src.EndTime,
+		RevokedAt:  src.RevokedAt,
+		Status:     src.Status.String(),
+		Error:      src.Error,
+	}
+}
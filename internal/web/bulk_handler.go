@@ -0,0 +1,368 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxBulkUploadSize 上传文件的大小上限
+	maxBulkUploadSize = 2 << 20
+	// maxBulkUploadRows 一个文件最多包含的授权行数，不包括表头
+	maxBulkUploadRows = 5000
+	// bulkGrantConcurrency 同时向权限平台发起的授权请求数量
+	bulkGrantConcurrency = 8
+)
+
+// BulkHandler 通过上传 CSV 文件批量授权
+type BulkHandler struct {
+	*BaseHandler
+}
+
+func NewBulkHandler(handler *BaseHandler) *BulkHandler {
+	return &BulkHandler{BaseHandler: handler}
+}
+
+func (h *BulkHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/user/grant_role/upload", ginx.BS(audited(h.audits, domain.UserRoleTable, h.UploadUserRoles)))
+}
+
+// UploadUserRoles 批量授予用户角色
+// 文件第一行是表头，包含 userId、roleId 或者 roleName、startTime、endTime 列，时间可以是毫秒时间戳或者 RFC3339 格式
+// 先校验全部行，任意一行不通过时不执行任何授权；用户已经拥有的角色会跳过，所以重复上传同一个文件是幂等的
+func (h *BulkHandler) UploadUserRoles(ctx *ginx.Context, req BulkUserRoleUploadReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.UserRoleTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return ginx.Result{}, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	if header.Size > maxBulkUploadSize {
+		return ginx.Result{}, fmt.Errorf("上传文件不能超过 %d 字节", maxBulkUploadSize)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return ginx.Result{}, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	defer file.Close()
+	rows, err := parseBulkUserRoles(file)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+
+	if err = h.validateUserRoles(businessAdminCtx, req.BizID, rows); err != nil {
+		return ginx.Result{}, err
+	}
+	res := toBulkGrantResult(req.DryRun, rows)
+	if res.Counts[domain.BulkGrantInvalid.String()] > 0 {
+		return ginx.Result{Msg: "文件校验失败，没有执行任何授权", Data: res}, errors.New("批量授权文件校验失败")
+	}
+	if req.DryRun {
+		return ginx.Result{Data: res}, nil
+	}
+
+	h.grantUserRoles(businessAdminCtx, req.BizID, rows)
+	res = toBulkGrantResult(req.DryRun, rows)
+	if n := res.Counts[domain.BulkGrantFailed.String()]; n > 0 {
+		return ginx.Result{Msg: "部分授权失败，修正后重新上传同一个文件即可", Data: res}, fmt.Errorf("%d 行授权失败", n)
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+// validateUserRoles 校验全部行并且解析角色，用户已经拥有的角色标记为跳过
+func (h *BulkHandler) validateUserRoles(ctx context.Context, bizID int64, rows []domain.BulkUserRoleRow) error {
+	roles, err := h.loadBusinessRoles(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*permissionv1.Role, len(roles))
+	for _, role := range roles {
+		byName[role.Name] = role
+	}
+	existing := make(map[[2]int64]bool)
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err1 := h.rbacSvc.ListUserRoles(ctx, &permissionv1.ListUserRolesRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err1 != nil {
+			return 0, err1
+		}
+		for _, src := range resp.UserRoles {
+			existing[[2]int64{src.UserId, src.RoleId}] = true
+		}
+		return len(resp.UserRoles), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	seen := make(map[[2]int64]int, len(rows))
+	// 同一个角色只查询一次审批策略
+	approvals := make(map[int64]bool)
+	for i := range rows {
+		row := &rows[i]
+		if row.Status == domain.BulkGrantInvalid {
+			continue
+		}
+		if err = row.Validate(now); err != nil {
+			row.Invalidate(err)
+			continue
+		}
+		role := roles[row.RoleID]
+		if row.RoleID == 0 {
+			role = byName[row.RoleName]
+		}
+		switch {
+		case role == nil:
+			row.Invalidate(errors.New("角色不存在"))
+			continue
+		case row.RoleName != "" && row.RoleName != role.Name:
+			row.Invalidate(fmt.Errorf("角色ID %d 和角色名 %s 不一致", row.RoleID, row.RoleName))
+			continue
+		}
+		row.RoleID, row.RoleName = role.Id, role.Name
+		if line, ok := seen[row.Key()]; ok {
+			row.Invalidate(fmt.Errorf("和第 %d 行重复", line))
+			continue
+		}
+		seen[row.Key()] = row.Line
+		needApproval, ok := approvals[row.RoleID]
+		if !ok {
+			_, needApproval, err = h.approvals.MatchPolicy(ctx, bizID, domain.ChangeRequestGrantUserRole, row.RoleID)
+			if err != nil {
+				return err
+			}
+			approvals[row.RoleID] = needApproval
+		}
+		if needApproval {
+			row.Invalidate(errors.New("该角色需要审批，不能批量授予"))
+			continue
+		}
+		row.Status = domain.BulkGrantPending
+		if existing[row.Key()] {
+			row.Status = domain.BulkGrantExists
+		}
+	}
+	return h.checkUserRolesSoD(ctx, bizID, rows)
+}
+
+// checkUserRolesSoD 把整批授权放到同一个模型上检查职责分离约束，
+// 同一个文件里分别授予同一个用户两个互斥的角色也会被发现，违反约束的用户的全部行标记为不合法
+func (h *BulkHandler) checkUserRolesSoD(ctx context.Context, bizID int64, rows []domain.BulkUserRoleRow) error {
+	err := h.sod.Check(ctx, bizID, h.loadModel, func(model *domain.RBACModel) error {
+		for _, row := range rows {
+			if row.Status == domain.BulkGrantPending {
+				model.UserRoles = append(model.UserRoles, domain.ModelUserRole{
+					UserID:    row.UserID,
+					Role:      row.RoleName,
+					StartTime: row.StartTime,
+					EndTime:   row.EndTime,
+				})
+			}
+		}
+		return nil
+	})
+	var sodErr *domain.SoDError
+	if !errors.As(err, &sodErr) {
+		return err
+	}
+	for _, v := range sodErr.Violations {
+		for i := range rows {
+			if rows[i].UserID == v.UserID && rows[i].Status == domain.BulkGrantPending {
+				rows[i].Invalidate(fmt.Errorf("违反职责分离约束: %s", v))
+			}
+		}
+	}
+	return nil
+}
+
+// grantUserRoles 并发执行校验通过的授权，单行失败不影响其他行
+// 职责分离约束已经在校验时对整批检查过，这里不再逐行读取权限模型
+func (h *BulkHandler) grantUserRoles(ctx context.Context, bizID int64, rows []domain.BulkUserRoleRow) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, bulkGrantConcurrency)
+	for i := range rows {
+		if rows[i].Status != domain.BulkGrantPending {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(row *domain.BulkUserRoleRow) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			_, err := h.doGrantUserRole(ctx, UserRoleReq{BizID: bizID, UserRole: UserRole{
+				BizID:     bizID,
+				UserID:    row.UserID,
+				Role:      Role{ID: row.RoleID, Name: row.RoleName},
+				StartTime: row.StartTime,
+				EndTime:   row.EndTime,
+			}})
+			if err != nil {
+				row.Status, row.Error = domain.BulkGrantFailed, err.Error()
+				return
+			}
+			row.Status = domain.BulkGrantGranted
+		}(&rows[i])
+	}
+	wg.Wait()
+}
+
+func (h *BulkHandler) loadBusinessRoles(ctx context.Context, bizID int64) (map[int64]*permissionv1.Role, error) {
+	roles := make(map[int64]*permissionv1.Role)
+	err := h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListRoles(ctx, &permissionv1.ListRolesRequest{
+			BizId:  bizID,
+			Type:   domain.DefaultBusinessRoleType,
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, role := range resp.Roles {
+			roles[role.Id] = role
+		}
+		return len(resp.Roles), nil
+	})
+	return roles, err
+}
+
+// parseBulkUserRoles 按照表头解析 CSV 文件，格式错误的行标记为校验失败，继续解析后面的行
+func parseBulkUserRoles(r io.Reader) ([]domain.BulkUserRoleRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Excel 导出的 CSV 文件可能带有 BOM
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["userid"]; !ok {
+		return nil, errors.New("表头缺少 userId 列")
+	}
+	_, hasRoleID := columns["roleid"]
+	_, hasRoleName := columns["rolename"]
+	if !hasRoleID && !hasRoleName {
+		return nil, errors.New("表头缺少 roleId 或者 roleName 列")
+	}
+
+	var rows []domain.BulkUserRoleRow
+	for {
+		record, err1 := reader.Read()
+		if errors.Is(err1, io.EOF) {
+			break
+		}
+		if len(rows) >= maxBulkUploadRows {
+			return nil, fmt.Errorf("文件不能超过 %d 行", maxBulkUploadRows)
+		}
+		var row domain.BulkUserRoleRow
+		if err1 != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err1, &parseErr) {
+				row.Line = parseErr.StartLine
+			}
+			row.Invalidate(err1)
+			rows = append(rows, row)
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+		row.Line, _ = reader.FieldPos(0)
+		row.RoleName = field("rolename")
+		if row.UserID, err1 = parseBulkInt(field("userid")); err1 != nil {
+			row.Invalidate(fmt.Errorf("userId 格式错误: %w", err1))
+		}
+		if row.RoleID, err1 = parseBulkInt(field("roleid")); err1 != nil {
+			row.Invalidate(fmt.Errorf("roleId 格式错误: %w", err1))
+		}
+		if row.StartTime, err1 = parseBulkTime(field("starttime")); err1 != nil {
+			row.Invalidate(fmt.Errorf("startTime 格式错误: %w", err1))
+		}
+		if row.EndTime, err1 = parseBulkTime(field("endtime")); err1 != nil {
+			row.Invalidate(fmt.Errorf("endTime 格式错误: %w", err1))
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("文件中没有授权数据")
+	}
+	return rows, nil
+}
+
+func parseBulkInt(val string) (int64, error) {
+	if val == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+// parseBulkTime 支持毫秒时间戳和 RFC3339 格式，为空表示不限制
+func parseBulkTime(val string) (int64, error) {
+	if val == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+func toBulkGrantResult(dryRun bool, rows []domain.BulkUserRoleRow) BulkGrantResult {
+	res := BulkGrantResult{
+		DryRun: dryRun,
+		Total:  len(rows),
+		Counts: make(map[string]int),
+		Rows: slice.Map(rows, func(_ int, src domain.BulkUserRoleRow) BulkUserRoleRow {
+			return BulkUserRoleRow{
+				Line:      src.Line,
+				UserID:    src.UserID,
+				RoleID:    src.RoleID,
+				RoleName:  src.RoleName,
+				StartTime: src.StartTime,
+				EndTime:   src.EndTime,
+				Status:    src.Status.String(),
+				Error:     src.Error,
+			}
+		}),
+	}
+	for _, row := range rows {
+		res.Counts[row.Status.String()]++
+	}
+	return res
+}
@@ -18,40 +18,40 @@ func NewBusinessHandler(handler *BaseHandler) *BusinessHandler {
 
 //nolint:dupl // 忽略
 func (h *BusinessHandler) PrivateRoutes(server *gin.Engine) {
-	server.POST("/resource/create", ginx.BS[ResourceReq](h.CreateResource))
+	server.POST("/resource/create", ginx.BS(audited(h.audits, domain.ResourceTable, h.CreateResource)))
 	server.GET("/resource/get", ginx.BS[ResourceReq](h.GetResource))
 	server.GET("/resource/list", ginx.BS[ListReq](h.ListResources))
-	server.POST("/resource/update", ginx.BS[ResourceReq](h.UpdateResource))
-	server.POST("/resource/delete", ginx.BS[ResourceReq](h.DeleteResource))
+	server.POST("/resource/update", ginx.BS(audited(h.audits, domain.ResourceTable, h.UpdateResource)))
+	server.POST("/resource/delete", ginx.BS(audited(h.audits, domain.ResourceTable, h.DeleteResource)))
 
-	server.POST("/permission/create", ginx.BS[PermissionReq](h.CreatePermission))
+	server.POST("/permission/create", ginx.BS(audited(h.audits, domain.PermissionTable, h.CreatePermission)))
 	server.GET("/permission/get", ginx.BS[PermissionReq](h.GetPermission))
 	server.GET("/permission/list", ginx.BS[ListReq](h.ListPermissions))
-	server.POST("/permission/update", ginx.BS[PermissionReq](h.UpdatePermission))
-	server.POST("/permission/delete", ginx.BS[PermissionReq](h.DeletePermission))
+	server.POST("/permission/update", ginx.BS(audited(h.audits, domain.PermissionTable, h.UpdatePermission)))
+	server.POST("/permission/delete", ginx.BS(audited(h.audits, domain.PermissionTable, h.DeletePermission)))
 
-	server.POST("/role/create", ginx.BS[RoleReq](h.CreateRole))
+	server.POST("/role/create", ginx.BS(audited(h.audits, domain.RoleTable, h.CreateRole)))
 	server.GET("/role/get", ginx.BS[RoleReq](h.GetRole))
 	server.GET("/role/list", ginx.BS[ListReq](h.ListRoles))
-	server.POST("/role/update", ginx.BS[RoleReq](h.UpdateRole))
-	server.POST("/role/delete", ginx.BS[RoleReq](h.DeleteRole))
+	server.POST("/role/update", ginx.BS(audited(h.audits, domain.RoleTable, h.UpdateRole)))
+	server.POST("/role/delete", ginx.BS(audited(h.audits, domain.RoleTable, h.DeleteRole)))
 
-	server.POST("/role-inclusion/create", ginx.BS[RoleInclusionReq](h.CreateRoleInclusion))
+	server.POST("/role-inclusion/create", ginx.BS(audited(h.audits, domain.RoleInclusionTable, h.CreateRoleInclusion)))
 	server.GET("/role-inclusion/get", ginx.BS[RoleInclusionReq](h.GetRoleInclusion))
 	server.GET("/role-inclusion/list", ginx.BS[ListReq](h.ListRoleInclusions))
-	server.POST("/role-inclusion/delete", ginx.BS[RoleInclusionReq](h.DeleteRoleInclusion))
+	server.POST("/role-inclusion/delete", ginx.BS(audited(h.audits, domain.RoleInclusionTable, h.DeleteRoleInclusion)))
 
-	server.POST("/role/grant_permission", ginx.BS[RolePermissionReq](h.GrantRolePermission))
+	server.POST("/role/grant_permission", ginx.BS(audited(h.audits, domain.RolePermissionTable, h.GrantRolePermission)))
 	server.GET("/role/list_permission", ginx.BS[ListReq](h.ListRolePermissions))
-	server.POST("/role/revoke_permission", ginx.BS[RolePermissionReq](h.RevokeRolePermission))
+	server.POST("/role/revoke_permission", ginx.BS(audited(h.audits, domain.RolePermissionTable, h.RevokeRolePermission)))
 
-	server.POST("/user/grant_role", ginx.BS(h.GrantUserRole))
+	server.POST("/user/grant_role", ginx.BS(audited(h.audits, domain.UserRoleTable, h.GrantUserRole)))
 	server.GET("/user/list_role", ginx.BS[ListReq](h.ListUserRoles))
-	server.POST("/user/revoke_role", ginx.BS(h.RevokeUserRole))
+	server.POST("/user/revoke_role", ginx.BS(audited(h.audits, domain.UserRoleTable, h.RevokeUserRole)))
 
-	server.POST("/user/grant_permission", ginx.BS[UserPermissionReq](h.GrantUserPermission))
+	server.POST("/user/grant_permission", ginx.BS(audited(h.audits, domain.UserPermissionTable, h.GrantUserPermission)))
 	server.GET("/user/list_permission", ginx.BS[ListReq](h.ListUserPermissions))
-	server.POST("/user/revoke_permission", ginx.BS[UserPermissionReq](h.RevokeUserPermission))
+	server.POST("/user/revoke_permission", ginx.BS(audited(h.audits, domain.UserPermissionTable, h.RevokeUserPermission)))
 }
 
 func (h *BusinessHandler) createCheckPermissionRequest(bizID, uid int64, resource domain.SystemTableResource, action domain.PermissionActionType) *permissionv1.CheckPermissionRequest {
@@ -351,6 +351,18 @@ func (h *BusinessHandler) GrantUserRole(ctx *ginx.Context, req UserRoleReq, sess
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	res, ok, err := h.requireApproval(businessAdminCtx, domain.ChangeRequest{
+		BizID:        req.BizID,
+		Kind:         domain.ChangeRequestGrantUserRole,
+		TargetID:     req.UserRole.Role.ID,
+		TargetUserID: req.UserRole.UserID,
+		Payload:      toJSONString(req),
+		RequesterUID: sess.Claims().Uid,
+		Reason:       req.Reason,
+	})
+	if ok || err != nil {
+		return res, err
+	}
 	return h.grantUserRole(businessAdminCtx, req)
 }
 
@@ -389,6 +401,18 @@ func (h *BusinessHandler) GrantUserPermission(ctx *ginx.Context, req UserPermiss
 	if err != nil {
 		return ginx.Result{}, err
 	}
+	res, ok, err := h.requireApproval(businessAdminCtx, domain.ChangeRequest{
+		BizID:        req.BizID,
+		Kind:         domain.ChangeRequestGrantUserPermission,
+		TargetID:     req.UserPermission.Permission.ID,
+		TargetUserID: req.UserPermission.UserID,
+		Payload:      toJSONString(req),
+		RequesterUID: sess.Claims().Uid,
+		Reason:       req.Reason,
+	})
+	if ok || err != nil {
+		return res, err
+	}
 	return h.grantUserPermission(businessAdminCtx, req)
 }
 
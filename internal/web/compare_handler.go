@@ -0,0 +1,166 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// CompareHandler 对比两个用户或者两个角色的权限，以及把一个用户的角色复制给另一个用户
+type CompareHandler struct {
+	*BaseHandler
+	breakGlass *service.BreakGlassService
+}
+
+func NewCompareHandler(handler *BaseHandler, breakGlass *service.BreakGlassService) *CompareHandler {
+	return &CompareHandler{BaseHandler: handler, breakGlass: breakGlass}
+}
+
+func (h *CompareHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/compare", ginx.BS[CompareReq](h.Compare))
+	server.POST("/user/copy-access", ginx.BS(audited(h.audits, domain.UserRoleTable, h.CopyAccess)))
+}
+
+// Compare 对比两边的有效权限，用户的权限展开角色包含关系并且合并直接授予的权限，需要全部业务表的读权限
+func (h *CompareHandler) Compare(ctx *ginx.Context, req CompareReq, sess session.Session) (ginx.Result, error) {
+	byUser := req.LeftUser > 0 && req.RightUser > 0
+	if byUser == (req.LeftRole != "" && req.RightRole != "") {
+		return ginx.Result{}, errors.New("需要指定两个用户或者两个角色")
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if req.At == 0 {
+		req.At = time.Now().UnixMilli()
+	}
+	var left, right []domain.EffectivePermission
+	if byUser {
+		left, right = model.EffectivePermissions(req.LeftUser, req.At), model.EffectivePermissions(req.RightUser, req.At)
+	} else {
+		for _, role := range []string{req.LeftRole, req.RightRole} {
+			if _, err = modelRoleName(model, Role{Name: role}); err != nil {
+				return ginx.Result{}, err
+			}
+		}
+		left, right = model.RoleEffectivePermissions(req.LeftRole), model.RoleEffectivePermissions(req.RightRole)
+	}
+	diff := domain.ComparePermissions(left, right)
+	return ginx.Result{Data: Comparison{
+		At:        req.At,
+		OnlyLeft:  slice.Map(diff.OnlyLeft, toEffectivePermissionVO),
+		OnlyRight: slice.Map(diff.OnlyRight, toEffectivePermissionVO),
+		Both:      slice.Map(diff.Both, toEffectivePermissionVO),
+	}}, nil
+}
+
+// CopyAccess 把 fromUser 有而 toUser 没有的角色授予 toUser，保留原来的有效期，直接授予的权限和紧急角色不复制
+// 先用 dryRun 预览，命中审批策略的角色和单独授予一样创建变更请求，单个角色失败时停止
+func (h *CompareHandler) CopyAccess(ctx *ginx.Context, req CopyAccessReq, sess session.Session) (ginx.Result, error) {
+	if req.FromUser <= 0 || req.ToUser <= 0 || req.FromUser == req.ToUser {
+		return ginx.Result{}, errors.New("用户ID不合法")
+	}
+	uid := sess.Claims().Uid
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if !req.DryRun {
+		err = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.UserRoleTable, domain.PermissionActionWrite)
+		if err != nil {
+			return ginx.Result{}, err
+		}
+	}
+	live, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	// 只复制业务角色，账号角色通过账号管理授予
+	model := live.Business()
+	roles := make(map[string]domain.ModelRole, len(model.Roles))
+	for _, r := range model.Roles {
+		roles[r.Name] = r
+	}
+	// 紧急角色只能通过紧急授权获得，不能被复制成日常授权
+	emergency, err := h.breakGlass.EmergencyRoleIDs(ctx, req.BizID, req.FromUser)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	res := CopyAccessResult{DryRun: req.DryRun}
+	for _, ur := range model.MissingUserRoles(req.FromUser, req.ToUser, time.Now().UnixMilli()) {
+		role := Role{ID: roles[ur.Role].ID, BizID: req.BizID, Name: ur.Role}
+		if slices.Contains(emergency, role.ID) {
+			res.Skipped = append(res.Skipped, SkippedUserRole{Role: role, Reason: "紧急角色不能复制"})
+			continue
+		}
+		_, needApproval, err1 := h.approvals.MatchPolicy(ctx, req.BizID, domain.ChangeRequestGrantUserRole, role.ID)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+		res.Roles = append(res.Roles, CopiedUserRole{Role: role, StartTime: ur.StartTime, EndTime: ur.EndTime, NeedApproval: needApproval})
+	}
+	if req.DryRun {
+		return ginx.Result{Data: res}, nil
+	}
+	grants := slice.Map(res.Roles, func(_ int, src CopiedUserRole) UserRoleReq {
+		return UserRoleReq{BizID: req.BizID, Reason: req.Reason, UserRole: UserRole{
+			BizID:     req.BizID,
+			UserID:    req.ToUser,
+			Role:      src.Role,
+			StartTime: src.StartTime,
+			EndTime:   src.EndTime,
+		}}
+	})
+	// 直接授予的角色整批检查一次职责分离约束，需要审批的角色在执行变更请求时检查
+	err = h.sod.Check(businessAdminCtx, req.BizID, h.loadModel, func(after *domain.RBACModel) error {
+		for i := range grants {
+			if res.Roles[i].NeedApproval {
+				continue
+			}
+			if err1 := addUserRole(after, grants[i]); err1 != nil {
+				return err1
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	for i := range res.Roles {
+		copied, grant := &res.Roles[i], grants[i]
+		approval, ok, err1 := h.requireApproval(businessAdminCtx, domain.ChangeRequest{
+			BizID:        req.BizID,
+			Kind:         domain.ChangeRequestGrantUserRole,
+			TargetID:     copied.Role.ID,
+			TargetUserID: req.ToUser,
+			Payload:      toJSONString(grant),
+			RequesterUID: uid,
+			Reason:       req.Reason,
+		})
+		if err1 == nil && ok {
+			cr, _ := approval.Data.(ChangeRequest)
+			copied.ChangeRequestID = cr.ID
+			continue
+		}
+		if err1 == nil {
+			_, err1 = h.doGrantUserRole(businessAdminCtx, grant)
+			copied.Granted = err1 == nil
+		}
+		if err1 != nil {
+			return ginx.Result{Msg: "复制中途失败，已经授予的角色不会撤销", Data: res}, fmt.Errorf("授予角色 %s 失败: %w", copied.Role.Name, err1)
+		}
+	}
+	return ginx.Result{Data: res}, nil
+}
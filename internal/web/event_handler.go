@@ -0,0 +1,240 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	eventTypeChange = "permission_change"
+	// eventTypeReset 续传位置已经被裁剪出重放缓冲区，客户端需要全量刷新
+	eventTypeReset = "reset"
+	readBatchSize  = 100
+	// readBlockTimeout 单次阻塞读取 Redis Stream 的时间
+	readBlockTimeout = 5 * time.Second
+
+	defaultEventHeartbeat    = 15 * time.Second
+	defaultEventBufferSize   = 64
+	defaultEventWriteTimeout = 5 * time.Second
+	defaultEventAuthRefresh  = time.Minute
+)
+
+type EventStreamConfig struct {
+	// Heartbeat 心跳间隔，防止代理因为空闲断开连接
+	Heartbeat time.Duration
+	// BufferSize 每个连接缓存的事件数量，写满说明客户端消费太慢
+	BufferSize int
+	// WriteTimeout 单次写入的超时时间
+	WriteTimeout time.Duration
+	// AuthRefresh 重新校验读权限的间隔，以便及时感知权限回收
+	AuthRefresh time.Duration
+}
+
+// EventHandler 通过 SSE 向管理后台推送业务内的权限变更
+type EventHandler struct {
+	*BaseHandler
+	cfg EventStreamConfig
+}
+
+// NewEventHandler 没有配置的项使用默认值，心跳间隔为0时 time.NewTicker 会 panic
+func NewEventHandler(handler *BaseHandler, cfg EventStreamConfig) *EventHandler {
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = defaultEventHeartbeat
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultEventBufferSize
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultEventWriteTimeout
+	}
+	if cfg.AuthRefresh <= 0 {
+		cfg.AuthRefresh = defaultEventAuthRefresh
+	}
+	return &EventHandler{BaseHandler: handler, cfg: cfg}
+}
+
+func (h *EventHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/events/stream", ginx.BS[EventStreamReq](h.Stream))
+}
+
+func (h *EventHandler) Stream(ctx *ginx.Context, req EventStreamReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	readable, err := h.readableTables(businessAdminCtx, req.BizID, uid)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if len(readable) == 0 {
+		return ginx.Result{}, errors.New("没有权限")
+	}
+
+	// 浏览器的 EventSource 重连时会自动带上 Last-Event-ID 头部
+	lastID := ctx.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = req.LastEventID
+	}
+	truncated := false
+	if lastID == "" {
+		lastID, err = h.changes.LatestID(ctx, req.BizID)
+	} else {
+		truncated, err = h.changes.Truncated(ctx, req.BizID, lastID)
+	}
+	if err != nil {
+		return ginx.Result{}, err
+	}
+
+	w := newSSEWriter(ctx.Context, h.cfg.WriteTimeout)
+	defer w.close()
+	if truncated {
+		if err = w.event("", eventTypeReset, []byte("{}")); err != nil {
+			return ginx.Result{}, ginx.ErrNoResponse
+		}
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+	events := make(chan permission.ChangeEvent, h.cfg.BufferSize)
+	go h.pump(streamCtx, cancel, req.BizID, lastID, events)
+
+	heartbeat := time.NewTicker(h.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	refreshAt := time.Now().Add(h.cfg.AuthRefresh)
+	for {
+		select {
+		case <-streamCtx.Done():
+			return ginx.Result{}, ginx.ErrNoResponse
+		case <-heartbeat.C:
+			if err = w.comment("heartbeat"); err != nil {
+				return ginx.Result{}, ginx.ErrNoResponse
+			}
+		case evt := <-events:
+			if time.Now().After(refreshAt) {
+				readable, err = h.readableTables(businessAdminCtx, req.BizID, uid)
+				if err != nil || len(readable) == 0 {
+					return ginx.Result{}, ginx.ErrNoResponse
+				}
+				refreshAt = time.Now().Add(h.cfg.AuthRefresh)
+			}
+			if !readable[evt.Table] {
+				continue
+			}
+			data, err1 := json.Marshal(evt)
+			if err1 != nil {
+				h.logger.Error("序列化权限变更事件失败", elog.FieldErr(err1), elog.String("id", evt.ID))
+				continue
+			}
+			if err = w.event(evt.ID, eventTypeChange, data); err != nil {
+				return ginx.Result{}, ginx.ErrNoResponse
+			}
+		}
+	}
+}
+
+// pump 从 Redis Stream 读取事件放入连接缓冲区
+// 缓冲区写满时直接断开连接，客户端带着 Last-Event-ID 重连后从重放缓冲区补齐
+func (h *EventHandler) pump(ctx context.Context, cancel context.CancelFunc, bizID int64, lastID string, events chan<- permission.ChangeEvent) {
+	defer cancel()
+	for ctx.Err() == nil {
+		evts, next, err := h.changes.Read(ctx, bizID, lastID, readBatchSize, readBlockTimeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				h.logger.Error("读取权限变更事件失败", elog.FieldErr(err), elog.Int64("bizId", bizID))
+			}
+			return
+		}
+		for i := range evts {
+			select {
+			case events <- evts[i]:
+				lastID = evts[i].ID
+			case <-ctx.Done():
+				return
+			default:
+				h.logger.Warn("客户端消费权限变更事件太慢，断开连接",
+					elog.Int64("bizId", bizID),
+					elog.String("lastId", lastID))
+				return
+			}
+		}
+		// 最后几条事件格式错误被跳过时，从它们后面继续读取
+		lastID = next
+	}
+}
+
+// readableTables 返回用户在业务内有读权限的系统表
+func (h *EventHandler) readableTables(ctx context.Context, bizID, uid int64) (map[string]bool, error) {
+	res := make(map[string]bool, len(domain.BusinessTables))
+	for _, table := range domain.BusinessTables {
+		resp, err := h.permissionSvc.CheckPermission(ctx, &permissionv1.CheckPermissionRequest{
+			Uid: uid,
+			Permission: &permissionv1.Permission{
+				BizId:        bizID,
+				ResourceType: table.Type(),
+				ResourceKey:  table.KeyForBusinessAdmin(bizID),
+				Actions:      []string{domain.PermissionActionRead.String()},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Allowed {
+			res[table.String()] = true
+		}
+	}
+	return res, nil
+}
+
+type sseWriter struct {
+	w       gin.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func newSSEWriter(ctx *gin.Context, timeout time.Duration) *sseWriter {
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭 Nginx 的响应缓冲
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	return &sseWriter{w: ctx.Writer, rc: http.NewResponseController(ctx.Writer), timeout: timeout}
+}
+
+func (w *sseWriter) event(id, typ string, data []byte) error {
+	if id != "" {
+		return w.write("id: %s\nevent: %s\ndata: %s\n\n", id, typ, data)
+	}
+	return w.write("event: %s\ndata: %s\n\n", typ, data)
+}
+
+func (w *sseWriter) comment(text string) error {
+	return w.write(": %s\n\n", text)
+}
+
+func (w *sseWriter) write(format string, args ...any) error {
+	// 写超时保证一个卡住的客户端不会一直占用连接，底层不支持时忽略
+	_ = w.rc.SetWriteDeadline(time.Now().Add(w.timeout))
+	if _, err := fmt.Fprintf(w.w, format, args...); err != nil {
+		return err
+	}
+	return w.rc.Flush()
+}
+
+func (w *sseWriter) close() {
+	_ = w.rc.SetWriteDeadline(time.Time{})
+}
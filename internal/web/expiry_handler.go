@@ -0,0 +1,80 @@
+package web
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// ExpiryHandler 限时授权的到期情况
+type ExpiryHandler struct {
+	*BaseHandler
+	svc *service.ExpiryService
+}
+
+func NewExpiryHandler(handler *BaseHandler, svc *service.ExpiryService) *ExpiryHandler {
+	return &ExpiryHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *ExpiryHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/user/expiring", ginx.BS[ExpiringReq](h.ListExpiring))
+	server.GET("/user/archived", ginx.BS[ArchivedGrantListReq](h.ListArchived))
+}
+
+// ListExpiring 查询即将到期的用户角色和用户权限
+func (h *ExpiryHandler) ListExpiring(ctx *ginx.Context, req ExpiringReq, sess session.Session) (ginx.Result, error) {
+	if err := h.check(ctx, req.BizID, sess.Claims().Uid); err != nil {
+		return ginx.Result{}, err
+	}
+	roles, perms, err := h.svc.ListExpiring(ctx, req.BizID, req.WithinDays)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: ExpiringGrants{
+			UserRoles:       slice.Map(roles, func(_ int, src *permissionv1.UserRole) UserRole { return h.toUserRoleVO(src) }),
+			UserPermissions: slice.Map(perms, func(_ int, src *permissionv1.UserPermission) UserPermission { return h.toUserPermissionVO(src) }),
+		},
+	}, nil
+}
+
+// ListArchived 查询到期后被归档的授权
+func (h *ExpiryHandler) ListArchived(ctx *ginx.Context, req ArchivedGrantListReq, sess session.Session) (ginx.Result, error) {
+	if err := h.check(ctx, req.BizID, sess.Claims().Uid); err != nil {
+		return ginx.Result{}, err
+	}
+	// 用户角色和用户权限的授权ID可能相同，游标只按 offset 定位
+	return listRows(h.BaseHandler, req.ListReq, func(offset, limit int32) ([]domain.ArchivedGrant, error) {
+		return h.svc.ListArchived(ctx, req.BizID, int(offset), int(limit))
+	}, nil, func(src domain.ArchivedGrant) ArchivedGrant {
+		return ArchivedGrant{
+			Table:      src.Table.String(),
+			GrantID:    src.GrantID,
+			UserID:     src.UserID,
+			TargetID:   src.TargetID,
+			TargetName: src.TargetName,
+			StartTime:  src.StartTime,
+			EndTime:    src.EndTime,
+			ArchivedAt: src.ArchivedAt,
+		}
+	})
+}
+
+// check 同时涉及用户角色和用户权限，两张表的读权限都需要
+func (h *ExpiryHandler) check(ctx *ginx.Context, bizID, uid int64) error {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, bizID)
+	if err != nil {
+		return err
+	}
+	for _, table := range []domain.SystemTableResource{domain.UserRoleTable, domain.UserPermissionTable} {
+		err = h.checkBusinessPermission(businessAdminCtx, bizID, uid, table, domain.PermissionActionRead)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package web
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	exportFormatCSV   = "csv"
+	exportFormatJSONL = "jsonl"
+)
+
+var (
+	userRoleCSVHeader       = []string{"id", "bizId", "userId", "roleId", "roleName", "startTime", "endTime"}
+	userPermissionCSVHeader = []string{"id", "bizId", "userId", "permissionId", "permissionName", "action", "effect", "startTime", "endTime"}
+	rolePermissionCSVHeader = []string{"id", "bizId", "roleId", "roleName", "permissionId", "resourceType", "resourceKey", "action"}
+	auditRecordCSVHeader    = []string{"id", "bizId", "actorUid", "route", "table", "targetId", "targetUserId", "outcome", "error", "clientIp", "ctime", "payload", "result", "seq", "hash"}
+)
+
+// ExportHandler 以 CSV 或者 JSONL 格式流式导出授权关系和审计日志
+type ExportHandler struct {
+	*BaseHandler
+}
+
+func NewExportHandler(handler *BaseHandler) *ExportHandler {
+	return &ExportHandler{BaseHandler: handler}
+}
+
+func (h *ExportHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/export/user_roles", ginx.BS[ExportReq](h.ExportUserRoles))
+	server.GET("/export/user_permissions", ginx.BS[ExportReq](h.ExportUserPermissions))
+	server.GET("/export/role_permissions", ginx.BS[ExportReq](h.ExportRolePermissions))
+	server.GET("/export/audit", ginx.BS[ExportReq](h.ExportAuditRecords))
+}
+
+func (h *ExportHandler) ExportUserRoles(ctx *ginx.Context, req ExportReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.prepare(ctx, req, sess, domain.UserRoleTable)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	enc := newExportEncoder(ctx.Context, req, domain.UserRoleTable.String(), userRoleCSVHeader)
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err1 := h.rbacSvc.ListUserRoles(businessAdminCtx, &permissionv1.ListUserRolesRequest{
+			BizId:  req.BizID,
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err1 != nil {
+			return 0, err1
+		}
+		for _, src := range resp.UserRoles {
+			if !overlaps(src.StartTime, src.EndTime, req.StartTime, req.EndTime) {
+				continue
+			}
+			if err1 = enc.encode(h.toUserRoleVO(src)); err1 != nil {
+				return 0, err1
+			}
+		}
+		return len(resp.UserRoles), enc.flush()
+	})
+	return h.finishExport(enc, req, err)
+}
+
+func (h *ExportHandler) ExportUserPermissions(ctx *ginx.Context, req ExportReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.prepare(ctx, req, sess, domain.UserPermissionTable)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	enc := newExportEncoder(ctx.Context, req, domain.UserPermissionTable.String(), userPermissionCSVHeader)
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err1 := h.rbacSvc.ListUserPermissions(businessAdminCtx, &permissionv1.ListUserPermissionsRequest{
+			BizId:  req.BizID,
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err1 != nil {
+			return 0, err1
+		}
+		for _, src := range resp.UserPermissions {
+			if !overlaps(src.StartTime, src.EndTime, req.StartTime, req.EndTime) {
+				continue
+			}
+			if err1 = enc.encode(h.toUserPermissionVO(src)); err1 != nil {
+				return 0, err1
+			}
+		}
+		return len(resp.UserPermissions), enc.flush()
+	})
+	return h.finishExport(enc, req, err)
+}
+
+// ExportRolePermissions 角色权限没有生效时间，所以忽略时间范围
+func (h *ExportHandler) ExportRolePermissions(ctx *ginx.Context, req ExportReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.prepare(ctx, req, sess, domain.RolePermissionTable)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	enc := newExportEncoder(ctx.Context, req, domain.RolePermissionTable.String(), rolePermissionCSVHeader)
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err1 := h.rbacSvc.ListRolePermissions(businessAdminCtx, &permissionv1.ListRolePermissionsRequest{
+			BizId:  req.BizID,
+			Offset: offset,
+			Limit:  limit,
+		})
+		if err1 != nil {
+			return 0, err1
+		}
+		for _, src := range resp.RolePermissions {
+			if err1 = enc.encode(h.toRolePermissionVO(src)); err1 != nil {
+				return 0, err1
+			}
+		}
+		return len(resp.RolePermissions), enc.flush()
+	})
+	return h.finishExport(enc, req, err)
+}
+
+func (h *ExportHandler) ExportAuditRecords(ctx *ginx.Context, req ExportReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.prepare(ctx, req, sess, domain.AuditLogTable); err != nil {
+		return ginx.Result{}, err
+	}
+	enc := newExportEncoder(ctx.Context, req, domain.AuditLogTable.String(), auditRecordCSVHeader)
+	err := h.audits.Scan(ctx, req.BizID, req.StartTime, req.EndTime, func(records []domain.AuditRecord) error {
+		for i := range records {
+			if err := enc.encode(toAuditRecordVO(records[i])); err != nil {
+				return err
+			}
+		}
+		return enc.flush()
+	})
+	return h.finishExport(enc, req, err)
+}
+
+// prepare 校验导出参数和读权限，返回业务管理员身份的 ctx
+func (h *ExportHandler) prepare(ctx *ginx.Context, req ExportReq, sess session.Session, table domain.SystemTableResource) (context.Context, error) {
+	if req.Format != exportFormatCSV && req.Format != exportFormatJSONL {
+		return nil, fmt.Errorf("不支持的导出格式: %s", req.Format)
+	}
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return nil, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, table, domain.PermissionActionRead)
+	if err != nil {
+		return nil, err
+	}
+	return businessAdminCtx, nil
+}
+
+// finishExport 结束导出
+// 响应头已经发出，中途出错只能记录日志，客户端会收到一个不完整的文件
+// gzip 格式下不会写入文件尾，解压时能够发现文件不完整
+func (h *BaseHandler) finishExport(enc *exportEncoder, req ExportReq, err error) (ginx.Result, error) {
+	if err == nil {
+		err = enc.close()
+	}
+	if err != nil {
+		h.logger.Error("导出失败",
+			elog.FieldErr(err),
+			elog.Int64("bizId", req.BizID),
+			elog.String("format", req.Format))
+	}
+	return ginx.Result{}, ginx.ErrNoResponse
+}
+
+// overlaps 判断授权的生效时间段和查询时间段是否有交集，0 表示不限制
+func overlaps(start, end, from, to int64) bool {
+	return (to == 0 || start == 0 || start <= to) && (from == 0 || end == 0 || end >= from)
+}
+
+// csvRecorder 可以导出为 CSV 的一行
+type csvRecorder interface {
+	csvRecord() []string
+}
+
+type exportEncoder struct {
+	w       io.Writer
+	gz      *gzip.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher http.Flusher
+	header  []string
+	wrote   bool
+}
+
+func newExportEncoder(ctx *gin.Context, req ExportReq, name string, header []string) *exportEncoder {
+	filename := fmt.Sprintf("%s-%d.%s", name, req.BizID, req.Format)
+	contentType := "text/csv; charset=utf-8"
+	if req.Format == exportFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	enc := &exportEncoder{w: ctx.Writer, flusher: ctx.Writer, header: header}
+	if req.Gzip {
+		filename += ".gz"
+		contentType = "application/gzip"
+		enc.gz = gzip.NewWriter(ctx.Writer)
+		enc.w = enc.gz
+	}
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+	if req.Format == exportFormatCSV {
+		enc.csv = csv.NewWriter(enc.w)
+	} else {
+		enc.json = json.NewEncoder(enc.w)
+	}
+	return enc
+}
+
+func (e *exportEncoder) encode(row csvRecorder) error {
+	if e.json != nil {
+		return e.json.Encode(row)
+	}
+	if !e.wrote {
+		e.wrote = true
+		if err := e.csv.Write(e.header); err != nil {
+			return err
+		}
+	}
+	return e.csv.Write(csvEscape(row.csvRecord()))
+}
+
+// csvEscape 角色、权限和资源的名称由用户填写，以公式字符开头的单元格加上单引号，
+// 避免导出的文件用表格软件打开时被当作公式执行。整数不是公式，保持原样
+func csvEscape(record []string) []string {
+	for i, cell := range record {
+		if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			continue
+		}
+		if _, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			continue
+		}
+		record[i] = "'" + cell
+	}
+	return record
+}
+
+// flush 把已经编码的数据推送给客户端
+func (e *exportEncoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.gz != nil {
+		if err := e.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+func (e *exportEncoder) close() error {
+	if e.csv != nil && !e.wrote {
+		// 没有数据也输出表头
+		e.wrote = true
+		if err := e.csv.Write(e.header); err != nil {
+			return err
+		}
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if e.gz != nil {
+		return e.gz.Close()
+	}
+	return nil
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func (r UserRole) csvRecord() []string {
+	return []string{
+		formatInt(r.ID), formatInt(r.BizID), formatInt(r.UserID), formatInt(r.Role.ID), r.Role.Name,
+		formatInt(r.StartTime), formatInt(r.EndTime),
+	}
+}
+
+func (p UserPermission) csvRecord() []string {
+	return []string{
+		formatInt(p.ID), formatInt(p.BizID), formatInt(p.UserID), formatInt(p.Permission.ID), p.Permission.Name,
+		p.Permission.Action, p.Effect, formatInt(p.StartTime), formatInt(p.EndTime),
+	}
+}
+
+func (p RolePermission) csvRecord() []string {
+	return []string{
+		formatInt(p.ID), formatInt(p.BizID), formatInt(p.Role.ID), p.Role.Name, formatInt(p.Permission.ID),
+		p.Permission.ResourceType, p.Permission.ResourceKey, p.Permission.Action,
+	}
+}
+
+func (r AuditRecord) csvRecord() []string {
+	return []string{
+		r.ID, formatInt(r.BizID), formatInt(r.ActorUID), r.Route, r.Table, formatInt(r.TargetID),
+		formatInt(r.TargetUserID), r.Outcome, r.Error, r.ClientIP, formatInt(r.Ctime), r.Payload, r.Result,
+		formatInt(r.Seq), r.Hash,
+	}
+}
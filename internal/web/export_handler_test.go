@@ -0,0 +1,32 @@
+package web
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCSVEscape(t *testing.T) {
+	testCases := []struct {
+		name string
+		cell string
+		want string
+	}{
+		{name: "普通文本", cell: "viewer", want: "viewer"},
+		{name: "空", cell: "", want: ""},
+		{name: "公式", cell: "=HYPERLINK(\"http://x\")", want: "'=HYPERLINK(\"http://x\")"},
+		{name: "加号", cell: "+1+1", want: "'+1+1"},
+		{name: "减号", cell: "-2+3", want: "'-2+3"},
+		{name: "at", cell: "@SUM(A1)", want: "'@SUM(A1)"},
+		{name: "制表符", cell: "\t=1", want: "'\t=1"},
+		{name: "回车", cell: "\r=1", want: "'\r=1"},
+		{name: "负数保持原样", cell: "-1", want: "-1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := csvEscape([]string{tc.cell})
+			if !slices.Equal(got, []string{tc.want}) {
+				t.Fatalf("csvEscape(%q) = %q, want %q", tc.cell, got[0], tc.want)
+			}
+		})
+	}
+}
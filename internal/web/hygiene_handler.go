@@ -0,0 +1,110 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// HygieneHandler 找出权限模型中没有用到、重复和过期的对象，并且按照选中的问题清理
+type HygieneHandler struct {
+	*BaseHandler
+}
+
+func NewHygieneHandler(handler *BaseHandler) *HygieneHandler {
+	return &HygieneHandler{BaseHandler: handler}
+}
+
+func (h *HygieneHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/hygiene/report", ginx.BS[HygieneReq](h.Report))
+	server.POST("/hygiene/apply", ginx.BS(audited(h.audits, domain.BusinessConfigTable, h.Apply)))
+}
+
+// Report 检查业务的权限模型，每个问题都带上建议的清理方式，需要全部业务表的读权限
+func (h *HygieneHandler) Report(ctx *ginx.Context, req HygieneReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	at := time.Now().UnixMilli()
+	findings := model.Hygiene(at)
+	res := HygieneReport{At: at, Counts: make(map[string]int)}
+	res.Findings = slice.Map(findings, func(_ int, src domain.HygieneFinding) HygieneFinding {
+		res.Counts[src.Kind.String()]++
+		return HygieneFinding{
+			Key:        src.Key(),
+			Kind:       src.Kind.String(),
+			Ref:        src.Ref,
+			Detail:     src.Detail,
+			Suggestion: src.Suggestion,
+		}
+	})
+	return ginx.Result{Data: res}, nil
+}
+
+// Apply 重新检查业务的权限模型，按照建议清理选中的问题，需要全部业务表的写权限
+// 和快照回滚一样先用 dryRun 预览，再带上预览返回的摘要执行
+func (h *HygieneHandler) Apply(ctx *ginx.Context, req HygieneApplyReq, sess session.Session) (ginx.Result, error) {
+	if len(req.Keys) == 0 {
+		return ginx.Result{}, errors.New("要清理的问题不能为空")
+	}
+	action := domain.PermissionActionWrite
+	if req.DryRun {
+		action = domain.PermissionActionRead
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, action)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	live, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	findings := live.Hygiene(time.Now().UnixMilli())
+	selected := slices.DeleteFunc(findings, func(f domain.HygieneFinding) bool {
+		return !slices.Contains(req.Keys, f.Key())
+	})
+	var missing []string
+	for _, key := range req.Keys {
+		if !slices.ContainsFunc(selected, func(f domain.HygieneFinding) bool { return f.Key() == key }) {
+			missing = append(missing, fmt.Sprintf("问题 %s 不存在或者已经清理", key))
+		}
+	}
+	if len(missing) > 0 {
+		return ginx.Result{Msg: "选中的问题已经变化，请重新检查", Data: HygieneApplyResult{
+			BizModelPlan: BizModelPlan{Problems: missing},
+			DryRun:       req.DryRun,
+		}}, &domain.ModelImportError{Problems: missing}
+	}
+	plan := domain.CleanupPlan(selected)
+	if i := slices.IndexFunc(plan.Changes, live.Protected); i >= 0 {
+		return ginx.Result{}, fmt.Errorf("%s 由管理后台维护，不能清理", plan.Changes[i].Ref)
+	}
+	res := HygieneApplyResult{BizModelPlan: toBizModelPlanVO(plan), DryRun: req.DryRun}
+	if req.DryRun {
+		return ginx.Result{Data: res}, nil
+	}
+	if req.Fingerprint == "" {
+		return ginx.Result{Msg: "请先用 dryRun 预览，再带上返回的计划摘要执行", Data: res}, errFingerprintRequired
+	}
+	if req.Fingerprint != res.Fingerprint {
+		return ginx.Result{Msg: "业务当前状态在预览之后发生了变化，请重新预览", Data: res}, errors.New("计划摘要不一致")
+	}
+	// 清理只有删除，不需要期望的模型
+	res.Applied, err = h.applyModel(businessAdminCtx, req.BizID, domain.RBACModel{}, live, plan)
+	if err != nil {
+		return ginx.Result{Msg: "清理中途失败，已经执行的删除不会撤销", Data: res}, err
+	}
+	return ginx.Result{Data: res}, nil
+}
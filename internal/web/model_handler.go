@@ -0,0 +1,777 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// bizModelVersion 模型文档的格式版本
+	bizModelVersion    = 1
+	bizModelFormatJSON = "json"
+	bizModelFormatYAML = "yaml"
+)
+
+// errFingerprintRequired 声明式 apply、快照回滚和清理都必须先预览，带上预览返回的计划摘要执行
+var errFingerprintRequired = errors.New("缺少计划摘要")
+
+// ModelHandler 导出和导入业务完整的权限模型
+type ModelHandler struct {
+	*BaseHandler
+}
+
+func NewModelHandler(handler *BaseHandler) *ModelHandler {
+	return &ModelHandler{BaseHandler: handler}
+}
+
+func (h *ModelHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/biz/model/export", ginx.BS[BizModelExportReq](h.Export))
+	server.POST("/biz/model/import", ginx.BS(audited(h.audits, domain.BusinessConfigTable, h.Import)))
+	server.POST("/biz/model/plan", ginx.BS[BizModelPlanReq](h.Plan))
+	server.POST("/biz/model/apply", ginx.BS(audited(h.audits, domain.BusinessConfigTable, h.Apply)))
+}
+
+// Export 以 JSON 或者 YAML 文件的形式导出业务的权限模型，需要全部业务表的读权限
+func (h *ModelHandler) Export(ctx *ginx.Context, req BizModelExportReq, sess session.Session) (ginx.Result, error) {
+	if req.Format == "" {
+		req.Format = bizModelFormatJSON
+	}
+	if req.Format != bizModelFormatJSON && req.Format != bizModelFormatYAML {
+		return ginx.Result{}, fmt.Errorf("不支持的导出格式: %s", req.Format)
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	live, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model := live.Business()
+	if req.Declarative {
+		model.UserRoles, model.UserPermissions = nil, nil
+	}
+	doc := toBizModelVO(model)
+	doc.BizID = req.BizID
+	doc.ExportedAt = time.Now().UnixMilli()
+
+	var (
+		data        []byte
+		contentType = "application/json"
+	)
+	if req.Format == bizModelFormatYAML {
+		contentType = "application/yaml"
+		data, err = yaml.Marshal(doc)
+	} else {
+		data, err = json.MarshalIndent(doc, "", "  ")
+	}
+	if err != nil {
+		return ginx.Result{}, fmt.Errorf("序列化权限模型失败: %w", err)
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("biz-model-%d.%s", req.BizID, req.Format)))
+	ctx.Data(http.StatusOK, contentType, data)
+	return ginx.Result{}, ginx.ErrNoResponse
+}
+
+// Import 导入权限模型，需要全部业务表的写权限
+// 先完整校验并且生成导入计划，校验不通过或者 fail 策略下有冲突时不写入任何数据
+// 权限平台没有事务，执行中途失败时已经执行的变更不会回滚，按照相同的文档重新导入即可继续
+func (h *ModelHandler) Import(ctx *ginx.Context, req BizModelImportReq, sess session.Session) (ginx.Result, error) {
+	strategy := domain.ModelConflictStrategy(req.Strategy)
+	if strategy == "" {
+		strategy = domain.ModelConflictFail
+	}
+	if !strategy.Valid() {
+		return ginx.Result{}, fmt.Errorf("不支持的冲突处理方式: %s", req.Strategy)
+	}
+	doc, err := parseBizModel(req.Model, req.Document)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	live, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	existing := live.Business()
+
+	model := toRBACModel(doc)
+	res := BizModelImportResult{DryRun: req.DryRun}
+	problems := model.Validate(existing)
+	if len(problems) > 0 {
+		res.Problems = problems
+		return ginx.Result{Msg: "权限模型校验失败", Data: res}, &domain.ModelImportError{Problems: problems}
+	}
+	plan := model.Plan(existing, strategy)
+	res.Changes = slice.Map(plan.Changes, func(_ int, src domain.ModelChange) BizModelChange {
+		return BizModelChange{Table: src.Table.String(), Op: src.Op.String(), Ref: src.Ref}
+	})
+	res.Counts = make(map[string]int)
+	for op, n := range plan.Count() {
+		res.Counts[op.String()] = n
+	}
+	if len(plan.Conflicts) > 0 {
+		res.Conflicts = plan.Conflicts
+		return ginx.Result{Msg: "权限模型存在冲突", Data: res}, &domain.ModelImportError{Problems: plan.Conflicts}
+	}
+	// 导入不能绕过审批，需要审批的授权先走审批流程
+	res.Problems, err = h.checkModelApprovals(ctx, req.BizID, model, existing, plan)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if len(res.Problems) > 0 {
+		return ginx.Result{Msg: "权限模型包含需要审批的授权", Data: res}, &domain.ModelImportError{Problems: res.Problems}
+	}
+	if req.DryRun {
+		return ginx.Result{Data: res}, nil
+	}
+	res.Applied, err = h.applyModel(businessAdminCtx, req.BizID, model, existing, plan)
+	if err != nil {
+		return ginx.Result{Msg: "导入中途失败，已经执行的变更不会回滚", Data: res}, err
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+// Plan 把声明式文件当作期望状态，计算让业务当前状态和文件一致需要的新建、更新和删除，不写入任何数据
+func (h *ModelHandler) Plan(ctx *ginx.Context, req BizModelPlanReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	_, _, plan, err := h.diffModel(businessAdminCtx, req)
+	if err != nil {
+		return h.diffFailed(err)
+	}
+	return ginx.Result{Data: toBizModelPlanVO(plan)}, nil
+}
+
+// Apply 重新计算计划并且按照依赖顺序执行，遇到错误立即停止
+// 必须传入 plan 返回的摘要，当前状态或者文件在 plan 之后发生了变化就拒绝执行，保证执行的就是评审过的计划
+func (h *ModelHandler) Apply(ctx *ginx.Context, req BizModelPlanReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	desired, live, plan, err := h.diffModel(businessAdminCtx, req)
+	if err != nil {
+		return h.diffFailed(err)
+	}
+	res := toBizModelPlanVO(plan)
+	if req.Fingerprint == "" {
+		return ginx.Result{Msg: "请先 plan，再带上返回的计划摘要执行", Data: res}, errFingerprintRequired
+	}
+	if req.Fingerprint != res.Fingerprint {
+		return ginx.Result{Msg: "业务当前状态在 plan 之后发生了变化，请重新 plan", Data: res}, errors.New("计划摘要不一致")
+	}
+	res.Applied, err = h.applyModel(businessAdminCtx, req.BizID, desired, live, plan)
+	if err != nil {
+		return ginx.Result{Msg: "执行中途失败，已经执行的变更不会回滚", Data: res}, err
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+// diffModel 解析并且校验声明式文件，返回期望状态、当前状态和计划
+func (h *ModelHandler) diffModel(ctx context.Context, req BizModelPlanReq) (domain.RBACModel, domain.RBACModel, domain.ModelImportPlan, error) {
+	doc, err := parseBizModel(req.Model, req.Document)
+	if err != nil {
+		return domain.RBACModel{}, domain.RBACModel{}, domain.ModelImportPlan{}, err
+	}
+	if len(doc.UserRoles) > 0 || len(doc.UserPermissions) > 0 {
+		return domain.RBACModel{}, domain.RBACModel{}, domain.ModelImportPlan{},
+			errors.New("声明式文件不管理用户授权，请去掉 userRoles 和 userPermissions")
+	}
+	desired := toRBACModel(doc)
+	// 文件中没有的对象都会被删除，所以引用只能指向文件本身
+	if problems := desired.Validate(domain.RBACModel{}); len(problems) > 0 {
+		return domain.RBACModel{}, domain.RBACModel{}, domain.ModelImportPlan{}, &domain.ModelImportError{Problems: problems}
+	}
+	live, err := h.loadModel(ctx, req.BizID)
+	if err != nil {
+		return domain.RBACModel{}, domain.RBACModel{}, domain.ModelImportPlan{}, err
+	}
+	live = live.Business()
+	return desired, live, desired.Diff(live), nil
+}
+
+// diffFailed 校验失败时把全部问题返回给调用方
+func (h *ModelHandler) diffFailed(err error) (ginx.Result, error) {
+	var importErr *domain.ModelImportError
+	if errors.As(err, &importErr) {
+		return ginx.Result{Msg: "声明式文件校验失败", Data: BizModelPlan{Problems: importErr.Problems}}, err
+	}
+	return ginx.Result{}, err
+}
+
+// prepareModel 权限模型涉及全部业务表，需要每张表的对应权限
+func (h *BaseHandler) prepareModel(ctx context.Context, bizID, uid int64, action domain.PermissionActionType) (context.Context, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, bizID)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range domain.BusinessTables {
+		if err = h.checkBusinessPermission(businessAdminCtx, bizID, uid, table, action); err != nil {
+			return nil, err
+		}
+	}
+	return businessAdminCtx, nil
+}
+
+func parseBizModel(model *BizModel, document string) (BizModel, error) {
+	var doc BizModel
+	switch {
+	case model != nil:
+		doc = *model
+	case document != "":
+		// JSON 是 YAML 的子集，两种格式都可以直接按照 YAML 解析
+		if err := yaml.Unmarshal([]byte(document), &doc); err != nil {
+			return BizModel{}, fmt.Errorf("解析权限模型失败: %w", err)
+		}
+	default:
+		return BizModel{}, errors.New("权限模型不能为空")
+	}
+	if doc.Version != 0 && doc.Version != bizModelVersion {
+		return BizModel{}, fmt.Errorf("不支持的权限模型版本: %d", doc.Version)
+	}
+	return doc, nil
+}
+
+// checkModelApprovals 新增或者修改的用户授权命中审批策略时不能导入
+// 导入时新建的角色和权限还没有ID，不可能命中审批策略
+func (h *BaseHandler) checkModelApprovals(ctx context.Context, bizID int64, model, existing domain.RBACModel, plan domain.ModelImportPlan) ([]string, error) {
+	roleIDs := make(map[string]int64, len(existing.Roles))
+	for _, r := range existing.Roles {
+		roleIDs[r.Ref()] = r.ID
+	}
+	permissionIDs := make(map[string]int64, len(existing.Permissions))
+	for _, p := range existing.Permissions {
+		permissionIDs[p.Ref()] = p.ID
+	}
+	var problems []string
+	for _, c := range plan.Changes {
+		if c.Op != domain.ModelChangeCreate && c.Op != domain.ModelChangeUpdate {
+			continue
+		}
+		var (
+			kind     domain.ChangeRequestKind
+			targetID int64
+		)
+		switch c.Table {
+		case domain.UserRoleTable:
+			kind, targetID = domain.ChangeRequestGrantUserRole, roleIDs[model.UserRoles[c.Index].Role]
+		case domain.UserPermissionTable:
+			kind, targetID = domain.ChangeRequestGrantUserPermission, permissionIDs[model.UserPermissions[c.Index].PermissionRef()]
+		}
+		if targetID == 0 {
+			continue
+		}
+		_, found, err := h.approvals.MatchPolicy(ctx, bizID, kind, targetID)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			problems = append(problems, fmt.Sprintf("%s %s 需要审批，请先提交审批", c.Table, c.Ref))
+		}
+	}
+	return problems, nil
+}
+
+// loadModel 逐页读取业务当前完整的权限模型，包括管理后台的账号角色和系统资源
+// 导出、导入、声明式管理和回滚使用 Business 部分，分析类的功能使用完整的模型
+func (h *BaseHandler) loadModel(ctx context.Context, bizID int64) (domain.RBACModel, error) {
+	var model domain.RBACModel
+	err := h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListResources(ctx, &permissionv1.ListResourcesRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return 0, err
+		}
+		for _, src := range resp.Resources {
+			model.Resources = append(model.Resources, domain.ModelResource{
+				ID:          src.Id,
+				Type:        src.Type,
+				Key:         src.Key,
+				Name:        src.Name,
+				Description: src.Description,
+				Metadata:    src.Metadata,
+			})
+		}
+		return len(resp.Resources), nil
+	})
+	if err != nil {
+		return domain.RBACModel{}, err
+	}
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListPermissions(ctx, &permissionv1.ListPermissionsRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return 0, err
+		}
+		for _, src := range resp.Permissions {
+			// 管理后台只使用单个动作的权限，多个动作的权限拆开后引用标识会重复
+			for _, action := range src.Actions {
+				model.Permissions = append(model.Permissions, domain.ModelPermission{
+					ID:           src.Id,
+					Name:         src.Name,
+					Description:  src.Description,
+					ResourceType: src.ResourceType,
+					ResourceKey:  src.ResourceKey,
+					Action:       action,
+					Metadata:     src.Metadata,
+				})
+			}
+		}
+		return len(resp.Permissions), nil
+	})
+	if err != nil {
+		return domain.RBACModel{}, err
+	}
+	// 按照类型分别读取业务角色和账号角色
+	for _, typ := range []string{domain.DefaultBusinessRoleType, domain.DefaultAccountRoleType} {
+		err = h.paginate(func(offset, limit int32) (int, error) {
+			resp, err := h.rbacSvc.ListRoles(ctx, &permissionv1.ListRolesRequest{BizId: bizID, Type: typ, Offset: offset, Limit: limit})
+			if err != nil {
+				return 0, err
+			}
+			for _, src := range resp.Roles {
+				model.Roles = append(model.Roles, domain.ModelRole{
+					ID:          src.Id,
+					Name:        src.Name,
+					Description: src.Description,
+					Metadata:    src.Metadata,
+					Type:        src.Type,
+				})
+			}
+			return len(resp.Roles), nil
+		})
+		if err != nil {
+			return domain.RBACModel{}, err
+		}
+	}
+	model.RoleInclusions, err = h.loadRoleInclusions(ctx, bizID)
+	if err != nil {
+		return domain.RBACModel{}, err
+	}
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListRolePermissions(ctx, &permissionv1.ListRolePermissionsRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return 0, err
+		}
+		for _, src := range resp.RolePermissions {
+			model.RolePermissions = append(model.RolePermissions, domain.ModelRolePermission{
+				ID:           src.Id,
+				Role:         src.RoleName,
+				ResourceType: src.ResourceType,
+				ResourceKey:  src.ResourceKey,
+				Action:       src.PermissionAction,
+			})
+		}
+		return len(resp.RolePermissions), nil
+	})
+	if err != nil {
+		return domain.RBACModel{}, err
+	}
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListUserRoles(ctx, &permissionv1.ListUserRolesRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return 0, err
+		}
+		for _, src := range resp.UserRoles {
+			model.UserRoles = append(model.UserRoles, domain.ModelUserRole{
+				ID:        src.Id,
+				UserID:    src.UserId,
+				Role:      src.RoleName,
+				StartTime: src.StartTime,
+				EndTime:   src.EndTime,
+			})
+		}
+		return len(resp.UserRoles), nil
+	})
+	if err != nil {
+		return domain.RBACModel{}, err
+	}
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListUserPermissions(ctx, &permissionv1.ListUserPermissionsRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return 0, err
+		}
+		for _, src := range resp.UserPermissions {
+			model.UserPermissions = append(model.UserPermissions, domain.ModelUserPermission{
+				ID:           src.Id,
+				UserID:       src.UserId,
+				ResourceType: src.ResourceType,
+				ResourceKey:  src.ResourceKey,
+				Action:       src.PermissionAction,
+				Effect:       domain.Effect(src.Effect),
+				StartTime:    src.StartTime,
+				EndTime:      src.EndTime,
+			})
+		}
+		return len(resp.UserPermissions), nil
+	})
+	if err != nil {
+		return domain.RBACModel{}, err
+	}
+	return model, nil
+}
+
+// loadRoleInclusions 逐页读取全部角色之间的包含关系
+func (h *BaseHandler) loadRoleInclusions(ctx context.Context, bizID int64) ([]domain.ModelRoleInclusion, error) {
+	var res []domain.ModelRoleInclusion
+	err := h.paginate(func(offset, limit int32) (int, error) {
+		resp, err := h.rbacSvc.ListRoleInclusions(ctx, &permissionv1.ListRoleInclusionsRequest{BizId: bizID, Offset: offset, Limit: limit})
+		if err != nil {
+			return 0, err
+		}
+		for _, src := range resp.RoleInclusions {
+			res = append(res, domain.ModelRoleInclusion{
+				ID:        src.Id,
+				Including: src.IncludingRoleName,
+				Included:  src.IncludedRoleName,
+			})
+		}
+		return len(resp.RoleInclusions), nil
+	})
+	return res, err
+}
+
+// applyModel 按照计划逐个执行新建、更新和删除，复用单个对象的增删改和授权方法，
+// 所以每个变更都会推送权限变更事件。职责分离约束在执行前对整个计划检查一次，授权时不再逐个读取权限模型。返回已经执行的数量
+// 用户授权没有更新接口，更新时先授予新的再回收旧的，授予失败时旧的授权仍然有效
+// 多个动作的权限在模型中拆成了每个动作一行，更新和删除其中一行时保留其他动作
+func (h *BaseHandler) applyModel(ctx context.Context, bizID int64, model, existing domain.RBACModel, plan domain.ModelImportPlan) (int, error) {
+	resourceIDs := make(map[string]int64, len(existing.Resources))
+	for _, r := range existing.Resources {
+		resourceIDs[r.Ref()] = r.ID
+	}
+	permissions := make(map[string]Permission, len(existing.Permissions))
+	// actions 权限平台上每个权限当前的全部动作
+	actions := make(map[int64][]string)
+	for _, p := range existing.Permissions {
+		permissions[p.Ref()] = toModelPermissionVO(bizID, p)
+		actions[p.ID] = append(actions[p.ID], p.Action)
+	}
+	roles := make(map[string]Role, len(existing.Roles))
+	for _, r := range existing.Roles {
+		roles[r.Ref()] = Role{ID: r.ID, BizID: bizID, Name: r.Name}
+	}
+	if err := h.checkModelPlanSoD(ctx, bizID, model, plan); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, c := range plan.Changes {
+		if c.Op == domain.ModelChangeSkip || c.Op == domain.ModelChangeUnchanged {
+			continue
+		}
+		if c.Op == domain.ModelChangeDelete && c.Table == domain.PermissionTable {
+			p := existing.Permissions[c.Index]
+			actions[p.ID] = slices.DeleteFunc(actions[p.ID], func(a string) bool { return a == p.Action })
+			if len(actions[p.ID]) > 0 {
+				err := h.updateModelPermission(ctx, PermissionReq{BizID: bizID, Permission: toModelPermissionVO(bizID, p)}, actions[p.ID])
+				if err != nil {
+					return applied, fmt.Errorf("%s %s %s 失败: %w", c.Op, c.Table, c.Ref, err)
+				}
+				applied++
+				continue
+			}
+		}
+		if c.Op == domain.ModelChangeDelete {
+			if err := h.deleteModelObject(ctx, bizID, c); err != nil {
+				return applied, fmt.Errorf("%s %s %s 失败: %w", c.Op, c.Table, c.Ref, err)
+			}
+			applied++
+			continue
+		}
+		var err error
+		switch c.Table {
+		case domain.ResourceTable:
+			r := model.Resources[c.Index]
+			req := ResourceReq{BizID: bizID, Resource: Resource{
+				ID:          c.ExistingID,
+				BizID:       bizID,
+				Type:        r.Type,
+				Key:         r.Key,
+				Name:        r.Name,
+				Description: r.Description,
+				Metadata:    r.Metadata,
+			}}
+			if c.Op == domain.ModelChangeCreate {
+				resourceIDs[c.Ref], err = createdID(h.createResource(ctx, req))
+			} else {
+				_, err = h.updateResource(ctx, req)
+			}
+		case domain.PermissionTable:
+			p := model.Permissions[c.Index]
+			p.ID = c.ExistingID
+			vo := toModelPermissionVO(bizID, p)
+			vo.ResourceID = resourceIDs[p.ResourceRef()]
+			req := PermissionReq{BizID: bizID, Permission: vo}
+			if c.Op == domain.ModelChangeCreate {
+				vo.ID, err = createdID(h.createPermission(ctx, req))
+			} else {
+				err = h.updateModelPermission(ctx, req, actions[c.ExistingID])
+			}
+			permissions[c.Ref] = vo
+		case domain.RoleTable:
+			r := model.Roles[c.Index]
+			req := RoleReq{BizID: bizID, Role: Role{
+				ID:          c.ExistingID,
+				BizID:       bizID,
+				Name:        r.Name,
+				Description: r.Description,
+				Metadata:    r.Metadata,
+			}}
+			if c.Op == domain.ModelChangeCreate {
+				req.Role.ID, err = createdID(h.createRole(ctx, req))
+			} else {
+				_, err = h.updateRole(ctx, req)
+			}
+			roles[c.Ref] = Role{ID: req.Role.ID, BizID: bizID, Name: r.Name}
+		case domain.RoleInclusionTable:
+			r := model.RoleInclusions[c.Index]
+			_, err = h.createRoleInclusion(ctx, RoleInclusionReq{BizID: bizID, RoleInclusion: RoleInclusion{
+				BizID:         bizID,
+				IncludingRole: roles[r.Including],
+				IncludedRole:  roles[r.Included],
+			}})
+		case domain.RolePermissionTable:
+			r := model.RolePermissions[c.Index]
+			_, err = h.doGrantRolePermission(ctx, RolePermissionReq{BizID: bizID, RolePermission: RolePermission{
+				BizID:      bizID,
+				Role:       roles[r.Role],
+				Permission: permissions[r.PermissionRef()],
+			}})
+		case domain.UserRoleTable:
+			r := model.UserRoles[c.Index]
+			_, err = h.doGrantUserRole(ctx, UserRoleReq{BizID: bizID, UserRole: UserRole{
+				BizID:     bizID,
+				UserID:    r.UserID,
+				Role:      roles[r.Role],
+				StartTime: r.StartTime,
+				EndTime:   r.EndTime,
+			}})
+			if err == nil && c.Op == domain.ModelChangeUpdate {
+				_, err = h.revokeUserRole(ctx, UserRoleReq{BizID: bizID, UserRole: UserRole{ID: c.ExistingID, UserID: r.UserID}})
+			}
+		case domain.UserPermissionTable:
+			r := model.UserPermissions[c.Index]
+			_, err = h.doGrantUserPermission(ctx, UserPermissionReq{BizID: bizID, UserPermission: UserPermission{
+				BizID:      bizID,
+				UserID:     r.UserID,
+				Permission: permissions[r.PermissionRef()],
+				StartTime:  r.StartTime,
+				EndTime:    r.EndTime,
+				Effect:     r.Effect.String(),
+			}})
+			if err == nil && c.Op == domain.ModelChangeUpdate {
+				_, err = h.revokeUserPermission(ctx, UserPermissionReq{BizID: bizID, UserPermission: UserPermission{ID: c.ExistingID, UserID: r.UserID}})
+			}
+		}
+		if err != nil {
+			return applied, fmt.Errorf("%s %s %s 失败: %w", c.Op, c.Table, c.Ref, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// checkModelPlanSoD 把计划新增的包含关系和授权一起加到当前的权限模型上检查职责分离约束
+// 计划中的对象都用名称引用，还没有创建的角色也能检查。删除只会减少拥有的对象，不需要检查
+func (h *BaseHandler) checkModelPlanSoD(ctx context.Context, bizID int64, model domain.RBACModel, plan domain.ModelImportPlan) error {
+	grants := slices.ContainsFunc(plan.Changes, func(c domain.ModelChange) bool {
+		return c.Op == domain.ModelChangeCreate || c.Op == domain.ModelChangeUpdate
+	})
+	if !grants {
+		return nil
+	}
+	return h.sod.Check(ctx, bizID, h.loadModel, func(after *domain.RBACModel) error {
+		for _, c := range plan.Changes {
+			if c.Op != domain.ModelChangeCreate && c.Op != domain.ModelChangeUpdate {
+				continue
+			}
+			switch c.Table {
+			case domain.RoleInclusionTable:
+				after.RoleInclusions = append(after.RoleInclusions, model.RoleInclusions[c.Index])
+			case domain.RolePermissionTable:
+				after.RolePermissions = append(after.RolePermissions, model.RolePermissions[c.Index])
+			case domain.UserRoleTable:
+				after.UserRoles = append(after.UserRoles, model.UserRoles[c.Index])
+			case domain.UserPermissionTable:
+				after.UserPermissions = append(after.UserPermissions, model.UserPermissions[c.Index])
+			}
+		}
+		return nil
+	})
+}
+
+// updateModelPermission 更新权限并且保留权限在平台上的全部动作，actions 为空时只有 req 中的动作
+func (h *BaseHandler) updateModelPermission(ctx context.Context, req PermissionReq, actions []string) error {
+	pb := h.toPermissionPB(req)
+	if len(actions) > 0 {
+		pb.Actions = slices.Clone(actions)
+	}
+	if _, err := h.rbacSvc.UpdatePermission(ctx, &permissionv1.UpdatePermissionRequest{Permission: pb}); err != nil {
+		return err
+	}
+	h.publishChange(ctx, req.BizID, domain.PermissionTable, permission.ChangeActionUpdate, req.Permission)
+	return nil
+}
+
+func (h *BaseHandler) deleteModelObject(ctx context.Context, bizID int64, c domain.ModelChange) error {
+	var err error
+	switch c.Table {
+	case domain.UserPermissionTable:
+		_, err = h.revokeUserPermission(ctx, UserPermissionReq{BizID: bizID, UserPermission: UserPermission{ID: c.ExistingID}})
+	case domain.UserRoleTable:
+		_, err = h.revokeUserRole(ctx, UserRoleReq{BizID: bizID, UserRole: UserRole{ID: c.ExistingID}})
+	case domain.RolePermissionTable:
+		_, err = h.revokeRolePermission(ctx, RolePermissionReq{BizID: bizID, RolePermission: RolePermission{ID: c.ExistingID}})
+	case domain.RoleInclusionTable:
+		_, err = h.deleteRoleInclusion(ctx, RoleInclusionReq{BizID: bizID, RoleInclusion: RoleInclusion{ID: c.ExistingID}})
+	case domain.RoleTable:
+		_, err = h.deleteRole(ctx, RoleReq{BizID: bizID, Role: Role{ID: c.ExistingID}})
+	case domain.PermissionTable:
+		_, err = h.deletePermission(ctx, PermissionReq{BizID: bizID, Permission: Permission{ID: c.ExistingID}})
+	case domain.ResourceTable:
+		_, err = h.deleteResource(ctx, ResourceReq{BizID: bizID, Resource: Resource{ID: c.ExistingID}})
+	default:
+		err = fmt.Errorf("不支持删除 %s", c.Table)
+	}
+	return err
+}
+
+func toBizModelPlanVO(plan domain.ModelImportPlan) BizModelPlan {
+	res := BizModelPlan{
+		Fingerprint: plan.Fingerprint(),
+		Warnings:    plan.Warnings,
+		Counts:      make(map[string]int),
+	}
+	for _, c := range plan.Changes {
+		res.Counts[c.Op.String()]++
+		if c.Op == domain.ModelChangeUnchanged {
+			continue
+		}
+		res.Changes = append(res.Changes, BizModelChange{Table: c.Table.String(), Op: c.Op.String(), Ref: c.Ref})
+	}
+	return res
+}
+
+// createdID 新建方法返回的是新实体的ID
+func createdID(res ginx.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.Data.(int64)
+	return id, nil
+}
+
+func toModelPermissionVO(bizID int64, src domain.ModelPermission) Permission {
+	return Permission{
+		ID:           src.ID,
+		BizID:        bizID,
+		Name:         src.Name,
+		Description:  src.Description,
+		ResourceType: src.ResourceType,
+		ResourceKey:  src.ResourceKey,
+		Action:       src.Action,
+		Metadata:     src.Metadata,
+	}
+}
+
+func toBizModelVO(src domain.RBACModel) BizModel {
+	return BizModel{
+		Version: bizModelVersion,
+		Resources: slice.Map(src.Resources, func(_ int, r domain.ModelResource) BizModelResource {
+			return BizModelResource{Type: r.Type, Key: r.Key, Name: r.Name, Description: r.Description, Metadata: r.Metadata}
+		}),
+		Permissions: slice.Map(src.Permissions, func(_ int, p domain.ModelPermission) BizModelPermission {
+			return BizModelPermission{
+				Name:         p.Name,
+				Description:  p.Description,
+				ResourceType: p.ResourceType,
+				ResourceKey:  p.ResourceKey,
+				Action:       p.Action,
+				Metadata:     p.Metadata,
+			}
+		}),
+		Roles: slice.Map(src.Roles, func(_ int, r domain.ModelRole) BizModelRole {
+			return BizModelRole{Name: r.Name, Description: r.Description, Metadata: r.Metadata}
+		}),
+		RoleInclusions: slice.Map(src.RoleInclusions, func(_ int, r domain.ModelRoleInclusion) BizModelRoleInclusion {
+			return BizModelRoleInclusion{Including: r.Including, Included: r.Included}
+		}),
+		RolePermissions: slice.Map(src.RolePermissions, func(_ int, r domain.ModelRolePermission) BizModelRolePermission {
+			return BizModelRolePermission{Role: r.Role, ResourceType: r.ResourceType, ResourceKey: r.ResourceKey, Action: r.Action}
+		}),
+		UserRoles: slice.Map(src.UserRoles, func(_ int, r domain.ModelUserRole) BizModelUserRole {
+			return BizModelUserRole{UserID: r.UserID, Role: r.Role, StartTime: r.StartTime, EndTime: r.EndTime}
+		}),
+		UserPermissions: slice.Map(src.UserPermissions, func(_ int, r domain.ModelUserPermission) BizModelUserPermission {
+			return BizModelUserPermission{
+				UserID:       r.UserID,
+				ResourceType: r.ResourceType,
+				ResourceKey:  r.ResourceKey,
+				Action:       r.Action,
+				Effect:       r.Effect.String(),
+				StartTime:    r.StartTime,
+				EndTime:      r.EndTime,
+			}
+		}),
+	}
+}
+
+func toRBACModel(src BizModel) domain.RBACModel {
+	return domain.RBACModel{
+		Resources: slice.Map(src.Resources, func(_ int, r BizModelResource) domain.ModelResource {
+			return domain.ModelResource{Type: r.Type, Key: r.Key, Name: r.Name, Description: r.Description, Metadata: r.Metadata}
+		}),
+		Permissions: slice.Map(src.Permissions, func(_ int, p BizModelPermission) domain.ModelPermission {
+			return domain.ModelPermission{
+				Name:         p.Name,
+				Description:  p.Description,
+				ResourceType: p.ResourceType,
+				ResourceKey:  p.ResourceKey,
+				Action:       p.Action,
+				Metadata:     p.Metadata,
+			}
+		}),
+		Roles: slice.Map(src.Roles, func(_ int, r BizModelRole) domain.ModelRole {
+			return domain.ModelRole{Name: r.Name, Description: r.Description, Metadata: r.Metadata}
+		}),
+		RoleInclusions: slice.Map(src.RoleInclusions, func(_ int, r BizModelRoleInclusion) domain.ModelRoleInclusion {
+			return domain.ModelRoleInclusion{Including: r.Including, Included: r.Included}
+		}),
+		RolePermissions: slice.Map(src.RolePermissions, func(_ int, r BizModelRolePermission) domain.ModelRolePermission {
+			return domain.ModelRolePermission{Role: r.Role, ResourceType: r.ResourceType, ResourceKey: r.ResourceKey, Action: r.Action}
+		}),
+		UserRoles: slice.Map(src.UserRoles, func(_ int, r BizModelUserRole) domain.ModelUserRole {
+			return domain.ModelUserRole{UserID: r.UserID, Role: r.Role, StartTime: r.StartTime, EndTime: r.EndTime}
+		}),
+		UserPermissions: slice.Map(src.UserPermissions, func(_ int, r BizModelUserPermission) domain.ModelUserPermission {
+			return domain.ModelUserPermission{
+				UserID:       r.UserID,
+				ResourceType: r.ResourceType,
+				ResourceKey:  r.ResourceKey,
+				Action:       r.Action,
+				Effect:       domain.Effect(r.Effect),
+				StartTime:    r.StartTime,
+				EndTime:      r.EndTime,
+			}
+		}),
+	}
+}
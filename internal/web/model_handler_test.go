@@ -0,0 +1,135 @@
+package web
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"google.golang.org/grpc"
+)
+
+// fakeRBACClient 一次返回全部数据的权限平台，只实现读取权限模型需要的列表接口
+type fakeRBACClient struct {
+	permissionv1.RBACServiceClient
+	resources       []*permissionv1.Resource
+	permissions     []*permissionv1.Permission
+	roles           []*permissionv1.Role
+	rolePermissions []*permissionv1.RolePermission
+	userRoles       []*permissionv1.UserRole
+}
+
+func firstPage[T any](rows []T, offset int32) []T {
+	if offset > 0 {
+		return nil
+	}
+	return rows
+}
+
+func (c *fakeRBACClient) ListResources(_ context.Context, in *permissionv1.ListResourcesRequest, _ ...grpc.CallOption) (*permissionv1.ListResourcesResponse, error) {
+	return &permissionv1.ListResourcesResponse{Resources: firstPage(c.resources, in.Offset)}, nil
+}
+
+func (c *fakeRBACClient) ListPermissions(_ context.Context, in *permissionv1.ListPermissionsRequest, _ ...grpc.CallOption) (*permissionv1.ListPermissionsResponse, error) {
+	return &permissionv1.ListPermissionsResponse{Permissions: firstPage(c.permissions, in.Offset)}, nil
+}
+
+func (c *fakeRBACClient) ListRoles(_ context.Context, in *permissionv1.ListRolesRequest, _ ...grpc.CallOption) (*permissionv1.ListRolesResponse, error) {
+	roles := slices.DeleteFunc(slices.Clone(c.roles), func(r *permissionv1.Role) bool {
+		return in.Type != "" && r.Type != in.Type
+	})
+	return &permissionv1.ListRolesResponse{Roles: firstPage(roles, in.Offset)}, nil
+}
+
+func (c *fakeRBACClient) ListRoleInclusions(context.Context, *permissionv1.ListRoleInclusionsRequest, ...grpc.CallOption) (*permissionv1.ListRoleInclusionsResponse, error) {
+	return &permissionv1.ListRoleInclusionsResponse{}, nil
+}
+
+func (c *fakeRBACClient) ListRolePermissions(_ context.Context, in *permissionv1.ListRolePermissionsRequest, _ ...grpc.CallOption) (*permissionv1.ListRolePermissionsResponse, error) {
+	return &permissionv1.ListRolePermissionsResponse{RolePermissions: firstPage(c.rolePermissions, in.Offset)}, nil
+}
+
+func (c *fakeRBACClient) ListUserRoles(_ context.Context, in *permissionv1.ListUserRolesRequest, _ ...grpc.CallOption) (*permissionv1.ListUserRolesResponse, error) {
+	return &permissionv1.ListUserRolesResponse{UserRoles: firstPage(c.userRoles, in.Offset)}, nil
+}
+
+func (c *fakeRBACClient) ListUserPermissions(context.Context, *permissionv1.ListUserPermissionsRequest, ...grpc.CallOption) (*permissionv1.ListUserPermissionsResponse, error) {
+	return &permissionv1.ListUserPermissionsResponse{}, nil
+}
+
+// TestLoadModelAccountRoles 业务管理员通过账号角色拥有系统表的权限，分析类的功能要能看到，导出的业务模型里不能有
+func TestLoadModelAccountRoles(t *testing.T) {
+	const (
+		bizID = int64(7)
+		admin = int64(100)
+		user  = int64(200)
+		at    = int64(1000)
+	)
+	tableKey := domain.RolePermissionTable.KeyForBusinessAdmin(bizID)
+	client := &fakeRBACClient{
+		resources: []*permissionv1.Resource{
+			{Id: 1, Type: domain.RolePermissionTable.Type(), Key: tableKey},
+			{Id: 2, Type: "order", Key: "/order"},
+		},
+		permissions: []*permissionv1.Permission{
+			{Id: 11, ResourceType: domain.RolePermissionTable.Type(), ResourceKey: tableKey, Actions: []string{"read", "write"}},
+			{Id: 12, ResourceType: "order", ResourceKey: "/order", Actions: []string{"read"}},
+		},
+		roles: []*permissionv1.Role{
+			{Id: 21, Type: domain.DefaultAccountRoleType, Name: "业务管理员"},
+			{Id: 22, Type: domain.DefaultBusinessRoleType, Name: "viewer"},
+		},
+		rolePermissions: []*permissionv1.RolePermission{
+			{Id: 31, RoleName: "业务管理员", RoleType: domain.DefaultAccountRoleType, ResourceType: domain.RolePermissionTable.Type(), ResourceKey: tableKey, PermissionAction: "write"},
+			{Id: 32, RoleName: "viewer", RoleType: domain.DefaultBusinessRoleType, ResourceType: "order", ResourceKey: "/order", PermissionAction: "read"},
+		},
+		userRoles: []*permissionv1.UserRole{
+			{Id: 41, UserId: admin, RoleName: "业务管理员", RoleType: domain.DefaultAccountRoleType},
+			{Id: 42, UserId: user, RoleName: "viewer", RoleType: domain.DefaultBusinessRoleType},
+		},
+	}
+	h := &BaseHandler{rbacSvc: client}
+	model, err := h.loadModel(context.Background(), bizID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		uid     int64
+		typ     string
+		key     string
+		action  string
+		allowed bool
+	}{
+		{name: "账号角色的系统表权限", uid: admin, typ: domain.RolePermissionTable.Type(), key: tableKey, action: "write", allowed: true},
+		{name: "账号角色没有授予的动作", uid: admin, typ: domain.RolePermissionTable.Type(), key: tableKey, action: "read"},
+		{name: "业务角色的权限", uid: user, typ: "order", key: "/order", action: "read", allowed: true},
+		{name: "业务用户没有系统表权限", uid: user, typ: domain.RolePermissionTable.Type(), key: tableKey, action: "write"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			effective := slices.ContainsFunc(model.EffectivePermissions(tc.uid, at), func(p domain.EffectivePermission) bool {
+				return p.ResourceType == tc.typ && p.ResourceKey == tc.key && p.Action == tc.action && p.Effect.IsAllow()
+			})
+			if effective != tc.allowed {
+				t.Errorf("EffectivePermissions = %v, want %v", effective, tc.allowed)
+			}
+			_, users := model.WhoCan(tc.typ, tc.key, tc.action, at)
+			found := slices.ContainsFunc(users, func(u domain.PermissionHolder) bool { return u.UserID == tc.uid })
+			if found != tc.allowed {
+				t.Errorf("WhoCan 包含用户 = %v, want %v", found, tc.allowed)
+			}
+			if got := model.Explain(tc.uid, tc.typ, tc.key, tc.action, at).Allowed; got != tc.allowed {
+				t.Errorf("Explain.Allowed = %v, want %v", got, tc.allowed)
+			}
+		})
+	}
+
+	business := model.Business()
+	if len(business.Resources) != 1 || len(business.Permissions) != 1 || len(business.Roles) != 1 ||
+		len(business.RolePermissions) != 1 || len(business.UserRoles) != 1 {
+		t.Errorf("Business 应该只剩下业务自己的对象: %+v", business)
+	}
+}
@@ -0,0 +1,200 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+)
+
+const (
+	defaultListLimit = 20
+	// defaultMaxListLimit 没有配置 pagination.maxLimit 时每页的上限
+	defaultMaxListLimit = 100
+	// fetchPageSize 逐页拉取全部数据时每次从权限平台拉取的数量，导出时拉取一页写出一页，不在内存中缓存全部数据
+	fetchPageSize = 500
+)
+
+var errInvalidListCursor = errors.New("分页游标不合法")
+
+// listPage 校验后的分页参数
+type listPage struct {
+	bizID  int64
+	offset int
+	limit  int
+	// withTotal 调用方要求返回总数，使用游标分页时忽略
+	withTotal bool
+	// cursor 使用游标分页时上一页返回的游标
+	cursor *listCursor
+}
+
+// listCursor 下一页的位置和上一页最后一行的ID
+type listCursor struct {
+	offset int
+	lastID int64
+}
+
+// checkListLimit 校验每页的数量不超过配置的上限，全部列表接口共用
+func (h *BaseHandler) checkListLimit(limit int) error {
+	if limit < 0 || limit > h.listLimitMax() {
+		return fmt.Errorf("limit 必须在 0 到 %d 之间", h.listLimitMax())
+	}
+	return nil
+}
+
+// listLimitMax 每页的上限，服务层每次最多返回 service.MaxListLimit 行，这里多读一行判断是否还有下一页
+func (h *BaseHandler) listLimitMax() int {
+	if h.maxListLimit <= 0 {
+		return defaultMaxListLimit
+	}
+	return min(h.maxListLimit, service.MaxListLimit-1)
+}
+
+// page 校验 offset 和 limit，limit 为 0 时使用默认值，指定 cursor 时忽略 offset
+func (h *BaseHandler) page(req ListReq) (listPage, error) {
+	if err := h.checkListLimit(req.Limit); err != nil {
+		return listPage{}, err
+	}
+	if req.Offset < 0 {
+		return listPage{}, errors.New("offset 不能小于 0")
+	}
+	p := listPage{bizID: req.BizID, offset: req.Offset, limit: req.Limit, withTotal: req.WithTotal}
+	if p.limit == 0 {
+		p.limit = min(defaultListLimit, h.listLimitMax())
+	}
+	if req.Cursor != "" {
+		c, err := decodeListCursor(req.BizID, req.Cursor)
+		if err != nil {
+			return listPage{}, err
+		}
+		p.offset, p.cursor, p.withTotal = c.offset, &c, false
+	}
+	return p, nil
+}
+
+// listRows 按照分页参数读取一页，多读一行判断是否还有下一页
+// 权限平台和仓储的列表接口都只支持 offset 分页，游标额外记录上一页最后一行的ID，
+// 读取下一页前在游标位置前后一页的范围内找到这一行，前面插入或者删除了行时据此校正位置。
+// 变化超过一页或者这一行本身被删除时退化为 offset 分页，可能重复或者漏掉行。id 为 nil 时只按 offset 分页
+// 权限平台不返回总数，只有 WithTotal 时才逐页读取剩下的行计数
+func listRows[P, T any](h *BaseHandler, req ListReq, fetch func(offset, limit int32) ([]P, error),
+	id func(P) int64, toVO func(P) T) (ginx.Result, error) {
+	p, err := h.page(req)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if p.cursor != nil && id != nil {
+		if p.offset, err = seekListCursor(fetch, id, *p.cursor, p.limit); err != nil {
+			return ginx.Result{}, err
+		}
+	}
+	rows, err := fetch(int32(p.offset), int32(p.limit+1))
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	more := len(rows) > p.limit
+	rows = rows[:min(len(rows), p.limit)]
+	res := ListResp[T]{Rows: slice.Map(rows, func(_ int, src P) T { return toVO(src) })}
+	if more {
+		c := listCursor{offset: p.offset + p.limit}
+		if id != nil {
+			c.lastID = id(rows[len(rows)-1])
+		}
+		res.Cursor = encodeListCursor(p.bizID, c)
+	}
+	if !p.withTotal {
+		return ginx.Result{Data: res}, nil
+	}
+
+	res.Total = p.offset + len(rows)
+	if !more && (len(rows) > 0 || p.offset == 0) {
+		return ginx.Result{Data: res}, nil
+	}
+	// 还有下一页时从下一页开始计数，offset 超过了总数时从头计数
+	start := int32(p.offset + p.limit)
+	if !more {
+		res.Total, start = 0, 0
+	}
+	err = h.paginate(func(offset, limit int32) (int, error) {
+		page, err1 := fetch(start+offset, limit)
+		res.Total += len(page)
+		return len(page), err1
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+// paginate 逐页拉取，直到某一页不满
+func (h *BaseHandler) paginate(fetch func(offset, limit int32) (int, error)) error {
+	for offset := int32(0); ; offset += fetchPageSize {
+		n, err := fetch(offset, fetchPageSize)
+		if err != nil {
+			return err
+		}
+		if n < fetchPageSize {
+			return nil
+		}
+	}
+}
+
+// listSlice 按照分页参数返回已经全部读取的行中的一页
+func listSlice[P, T any](h *BaseHandler, req ListReq, rows []P, id func(P) int64, toVO func(P) T) (ginx.Result, error) {
+	return listRows(h, req, func(offset, limit int32) ([]P, error) {
+		start := min(int(offset), len(rows))
+		return rows[start:min(start+int(limit), len(rows))], nil
+	}, id, toVO)
+}
+
+// seekListCursor 在游标位置前后各一页的范围内查找上一页的最后一行，返回它后面一行的位置
+func seekListCursor[P any](fetch func(offset, limit int32) ([]P, error), id func(P) int64, c listCursor, limit int) (int, error) {
+	if c.lastID == 0 {
+		return c.offset, nil
+	}
+	from := max(c.offset-1-limit, 0)
+	rows, err := fetch(int32(from), int32(c.offset-from+limit))
+	if err != nil {
+		return 0, err
+	}
+	// 最后一行原本在 c.offset-1，从这里向两边找距离最近的位置
+	want := c.offset - 1 - from
+	for d := 0; d <= limit; d++ {
+		for _, i := range []int{want - d, want + d} {
+			if i >= 0 && i < len(rows) && id(rows[i]) == c.lastID {
+				return from + i + 1, nil
+			}
+		}
+	}
+	return c.offset, nil
+}
+
+// encodeListCursor 游标对调用方不透明，带上业务ID避免在不同的业务之间混用
+func encodeListCursor(bizID int64, c listCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%d", bizID, c.offset, c.lastID)))
+}
+
+func decodeListCursor(bizID int64, cursor string) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listCursor{}, errInvalidListCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != strconv.FormatInt(bizID, 10) {
+		return listCursor{}, errInvalidListCursor
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil || offset < 0 {
+		return listCursor{}, errInvalidListCursor
+	}
+	lastID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return listCursor{}, errInvalidListCursor
+	}
+	return listCursor{offset: offset, lastID: lastID}, nil
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"slices"
+	"testing"
+)
+
+func identity(id int64) int64 {
+	return id
+}
+
+func listInts(t *testing.T, h *BaseHandler, req ListReq, rows []int64, id func(int64) int64) (ListResp[int64], error) {
+	t.Helper()
+	res, err := listSlice(h, req, rows, id, identity)
+	if err != nil {
+		return ListResp[int64]{}, err
+	}
+	return res.Data.(ListResp[int64]), nil
+}
+
+func TestListRows(t *testing.T) {
+	h := &BaseHandler{maxListLimit: 10}
+	rows := []int64{1, 2, 3, 4, 5}
+	otherBiz, err := listInts(t, h, ListReq{BizID: 2, Limit: 1}, rows, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name      string
+		req       ListReq
+		wantRows  []int64
+		wantTotal int
+		wantMore  bool
+		wantErr   bool
+	}{
+		{name: "默认不计算总数", req: ListReq{BizID: 1, Limit: 2}, wantRows: []int64{1, 2}, wantMore: true},
+		{name: "默认每页数量", req: ListReq{BizID: 1}, wantRows: rows},
+		{name: "计算总数", req: ListReq{BizID: 1, Limit: 2, WithTotal: true}, wantRows: []int64{1, 2}, wantTotal: 5, wantMore: true},
+		{name: "最后一页计算总数", req: ListReq{BizID: 1, Offset: 4, Limit: 2, WithTotal: true}, wantRows: []int64{5}, wantTotal: 5},
+		{name: "offset 超过总数", req: ListReq{BizID: 1, Offset: 10, Limit: 2, WithTotal: true}, wantRows: []int64{}, wantTotal: 5},
+		{name: "limit 超过上限", req: ListReq{BizID: 1, Limit: 11}, wantErr: true},
+		{name: "offset 小于 0", req: ListReq{BizID: 1, Offset: -1}, wantErr: true},
+		{name: "其他业务的游标", req: ListReq{BizID: 1, Cursor: otherBiz.Cursor}, wantErr: true},
+		{name: "伪造的游标", req: ListReq{BizID: 1, Cursor: "1:2:3"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := listInts(t, h, tc.req, rows, identity)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("listSlice() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !slices.Equal(got.Rows, tc.wantRows) || got.Total != tc.wantTotal || (got.Cursor != "") != tc.wantMore {
+				t.Fatalf("listSlice() = %+v, want rows %v total %d more %v", got, tc.wantRows, tc.wantTotal, tc.wantMore)
+			}
+		})
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	h := &BaseHandler{}
+	rows := []int64{1, 2, 3, 4, 5, 6}
+	testCases := []struct {
+		name string
+		// change 读取第一页之后表里的变化
+		change func([]int64) []int64
+		// noID 只按照 offset 分页
+		noID bool
+		want []int64
+	}{
+		{name: "没有变化", change: slices.Clone[[]int64], want: []int64{3, 4}},
+		{name: "游标前面插入了行", change: func(r []int64) []int64 { return append([]int64{0}, r...) }, want: []int64{3, 4}},
+		{name: "游标前面删除了行", change: func(r []int64) []int64 { return slices.Delete(slices.Clone(r), 0, 1) }, want: []int64{3, 4}},
+		{name: "游标后面插入了行", change: func(r []int64) []int64 { return slices.Insert(slices.Clone(r), 2, 7) }, want: []int64{7, 3}},
+		// 上一页最后一行被删除时退化为 offset 分页，会漏掉一行
+		{name: "上一页最后一行被删除", change: func(r []int64) []int64 { return slices.Delete(slices.Clone(r), 1, 2) }, want: []int64{4, 5}},
+		{name: "没有ID时按照 offset 分页", change: func(r []int64) []int64 { return append([]int64{0}, r...) }, noID: true, want: []int64{2, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id := identity
+			if tc.noID {
+				id = nil
+			}
+			first, err := listInts(t, h, ListReq{BizID: 1, Limit: 2}, rows, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := listInts(t, h, ListReq{BizID: 1, Limit: 2, Cursor: first.Cursor}, tc.change(rows), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(second.Rows, tc.want) {
+				t.Fatalf("第二页 = %v, want %v", second.Rows, tc.want)
+			}
+		})
+	}
+}
+
+func TestListCursorLastPage(t *testing.T) {
+	h := &BaseHandler{}
+	rows := []int64{1, 2, 3}
+	var got []int64
+	req := ListReq{BizID: 1, Limit: 2}
+	for i := 0; ; i++ {
+		res, err := listInts(t, h, req, rows, identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, res.Rows...)
+		if res.Cursor == "" {
+			break
+		}
+		if i > len(rows) {
+			t.Fatal("游标没有结束")
+		}
+		req.Cursor = res.Cursor
+	}
+	if !slices.Equal(got, rows) {
+		t.Fatalf("逐页读取 = %v, want %v", got, rows)
+	}
+}
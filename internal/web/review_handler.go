@@ -0,0 +1,207 @@
+package web
+
+import (
+	"errors"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// ReviewHandler 周期性权限审查
+type ReviewHandler struct {
+	*BaseHandler
+	svc *service.ReviewService
+}
+
+func NewReviewHandler(handler *BaseHandler, svc *service.ReviewService) *ReviewHandler {
+	return &ReviewHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *ReviewHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/review/campaign/create", ginx.BS(audited(h.audits, domain.ReviewTable, h.Create)))
+	server.GET("/review/campaign/list", ginx.BS[ReviewCampaignListReq](h.List))
+	server.GET("/review/campaign/report", ginx.BS[ReviewReq](h.Report))
+	server.GET("/review/item/list", ginx.BS[ReviewItemListReq](h.ListItems))
+	server.POST("/review/item/certify", ginx.BS(audited(h.audits, domain.UserRoleTable, h.Certify)))
+	server.POST("/review/item/revoke", ginx.BS(audited(h.audits, domain.UserRoleTable, h.Revoke)))
+}
+
+// Create 创建审查并且对当前授权做快照
+func (h *ReviewHandler) Create(ctx *ginx.Context, req ReviewCampaignReq, sess session.Session) (ginx.Result, error) {
+	uid := sess.Claims().Uid
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.ReviewTable, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	c, err := h.svc.Create(ctx, domain.ReviewCampaign{
+		BizID:           req.BizID,
+		Name:            req.Campaign.Name,
+		RoleIDs:         req.Campaign.RoleIDs,
+		Privileged:      req.Campaign.Privileged,
+		DefaultReviewer: req.Campaign.DefaultReviewer,
+		Deadline:        req.Campaign.Deadline,
+		ExpirePolicy:    domain.ReviewExpirePolicy(req.Campaign.ExpirePolicy),
+		EscalateTo:      req.Campaign.EscalateTo,
+		CreatorUID:      uid,
+	}, req.Managers)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toReviewCampaignVO(c),
+	}, nil
+}
+
+func (h *ReviewHandler) List(ctx *ginx.Context, req ReviewCampaignListReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ReviewTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listRows(h.BaseHandler, req.ListReq, func(offset, limit int32) ([]domain.ReviewCampaign, error) {
+		return h.svc.ListCampaigns(ctx, req.BizID, int(offset), int(limit))
+	}, func(src domain.ReviewCampaign) int64 { return src.ID }, toReviewCampaignVO)
+}
+
+// Report 审查报告，审查的创建人或者有权限审查读权限的用户可以查看
+func (h *ReviewHandler) Report(ctx *ginx.Context, req ReviewReq, sess session.Session) (ginx.Result, error) {
+	c, err := h.campaign(ctx, req.BizID, req.ID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	uid := sess.Claims().Uid
+	if c.CreatorUID != uid {
+		businessAdminCtx, err1 := h.businessAdminCtx(ctx, req.BizID)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+		err1 = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, domain.ReviewTable, domain.PermissionActionRead)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+	}
+	report, err := h.svc.Report(ctx, c.ID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: ReviewReport{
+			Campaign:    toReviewCampaignVO(report.Campaign),
+			Total:       report.Total,
+			Certified:   report.Certified,
+			Revoked:     report.Revoked,
+			AutoRevoked: report.AutoRevoked,
+			Escalated:   report.Escalated,
+			Pending:     report.Pending,
+			Items:       slice.Map(report.Items, func(_ int, src domain.ReviewItem) ReviewItem { return toReviewItemVO(src) }),
+		},
+	}, nil
+}
+
+// ListItems 审查人查询分配给自己的审查项
+func (h *ReviewHandler) ListItems(ctx *ginx.Context, req ReviewItemListReq, sess session.Session) (ginx.Result, error) {
+	c, err := h.campaign(ctx, req.BizID, req.CampaignID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	reviewer := sess.Claims().Uid
+	if req.All {
+		businessAdminCtx, err1 := h.businessAdminCtx(ctx, req.BizID)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+		err1 = h.checkBusinessPermission(businessAdminCtx, req.BizID, reviewer, domain.ReviewTable, domain.PermissionActionRead)
+		if err1 != nil {
+			return ginx.Result{}, err1
+		}
+		reviewer = 0
+	}
+	items, err := h.svc.ListItems(ctx, c.ID, reviewer)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listSlice(h.BaseHandler, req.ListReq, items, func(src domain.ReviewItem) int64 { return src.ID }, toReviewItemVO)
+}
+
+// Certify 确认授权仍然需要保留
+func (h *ReviewHandler) Certify(ctx *ginx.Context, req ReviewItemReq, sess session.Session) (ginx.Result, error) {
+	return h.decide(ctx, req, sess.Claims().Uid, true)
+}
+
+// Revoke 回收授权
+func (h *ReviewHandler) Revoke(ctx *ginx.Context, req ReviewItemReq, sess session.Session) (ginx.Result, error) {
+	return h.decide(ctx, req, sess.Claims().Uid, false)
+}
+
+func (h *ReviewHandler) decide(ctx *ginx.Context, req ReviewItemReq, uid int64, certify bool) (ginx.Result, error) {
+	c, err := h.campaign(ctx, req.BizID, req.CampaignID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	item, err := h.svc.Decide(ctx, c.ID, req.ItemID, uid, certify, req.Comment)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toReviewItemVO(item),
+	}, nil
+}
+
+func (h *ReviewHandler) campaign(ctx *ginx.Context, bizID, id int64) (domain.ReviewCampaign, error) {
+	c, err := h.svc.GetCampaign(ctx, id)
+	if err != nil {
+		return domain.ReviewCampaign{}, err
+	}
+	if c.BizID != bizID {
+		return domain.ReviewCampaign{}, errors.New("权限审查不属于该业务")
+	}
+	return c, nil
+}
+
+func toReviewCampaignVO(src domain.ReviewCampaign) ReviewCampaign {
+	return ReviewCampaign{
+		ID:              src.ID,
+		BizID:           src.BizID,
+		Name:            src.Name,
+		RoleIDs:         src.RoleIDs,
+		Privileged:      src.Privileged,
+		DefaultReviewer: src.DefaultReviewer,
+		Deadline:        src.Deadline,
+		ExpirePolicy:    string(src.ExpirePolicy),
+		EscalateTo:      src.EscalateTo,
+		CreatorUID:      src.CreatorUID,
+		Status:          src.Status.String(),
+		Ctime:           src.Ctime,
+		Utime:           src.Utime,
+	}
+}
+
+func toReviewItemVO(src domain.ReviewItem) ReviewItem {
+	return ReviewItem{
+		ID:         src.ID,
+		CampaignID: src.CampaignID,
+		UserRoleID: src.UserRoleID,
+		UserID:     src.UserID,
+		RoleID:     src.RoleID,
+		RoleName:   src.RoleName,
+		StartTime:  src.StartTime,
+		EndTime:    src.EndTime,
+		Reviewer:   src.Reviewer,
+		Escalated:  src.Escalated,
+		Decision:   src.Decision.String(),
+		Comment:    src.Comment,
+		DecidedBy:  src.DecidedBy,
+		DecidedAt:  src.DecidedAt,
+	}
+}
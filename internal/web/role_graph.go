@@ -0,0 +1,75 @@
+package web
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+)
+
+const (
+	roleGraphFormatDOT     = "dot"
+	roleGraphFormatMermaid = "mermaid"
+	roleGraphFormatJSON    = "json"
+)
+
+// renderRoleGraphDOT 输出 Graphviz 的 DOT 格式，可以用 dot -Tsvg 渲染
+func renderRoleGraphDOT(graph domain.RoleGraph, opts domain.RoleGraphOptions) string {
+	var sb strings.Builder
+	sb.WriteString("digraph roles {\n  rankdir=TB;\n  node [shape=box];\n")
+	for _, n := range graph.Nodes {
+		fmt.Fprintf(&sb, "  %s [label=%s];\n", strconv.Quote(n.Name), strconv.Quote(roleGraphLabel(n, opts, "\n")))
+	}
+	for _, e := range graph.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s;\n", strconv.Quote(e.Including), strconv.Quote(e.Included))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// renderRoleGraphMermaid 输出 Mermaid 流程图，角色名可能包含特殊字符，所以节点用编号
+func renderRoleGraphMermaid(graph domain.RoleGraph, opts domain.RoleGraphOptions) string {
+	var sb strings.Builder
+	sb.WriteString("graph TD\n")
+	ids := make(map[string]string, len(graph.Nodes))
+	for i, n := range graph.Nodes {
+		ids[n.Name] = "r" + strconv.Itoa(i)
+		label := strings.ReplaceAll(roleGraphLabel(n, opts, "<br/>"), `"`, "#quot;")
+		fmt.Fprintf(&sb, "  %s[\"%s\"]\n", ids[n.Name], label)
+	}
+	for _, e := range graph.Edges {
+		fmt.Fprintf(&sb, "  %s --> %s\n", ids[e.Including], ids[e.Included])
+	}
+	return sb.String()
+}
+
+func roleGraphLabel(n domain.RoleGraphNode, opts domain.RoleGraphOptions, sep string) string {
+	lines := []string{n.Name}
+	if opts.WithUserCounts {
+		lines = append(lines, fmt.Sprintf("%d 个用户", n.UserCount))
+	}
+	if opts.WithPermissions {
+		lines = append(lines, n.Permissions...)
+	}
+	return strings.Join(lines, sep)
+}
+
+func toRoleGraphVO(src domain.RoleGraph) RoleGraph {
+	res := RoleGraph{
+		Nodes: make([]RoleGraphNode, 0, len(src.Nodes)),
+		Edges: make([]BizModelRoleInclusion, 0, len(src.Edges)),
+	}
+	for _, n := range src.Nodes {
+		res.Nodes = append(res.Nodes, RoleGraphNode{
+			Name:        n.Name,
+			Description: n.Description,
+			Permissions: n.Permissions,
+			UserCount:   n.UserCount,
+		})
+	}
+	for _, e := range src.Edges {
+		res.Edges = append(res.Edges, BizModelRoleInclusion{Including: e.Including, Included: e.Included})
+	}
+	return res
+}
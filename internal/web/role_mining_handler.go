@@ -0,0 +1,161 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleMiningHandler 从直接授予用户的权限中挖掘候选角色，接受候选角色后用角色代替直接授权
+type RoleMiningHandler struct {
+	*BaseHandler
+}
+
+func NewRoleMiningHandler(handler *BaseHandler) *RoleMiningHandler {
+	return &RoleMiningHandler{BaseHandler: handler}
+}
+
+func (h *RoleMiningHandler) PrivateRoutes(server *gin.Engine) {
+	server.GET("/role-mining/suggestions", ginx.BS[RoleMiningReq](h.Suggestions))
+	server.POST("/role-mining/accept", ginx.BS(audited(h.audits, domain.RoleTable, h.Accept)))
+}
+
+// Suggestions 分析业务当前的直接授权，返回候选角色，需要全部业务表的读权限
+func (h *RoleMiningHandler) Suggestions(ctx *ginx.Context, req RoleMiningReq, sess session.Session) (ginx.Result, error) {
+	suggestions, _, err := h.mine(ctx, req, sess.Claims().Uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{Data: ListResp[RoleSuggestion]{Rows: slice.Map(suggestions, func(_ int, src domain.RoleSuggestion) RoleSuggestion {
+		return RoleSuggestion{
+			Key:         src.Key(),
+			Name:        src.Name,
+			Permissions: src.Permissions,
+			Members:     src.Members,
+			Saved:       src.Saved(),
+		}
+	})}}, nil
+}
+
+// Accept 用同样的参数重新分析，候选角色没有变化时创建角色、授予角色权限、把角色授予成员，
+// 最后回收成员被角色代替的直接授权。先授予再回收，中途失败时成员不会失去权限
+func (h *RoleMiningHandler) Accept(ctx *ginx.Context, req RoleMiningAcceptReq, sess session.Session) (ginx.Result, error) {
+	suggestions, model, err := h.mine(ctx, req.RoleMiningReq, sess.Claims().Uid, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	idx := slices.IndexFunc(suggestions, func(s domain.RoleSuggestion) bool { return s.Key() == req.Key })
+	if idx < 0 {
+		return ginx.Result{}, errors.New("候选角色已经变化，请重新分析")
+	}
+	suggestion := suggestions[idx]
+	if req.Name == "" {
+		req.Name = suggestion.Name
+	}
+	if slices.ContainsFunc(model.Roles, func(r domain.ModelRole) bool { return r.Name == req.Name }) {
+		return ginx.Result{}, fmt.Errorf("角色 %s 已经存在", req.Name)
+	}
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	var res RoleMiningAcceptResult
+	if err = h.accept(businessAdminCtx, req, model, suggestion, &res); err != nil {
+		return ginx.Result{Msg: "接受中途失败，已经执行的变更不会撤销", Data: res}, err
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+func (h *RoleMiningHandler) accept(ctx context.Context, req RoleMiningAcceptReq, model domain.RBACModel,
+	suggestion domain.RoleSuggestion, res *RoleMiningAcceptResult,
+) error {
+	role := Role{BizID: req.BizID, Name: req.Name, Description: req.Description}
+	var err error
+	role.ID, err = createdID(h.createRole(ctx, RoleReq{BizID: req.BizID, Role: role}))
+	if err != nil {
+		return fmt.Errorf("创建角色失败: %w", err)
+	}
+	res.RoleID = role.ID
+
+	permissions := make(map[string]domain.ModelPermission, len(model.Permissions))
+	for _, p := range model.Permissions {
+		permissions[p.Ref()] = p
+	}
+	rolePermissions := slice.Map(suggestion.Permissions, func(_ int, ref string) RolePermissionReq {
+		return RolePermissionReq{BizID: req.BizID, RolePermission: RolePermission{
+			BizID:      req.BizID,
+			Role:       role,
+			Permission: toModelPermissionVO(req.BizID, permissions[ref]),
+		}}
+	})
+	userRoles := slice.Map(suggestion.Members, func(_ int, uid int64) UserRoleReq {
+		return UserRoleReq{BizID: req.BizID, UserRole: UserRole{BizID: req.BizID, UserID: uid, Role: role}}
+	})
+	// 整批检查一次职责分离约束，逐个授权时不再读取权限模型
+	err = h.sod.Check(ctx, req.BizID, h.loadModel, func(after *domain.RBACModel) error {
+		for _, rp := range rolePermissions {
+			if err1 := addRolePermission(after, rp); err1 != nil {
+				return err1
+			}
+		}
+		for _, ur := range userRoles {
+			if err1 := addUserRole(after, ur); err1 != nil {
+				return err1
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, rp := range rolePermissions {
+		if _, err = h.doGrantRolePermission(ctx, rp); err != nil {
+			return fmt.Errorf("授予角色权限 %s 失败: %w", suggestion.Permissions[i], err)
+		}
+		res.Granted++
+	}
+	for _, ur := range userRoles {
+		uid := ur.UserRole.UserID
+		_, err = h.doGrantUserRole(ctx, ur)
+		if err != nil {
+			return fmt.Errorf("授予用户 %d 角色失败: %w", uid, err)
+		}
+		res.Granted++
+	}
+	// 只回收参与挖掘的授权，也就是没有期限的 allow 授权
+	for _, up := range model.UserPermissions {
+		if !up.Effect.IsAllow() || up.StartTime != 0 || up.EndTime != 0 ||
+			!slices.Contains(suggestion.Members, up.UserID) || !slices.Contains(suggestion.Permissions, up.PermissionRef()) {
+			continue
+		}
+		_, err = h.revokeUserPermission(ctx, UserPermissionReq{BizID: req.BizID, UserPermission: UserPermission{ID: up.ID, UserID: up.UserID}})
+		if err != nil {
+			return fmt.Errorf("回收 %s 失败: %w", up.Ref(), err)
+		}
+		res.Revoked++
+	}
+	return nil
+}
+
+func (h *RoleMiningHandler) mine(ctx context.Context, req RoleMiningReq, uid int64, action domain.PermissionActionType) ([]domain.RoleSuggestion, domain.RBACModel, error) {
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, uid, action)
+	if err != nil {
+		return nil, domain.RBACModel{}, err
+	}
+	live, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return nil, domain.RBACModel{}, err
+	}
+	// 系统资源的授权由管理后台维护，不参与挖掘
+	model := live.Business()
+	opts := domain.RoleMiningOptions{Threshold: req.Threshold, MinMembers: req.MinMembers, MinPermissions: req.MinPermissions}
+	return model.MineRoles(opts, time.Now().UnixMilli()), model, nil
+}
@@ -0,0 +1,250 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler 定时执行的授权变更
+type ScheduleHandler struct {
+	*BaseHandler
+	svc *service.ScheduleService
+}
+
+func NewScheduleHandler(handler *BaseHandler, svc *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *ScheduleHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/schedule/create", ginx.BS(audited(h.audits, domain.ScheduleTable, h.Create)))
+	server.GET("/schedule/list", ginx.BS[ScheduledChangeListReq](h.List))
+	server.POST("/schedule/cancel", ginx.BS(audited(h.audits, domain.ScheduleTable, h.Cancel)))
+	server.POST("/schedule/reschedule", ginx.BS(audited(h.audits, domain.ScheduleTable, h.Reschedule)))
+}
+
+// Create 创建定时变更，需要和立即执行同样的权限
+// 需要审批的授权不能绕过审批定时执行
+func (h *ScheduleHandler) Create(ctx *ginx.Context, req ScheduleChangeReq, sess session.Session) (ginx.Result, error) {
+	uid := sess.Claims().Uid
+	kind := domain.ScheduledChangeKind(req.Kind)
+	if kind.Table() == "" {
+		return ginx.Result{}, fmt.Errorf("不支持的定时变更类型: %s", req.Kind)
+	}
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, kind.Table(), domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	change := domain.ScheduledChange{
+		BizID:      req.BizID,
+		Kind:       kind,
+		Reason:     req.Reason,
+		CreatorUID: uid,
+		ExecuteAt:  req.ExecuteAt,
+	}
+	switch kind {
+	case domain.ScheduledGrantUserRole, domain.ScheduledRevokeUserRole:
+		req.UserRole.BizID = req.BizID
+		change.TargetID, change.TargetUserID = req.UserRole.Role.ID, req.UserRole.UserID
+		if kind == domain.ScheduledRevokeUserRole {
+			change.TargetID = req.UserRole.ID
+		}
+		change.Payload = toJSONString(UserRoleReq{BizID: req.BizID, UserRole: req.UserRole, Reason: req.Reason})
+	case domain.ScheduledGrantUserPermission, domain.ScheduledRevokeUserPermission:
+		req.UserPermission.BizID = req.BizID
+		change.TargetID, change.TargetUserID = req.UserPermission.Permission.ID, req.UserPermission.UserID
+		if kind == domain.ScheduledRevokeUserPermission {
+			change.TargetID = req.UserPermission.ID
+		}
+		change.Payload = toJSONString(UserPermissionReq{BizID: req.BizID, UserPermission: req.UserPermission, Reason: req.Reason})
+	case domain.ScheduledGrantRolePermission, domain.ScheduledRevokeRolePermission:
+		req.RolePermission.BizID = req.BizID
+		change.TargetID = req.RolePermission.Role.ID
+		if kind == domain.ScheduledRevokeRolePermission {
+			change.TargetID = req.RolePermission.ID
+		}
+		change.Payload = toJSONString(RolePermissionReq{BizID: req.BizID, RolePermission: req.RolePermission})
+	}
+	if err = h.checkApproval(ctx, change); err != nil {
+		return ginx.Result{}, err
+	}
+	change, err = h.svc.Schedule(ctx, change)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toScheduledChangeVO(change),
+	}, nil
+}
+
+func (h *ScheduleHandler) List(ctx *ginx.Context, req ScheduledChangeListReq, sess session.Session) (ginx.Result, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, sess.Claims().Uid, domain.ScheduleTable, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listRows(h.BaseHandler, req.ListReq, func(offset, limit int32) ([]domain.ScheduledChange, error) {
+		return h.svc.List(ctx, req.BizID, int(offset), int(limit))
+	}, func(src domain.ScheduledChange) int64 { return src.ID }, toScheduledChangeVO)
+}
+
+func (h *ScheduleHandler) Cancel(ctx *ginx.Context, req ScheduledChangeReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.change(ctx, req, sess.Claims().Uid); err != nil {
+		return ginx.Result{}, err
+	}
+	change, err := h.svc.Cancel(ctx, req.ID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toScheduledChangeVO(change),
+	}, nil
+}
+
+func (h *ScheduleHandler) Reschedule(ctx *ginx.Context, req ScheduledChangeReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.change(ctx, req, sess.Claims().Uid); err != nil {
+		return ginx.Result{}, err
+	}
+	change, err := h.svc.Reschedule(ctx, req.ID, req.ExecuteAt)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: toScheduledChangeVO(change),
+	}, nil
+}
+
+// ExecuteDue 执行到期的定时变更，由定时任务调用
+func (h *ScheduleHandler) ExecuteDue(ctx context.Context) error {
+	return h.svc.ExecuteDue(ctx, h.execute)
+}
+
+func (h *ScheduleHandler) execute(ctx context.Context, change domain.ScheduledChange) (string, error) {
+	// 创建之后可能新增了审批策略，执行前重新校验
+	if err := h.checkApproval(ctx, change); err != nil {
+		return "", err
+	}
+	businessAdminCtx, err := h.businessAdminCtx(ctx, change.BizID)
+	if err != nil {
+		return "", err
+	}
+	// 创建人的权限可能已经被回收，不能再以业务管理员的身份替他执行
+	err = h.checkBusinessPermission(businessAdminCtx, change.BizID, change.CreatorUID, change.Kind.Table(), domain.PermissionActionWrite)
+	if errors.Is(err, errNoPermission) {
+		return "", fmt.Errorf("%w: uid=%d", domain.ErrScheduledChangeForbidden, change.CreatorUID)
+	}
+	if err != nil {
+		return "", err
+	}
+	var res ginx.Result
+	switch change.Kind {
+	case domain.ScheduledGrantUserRole, domain.ScheduledRevokeUserRole:
+		var req UserRoleReq
+		if err = json.Unmarshal([]byte(change.Payload), &req); err != nil {
+			return "", fmt.Errorf("解析定时变更失败: %w", err)
+		}
+		if change.Kind == domain.ScheduledGrantUserRole {
+			res, err = h.grantUserRole(businessAdminCtx, req)
+		} else {
+			res, err = h.revokeUserRole(businessAdminCtx, req)
+		}
+	case domain.ScheduledGrantUserPermission, domain.ScheduledRevokeUserPermission:
+		var req UserPermissionReq
+		if err = json.Unmarshal([]byte(change.Payload), &req); err != nil {
+			return "", fmt.Errorf("解析定时变更失败: %w", err)
+		}
+		if change.Kind == domain.ScheduledGrantUserPermission {
+			res, err = h.grantUserPermission(businessAdminCtx, req)
+		} else {
+			res, err = h.revokeUserPermission(businessAdminCtx, req)
+		}
+	case domain.ScheduledGrantRolePermission, domain.ScheduledRevokeRolePermission:
+		var req RolePermissionReq
+		if err = json.Unmarshal([]byte(change.Payload), &req); err != nil {
+			return "", fmt.Errorf("解析定时变更失败: %w", err)
+		}
+		if change.Kind == domain.ScheduledGrantRolePermission {
+			res, err = h.grantRolePermission(businessAdminCtx, req)
+		} else {
+			res, err = h.revokeRolePermission(businessAdminCtx, req)
+		}
+	default:
+		return "", fmt.Errorf("不支持的定时变更类型: %s", change.Kind)
+	}
+	if err != nil {
+		return "", err
+	}
+	return toJSONString(res.Data), nil
+}
+
+// checkApproval 需要审批的授权不能绕过审批定时执行，回收和角色权限变更不需要审批
+func (h *ScheduleHandler) checkApproval(ctx context.Context, change domain.ScheduledChange) error {
+	var kind domain.ChangeRequestKind
+	switch change.Kind {
+	case domain.ScheduledGrantUserRole:
+		kind = domain.ChangeRequestGrantUserRole
+	case domain.ScheduledGrantUserPermission:
+		kind = domain.ChangeRequestGrantUserPermission
+	default:
+		return nil
+	}
+	_, found, err := h.approvals.MatchPolicy(ctx, change.BizID, kind, change.TargetID)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%w，请先提交审批", domain.ErrScheduledChangeNeedsApproval)
+	}
+	return nil
+}
+
+// change 查询定时变更，修改定时变更需要和创建时同样的权限
+func (h *ScheduleHandler) change(ctx *ginx.Context, req ScheduledChangeReq, uid int64) (domain.ScheduledChange, error) {
+	change, err := h.svc.Get(ctx, req.ID)
+	if err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	if change.BizID != req.BizID {
+		return domain.ScheduledChange{}, errors.New("定时变更不属于该业务")
+	}
+	businessAdminCtx, err := h.businessAdminCtx(ctx, req.BizID)
+	if err != nil {
+		return domain.ScheduledChange{}, err
+	}
+	err = h.checkBusinessPermission(businessAdminCtx, req.BizID, uid, change.Kind.Table(), domain.PermissionActionWrite)
+	return change, err
+}
+
+func toScheduledChangeVO(src domain.ScheduledChange) ScheduledChange {
+	return ScheduledChange{
+		ID:           src.ID,
+		BizID:        src.BizID,
+		Kind:         src.Kind.String(),
+		TargetID:     src.TargetID,
+		TargetUserID: src.TargetUserID,
+		Payload:      src.Payload,
+		Reason:       src.Reason,
+		CreatorUID:   src.CreatorUID,
+		ExecuteAt:    src.ExecuteAt,
+		Status:       src.Status.String(),
+		Attempts:     src.Attempts,
+		Result:       src.Result,
+		ExecutedAt:   src.ExecutedAt,
+		Ctime:        src.Ctime,
+		Utime:        src.Utime,
+	}
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotHandler 业务权限模型的快照和回滚
+type SnapshotHandler struct {
+	*BaseHandler
+	svc *service.SnapshotService
+}
+
+func NewSnapshotHandler(handler *BaseHandler, svc *service.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *SnapshotHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/biz/snapshot/create", ginx.BS(audited(h.audits, domain.BusinessConfigTable, h.Create)))
+	server.GET("/biz/snapshot/list", ginx.BS[SnapshotListReq](h.List))
+	server.GET("/biz/snapshot/get", ginx.BS[SnapshotReq](h.Get))
+	server.POST("/biz/snapshot/rollback", ginx.BS(audited(h.audits, domain.BusinessConfigTable, h.Rollback)))
+}
+
+// Create 立即为业务创建快照，快照包含全部业务表，需要每张表的读权限
+func (h *SnapshotHandler) Create(ctx *ginx.Context, req SnapshotCreateReq, sess session.Session) (ginx.Result, error) {
+	uid := sess.Claims().Uid
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	// 快照和导出一样只保存业务自己的部分，账号角色和系统资源由管理后台维护
+	snapshot, err := h.svc.Create(ctx, domain.Snapshot{
+		BizID:      req.BizID,
+		Trigger:    domain.SnapshotManual,
+		CreatorUID: uid,
+		Note:       req.Note,
+		Model:      model.Business(),
+	})
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{Data: toSnapshotVO(snapshot)}, nil
+}
+
+func (h *SnapshotHandler) List(ctx *ginx.Context, req SnapshotListReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead); err != nil {
+		return ginx.Result{}, err
+	}
+	snapshots, err := h.svc.List(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listSlice(h.BaseHandler, req.ListReq, snapshots, func(src domain.Snapshot) int64 { return src.ID }, toSnapshotVO)
+}
+
+// Get 查询快照的完整模型，格式和导出的权限模型相同
+func (h *SnapshotHandler) Get(ctx *ginx.Context, req SnapshotReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.prepareModel(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead); err != nil {
+		return ginx.Result{}, err
+	}
+	snapshot, err := h.svc.Get(ctx, req.BizID, req.ID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	res := toSnapshotVO(snapshot)
+	model := toBizModelVO(snapshot.Model)
+	model.BizID, model.ExportedAt = snapshot.BizID, snapshot.Ctime
+	res.Model = &model
+	return ginx.Result{Data: res}, nil
+}
+
+// Rollback 把业务恢复到快照的状态，包括用户授权，需要全部业务表的写权限
+// 先用 dryRun 预览变更，再带上预览返回的摘要执行，保证执行的就是预览过的变更
+// 执行前自动为当前状态创建快照，回滚错了可以再回滚到这个快照
+// 权限平台没有事务，执行中途失败时已经执行的变更不会撤销，重新回滚到同一个快照即可继续
+func (h *SnapshotHandler) Rollback(ctx *ginx.Context, req SnapshotRollbackReq, sess session.Session) (ginx.Result, error) {
+	uid := sess.Claims().Uid
+	action := domain.PermissionActionWrite
+	if req.DryRun {
+		action = domain.PermissionActionRead
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, uid, action)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	snapshot, err := h.svc.Get(ctx, req.BizID, req.ID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	live, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	// 旧的快照可能包含系统资源，两边都只比较业务自己的部分
+	// 快照之后已经过期的授权不恢复，否则回滚会重新授予已经到期的权限
+	live, target := live.Business(), snapshot.Model.Business().Unexpired(time.Now().UnixMilli())
+	plan := target.Rollback(live)
+	res := SnapshotRollbackResult{BizModelPlan: toBizModelPlanVO(plan), DryRun: req.DryRun}
+	// 回滚不能绕过审批，快照之后被回收的需要审批的授权要重新走审批流程
+	res.Problems, err = h.checkModelApprovals(ctx, req.BizID, target, live, plan)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	if len(res.Problems) > 0 {
+		return ginx.Result{Msg: "回滚包含需要审批的授权", Data: res}, &domain.ModelImportError{Problems: res.Problems}
+	}
+	if req.DryRun {
+		return ginx.Result{Data: res}, nil
+	}
+	if req.Fingerprint == "" {
+		return ginx.Result{Msg: "请先用 dryRun 预览，再带上返回的计划摘要回滚", Data: res}, errFingerprintRequired
+	}
+	if req.Fingerprint != res.Fingerprint {
+		return ginx.Result{Msg: "业务当前状态在预览之后发生了变化，请重新预览", Data: res}, errors.New("计划摘要不一致")
+	}
+	if len(res.Changes) == 0 {
+		return ginx.Result{Data: res}, nil
+	}
+
+	backup, err := h.svc.Create(ctx, domain.Snapshot{
+		BizID:      req.BizID,
+		Trigger:    domain.SnapshotPreRollback,
+		CreatorUID: uid,
+		Note:       fmt.Sprintf("回滚到快照 %d 之前", snapshot.ID),
+		Model:      live,
+	})
+	if err != nil {
+		return ginx.Result{}, fmt.Errorf("保存回滚前的快照失败: %w", err)
+	}
+	res.BackupID = backup.ID
+	res.Applied, err = h.applyModel(businessAdminCtx, req.BizID, target, live, plan)
+	if err != nil {
+		return ginx.Result{Msg: "回滚中途失败，已经执行的变更不会撤销", Data: res}, err
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+// SnapshotAll 为全部业务创建快照，由定时任务调用
+func (h *SnapshotHandler) SnapshotAll(ctx context.Context) error {
+	return h.svc.SnapshotAll(ctx, func(ctx context.Context, bizID int64) (domain.RBACModel, error) {
+		businessAdminCtx, err := h.businessAdminCtx(ctx, bizID)
+		if err != nil {
+			return domain.RBACModel{}, err
+		}
+		model, err := h.loadModel(businessAdminCtx, bizID)
+		return model.Business(), err
+	})
+}
+
+func toSnapshotVO(src domain.Snapshot) Snapshot {
+	counts := make(map[string]int, len(src.Counts))
+	for table, n := range src.Counts {
+		counts[table.String()] = n
+	}
+	return Snapshot{
+		ID:         src.ID,
+		BizID:      src.BizID,
+		Trigger:    src.Trigger.String(),
+		CreatorUID: src.CreatorUID,
+		Note:       src.Note,
+		Counts:     counts,
+		Size:       src.Size,
+		Ctime:      src.Ctime,
+	}
+}
@@ -0,0 +1,141 @@
+package web
+
+import (
+	"context"
+	"fmt"
+
+	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/ecodeclub/ginx"
+	"github.com/ecodeclub/ginx/session"
+	"github.com/gin-gonic/gin"
+)
+
+// SoDHandler 职责分离约束，约束在授予用户角色、用户权限、角色权限和创建角色包含关系时检查
+type SoDHandler struct {
+	*BaseHandler
+	svc *service.SoDService
+}
+
+func NewSoDHandler(handler *BaseHandler, svc *service.SoDService) *SoDHandler {
+	return &SoDHandler{BaseHandler: handler, svc: svc}
+}
+
+func (h *SoDHandler) PrivateRoutes(server *gin.Engine) {
+	server.POST("/sod/create", ginx.BS(audited(h.audits, domain.SoDTable, h.Create)))
+	server.GET("/sod/list", ginx.BS[SoDListReq](h.List))
+	server.POST("/sod/delete", ginx.BS(audited(h.audits, domain.SoDTable, h.Delete)))
+	server.GET("/sod/violations", ginx.BS[SoDListReq](h.Violations))
+}
+
+// Create 创建约束，互斥的角色和权限必须已经存在，避免名称写错的约束永远不生效
+// 约束只阻止之后的授权，已经存在的违反通过 Violations 查询
+func (h *SoDHandler) Create(ctx *ginx.Context, req SoDConstraintReq, sess session.Session) (ginx.Result, error) {
+	uid := sess.Claims().Uid
+	businessAdminCtx, err := h.prepare(ctx, req.BizID, uid, domain.PermissionActionWrite)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	constraint := domain.SoDConstraint{
+		BizID:       req.BizID,
+		Name:        req.Name,
+		Description: req.Description,
+		Kind:        domain.SoDKind(req.Kind),
+		Members:     req.Members,
+		CreatorUID:  uid,
+	}
+	if err = constraint.Validate(); err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	existing := make(map[string]bool)
+	if constraint.Kind == domain.SoDRole {
+		for _, r := range model.Roles {
+			existing[r.Ref()] = true
+		}
+	} else {
+		for _, p := range model.Permissions {
+			existing[p.Ref()] = true
+		}
+	}
+	for _, member := range constraint.Members {
+		if !existing[member] {
+			return ginx.Result{}, fmt.Errorf("%s %s 不存在", constraint.Kind, member)
+		}
+	}
+	constraint, err = h.svc.Create(ctx, constraint)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{Data: toSoDConstraintVO(constraint)}, nil
+}
+
+func (h *SoDHandler) List(ctx *ginx.Context, req SoDListReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.prepare(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionRead); err != nil {
+		return ginx.Result{}, err
+	}
+	constraints, err := h.svc.List(ctx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return listSlice(h.BaseHandler, req.ListReq, constraints, func(src domain.SoDConstraint) int64 { return src.ID }, toSoDConstraintVO)
+}
+
+func (h *SoDHandler) Delete(ctx *ginx.Context, req SoDConstraintIDReq, sess session.Session) (ginx.Result, error) {
+	if _, err := h.prepare(ctx, req.BizID, sess.Claims().Uid, domain.PermissionActionWrite); err != nil {
+		return ginx.Result{}, err
+	}
+	if err := h.svc.Delete(ctx, req.BizID, req.ID); err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{Data: true}, nil
+}
+
+// Violations 列出当前违反约束的用户，需要约束和全部业务表的读权限
+func (h *SoDHandler) Violations(ctx *ginx.Context, req SoDListReq, sess session.Session) (ginx.Result, error) {
+	uid := sess.Claims().Uid
+	if _, err := h.prepare(ctx, req.BizID, uid, domain.PermissionActionRead); err != nil {
+		return ginx.Result{}, err
+	}
+	businessAdminCtx, err := h.prepareModel(ctx, req.BizID, uid, domain.PermissionActionRead)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	model, err := h.loadModel(businessAdminCtx, req.BizID)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	violations, err := h.svc.Report(ctx, req.BizID, model)
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	// 违规没有唯一的ID，游标只按 offset 定位
+	return listSlice(h.BaseHandler, req.ListReq, violations, nil, func(src domain.SoDViolation) SoDViolation {
+		return SoDViolation{ConstraintID: src.ConstraintID, Name: src.Name, UserID: src.UserID, Held: src.Held}
+	})
+}
+
+func (h *SoDHandler) prepare(ctx context.Context, bizID, uid int64, action domain.PermissionActionType) (context.Context, error) {
+	businessAdminCtx, err := h.businessAdminCtx(ctx, bizID)
+	if err != nil {
+		return nil, err
+	}
+	return businessAdminCtx, h.checkBusinessPermission(businessAdminCtx, bizID, uid, domain.SoDTable, action)
+}
+
+func toSoDConstraintVO(src domain.SoDConstraint) SoDConstraint {
+	return SoDConstraint{
+		ID:          src.ID,
+		BizID:       src.BizID,
+		Name:        src.Name,
+		Description: src.Description,
+		Kind:        src.Kind.String(),
+		Members:     src.Members,
+		CreatorUID:  src.CreatorUID,
+		Ctime:       src.Ctime,
+		Utime:       src.Utime,
+	}
+}
@@ -2,7 +2,9 @@ package web
 
 import (
 	"gitee.com/flycash/permission-platform-admin/internal/domain"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
 	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/ecodeclub/ekit/slice"
 	"github.com/ecodeclub/ginx"
 	"github.com/ecodeclub/ginx/session"
 	"github.com/gin-gonic/gin"
@@ -11,49 +13,52 @@ import (
 
 type SystemAdminHandler struct {
 	*BaseHandler
+	tables *service.SystemTableService
 	logger *elog.Component
 }
 
-func NewSystemAdminHandler(handler *BaseHandler) *SystemAdminHandler {
-	return &SystemAdminHandler{BaseHandler: handler, logger: elog.DefaultLogger}
+func NewSystemAdminHandler(handler *BaseHandler, tables *service.SystemTableService) *SystemAdminHandler {
+	return &SystemAdminHandler{BaseHandler: handler, tables: tables, logger: elog.DefaultLogger}
 }
 
 //nolint:dupl // 忽略
 func (h *SystemAdminHandler) PrivateRoutes(server *gin.Engine) {
-	server.POST("/admin/resource/create", ginx.BS[ResourceReq](h.CreateResource))
+	server.POST("/admin/biz/backfill", ginx.BS(audited(h.audits, domain.BusinessConfigTable, h.Backfill)))
+
+	server.POST("/admin/resource/create", ginx.BS(audited(h.audits, domain.ResourceTable, h.CreateResource)))
 	server.GET("/admin/resource/get", ginx.BS[ResourceReq](h.GetResource))
 	server.GET("/admin/resource/list", ginx.BS[ListReq](h.ListResources))
-	server.POST("/admin/resource/update", ginx.BS[ResourceReq](h.UpdateResource))
-	server.POST("/admin/resource/delete", ginx.BS[ResourceReq](h.DeleteResource))
+	server.POST("/admin/resource/update", ginx.BS(audited(h.audits, domain.ResourceTable, h.UpdateResource)))
+	server.POST("/admin/resource/delete", ginx.BS(audited(h.audits, domain.ResourceTable, h.DeleteResource)))
 
-	server.POST("/admin/permission/create", ginx.BS[PermissionReq](h.CreatePermission))
+	server.POST("/admin/permission/create", ginx.BS(audited(h.audits, domain.PermissionTable, h.CreatePermission)))
 	server.GET("/admin/permission/get", ginx.BS[PermissionReq](h.GetPermission))
 	server.GET("/admin/permission/list", ginx.BS[ListReq](h.ListPermissions))
-	server.POST("/admin/permission/update", ginx.BS[PermissionReq](h.UpdatePermission))
-	server.POST("/admin/permission/delete", ginx.BS[PermissionReq](h.DeletePermission))
+	server.POST("/admin/permission/update", ginx.BS(audited(h.audits, domain.PermissionTable, h.UpdatePermission)))
+	server.POST("/admin/permission/delete", ginx.BS(audited(h.audits, domain.PermissionTable, h.DeletePermission)))
 
-	server.POST("/admin/role/create", ginx.BS[RoleReq](h.CreateRole))
+	server.POST("/admin/role/create", ginx.BS(audited(h.audits, domain.RoleTable, h.CreateRole)))
 	server.GET("/admin/role/get", ginx.BS[RoleReq](h.GetRole))
 	server.GET("/admin/role/list", ginx.BS[ListReq](h.ListRoles))
-	server.POST("/admin/role/update", ginx.BS[RoleReq](h.UpdateRole))
-	server.POST("/admin/role/delete", ginx.BS[RoleReq](h.DeleteRole))
+	server.POST("/admin/role/update", ginx.BS(audited(h.audits, domain.RoleTable, h.UpdateRole)))
+	server.POST("/admin/role/delete", ginx.BS(audited(h.audits, domain.RoleTable, h.DeleteRole)))
 
-	server.POST("/admin/role-inclusion/create", ginx.BS[RoleInclusionReq](h.CreateRoleInclusion))
+	server.POST("/admin/role-inclusion/create", ginx.BS(audited(h.audits, domain.RoleInclusionTable, h.CreateRoleInclusion)))
 	server.GET("/admin/role-inclusion/get", ginx.BS[RoleInclusionReq](h.GetRoleInclusion))
 	server.GET("/admin/role-inclusion/list", ginx.BS[ListReq](h.ListRoleInclusions))
-	server.POST("/admin/role-inclusion/delete", ginx.BS[RoleInclusionReq](h.DeleteRoleInclusion))
+	server.POST("/admin/role-inclusion/delete", ginx.BS(audited(h.audits, domain.RoleInclusionTable, h.DeleteRoleInclusion)))
 
-	server.POST("/admin/role/grant_permission", ginx.BS[RolePermissionReq](h.GrantRolePermission))
+	server.POST("/admin/role/grant_permission", ginx.BS(audited(h.audits, domain.RolePermissionTable, h.GrantRolePermission)))
 	server.GET("/admin/role/list_permission", ginx.BS[ListReq](h.ListRolePermissions))
-	server.POST("/admin/role/revoke_permission", ginx.BS[RolePermissionReq](h.RevokeRolePermission))
+	server.POST("/admin/role/revoke_permission", ginx.BS(audited(h.audits, domain.RolePermissionTable, h.RevokeRolePermission)))
 
-	server.POST("/admin/user/grant_role", ginx.BS(h.GrantUserRole))
+	server.POST("/admin/user/grant_role", ginx.BS(audited(h.audits, domain.UserRoleTable, h.GrantUserRole)))
 	server.GET("/admin/user/list_role", ginx.BS[ListReq](h.ListUserRoles))
-	server.POST("/admin/user/revoke_role", ginx.BS(h.RevokeUserRole))
+	server.POST("/admin/user/revoke_role", ginx.BS(audited(h.audits, domain.UserRoleTable, h.RevokeUserRole)))
 
-	server.POST("/admin/user/grant_permission", ginx.BS[UserPermissionReq](h.GrantUserPermission))
+	server.POST("/admin/user/grant_permission", ginx.BS(audited(h.audits, domain.UserPermissionTable, h.GrantUserPermission)))
 	server.GET("/admin/user/list_permission", ginx.BS[ListReq](h.ListUserPermissions))
-	server.POST("/admin/user/revoke_permission", ginx.BS[UserPermissionReq](h.RevokeUserPermission))
+	server.POST("/admin/user/revoke_permission", ginx.BS(audited(h.audits, domain.UserPermissionTable, h.RevokeUserPermission)))
 }
 
 func (h *SystemAdminHandler) createCheckPermissionRequest(bizID, uid int64, resource domain.SystemTableResource, action domain.PermissionActionType) *permissionv1.CheckPermissionRequest {
@@ -70,6 +75,39 @@ func (h *SystemAdminHandler) createCheckPermissionRequest(bizID, uid int64, reso
 
 // BusinessConfig
 
+// Backfill 为已经接入的业务补齐后来新增的系统表，bizId 为 0 时处理全部业务
+// 补齐是幂等的，部分业务失败时修复后重新执行即可
+func (h *SystemAdminHandler) Backfill(ctx *ginx.Context, req SystemTableBackfillReq, sess session.Session) (ginx.Result, error) {
+	systemAdminCtx := h.systemAdminCtx(ctx)
+	err := h.checkPermission(systemAdminCtx, h.createCheckPermissionRequest(req.BizID, sess.Claims().Uid, domain.BusinessConfigTable, domain.PermissionActionWrite))
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	var res []domain.SystemTableBackfill
+	if req.BizID > 0 {
+		var backfill domain.SystemTableBackfill
+		backfill, err = h.tables.Backfill(ctx, req.BizID)
+		res = append(res, backfill)
+	} else {
+		res, err = h.tables.BackfillAll(ctx)
+	}
+	if err != nil {
+		return ginx.Result{}, err
+	}
+	return ginx.Result{
+		Data: ListResp[SystemTableBackfill]{
+			Rows: slice.Map(res, func(_ int, src domain.SystemTableBackfill) SystemTableBackfill {
+				return SystemTableBackfill{
+					BizID:           src.BizID,
+					Resources:       src.Resources,
+					Permissions:     src.Permissions,
+					RolePermissions: src.RolePermissions,
+				}
+			}),
+		},
+	}, nil
+}
+
 func (h *SystemAdminHandler) CreateBusinessConfig(ctx *ginx.Context, req BusinessConfigReq, sess session.Session) (ginx.Result, error) {
 	systemAdminCtx := h.systemAdminCtx(ctx)
 	err := h.checkPermission(systemAdminCtx, h.createCheckPermissionRequest(req.BizID, sess.Claims().Uid, domain.BusinessConfigTable, domain.PermissionActionWrite))
@@ -18,14 +18,24 @@ type BusinessConfigReq struct {
 	BusinessConfig BusinessConfig `json:"businessConfig,omitzero"`
 }
 
+// ListReq 按照 offset 和 limit 分页，大表使用上一页返回的 cursor 分页
 type ListReq struct {
-	BizID  int64 `json:"bizId,omitzero"`
-	Offset int   `json:"offset,omitzero"`
-	Limit  int   `json:"limit,omitzero"`
+	BizID  int64 `json:"bizId,omitzero" form:"bizId"`
+	Offset int   `json:"offset,omitzero" form:"offset"`
+	// Limit 默认 20，不能超过配置的上限
+	Limit int `json:"limit,omitzero" form:"limit"`
+	// Cursor 指定时忽略 Offset，并且不计算总数
+	Cursor string `json:"cursor,omitzero" form:"cursor"`
+	// WithTotal 返回总数，需要读取剩下的全部行，大表不要使用
+	WithTotal bool `json:"withTotal,omitzero" form:"withTotal"`
 }
 
 type ListResp[T any] struct {
 	Rows []T `json:"rows,omitzero"`
+	// Total 按照 offset 分页并且指定了 WithTotal 时的总数
+	Total int `json:"total,omitzero"`
+	// Cursor 下一页的游标，没有下一页时为空
+	Cursor string `json:"cursor,omitzero"`
 }
 
 // Resource 资源
@@ -109,6 +119,8 @@ type UserRole struct {
 type UserRoleReq struct {
 	BizID    int64    `json:"bizId,omitzero"`
 	UserRole UserRole `json:"userRole,omitzero"`
+	// Reason 申请理由，授权需要审批时记录在变更请求中
+	Reason string `json:"reason,omitzero"`
 }
 
 type UserPermission struct {
@@ -124,6 +136,7 @@ type UserPermission struct {
 type UserPermissionReq struct {
 	BizID          int64          `json:"bizId,omitzero"`
 	UserPermission UserPermission `json:"userPermission,omitzero"`
+	Reason         string         `json:"reason,omitzero"`
 }
 
 type CreateAccountRoleReq struct {
@@ -149,9 +162,10 @@ type RevokeRolePermissionReq struct {
 }
 
 type GrantUserRoleReq struct {
-	BizID  int64 `json:"bizId,omitzero"`
-	UserID int64 `json:"userId,omitzero"`
-	Role   Role  `json:"role,omitzero"`
+	BizID  int64  `json:"bizId,omitzero"`
+	UserID int64  `json:"userId,omitzero"`
+	Role   Role   `json:"role,omitzero"`
+	Reason string `json:"reason,omitzero"`
 }
 
 type RevokeUserRoleReq struct {
@@ -160,9 +174,1060 @@ type RevokeUserRoleReq struct {
 }
 
 type LoginReq struct {
-	ID   int64  `json:"id"`
-	BizID int64  `json:"bizId"`
+	ID       int64  `json:"id"`
+	BizID    int64  `json:"bizId"`
 	Username string `json:"username,omitzero"`
 	Password string `json:"password,omitzero"`
 }
 
+type EventStreamReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// LastEventID 首次连接时指定的续传位置，重连时以 Last-Event-ID 头部为准
+	LastEventID string `json:"lastEventId,omitzero" form:"lastEventId"`
+}
+
+func (r ResourceReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Resource.ID}
+}
+
+func (r PermissionReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Permission.ID}
+}
+
+func (r RoleReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Role.ID}
+}
+
+func (r RoleInclusionReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.RoleInclusion.ID}
+}
+
+func (r RolePermissionReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.RolePermission.ID}
+}
+
+func (r UserRoleReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.UserRole.ID, TargetUserID: r.UserRole.UserID}
+}
+
+func (r UserPermissionReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.UserPermission.ID, TargetUserID: r.UserPermission.UserID}
+}
+
+func (r CreateAccountRoleReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Role.ID}
+}
+
+func (r GrantAccountRolePermissionReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+func (r RevokeRolePermissionReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+func (r GrantUserRoleReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetUserID: r.UserID}
+}
+
+func (r RevokeUserRoleReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+type AuditListReq struct {
+	BizID        int64  `json:"bizId,omitzero" form:"bizId"`
+	ActorUID     int64  `json:"actorUid,omitzero" form:"actorUid"`
+	TargetUserID int64  `json:"targetUserId,omitzero" form:"targetUserId"`
+	Table        string `json:"table,omitzero" form:"table"`
+	StartTime    int64  `json:"startTime,omitzero" form:"startTime"`
+	EndTime      int64  `json:"endTime,omitzero" form:"endTime"`
+	// Outcome success 或 failure
+	Outcome string `json:"outcome,omitzero" form:"outcome"`
+	Cursor  string `json:"cursor,omitzero" form:"cursor"`
+	Limit   int    `json:"limit,omitzero" form:"limit"`
+}
+
+type AuditRecord struct {
+	ID           string `json:"id"`
+	BizID        int64  `json:"bizId"`
+	ActorUID     int64  `json:"actorUid"`
+	Route        string `json:"route"`
+	Table        string `json:"table"`
+	TargetID     int64  `json:"targetId,omitzero"`
+	TargetUserID int64  `json:"targetUserId,omitzero"`
+	Payload      string `json:"payload,omitzero"`
+	Result       string `json:"result,omitzero"`
+	Error        string `json:"error,omitzero"`
+	Outcome      string `json:"outcome"`
+	ClientIP     string `json:"clientIp,omitzero"`
+	Ctime        int64  `json:"ctime"`
+	Seq          int64  `json:"seq,omitzero"`
+	PrevHash     string `json:"prevHash,omitzero"`
+	Hash         string `json:"hash,omitzero"`
+}
+
+type AuditListResp struct {
+	Rows []AuditRecord `json:"rows,omitzero"`
+	// Cursor 不为空时，用它查询下一页
+	Cursor string `json:"cursor,omitzero"`
+}
+
+type AuditVerifyReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// From 和 To 是毫秒时间戳，为0表示不限制
+	From int64 `json:"from,omitzero" form:"from"`
+	To   int64 `json:"to,omitzero" form:"to"`
+}
+
+type SystemTableBackfillReq struct {
+	// BizID 为 0 时处理全部业务
+	BizID int64 `json:"bizId,omitzero"`
+}
+
+func (r SystemTableBackfillReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type SystemTableBackfill struct {
+	BizID           int64    `json:"bizId"`
+	Resources       []string `json:"resources,omitzero"`
+	Permissions     []string `json:"permissions,omitzero"`
+	RolePermissions []string `json:"rolePermissions,omitzero"`
+}
+
+type AuditVerification struct {
+	BizID        int64  `json:"bizId"`
+	Checked      int    `json:"checked"`
+	Checkpoints  int    `json:"checkpoints"`
+	Valid        bool   `json:"valid"`
+	BrokenID     string `json:"brokenId,omitzero"`
+	BrokenReason string `json:"brokenReason,omitzero"`
+}
+
+type ExportReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// Format csv 或者 jsonl
+	Format string `json:"format,omitzero" form:"format"`
+	Gzip   bool   `json:"gzip,omitzero" form:"gzip"`
+	// StartTime 和 EndTime 是毫秒时间戳，为0表示不限制
+	// 授权按照生效时间段是否和查询时间段有交集过滤，审计日志按照记录时间过滤
+	StartTime int64 `json:"startTime,omitzero" form:"startTime"`
+	EndTime   int64 `json:"endTime,omitzero" form:"endTime"`
+}
+
+type ApprovalPolicy struct {
+	ID            int64   `json:"id,omitzero"`
+	BizID         int64   `json:"bizId,omitzero"`
+	Name          string  `json:"name,omitzero"`
+	RoleIDs       []int64 `json:"roleIds,omitzero"`
+	PermissionIDs []int64 `json:"permissionIds,omitzero"`
+	Approvers     []int64 `json:"approvers,omitzero"`
+	// RequiredApprovals 需要多少个审批人同意
+	RequiredApprovals int `json:"requiredApprovals,omitzero"`
+	// TTL 变更请求的有效期，毫秒
+	TTL   int64 `json:"ttl,omitzero"`
+	Ctime int64 `json:"ctime,omitzero"`
+	Utime int64 `json:"utime,omitzero"`
+}
+
+type ApprovalPolicyReq struct {
+	BizID  int64          `json:"bizId,omitzero"`
+	Policy ApprovalPolicy `json:"policy,omitzero"`
+}
+
+func (r ApprovalPolicyReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Policy.ID}
+}
+
+type ApprovalPolicyListReq struct {
+	ListReq
+}
+
+type ApprovalDecision struct {
+	UID     int64  `json:"uid"`
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment,omitzero"`
+	Ctime   int64  `json:"ctime"`
+}
+
+type ChangeRequest struct {
+	ID                int64              `json:"id"`
+	BizID             int64              `json:"bizId"`
+	Kind              string             `json:"kind"`
+	PolicyID          int64              `json:"policyId"`
+	TargetID          int64              `json:"targetId"`
+	TargetUserID      int64              `json:"targetUserId"`
+	Payload           string             `json:"payload"`
+	RequesterUID      int64              `json:"requesterUid"`
+	Reason            string             `json:"reason,omitzero"`
+	Approvers         []int64            `json:"approvers"`
+	RequiredApprovals int                `json:"requiredApprovals"`
+	Decisions         []ApprovalDecision `json:"decisions,omitzero"`
+	Duration          int64              `json:"duration,omitzero"`
+	GrantStartTime    int64              `json:"grantStartTime,omitzero"`
+	GrantEndTime      int64              `json:"grantEndTime,omitzero"`
+	Status            string             `json:"status"`
+	Result            string             `json:"result,omitzero"`
+	ExpireAt          int64              `json:"expireAt"`
+	Ctime             int64              `json:"ctime"`
+	Utime             int64              `json:"utime"`
+}
+
+// SubmitChangeRequestReq 主动提交授权变更请求
+// Kind 为 grant_user_role 或者 grant_account_role 时使用 UserRole，为 grant_user_permission 时使用 UserPermission
+type SubmitChangeRequestReq struct {
+	BizID          int64          `json:"bizId,omitzero"`
+	Kind           string         `json:"kind,omitzero"`
+	Reason         string         `json:"reason,omitzero"`
+	UserRole       UserRole       `json:"userRole,omitzero"`
+	UserPermission UserPermission `json:"userPermission,omitzero"`
+}
+
+func (r SubmitChangeRequestReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetUserID: max(r.UserRole.UserID, r.UserPermission.UserID)}
+}
+
+type ChangeRequestReq struct {
+	BizID   int64  `json:"bizId,omitzero" form:"bizId"`
+	ID      int64  `json:"id,omitzero" form:"id"`
+	Comment string `json:"comment,omitzero"`
+}
+
+func (r ChangeRequestReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+type ChangeRequestListReq struct {
+	ListReq
+	Status string `json:"status,omitzero" form:"status"`
+	// Mine 只查询自己提交的或者需要自己审批的请求，没有审批读权限时总是为 true
+	Mine bool `json:"mine,omitzero" form:"mine"`
+}
+
+type AccessPolicy struct {
+	BizID  int64   `json:"bizId,omitzero"`
+	RoleID int64   `json:"roleId,omitzero"`
+	Owners []int64 `json:"owners,omitzero"`
+	// MaxDuration 单次申请的最长时长，毫秒
+	MaxDuration int64 `json:"maxDuration,omitzero"`
+	Ctime       int64 `json:"ctime,omitzero"`
+	Utime       int64 `json:"utime,omitzero"`
+}
+
+type AccessPolicyReq struct {
+	BizID  int64        `json:"bizId,omitzero"`
+	Policy AccessPolicy `json:"policy,omitzero"`
+}
+
+func (r AccessPolicyReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Policy.RoleID}
+}
+
+type AccessPolicyListReq struct {
+	ListReq
+}
+
+type AccessRequestReq struct {
+	BizID  int64 `json:"bizId,omitzero"`
+	RoleID int64 `json:"roleId,omitzero"`
+	// Duration 申请时长，毫秒
+	Duration int64  `json:"duration,omitzero"`
+	Reason   string `json:"reason,omitzero"`
+}
+
+func (r AccessRequestReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.RoleID}
+}
+
+type AccessRequestListReq struct {
+	ListReq
+	Status string `json:"status,omitzero" form:"status"`
+}
+
+type BreakGlassPolicy struct {
+	BizID      int64   `json:"bizId,omitzero"`
+	RoleID     int64   `json:"roleId,omitzero"`
+	Responders []int64 `json:"responders,omitzero"`
+	Watchers   []int64 `json:"watchers,omitzero"`
+	// Window 紧急授权时长，毫秒
+	Window int64 `json:"window,omitzero"`
+	Ctime  int64 `json:"ctime,omitzero"`
+	Utime  int64 `json:"utime,omitzero"`
+}
+
+type BreakGlassPolicyReq struct {
+	BizID  int64            `json:"bizId,omitzero"`
+	Policy BreakGlassPolicy `json:"policy,omitzero"`
+}
+
+func (r BreakGlassPolicyReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.Policy.RoleID}
+}
+
+type BreakGlassPolicyGetReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+}
+
+type BreakGlassActivateReq struct {
+	BizID  int64  `json:"bizId,omitzero"`
+	Reason string `json:"reason,omitzero"`
+}
+
+func (r BreakGlassActivateReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type BreakGlassReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	ID    int64 `json:"id,omitzero" form:"id"`
+}
+
+func (r BreakGlassReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+type BreakGlassListReq struct {
+	ListReq
+}
+
+type BreakGlassSession struct {
+	ID         int64  `json:"id"`
+	BizID      int64  `json:"bizId"`
+	UID        int64  `json:"uid"`
+	RoleID     int64  `json:"roleId"`
+	RoleName   string `json:"roleName"`
+	UserRoleID int64  `json:"userRoleId,omitzero"`
+	Reason     string `json:"reason"`
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	RevokedAt  int64  `json:"revokedAt,omitzero"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitzero"`
+}
+
+// BreakGlassReport 紧急授权的事后报告
+type BreakGlassReport struct {
+	Session BreakGlassSession `json:"session"`
+	// Actions 紧急授权期间持有人的全部写操作
+	Actions []AuditRecord `json:"actions"`
+}
+
+type ReviewCampaign struct {
+	ID         int64   `json:"id,omitzero"`
+	BizID      int64   `json:"bizId,omitzero"`
+	Name       string  `json:"name,omitzero"`
+	RoleIDs    []int64 `json:"roleIds,omitzero"`
+	Privileged bool    `json:"privileged,omitzero"`
+	// DefaultReviewer 角色没有负责人、用户也没有上级时的审查人
+	DefaultReviewer int64 `json:"defaultReviewer,omitzero"`
+	// Deadline 截止时间，毫秒时间戳
+	Deadline int64 `json:"deadline,omitzero"`
+	// ExpirePolicy 超期未审查的授权如何处理，revoke 或者 escalate
+	ExpirePolicy string `json:"expirePolicy,omitzero"`
+	EscalateTo   int64  `json:"escalateTo,omitzero"`
+	CreatorUID   int64  `json:"creatorUid,omitzero"`
+	Status       string `json:"status,omitzero"`
+	Ctime        int64  `json:"ctime,omitzero"`
+	Utime        int64  `json:"utime,omitzero"`
+}
+
+type ReviewCampaignReq struct {
+	BizID    int64          `json:"bizId,omitzero"`
+	Campaign ReviewCampaign `json:"campaign,omitzero"`
+	// Managers 用户ID到上级用户ID的映射，角色没有负责人时由上级审查
+	Managers map[int64]int64 `json:"managers,omitzero"`
+}
+
+func (r ReviewCampaignReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type ReviewCampaignListReq struct {
+	ListReq
+}
+
+type ReviewReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	ID    int64 `json:"id,omitzero" form:"id"`
+}
+
+type ReviewItemListReq struct {
+	ListReq
+	CampaignID int64 `json:"campaignId,omitzero" form:"campaignId"`
+	// All 查询全部审查项，需要审计日志的读权限，默认只返回分配给自己的
+	All bool `json:"all,omitzero" form:"all"`
+}
+
+type ReviewItemReq struct {
+	BizID      int64  `json:"bizId,omitzero"`
+	CampaignID int64  `json:"campaignId,omitzero"`
+	ItemID     int64  `json:"itemId,omitzero"`
+	Comment    string `json:"comment,omitzero"`
+}
+
+func (r ReviewItemReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ItemID}
+}
+
+type ReviewItem struct {
+	ID         int64  `json:"id"`
+	CampaignID int64  `json:"campaignId"`
+	UserRoleID int64  `json:"userRoleId"`
+	UserID     int64  `json:"userId"`
+	RoleID     int64  `json:"roleId"`
+	RoleName   string `json:"roleName"`
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	Reviewer   int64  `json:"reviewer"`
+	Escalated  bool   `json:"escalated,omitzero"`
+	Decision   string `json:"decision"`
+	Comment    string `json:"comment,omitzero"`
+	DecidedBy  int64  `json:"decidedBy,omitzero"`
+	DecidedAt  int64  `json:"decidedAt,omitzero"`
+}
+
+// ReviewReport 权限审查报告
+type ReviewReport struct {
+	Campaign    ReviewCampaign `json:"campaign"`
+	Total       int            `json:"total"`
+	Certified   int            `json:"certified"`
+	Revoked     int            `json:"revoked"`
+	AutoRevoked int            `json:"autoRevoked"`
+	Escalated   int            `json:"escalated"`
+	Pending     int            `json:"pending"`
+	Items       []ReviewItem   `json:"items"`
+}
+
+type ExpiringReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// WithinDays 查询接下来多少天内到期的授权
+	WithinDays int `json:"withinDays,omitzero" form:"withinDays"`
+}
+
+type ExpiringGrants struct {
+	UserRoles       []UserRole       `json:"userRoles"`
+	UserPermissions []UserPermission `json:"userPermissions"`
+}
+
+type ArchivedGrantListReq struct {
+	ListReq
+}
+
+type ArchivedGrant struct {
+	// Table 授权所在的表，user_roles 或者 user_permissions
+	Table   string `json:"table"`
+	GrantID int64  `json:"grantId"`
+	UserID  int64  `json:"userId"`
+	// TargetID 角色ID或者权限ID
+	TargetID   int64  `json:"targetId"`
+	TargetName string `json:"targetName"`
+	StartTime  int64  `json:"startTime"`
+	EndTime    int64  `json:"endTime"`
+	ArchivedAt int64  `json:"archivedAt"`
+}
+
+type ScheduleChangeReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	// Kind 变更类型，例如 grant_user_role、revoke_role_permission
+	Kind string `json:"kind,omitzero"`
+	// ExecuteAt 执行时间，毫秒时间戳
+	ExecuteAt int64  `json:"executeAt,omitzero"`
+	Reason    string `json:"reason,omitzero"`
+	// 根据变更类型填写其中一个，回收时只需要授权ID
+	UserRole       UserRole       `json:"userRole,omitzero"`
+	UserPermission UserPermission `json:"userPermission,omitzero"`
+	RolePermission RolePermission `json:"rolePermission,omitzero"`
+}
+
+func (r ScheduleChangeReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetUserID: max(r.UserRole.UserID, r.UserPermission.UserID)}
+}
+
+type ScheduledChangeReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	ID    int64 `json:"id,omitzero"`
+	// ExecuteAt 修改执行时间时的新执行时间
+	ExecuteAt int64 `json:"executeAt,omitzero"`
+}
+
+func (r ScheduledChangeReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+type ScheduledChangeListReq struct {
+	ListReq
+}
+
+type ScheduledChange struct {
+	ID           int64  `json:"id"`
+	BizID        int64  `json:"bizId"`
+	Kind         string `json:"kind"`
+	TargetID     int64  `json:"targetId,omitzero"`
+	TargetUserID int64  `json:"targetUserId,omitzero"`
+	Payload      string `json:"payload"`
+	Reason       string `json:"reason,omitzero"`
+	CreatorUID   int64  `json:"creatorUid"`
+	ExecuteAt    int64  `json:"executeAt"`
+	Status       string `json:"status"`
+	Attempts     int    `json:"attempts,omitzero"`
+	Result       string `json:"result,omitzero"`
+	ExecutedAt   int64  `json:"executedAt,omitzero"`
+	Ctime        int64  `json:"ctime"`
+	Utime        int64  `json:"utime"`
+}
+
+type BizModelExportReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// Format json 或者 yaml，默认 json
+	Format string `json:"format,omitzero" form:"format"`
+	// Declarative 导出为声明式文件，不包含用户授权
+	Declarative bool `json:"declarative,omitzero" form:"declarative"`
+}
+
+// BizModel 业务完整的权限模型文档，导出和导入使用同一个格式
+// 各部分之间用资源类型和标识、权限动作、角色名互相引用，不包含ID
+type BizModel struct {
+	Version int `json:"version" yaml:"version"`
+	// BizID 和 ExportedAt 记录文档的来源，导入时忽略
+	BizID           int64                    `json:"bizId,omitzero" yaml:"bizId,omitempty"`
+	ExportedAt      int64                    `json:"exportedAt,omitzero" yaml:"exportedAt,omitempty"`
+	Resources       []BizModelResource       `json:"resources" yaml:"resources"`
+	Permissions     []BizModelPermission     `json:"permissions" yaml:"permissions"`
+	Roles           []BizModelRole           `json:"roles" yaml:"roles"`
+	RoleInclusions  []BizModelRoleInclusion  `json:"roleInclusions" yaml:"roleInclusions"`
+	RolePermissions []BizModelRolePermission `json:"rolePermissions" yaml:"rolePermissions"`
+	UserRoles       []BizModelUserRole       `json:"userRoles" yaml:"userRoles"`
+	UserPermissions []BizModelUserPermission `json:"userPermissions" yaml:"userPermissions"`
+}
+
+type BizModelResource struct {
+	Type        string `json:"type" yaml:"type"`
+	Key         string `json:"key" yaml:"key"`
+	Name        string `json:"name,omitzero" yaml:"name,omitempty"`
+	Description string `json:"description,omitzero" yaml:"description,omitempty"`
+	Metadata    string `json:"metadata,omitzero" yaml:"metadata,omitempty"`
+}
+
+type BizModelPermission struct {
+	Name         string `json:"name,omitzero" yaml:"name,omitempty"`
+	Description  string `json:"description,omitzero" yaml:"description,omitempty"`
+	ResourceType string `json:"resourceType" yaml:"resourceType"`
+	ResourceKey  string `json:"resourceKey" yaml:"resourceKey"`
+	Action       string `json:"action" yaml:"action"`
+	Metadata     string `json:"metadata,omitzero" yaml:"metadata,omitempty"`
+}
+
+type BizModelRole struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitzero" yaml:"description,omitempty"`
+	Metadata    string `json:"metadata,omitzero" yaml:"metadata,omitempty"`
+}
+
+type BizModelRoleInclusion struct {
+	// Including 包含其他角色的角色名
+	Including string `json:"including" yaml:"including"`
+	// Included 被包含的角色名
+	Included string `json:"included" yaml:"included"`
+}
+
+type BizModelRolePermission struct {
+	Role         string `json:"role" yaml:"role"`
+	ResourceType string `json:"resourceType" yaml:"resourceType"`
+	ResourceKey  string `json:"resourceKey" yaml:"resourceKey"`
+	Action       string `json:"action" yaml:"action"`
+}
+
+type BizModelUserRole struct {
+	UserID    int64  `json:"userId" yaml:"userId"`
+	Role      string `json:"role" yaml:"role"`
+	StartTime int64  `json:"startTime,omitzero" yaml:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitzero" yaml:"endTime,omitempty"`
+}
+
+type BizModelUserPermission struct {
+	UserID       int64  `json:"userId" yaml:"userId"`
+	ResourceType string `json:"resourceType" yaml:"resourceType"`
+	ResourceKey  string `json:"resourceKey" yaml:"resourceKey"`
+	Action       string `json:"action" yaml:"action"`
+	Effect       string `json:"effect" yaml:"effect"`
+	StartTime    int64  `json:"startTime,omitzero" yaml:"startTime,omitempty"`
+	EndTime      int64  `json:"endTime,omitzero" yaml:"endTime,omitempty"`
+}
+
+type BizModelImportReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	// Strategy 已经存在并且内容不同时的处理方式，skip、overwrite 或者 fail，默认 fail
+	Strategy string `json:"strategy,omitzero"`
+	// DryRun 只校验和生成导入计划，不写入
+	DryRun bool `json:"dryRun,omitzero"`
+	// Model 和 Document 二选一，Document 是 YAML 或者 JSON 格式的模型文档
+	Model    *BizModel `json:"model,omitzero"`
+	Document string    `json:"document,omitzero"`
+}
+
+func (r BizModelImportReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type BizModelChange struct {
+	Table string `json:"table"`
+	// Op create、update、delete、skip 或者 unchanged
+	Op  string `json:"op"`
+	Ref string `json:"ref"`
+}
+
+type BizModelImportResult struct {
+	DryRun bool `json:"dryRun,omitzero"`
+	// Problems 校验发现的问题，不为空时没有写入任何数据
+	Problems []string `json:"problems,omitzero"`
+	// Conflicts fail 策略下发现的冲突，不为空时没有写入任何数据
+	Conflicts []string         `json:"conflicts,omitzero"`
+	Changes   []BizModelChange `json:"changes,omitzero"`
+	Counts    map[string]int   `json:"counts,omitzero"`
+	// Applied 已经执行的新建和更新数量，执行中途失败时可以据此判断进度
+	Applied int `json:"applied"`
+}
+
+type BulkUserRoleUploadReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// DryRun 只校验不授权
+	DryRun bool `json:"dryRun,omitzero" form:"dryRun"`
+}
+
+func (r BulkUserRoleUploadReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type BulkUserRoleRow struct {
+	Line      int    `json:"line"`
+	UserID    int64  `json:"userId"`
+	RoleID    int64  `json:"roleId,omitzero"`
+	RoleName  string `json:"roleName,omitzero"`
+	StartTime int64  `json:"startTime,omitzero"`
+	EndTime   int64  `json:"endTime,omitzero"`
+	// Status invalid、pending、exists、granted 或者 failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitzero"`
+}
+
+type BulkGrantResult struct {
+	DryRun bool `json:"dryRun,omitzero"`
+	Total  int  `json:"total"`
+	// Counts 每种处理结果的行数
+	Counts map[string]int    `json:"counts"`
+	Rows   []BulkUserRoleRow `json:"rows"`
+}
+
+// BizModelPlanReq 声明式管理业务的资源、权限、角色、角色包含和角色权限
+type BizModelPlanReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	// Model 和 Document 二选一，Document 是 YAML 或者 JSON 格式的声明式文件
+	Model    *BizModel `json:"model,omitzero"`
+	Document string    `json:"document,omitzero"`
+	// Fingerprint apply 时必须传入 plan 返回的摘要，当前状态发生变化时拒绝执行
+	Fingerprint string `json:"fingerprint,omitzero"`
+}
+
+func (r BizModelPlanReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type BizModelPlan struct {
+	// Problems 校验发现的问题，不为空时没有计划
+	Problems    []string         `json:"problems,omitzero"`
+	Warnings    []string         `json:"warnings,omitzero"`
+	Fingerprint string           `json:"fingerprint,omitzero"`
+	Changes     []BizModelChange `json:"changes,omitzero"`
+	Counts      map[string]int   `json:"counts,omitzero"`
+	// Applied apply 时已经执行的变更数量
+	Applied int `json:"applied,omitzero"`
+}
+
+type SnapshotCreateReq struct {
+	BizID int64  `json:"bizId,omitzero"`
+	Note  string `json:"note,omitzero"`
+}
+
+func (r SnapshotCreateReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type SnapshotReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	ID    int64 `json:"id,omitzero" form:"id"`
+}
+
+type SnapshotListReq struct {
+	ListReq
+}
+
+type SnapshotRollbackReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	ID    int64 `json:"id,omitzero"`
+	// DryRun 只计算回滚需要的变更，不写入任何数据
+	DryRun bool `json:"dryRun,omitzero"`
+	// Fingerprint 预览返回的计划摘要，回滚时必须指定，当前状态在预览之后发生了变化就拒绝回滚
+	Fingerprint string `json:"fingerprint,omitzero"`
+}
+
+func (r SnapshotRollbackReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+type Snapshot struct {
+	ID         int64          `json:"id"`
+	BizID      int64          `json:"bizId"`
+	Trigger    string         `json:"trigger"`
+	CreatorUID int64          `json:"creatorUid,omitzero"`
+	Note       string         `json:"note,omitzero"`
+	Counts     map[string]int `json:"counts"`
+	Size       int            `json:"size"`
+	Ctime      int64          `json:"ctime"`
+	// Model 只在查询单个快照时返回
+	Model *BizModel `json:"model,omitzero"`
+}
+
+type SnapshotRollbackResult struct {
+	BizModelPlan
+	DryRun bool `json:"dryRun"`
+	// BackupID 回滚前自动保存的当前状态的快照ID
+	BackupID int64 `json:"backupId,omitzero"`
+}
+
+type EffectivePermissionsReq struct {
+	BizID  int64 `json:"bizId,omitzero" form:"bizId"`
+	UserID int64 `json:"userId,omitzero" form:"userId"`
+	// At 计算哪个时刻的权限，毫秒时间戳，默认当前时间
+	At int64 `json:"at,omitzero" form:"at"`
+}
+
+type EffectivePermissions struct {
+	UserID      int64                 `json:"userId"`
+	At          int64                 `json:"at"`
+	Permissions []EffectivePermission `json:"permissions"`
+}
+
+type EffectivePermission struct {
+	ResourceType string             `json:"resourceType"`
+	ResourceKey  string             `json:"resourceKey"`
+	Action       string             `json:"action"`
+	Effect       string             `json:"effect"`
+	Sources      []PermissionSource `json:"sources"`
+}
+
+type PermissionSource struct {
+	// Path 例如 "管理员 > 编辑"，直接授予时为 "direct"
+	Path      string   `json:"path"`
+	Roles     []string `json:"roles,omitzero"`
+	Effect    string   `json:"effect"`
+	StartTime int64    `json:"startTime,omitzero"`
+	EndTime   int64    `json:"endTime,omitzero"`
+}
+
+type WhoCanReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// ResourceType 为空时匹配全部资源类型
+	ResourceType string `json:"resourceType,omitzero" form:"resourceType"`
+	ResourceKey  string `json:"resourceKey,omitzero" form:"resourceKey"`
+	Action       string `json:"action,omitzero" form:"action"`
+	// At 计算哪个时刻的权限，毫秒时间戳，默认当前时间
+	At     int64 `json:"at,omitzero" form:"at"`
+	Offset int   `json:"offset,omitzero" form:"offset"`
+	Limit  int   `json:"limit,omitzero" form:"limit"`
+	// Format 为 csv 或者 jsonl 时以文件形式导出全部用户，忽略分页参数
+	Format string `json:"format,omitzero" form:"format"`
+	Gzip   bool   `json:"gzip,omitzero" form:"gzip"`
+}
+
+type WhoCan struct {
+	At    int64              `json:"at"`
+	Roles []RoleHolder       `json:"roles"`
+	Users []PermissionHolder `json:"users"`
+	// Total 用户的总数，Users 只包含当前页
+	Total int `json:"total"`
+}
+
+type RoleHolder struct {
+	Role       string `json:"role"`
+	Permission string `json:"permission"`
+	// Path 例如 "管理员 > 编辑"，角色直接持有权限时只有角色名
+	Path string `json:"path"`
+}
+
+type PermissionHolder struct {
+	UserID int64 `json:"userId"`
+	EffectivePermission
+}
+
+type RoleGraphReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// Format dot、mermaid 或者 json，默认 json
+	Format string `json:"format,omitzero" form:"format"`
+	// Root 只展示以这个角色为根的子图
+	Root string `json:"root,omitzero" form:"root"`
+	// Depth 从 Root 开始最多展开的层数，0 表示不限制
+	Depth       int  `json:"depth,omitzero" form:"depth"`
+	Permissions bool `json:"permissions,omitzero" form:"permissions"`
+	UserCounts  bool `json:"userCounts,omitzero" form:"userCounts"`
+}
+
+type RoleGraph struct {
+	Nodes []RoleGraphNode         `json:"nodes"`
+	Edges []BizModelRoleInclusion `json:"edges"`
+}
+
+type RoleGraphNode struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitzero"`
+	Permissions []string `json:"permissions,omitzero"`
+	UserCount   int      `json:"userCount,omitzero"`
+}
+
+type SoDConstraintReq struct {
+	BizID       int64  `json:"bizId,omitzero"`
+	Name        string `json:"name,omitzero"`
+	Description string `json:"description,omitzero"`
+	// Kind role 或者 permission
+	Kind string `json:"kind,omitzero"`
+	// Members 互斥的角色名，或者 "资源类型:资源标识#动作" 格式的权限
+	Members []string `json:"members,omitzero"`
+}
+
+func (r SoDConstraintReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type SoDConstraintIDReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	ID    int64 `json:"id,omitzero" form:"id"`
+}
+
+func (r SoDConstraintIDReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.ID}
+}
+
+type SoDListReq struct {
+	ListReq
+}
+
+type SoDConstraint struct {
+	ID          int64    `json:"id"`
+	BizID       int64    `json:"bizId"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitzero"`
+	Kind        string   `json:"kind"`
+	Members     []string `json:"members"`
+	CreatorUID  int64    `json:"creatorUid"`
+	Ctime       int64    `json:"ctime"`
+	Utime       int64    `json:"utime"`
+}
+
+type SoDViolation struct {
+	ConstraintID int64    `json:"constraintId"`
+	Name         string   `json:"name"`
+	UserID       int64    `json:"userId"`
+	Held         []string `json:"held"`
+}
+
+type SimulateReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	// At 计算哪个时刻的权限，毫秒时间戳，默认当前时间
+	At        int64           `json:"at,omitzero"`
+	Mutations []ModelMutation `json:"mutations,omitzero"`
+	Offset    int             `json:"offset,omitzero"`
+	Limit     int             `json:"limit,omitzero"`
+}
+
+type ModelMutation struct {
+	// Op grant_role_permission、revoke_role_permission、add_role_inclusion、
+	// remove_role_inclusion、grant_user_role 或者 revoke_user_role
+	Op string `json:"op"`
+	// Role 角色权限和用户角色的角色，包含关系中包含其他角色的角色
+	Role         string `json:"role,omitzero"`
+	IncludedRole string `json:"includedRole,omitzero"`
+	UserID       int64  `json:"userId,omitzero"`
+	ResourceType string `json:"resourceType,omitzero"`
+	ResourceKey  string `json:"resourceKey,omitzero"`
+	Action       string `json:"action,omitzero"`
+	StartTime    int64  `json:"startTime,omitzero"`
+	EndTime      int64  `json:"endTime,omitzero"`
+}
+
+type Simulation struct {
+	At    int64        `json:"at"`
+	Users []UserImpact `json:"users"`
+	// Total 受影响用户的总数，Users 只包含当前页
+	Total int `json:"total"`
+	// Gained 和 Lost 是全部受影响用户得到和失去的权限数量
+	Gained int `json:"gained"`
+	Lost   int `json:"lost"`
+}
+
+type UserImpact struct {
+	UserID    int64            `json:"userId"`
+	Resources []ResourceImpact `json:"resources"`
+}
+
+type ResourceImpact struct {
+	ResourceType string   `json:"resourceType"`
+	ResourceKey  string   `json:"resourceKey"`
+	Gained       []string `json:"gained,omitzero"`
+	Lost         []string `json:"lost,omitzero"`
+}
+
+type ExplainReq struct {
+	BizID        int64  `json:"bizId,omitzero"`
+	UID          int64  `json:"uid,omitzero"`
+	ResourceType string `json:"resourceType,omitzero"`
+	ResourceKey  string `json:"resourceKey,omitzero"`
+	Action       string `json:"action,omitzero"`
+}
+
+type Explanation struct {
+	// Allowed 权限平台 CheckPermission 的结果
+	Allowed bool `json:"allowed"`
+	// ModelAllowed 根据权限模型计算的结果，和 Allowed 不一致时说明权限平台的缓存还没有更新
+	ModelAllowed bool         `json:"modelAllowed"`
+	Reason       string       `json:"reason"`
+	At           int64        `json:"at"`
+	Grants       []GrantTrace `json:"grants"`
+}
+
+type GrantTrace struct {
+	PermissionSource
+	// Status active、not_started、expired 或者 overridden
+	Status string `json:"status"`
+}
+
+type HygieneReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+}
+
+type HygieneReport struct {
+	At       int64            `json:"at"`
+	Findings []HygieneFinding `json:"findings"`
+	// Counts 每种问题的数量
+	Counts map[string]int `json:"counts"`
+}
+
+type HygieneFinding struct {
+	// Key 清理时用来选择问题
+	Key        string `json:"key"`
+	Kind       string `json:"kind"`
+	Ref        string `json:"ref"`
+	Detail     string `json:"detail"`
+	Suggestion string `json:"suggestion"`
+}
+
+type HygieneApplyReq struct {
+	BizID int64 `json:"bizId,omitzero"`
+	// Keys 要清理的问题，来自报告中的 key
+	Keys []string `json:"keys,omitzero"`
+	// DryRun 只计算清理需要的删除，不写入任何数据
+	DryRun bool `json:"dryRun,omitzero"`
+	// Fingerprint 预览返回的计划摘要，清理时必须指定，当前状态在预览之后发生了变化就拒绝清理
+	Fingerprint string `json:"fingerprint,omitzero"`
+}
+
+func (r HygieneApplyReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type HygieneApplyResult struct {
+	BizModelPlan
+	DryRun bool `json:"dryRun"`
+}
+
+type RoleMiningReq struct {
+	BizID int64 `json:"bizId,omitzero" form:"bizId"`
+	// Threshold Jaccard 相似度下限，默认 0.6
+	Threshold      float64 `json:"threshold,omitzero" form:"threshold"`
+	MinMembers     int     `json:"minMembers,omitzero" form:"minMembers"`
+	MinPermissions int     `json:"minPermissions,omitzero" form:"minPermissions"`
+}
+
+type RoleSuggestion struct {
+	// Key 接受候选角色时使用
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	Members     []int64  `json:"members"`
+	// Saved 接受之后减少的授权数量
+	Saved int `json:"saved"`
+}
+
+type RoleMiningAcceptReq struct {
+	RoleMiningReq
+	Key string `json:"key,omitzero"`
+	// Name 和 Description 是新角色的名称和描述，名称默认使用候选角色的名称
+	Name        string `json:"name,omitzero"`
+	Description string `json:"description,omitzero"`
+}
+
+func (r RoleMiningAcceptReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID}
+}
+
+type RoleMiningAcceptResult struct {
+	RoleID int64 `json:"roleId,omitzero"`
+	// Granted 新增的角色权限和用户角色数量，Revoked 回收的直接授权数量
+	Granted int `json:"granted"`
+	Revoked int `json:"revoked"`
+}
+
+// CompareReq 对比两个用户或者两个角色，指定 leftUser 和 rightUser 或者 leftRole 和 rightRole
+type CompareReq struct {
+	BizID     int64  `json:"bizId,omitzero" form:"bizId"`
+	LeftUser  int64  `json:"leftUser,omitzero" form:"leftUser"`
+	RightUser int64  `json:"rightUser,omitzero" form:"rightUser"`
+	LeftRole  string `json:"leftRole,omitzero" form:"leftRole"`
+	RightRole string `json:"rightRole,omitzero" form:"rightRole"`
+	// At 计算哪个时刻的用户权限，毫秒时间戳，默认当前时间
+	At int64 `json:"at,omitzero" form:"at"`
+}
+
+type Comparison struct {
+	At        int64                 `json:"at"`
+	OnlyLeft  []EffectivePermission `json:"onlyLeft"`
+	OnlyRight []EffectivePermission `json:"onlyRight"`
+	// Both 两边都拥有的权限，来源取左边的
+	Both []EffectivePermission `json:"both"`
+}
+
+type CopyAccessReq struct {
+	BizID    int64 `json:"bizId,omitzero"`
+	FromUser int64 `json:"fromUser,omitzero"`
+	ToUser   int64 `json:"toUser,omitzero"`
+	// DryRun 只返回需要新增的角色，不写入任何数据
+	DryRun bool `json:"dryRun,omitzero"`
+	// Reason 角色需要审批时记录在变更请求中
+	Reason string `json:"reason,omitzero"`
+}
+
+func (r CopyAccessReq) auditTarget() auditTarget {
+	return auditTarget{BizID: r.BizID, TargetID: r.FromUser, TargetUserID: r.ToUser}
+}
+
+type CopyAccessResult struct {
+	DryRun bool             `json:"dryRun"`
+	Roles  []CopiedUserRole `json:"roles"`
+	// Skipped 不会复制的角色，例如紧急授权获得的角色
+	Skipped []SkippedUserRole `json:"skipped"`
+}
+
+type SkippedUserRole struct {
+	Role   Role   `json:"role"`
+	Reason string `json:"reason"`
+}
+
+type CopiedUserRole struct {
+	Role      Role  `json:"role"`
+	StartTime int64 `json:"startTime,omitzero"`
+	EndTime   int64 `json:"endTime,omitzero"`
+	// NeedApproval 角色命中审批策略，执行时创建变更请求代替直接授权
+	NeedApproval bool `json:"needApproval"`
+	// ChangeRequestID 执行时创建的变更请求
+	ChangeRequestID int64 `json:"changeRequestId,omitzero"`
+	// Granted 执行时已经直接授予
+	Granted bool `json:"granted,omitzero"`
+}
@@ -0,0 +1,16 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/gotomicro/ego/core/econf"
+)
+
+func InitApprovalService(repo repository.ApprovalRepository) *service.ApprovalService {
+	var cfg service.ApprovalConfig
+	err := econf.UnmarshalKey("approval", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewApprovalService(repo, cfg)
+}
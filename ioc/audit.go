@@ -0,0 +1,25 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// sampleAuditCheckpointKey 早期配置文件里的示例密钥，已经公开，不能用于签名
+const sampleAuditCheckpointKey = "permission-platform-admin-audit"
+
+func InitAuditService(repo repository.AuditRepository) *service.AuditService {
+	var cfg service.AuditConfig
+	err := econf.UnmarshalKey("audit", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	// 任何人都知道的密钥签出来的检查点可以被伪造，没有可用的密钥时不生成检查点，只保留哈希链
+	if cfg.CheckpointKey == "" || cfg.CheckpointKey == sampleAuditCheckpointKey {
+		elog.DefaultLogger.Warn("没有配置 audit.checkpointKey，或者使用的是示例密钥，不生成审计签名检查点")
+		cfg.CheckpointKey, cfg.CheckpointInterval = "", 0
+	}
+	return service.NewAuditService(repo, cfg)
+}
@@ -0,0 +1,27 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
+	"github.com/gotomicro/ego/core/econf"
+)
+
+func InitAdminClient(rbacSvc permissionv1.RBACServiceClient) *service.AdminClient {
+	return service.NewAdminClient(rbacSvc, econf.GetString("adminToken"))
+}
+
+func InitBreakGlassService(
+	repo repository.BreakGlassRepository,
+	client *service.AdminClient,
+	changes *service.ChangePublisher,
+	audits *service.AuditService,
+	notifier service.Notifier,
+) *service.BreakGlassService {
+	var cfg service.BreakGlassConfig
+	err := econf.UnmarshalKey("breakGlass", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewBreakGlassService(repo, client, changes, audits, notifier, cfg)
+}
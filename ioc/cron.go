@@ -0,0 +1,52 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/pkg/redislock"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"gitee.com/flycash/permission-platform-admin/internal/web"
+	"github.com/gotomicro/ego/task/ecron"
+	"github.com/redis/go-redis/v9"
+)
+
+func InitCrons(
+	client redis.Cmdable,
+	approvals *service.ApprovalService,
+	approval *web.ApprovalHandler,
+	breakGlass *service.BreakGlassService,
+	reviews *service.ReviewService,
+	expiry *service.ExpiryService,
+	schedule *web.ScheduleHandler,
+	snapshot *web.SnapshotHandler,
+) []ecron.Ecron {
+	// 多实例部署时只有持有锁的实例执行任务，避免重复回收、重复通知和重复记录审计日志
+	return []ecron.Ecron{
+		ecron.Load("cron.approvalExpire").Build(
+			ecron.WithJob(approvals.ExpireStale),
+			ecron.WithLock(redislock.New(client, "cron:lock:approvalExpire")),
+		),
+		ecron.Load("cron.approvalExecute").Build(
+			ecron.WithJob(approval.ExecuteApproved),
+			ecron.WithLock(redislock.New(client, "cron:lock:approvalExecute")),
+		),
+		ecron.Load("cron.breakGlassRevoke").Build(
+			ecron.WithJob(breakGlass.RevokeExpired),
+			ecron.WithLock(redislock.New(client, "cron:lock:breakGlassRevoke")),
+		),
+		ecron.Load("cron.reviewDeadline").Build(
+			ecron.WithJob(reviews.ProcessDeadlines),
+			ecron.WithLock(redislock.New(client, "cron:lock:reviewDeadline")),
+		),
+		ecron.Load("cron.expirySweep").Build(
+			ecron.WithJob(expiry.Sweep),
+			ecron.WithLock(redislock.New(client, "cron:lock:expirySweep")),
+		),
+		ecron.Load("cron.scheduledChange").Build(
+			ecron.WithJob(schedule.ExecuteDue),
+			ecron.WithLock(redislock.New(client, "cron:lock:scheduledChange")),
+		),
+		ecron.Load("cron.snapshot").Build(
+			ecron.WithJob(snapshot.SnapshotAll),
+			ecron.WithLock(redislock.New(client, "cron:lock:snapshot")),
+		),
+	}
+}
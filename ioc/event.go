@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	"gitee.com/flycash/permission-platform-admin/internal/web"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/redis/go-redis/v9"
+)
+
+func InitChangeStream(cmd redis.Cmdable) *permission.Stream {
+	type Config struct {
+		// ReplayBufferSize 每个业务保留的变更事件数量，决定断线续传能回溯多远
+		ReplayBufferSize int64 `yaml:"replayBufferSize"`
+	}
+	var cfg Config
+	err := econf.UnmarshalKey("events", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return permission.NewStream(cmd, cfg.ReplayBufferSize)
+}
+
+func InitEventHandler(handler *web.BaseHandler) *web.EventHandler {
+	var cfg web.EventStreamConfig
+	err := econf.UnmarshalKey("events", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return web.NewEventHandler(handler, cfg)
+}
@@ -0,0 +1,22 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/gotomicro/ego/core/econf"
+)
+
+func InitExpiryService(
+	repo repository.ExpiryRepository,
+	client *service.AdminClient,
+	changes *service.ChangePublisher,
+	audits *service.AuditService,
+	notifier service.Notifier,
+) *service.ExpiryService {
+	var cfg service.ExpiryConfig
+	err := econf.UnmarshalKey("expiry", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewExpiryService(repo, client, changes, audits, notifier, cfg)
+}
@@ -16,6 +16,23 @@ func initGinServer(
 	account *web.AccountHandler,
 	business *web.BusinessHandler,
 	systemAdmin *web.SystemAdminHandler,
+	events *web.EventHandler,
+	audit *web.AuditHandler,
+	export *web.ExportHandler,
+	approval *web.ApprovalHandler,
+	access *web.AccessHandler,
+	breakGlass *web.BreakGlassHandler,
+	review *web.ReviewHandler,
+	expiry *web.ExpiryHandler,
+	schedule *web.ScheduleHandler,
+	model *web.ModelHandler,
+	bulk *web.BulkHandler,
+	snapshot *web.SnapshotHandler,
+	analysis *web.AnalysisHandler,
+	sod *web.SoDHandler,
+	hygiene *web.HygieneHandler,
+	roleMining *web.RoleMiningHandler,
+	compare *web.CompareHandler,
 ) *egin.Component {
 	session.SetDefaultProvider(sp)
 	res := egin.Load("server.web").Build()
@@ -26,5 +43,22 @@ func initGinServer(
 	account.PrivateRoutes(res.Engine)
 	business.PrivateRoutes(res.Engine)
 	systemAdmin.PrivateRoutes(res.Engine)
+	events.PrivateRoutes(res.Engine)
+	audit.PrivateRoutes(res.Engine)
+	export.PrivateRoutes(res.Engine)
+	approval.PrivateRoutes(res.Engine)
+	access.PrivateRoutes(res.Engine)
+	breakGlass.PrivateRoutes(res.Engine)
+	review.PrivateRoutes(res.Engine)
+	expiry.PrivateRoutes(res.Engine)
+	schedule.PrivateRoutes(res.Engine)
+	model.PrivateRoutes(res.Engine)
+	bulk.PrivateRoutes(res.Engine)
+	snapshot.PrivateRoutes(res.Engine)
+	analysis.PrivateRoutes(res.Engine)
+	sod.PrivateRoutes(res.Engine)
+	hygiene.PrivateRoutes(res.Engine)
+	roleMining.PrivateRoutes(res.Engine)
+	compare.PrivateRoutes(res.Engine)
 	return res
 }
@@ -1,6 +1,8 @@
 package ioc
 
 import (
+	"gitee.com/flycash/permission-platform-admin/internal/event/permission"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
 	"gitee.com/flycash/permission-platform-admin/internal/web"
 	permissionv1 "gitee.com/flycash/permission-platform/api/proto/gen/permission/v1"
 	"github.com/gotomicro/ego/client/egrpc"
@@ -18,6 +20,12 @@ func InitPermissionClient() permissionv1.PermissionServiceClient {
 func InitBaseHandler(
 	rbacSvc permissionv1.RBACServiceClient,
 	permSvc permissionv1.PermissionServiceClient,
+	changes *permission.Stream,
+	publisher *service.ChangePublisher,
+	audits *service.AuditService,
+	approvals *service.ApprovalService,
+	sod *service.SoDService,
 ) *web.BaseHandler {
-	return web.NewBaseHandler(rbacSvc, permSvc, econf.GetString("adminToken"))
+	return web.NewBaseHandler(rbacSvc, permSvc, econf.GetString("adminToken"), changes, publisher, audits, approvals, sod,
+		econf.GetInt("roleInclusion.maxDepth"), econf.GetInt("pagination.maxLimit"))
 }
@@ -0,0 +1,25 @@
+package ioc
+
+import (
+	"time"
+
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/gotomicro/ego/core/econf"
+)
+
+func InitNotifier() service.Notifier {
+	type Config struct {
+		// Webhook 为空时只记录日志
+		Webhook string        `yaml:"webhook"`
+		Timeout time.Duration `yaml:"timeout"`
+	}
+	var cfg Config
+	err := econf.UnmarshalKey("notify", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Webhook == "" {
+		return service.NewLogNotifier()
+	}
+	return service.NewWebhookNotifier(cfg.Webhook, cfg.Timeout)
+}
@@ -0,0 +1,20 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/gotomicro/ego/core/econf"
+)
+
+func InitScheduleService(
+	repo repository.ScheduledChangeRepository,
+	audits *service.AuditService,
+	notifier service.Notifier,
+) *service.ScheduleService {
+	var cfg service.ScheduleConfig
+	err := econf.UnmarshalKey("schedule", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewScheduleService(repo, audits, notifier, cfg)
+}
@@ -0,0 +1,34 @@
+package ioc
+
+import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/redis/go-redis/v9"
+)
+
+func InitSnapshotConfig() service.SnapshotConfig {
+	var cfg service.SnapshotConfig
+	err := econf.UnmarshalKey("snapshot", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// InitSnapshotRepository 快照的元数据总是保存在 Redis 中，压缩后的模型按照配置保存在 Redis 或者本地磁盘
+func InitSnapshotRepository(client redis.Cmdable, cfg service.SnapshotConfig) repository.SnapshotRepository {
+	var blobs repository.SnapshotBlobStore
+	switch cfg.Storage {
+	case "", "redis":
+		blobs = repository.NewRedisSnapshotBlobStore(client)
+	case "file":
+		if cfg.Dir == "" {
+			panic("snapshot.dir 不能为空")
+		}
+		blobs = repository.NewFileSnapshotBlobStore(cfg.Dir)
+	default:
+		panic("不支持的快照存储: " + cfg.Storage)
+	}
+	return repository.NewRedisSnapshotRepository(client, blobs)
+}
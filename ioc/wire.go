@@ -3,6 +3,8 @@
 package ioc
 
 import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
 	"gitee.com/flycash/permission-platform-admin/internal/web"
 	"github.com/google/wire"
 )
@@ -13,6 +15,35 @@ func InitApp() (*App, error) {
 		InitRedis,
 		InitSession,
 
+		InitChangeStream,
+
+		repository.NewRedisAuditRepository,
+		InitAuditService,
+
+		repository.NewRedisApprovalRepository,
+		InitApprovalService,
+
+		repository.NewRedisAccessPolicyRepository,
+		service.NewAccessService,
+
+		InitAdminClient,
+		service.NewChangePublisher,
+		InitNotifier,
+		repository.NewRedisBreakGlassRepository,
+		InitBreakGlassService,
+		repository.NewRedisReviewRepository,
+		service.NewReviewService,
+		repository.NewRedisExpiryRepository,
+		InitExpiryService,
+		repository.NewRedisScheduledChangeRepository,
+		InitScheduleService,
+		repository.NewRedisSoDConstraintRepository,
+		service.NewSoDService,
+		InitSnapshotConfig,
+		InitSnapshotRepository,
+		service.NewSnapshotService,
+		service.NewSystemTableService,
+
 		InitRBACClient,
 		InitPermissionClient,
 		InitBaseHandler,
@@ -26,6 +57,60 @@ func InitApp() (*App, error) {
 		// 权限平台系统管理员使用的管理后台API
 		web.NewSystemAdminHandler,
 
+		// 权限变更事件推送
+		InitEventHandler,
+
+		// 审计日志
+		web.NewAuditHandler,
+
+		// 授权关系和审计日志导出
+		web.NewExportHandler,
+
+		// 授权审批
+		web.NewApprovalHandler,
+
+		// 自助申请限时角色
+		web.NewAccessHandler,
+
+		// 紧急授权
+		web.NewBreakGlassHandler,
+
+		// 周期性权限审查
+		web.NewReviewHandler,
+
+		// 限时授权到期提醒和清理
+		web.NewExpiryHandler,
+
+		// 定时执行的授权变更
+		web.NewScheduleHandler,
+
+		// 业务权限模型的导出和导入
+		web.NewModelHandler,
+
+		// 上传文件批量授权
+		web.NewBulkHandler,
+
+		// 业务权限模型的快照和回滚
+		web.NewSnapshotHandler,
+
+		// 有效权限和授权关系分析
+		web.NewAnalysisHandler,
+
+		// 职责分离约束
+		web.NewSoDHandler,
+
+		// 权限模型清理
+		web.NewHygieneHandler,
+
+		// 角色挖掘
+		web.NewRoleMiningHandler,
+
+		// 用户和角色的权限对比
+		web.NewCompareHandler,
+
+		// 定时任务
+		InitCrons,
+
 		initGinServer,
 
 		wire.Struct(new(App), "*"),
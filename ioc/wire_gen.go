@@ -7,6 +7,8 @@
 package ioc
 
 import (
+	"gitee.com/flycash/permission-platform-admin/internal/repository"
+	"gitee.com/flycash/permission-platform-admin/internal/service"
 	"gitee.com/flycash/permission-platform-admin/internal/web"
 )
 
@@ -15,15 +17,58 @@ import (
 func InitApp() (*App, error) {
 	cmdable := InitRedis()
 	provider := InitSession(cmdable)
+	stream := InitChangeStream(cmdable)
 	rbacServiceClient := InitRBACClient()
 	permissionServiceClient := InitPermissionClient()
-	baseHandler := InitBaseHandler(rbacServiceClient, permissionServiceClient)
+	auditRepository := repository.NewRedisAuditRepository(cmdable)
+	auditService := InitAuditService(auditRepository)
+	approvalRepository := repository.NewRedisApprovalRepository(cmdable)
+	approvalService := InitApprovalService(approvalRepository)
+	soDConstraintRepository := repository.NewRedisSoDConstraintRepository(cmdable)
+	soDService := service.NewSoDService(soDConstraintRepository)
+	changePublisher := service.NewChangePublisher(stream)
+	baseHandler := InitBaseHandler(rbacServiceClient, permissionServiceClient, stream, changePublisher, auditService, approvalService, soDService)
 	accountHandler := web.NewAccountHandler(baseHandler)
 	businessHandler := web.NewBusinessHandler(baseHandler)
-	systemAdminHandler := web.NewSystemAdminHandler(baseHandler)
-	component := initGinServer(provider, accountHandler, businessHandler, systemAdminHandler)
+	adminClient := InitAdminClient(rbacServiceClient)
+	systemTableService := service.NewSystemTableService(adminClient)
+	systemAdminHandler := web.NewSystemAdminHandler(baseHandler, systemTableService)
+	eventHandler := InitEventHandler(baseHandler)
+	auditHandler := web.NewAuditHandler(baseHandler)
+	exportHandler := web.NewExportHandler(baseHandler)
+	approvalHandler := web.NewApprovalHandler(baseHandler)
+	accessPolicyRepository := repository.NewRedisAccessPolicyRepository(cmdable)
+	accessService := service.NewAccessService(accessPolicyRepository, approvalService)
+	accessHandler := web.NewAccessHandler(baseHandler, accessService)
+	breakGlassRepository := repository.NewRedisBreakGlassRepository(cmdable)
+	notifier := InitNotifier()
+	breakGlassService := InitBreakGlassService(breakGlassRepository, adminClient, changePublisher, auditService, notifier)
+	breakGlassHandler := web.NewBreakGlassHandler(baseHandler, breakGlassService)
+	reviewRepository := repository.NewRedisReviewRepository(cmdable)
+	reviewService := service.NewReviewService(reviewRepository, accessPolicyRepository, approvalService, adminClient, changePublisher, auditService, notifier)
+	reviewHandler := web.NewReviewHandler(baseHandler, reviewService)
+	expiryRepository := repository.NewRedisExpiryRepository(cmdable)
+	expiryService := InitExpiryService(expiryRepository, adminClient, changePublisher, auditService, notifier)
+	expiryHandler := web.NewExpiryHandler(baseHandler, expiryService)
+	scheduledChangeRepository := repository.NewRedisScheduledChangeRepository(cmdable)
+	scheduleService := InitScheduleService(scheduledChangeRepository, auditService, notifier)
+	scheduleHandler := web.NewScheduleHandler(baseHandler, scheduleService)
+	modelHandler := web.NewModelHandler(baseHandler)
+	bulkHandler := web.NewBulkHandler(baseHandler)
+	snapshotConfig := InitSnapshotConfig()
+	snapshotRepository := InitSnapshotRepository(cmdable, snapshotConfig)
+	snapshotService := service.NewSnapshotService(snapshotRepository, adminClient, auditService, snapshotConfig)
+	snapshotHandler := web.NewSnapshotHandler(baseHandler, snapshotService)
+	analysisHandler := web.NewAnalysisHandler(baseHandler)
+	soDHandler := web.NewSoDHandler(baseHandler, soDService)
+	hygieneHandler := web.NewHygieneHandler(baseHandler)
+	roleMiningHandler := web.NewRoleMiningHandler(baseHandler)
+	compareHandler := web.NewCompareHandler(baseHandler, breakGlassService)
+	component := initGinServer(provider, accountHandler, businessHandler, systemAdminHandler, eventHandler, auditHandler, exportHandler, approvalHandler, accessHandler, breakGlassHandler, reviewHandler, expiryHandler, scheduleHandler, modelHandler, bulkHandler, snapshotHandler, analysisHandler, soDHandler, hygieneHandler, roleMiningHandler, compareHandler)
+	v := InitCrons(cmdable, approvalService, approvalHandler, breakGlassService, reviewService, expiryService, scheduleHandler, snapshotHandler)
 	app := &App{
-		Web: component,
+		Web:   component,
+		Crons: v,
 	}
 	return app, nil
 }
@@ -19,6 +19,7 @@ func main() {
 		// Invoker 在 Ego 里面，应该叫做初始化函数
 		Invoker().
 		Serve(app.Web).
+		Cron(app.Crons...).
 		Run()
 	if err != nil {
 		panic(err)